@@ -3,10 +3,16 @@ package hconf
 import (
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sync"
 
+	"github.com/fsnotify/fsnotify"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/pelletier/go-toml/v2"
+	"go.uber.org/atomic"
 
+	"github.com/drharryhe/has/common/hlogger"
 	"github.com/drharryhe/has/utils/hio"
 	"github.com/drharryhe/has/utils/hruntime"
 )
@@ -21,11 +27,26 @@ type Config struct {
 	Version     string
 	LogOutputs  []string
 	LogFileName string
-	Debug       bool
+	LogLevel    string //最低日志级别，如 info，缺省为debug，修改后热加载生效
+	LogFormat   string //日志格式 text 或 json，缺省为text
+	Debug       bool   //启动时的值，热加载后以IsDebug为准
 
+	debug      atomic.Bool //热加载时由监视配置文件的goroutine修改，每个请求都会读取
 	configures map[string]interface{}
+	sections   map[string]interface{} //配置文件的原始内容，用于判断哪些配置节发生了变化
+	fileValues map[string]interface{} //Load时配置文件中的配置节，Save时恢复被覆盖的配置项
+	overrides  map[string]map[string]interface{}
+	flags      map[string]string
+	lock       sync.Mutex
+	watcher    *fsnotify.Watcher
+
+	localSections  map[string]interface{} //配置文件的内容，Save时恢复被配置来源覆盖的配置项
+	providerValues map[string]interface{} //配置文件之外的配置来源合并后的内容
 }
 
+// ReloadHandler 配置节变化时的回调，name 为配置节名称，section 为该节的新内容
+type ReloadHandler func(name string, section map[string]interface{})
+
 func Version() string {
 	return config.Version
 }
@@ -38,8 +59,16 @@ func LogFileName() string {
 	return config.LogFileName
 }
 
+func LogLevel() string {
+	return config.LogLevel
+}
+
+func LogFormat() string {
+	return config.LogFormat
+}
+
 func IsDebug() bool {
-	return config.Debug
+	return config.debug.Load()
 }
 
 func Init() {
@@ -48,22 +77,49 @@ func Init() {
 		panic("failed to read config file \r\n" + err.Error())
 	}
 
-	config.configures = make(map[string]interface{})
-	err = toml.Unmarshal(bytes, &config.configures)
+	config.localSections, err = parse(bytes)
 	if err != nil {
 		panic("failed to parse config file. \r\n" + err.Error())
 	}
+	config.providerValues, err = readProviders()
+	if err != nil {
+		panic(err.Error())
+	}
+	local, _ := parse(bytes)
+	config.configures = mergeSection(local, config.providerValues)
+	config.sections = mergeSection(config.localSections, config.providerValues)
+	config.fileValues = make(map[string]interface{})
+	config.overrides = make(map[string]map[string]interface{})
+	config.flags = parseFlags()
+
+	//顶层配置项也可以被覆盖，覆盖的值只保存在Config中
+	top := make(map[string]interface{})
+	for k, v := range config.configures {
+		if _, ok := v.(map[string]interface{}); !ok {
+			top[k] = v
+		}
+	}
+	overrides, err := overridesOf("", reflect.TypeOf(&config))
+	if err != nil {
+		panic("failed to parse config overrides. \r\n" + err.Error())
+	}
+	for k, v := range overrides {
+		top[k] = v
+	}
+	config.overrides[""] = overrides
 
-	config.Version, _ = config.configures["Version"].(string)
-	config.LogFileName, _ = config.configures["LogFileName"].(string)
-	config.Debug, _ = config.configures["Debug"].(bool)
-	if config.configures["LogOutputs"] != nil {
-		outputs := config.configures["LogOutputs"].([]interface{})
+	config.Version, _ = top["Version"].(string)
+	config.LogFileName, _ = top["LogFileName"].(string)
+	config.LogLevel, _ = top["LogLevel"].(string)
+	config.LogFormat, _ = top["LogFormat"].(string)
+	config.Debug, _ = top["Debug"].(bool)
+	config.debug.Store(config.Debug)
+	config.LogOutputs = nil
+	if outputs, ok := top["LogOutputs"].([]interface{}); ok {
 		for _, out := range outputs {
 			config.LogOutputs = append(config.LogOutputs, out.(string))
 		}
 	}
-
 }
 
 func Load(conf interface{}) {
@@ -73,6 +129,17 @@ func Load(conf interface{}) {
 		panic(fmt.Sprintf("failed to load conf, config section [%s] not found", name))
 	}
 
+	//只在第一次加载时合并命令行参数和环境变量，之后该配置节已是配置结构体
+	if section, ok := c.(map[string]interface{}); ok {
+		overrides, err := overridesOf(name, reflect.TypeOf(conf))
+		if err != nil {
+			panic(fmt.Sprintf("failed to load conf, %s", err.Error()))
+		}
+		config.fileValues[name] = section
+		config.overrides[name] = overrides
+		c = withOverrides(name, section)
+	}
+
 	bs, _ := jsoniter.Marshal(c)
 
 	err := jsoniter.Unmarshal(bs, conf)
@@ -87,14 +154,141 @@ func Save() {
 	tmp := make(map[string]interface{})
 	bs, _ := jsoniter.Marshal(config.configures)
 	_ = jsoniter.Unmarshal(bs, &tmp)
+	restoreFileValues(tmp)
+	restoreProviderValues(tmp, config.localSections, config.providerValues)
 
 	bs, err := toml.Marshal(tmp)
 	if err != nil {
 		panic("failed to save configures, unable to marshal conf")
 	}
 
+	//自身写入的内容不触发热加载
+	config.lock.Lock()
+	config.localSections, _ = parse(bs)
+	config.sections = mergeSection(config.localSections, config.providerValues)
+	config.lock.Unlock()
+
 	err = ioutil.WriteFile(confFile, bs, 0x666)
 	if err != nil {
 		panic("failed to save configures,failed to write config file")
 	}
 }
+
+// Decode 将配置节内容解析到conf中
+func Decode(section map[string]interface{}, conf interface{}) error {
+	bs, err := jsoniter.Marshal(section)
+	if err != nil {
+		return err
+	}
+	return jsoniter.Unmarshal(bs, conf)
+}
+
+// Watch 监听配置文件和支持变化通知的配置来源，内容变化后逐节比较，对有变化的配置节调用handler
+func Watch(handler ReloadHandler) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	//编辑器保存时可能以替换文件的方式写入，因此监听所在目录
+	if err = watcher.Add(filepath.Dir(confFile)); err != nil {
+		_ = watcher.Close()
+		return err
+	}
+	config.watcher = watcher
+
+	go func() {
+		for {
+			select {
+			case e, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(e.Name) != filepath.Clean(confFile) || e.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				reload(handler)
+			case e, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				hlogger.Warn("config watcher: %s", e.Error())
+			}
+		}
+	}()
+
+	if err = watchProviders(handler); err != nil {
+		Unwatch()
+		return err
+	}
+	return nil
+}
+
+// Unwatch 停止监听配置文件和配置来源
+func Unwatch() {
+	if config.watcher != nil {
+		_ = config.watcher.Close()
+		config.watcher = nil
+	}
+	unwatchProviders()
+}
+
+// reload 重新读取配置文件和所有配置来源，任一读取失败时保持原配置
+func reload(handler ReloadHandler) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	bs, err := hio.ReadFile(confFile)
+	if err != nil {
+		hlogger.Warn("failed to read config file: %s", err.Error())
+		return
+	}
+	local, err := parse(bs)
+	if err != nil {
+		//文件可能正在写入，等待下一次写入事件
+		hlogger.Warn("failed to parse config file: %s", err.Error())
+		return
+	}
+	values, err := readProviders()
+	if err != nil {
+		hlogger.Warn(err.Error())
+		return
+	}
+	sections := mergeSection(local, values)
+
+	config.lock.Lock()
+	old := config.sections
+	config.sections = sections
+	config.localSections = local
+	config.providerValues = values
+	config.lock.Unlock()
+
+	if debug, ok := sections["Debug"].(bool); ok && config.overrides[""]["Debug"] == nil {
+		config.debug.Store(debug)
+	}
+	if level, _ := sections["LogLevel"].(string); level != config.LogLevel && config.overrides[""]["LogLevel"] == nil {
+		if l, err := hlogger.ParseLevel(level); err != nil {
+			hlogger.Warn("failed to reload LogLevel: %s", err.Error())
+		} else {
+			config.LogLevel = level
+			hlogger.SetLevel(l)
+			hlogger.Info("log level changed to %s", level)
+		}
+	}
+
+	for name, v := range sections {
+		section, ok := v.(map[string]interface{})
+		//租户的覆盖项由LoadTenant读取时生效
+		if !ok || name == tenantsSection || reflect.DeepEqual(old[name], v) {
+			continue
+		}
+		handler(name, withOverrides(name, section))
+	}
+}
+
+func parse(bs []byte) (map[string]interface{}, error) {
+	sections := make(map[string]interface{})
+	if err := toml.Unmarshal(bs, &sections); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
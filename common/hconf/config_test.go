@@ -0,0 +1,109 @@
+package hconf
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+type TestSection struct {
+	Port  int
+	Host  string
+	Tags  []string
+	Limit map[string]int
+}
+
+func TestOverrides(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "hconf")
+	defer os.RemoveAll(dir)
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	_ = os.Chdir(dir)
+
+	_ = ioutil.WriteFile(confFile, []byte("Debug = false\n\n[TestSection]\nPort = 1976\nHost = \"localhost\"\n"), 0644)
+	_ = os.Setenv("HAS_DEBUG", "true")
+	_ = os.Setenv("HAS_TESTSECTION_PORT", "8080")
+	_ = os.Setenv("HAS_TESTSECTION_HOST", "env-host")
+	_ = os.Setenv("HAS_TESTSECTION_TAGS", "a, b")
+	defer func() {
+		for _, k := range []string{"HAS_DEBUG", "HAS_TESTSECTION_PORT", "HAS_TESTSECTION_HOST", "HAS_TESTSECTION_TAGS"} {
+			_ = os.Unsetenv(k)
+		}
+	}()
+	args = []string{"-v", "--has.testsection.host=flag-host", `--has.TestSection.Limit={"a":1}`}
+	defer func() { args = os.Args[1:] }()
+
+	Init()
+	if !IsDebug() {
+		t.Error("Debug should be overridden by env")
+	}
+
+	var conf TestSection
+	Load(&conf)
+	if conf.Port != 8080 {
+		t.Errorf("Port = %d, want env value 8080", conf.Port)
+	}
+	if conf.Host != "flag-host" {
+		t.Errorf("Host = %s, want flag value flag-host", conf.Host)
+	}
+	if len(conf.Tags) != 2 || conf.Tags[1] != "b" {
+		t.Errorf("Tags = %v, want [a b]", conf.Tags)
+	}
+	if conf.Limit["a"] != 1 {
+		t.Errorf("Limit = %v, want map[a:1]", conf.Limit)
+	}
+
+	//覆盖的值不写入配置文件，程序修改的值照常保存
+	conf.Port = 9090
+	Save()
+	bs, _ := ioutil.ReadFile(confFile)
+	s := string(bs)
+	if strings.Contains(s, "flag-host") || strings.Contains(s, "Tags") || !strings.Contains(s, "localhost") {
+		t.Errorf("overrides saved to file:\n%s", s)
+	}
+	if !strings.Contains(s, "9090") {
+		t.Errorf("changed value not saved:\n%s", s)
+	}
+}
+
+func TestLoadTenant(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "hconf")
+	defer os.RemoveAll(dir)
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	_ = os.Chdir(dir)
+
+	_ = ioutil.WriteFile(confFile, []byte(`
+[TestSection]
+Port = 1976
+Host = "localhost"
+Limit = {a = 1, b = 2}
+
+[Tenants.acme.TestSection]
+Host = "acme.example.com"
+Limit = {b = 20}
+`), 0644)
+	args = nil
+	defer func() { args = os.Args[1:] }()
+	Init()
+
+	var conf TestSection
+	if err := LoadTenant("acme", &conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Port != 1976 || conf.Host != "acme.example.com" || conf.Limit["a"] != 1 || conf.Limit["b"] != 20 {
+		t.Errorf("acme conf = %+v", conf)
+	}
+
+	conf = TestSection{}
+	if err := LoadTenant("other", &conf); err != nil || conf.Host != "localhost" || conf.Limit["b"] != 2 {
+		t.Errorf("default conf = %+v, %v", conf, err)
+	}
+	if v, _ := TenantValue("acme", "TestSection", "Port"); v != int64(1976) {
+		t.Errorf("TenantValue = %v", v)
+	}
+	if ts := Tenants(); len(ts) != 1 || ts[0] != "acme" {
+		t.Errorf("Tenants = %v", ts)
+	}
+}
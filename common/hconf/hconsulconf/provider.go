@@ -0,0 +1,96 @@
+package hconsulconf
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+
+	"github.com/drharryhe/has/common/hlogger"
+)
+
+const (
+	defaultWaitTime   = 5 * time.Minute
+	defaultRetryDelay = 5 * time.Second
+)
+
+// Provider 从Consul KV的一个key读取TOML格式的配置，以阻塞查询监听变化，实现hconf.IWatchableProvider。
+// 多个实例共用同一个key即可集中管理配置，按实例区分的配置可以使用不同的key叠加
+type Provider struct {
+	key    string
+	kv     *api.KV
+	index  uint64
+	cancel context.CancelFunc
+}
+
+// New conf为空时使用api.DefaultConfig()，即从CONSUL_HTTP_ADDR、CONSUL_HTTP_TOKEN等环境变量读取
+func New(key string, conf *api.Config) (*Provider, error) {
+	if conf == nil {
+		conf = api.DefaultConfig()
+	}
+	client, err := api.NewClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{key: key, kv: client.KV()}, nil
+}
+
+func (this *Provider) Name() string {
+	return "consul:" + this.key
+}
+
+func (this *Provider) Read() ([]byte, error) {
+	pair, meta, err := this.kv.Get(this.key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if pair == nil {
+		return nil, fmt.Errorf("key %s not found", this.key)
+	}
+	this.index = meta.LastIndex
+	return pair.Value, nil
+}
+
+// Watch key的修改索引变化时调用changed，请求失败时间隔5秒重试
+func (this *Provider) Watch(changed func()) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	this.cancel = cancel
+
+	go func() {
+		index := this.index
+		for {
+			opts := (&api.QueryOptions{WaitIndex: index, WaitTime: defaultWaitTime}).WithContext(ctx)
+			_, meta, err := this.kv.Get(this.key, opts)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				hlogger.Warn("failed to watch consul key %s: %s", this.key, err.Error())
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(defaultRetryDelay):
+				}
+				continue
+			}
+
+			//索引变小时(如Consul重建)重新开始阻塞查询
+			if meta.LastIndex < index {
+				index = 0
+				continue
+			}
+			if meta.LastIndex != index {
+				index = meta.LastIndex
+				changed()
+			}
+		}
+	}()
+	return nil
+}
+
+func (this *Provider) Close() {
+	if this.cancel != nil {
+		this.cancel()
+	}
+}
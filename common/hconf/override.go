@@ -0,0 +1,187 @@
+package hconf
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// 配置来源的优先级：命令行参数 > 环境变量 > 配置文件 > 代码中的缺省值。
+// 环境变量名为 HAS_<配置节>_<配置项>，如 HAS_WEBCONNECTOR_PORT；顶层配置项为 HAS_<配置项>，如 HAS_DEBUG。
+// 命令行参数为 --has.<配置节>.<配置项>=<值>，如 --has.WebConnector.Port=8080，名称不区分大小写。
+// 数组可以用逗号分隔，map和结构体使用JSON。覆盖的值不会被Save写入配置文件
+const (
+	envPrefix  = "HAS_"
+	flagPrefix = "has."
+)
+
+// args 命令行参数，测试时可以替换
+var args = os.Args[1:]
+
+func envName(section string, field string) string {
+	if section == "" {
+		return envPrefix + strings.ToUpper(field)
+	}
+	return envPrefix + strings.ToUpper(section) + "_" + strings.ToUpper(field)
+}
+
+// parseFlags 解析 --has.xxx=yyy 形式的参数，key为小写的 section.field，其他参数忽略
+func parseFlags() map[string]string {
+	flags := make(map[string]string)
+	for _, arg := range args {
+		arg = strings.TrimLeft(arg, "-")
+		if !strings.HasPrefix(strings.ToLower(arg), flagPrefix) {
+			continue
+		}
+		kv := strings.SplitN(arg[len(flagPrefix):], "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		flags[strings.ToLower(kv[0])] = kv[1]
+	}
+	return flags
+}
+
+// lookupOverride 依次查找命令行参数和环境变量
+func lookupOverride(section string, field string) (string, bool) {
+	key := strings.ToLower(field)
+	if section != "" {
+		key = strings.ToLower(section) + "." + key
+	}
+	if v, ok := config.flags[key]; ok {
+		return v, true
+	}
+	return os.LookupEnv(envName(section, field))
+}
+
+// overridesOf 返回配置节中被命令行参数或环境变量覆盖的配置项，值已转换为与配置文件解析结果一致的类型
+func overridesOf(section string, typ reflect.Type) (map[string]interface{}, error) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	fields := make(map[string]reflect.Type)
+	structFields(typ, fields)
+
+	var overrides map[string]interface{}
+	for name, t := range fields {
+		s, ok := lookupOverride(section, name)
+		if !ok {
+			continue
+		}
+		v, err := convertValue(s, t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q of %s.%s: %s", s, section, name, err.Error())
+		}
+		if overrides == nil {
+			overrides = make(map[string]interface{})
+		}
+		overrides[name] = normalize(v)
+	}
+	return overrides, nil
+}
+
+// structFields 与JSON解析一致，嵌入结构体的字段视为外层结构体的字段
+func structFields(typ reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			structFields(f.Type, fields)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		fields[f.Name] = f.Type
+	}
+}
+
+func convertValue(s string, typ reflect.Type) (interface{}, error) {
+	switch typ.Kind() {
+	case reflect.String:
+		return s, nil
+	case reflect.Bool:
+		return strconv.ParseBool(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(s, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(s, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(s, 64)
+	case reflect.Slice:
+		elem := typ.Elem().Kind()
+		if !strings.HasPrefix(strings.TrimSpace(s), "[") && elem != reflect.Struct && elem != reflect.Map && elem != reflect.Slice {
+			var items []interface{}
+			for _, item := range strings.Split(s, ",") {
+				v, err := convertValue(strings.TrimSpace(item), typ.Elem())
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, v)
+			}
+			return items, nil
+		}
+	}
+
+	var v interface{}
+	if err := jsoniter.UnmarshalFromString(s, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// normalize 转换为JSON解析的结果，便于与Save时的配置内容比较
+func normalize(v interface{}) interface{} {
+	bs, err := jsoniter.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var ret interface{}
+	_ = jsoniter.Unmarshal(bs, &ret)
+	return ret
+}
+
+// withOverrides 返回合并了覆盖值的配置节副本
+func withOverrides(name string, section map[string]interface{}) map[string]interface{} {
+	overrides := config.overrides[name]
+	if len(overrides) == 0 {
+		return section
+	}
+
+	merged := make(map[string]interface{}, len(section)+len(overrides))
+	for k, v := range section {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
+
+// restoreFileValues 保存前将仍为覆盖值的配置项恢复为配置文件中的值，程序修改过的配置项照常保存
+func restoreFileValues(sections map[string]interface{}) {
+	for name, overrides := range config.overrides {
+		section, ok := sections[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		file, _ := config.fileValues[name].(map[string]interface{})
+		for k, v := range overrides {
+			if !reflect.DeepEqual(section[k], v) {
+				continue
+			}
+			if fv, ok := file[k]; ok {
+				section[k] = fv
+			} else {
+				delete(section, k)
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package hconf
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/drharryhe/has/utils/hio"
+)
+
+// IProvider 配置来源，Read返回TOML格式的配置内容。配置文件是第一个来源，
+// 之后的来源按顺序覆盖之前的内容，配置节按字段合并，如远程配置覆盖本地配置、密钥来源覆盖远程配置。
+// 来源提供的配置项不会被Save写入配置文件
+type IProvider interface {
+	Name() string
+	Read() ([]byte, error)
+}
+
+// IWatchableProvider 支持监听变化的配置来源，内容变化时调用changed，由Watch触发热加载
+type IWatchableProvider interface {
+	IProvider
+	Watch(changed func()) error
+	Close()
+}
+
+var (
+	providers   []IProvider
+	reloadLock  sync.Mutex
+	watchedLock sync.Mutex
+	watched     []IWatchableProvider
+)
+
+// SetProviders 设置配置文件之外的配置来源，在Init之前调用
+func SetProviders(ps ...IProvider) {
+	providers = ps
+}
+
+// readProviders 依次读取配置来源并合并，返回合并后的内容
+func readProviders() (map[string]interface{}, error) {
+	var layer map[string]interface{}
+	for _, p := range providers {
+		bs, err := p.Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config provider %s: %s", p.Name(), err.Error())
+		}
+		sections, err := parse(bs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse config provider %s: %s", p.Name(), err.Error())
+		}
+		layer = mergeSection(layer, sections)
+	}
+	return layer, nil
+}
+
+// watchProviders 监听支持变化通知的配置来源
+func watchProviders(handler ReloadHandler) error {
+	watchedLock.Lock()
+	defer watchedLock.Unlock()
+
+	for _, p := range providers {
+		w, ok := p.(IWatchableProvider)
+		if !ok {
+			continue
+		}
+		if err := w.Watch(func() { reload(handler) }); err != nil {
+			return fmt.Errorf("failed to watch config provider %s: %s", p.Name(), err.Error())
+		}
+		watched = append(watched, w)
+	}
+	return nil
+}
+
+func unwatchProviders() {
+	watchedLock.Lock()
+	defer watchedLock.Unlock()
+
+	for _, w := range watched {
+		w.Close()
+	}
+	watched = nil
+}
+
+// restoreProviderValues 保存前将仍为配置来源提供的配置项恢复为配置文件中的值，文件中没有时删除。
+// 配置节和map按字段比较
+func restoreProviderValues(dst map[string]interface{}, local map[string]interface{}, values map[string]interface{}) {
+	for k, pv := range values {
+		sub, ok1 := pv.(map[string]interface{})
+		d, ok2 := dst[k].(map[string]interface{})
+		if ok1 && ok2 {
+			l, _ := local[k].(map[string]interface{})
+			restoreProviderValues(d, l, sub)
+			if len(d) == 0 && l == nil {
+				delete(dst, k)
+			}
+			continue
+		}
+		if reflect.DeepEqual(dst[k], normalize(pv)) {
+			restoreValue(dst, local, k)
+		}
+	}
+}
+
+func restoreValue(dst map[string]interface{}, local map[string]interface{}, key string) {
+	if lv, ok := local[key]; ok {
+		dst[key] = lv
+	} else {
+		delete(dst, key)
+	}
+}
+
+// FileProvider 从另一个TOML文件读取配置，如挂载的密钥文件，文件不存在时返回错误
+type FileProvider struct {
+	path string
+}
+
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{path: path}
+}
+
+func (this *FileProvider) Name() string {
+	return "file:" + this.path
+}
+
+func (this *FileProvider) Read() ([]byte, error) {
+	return hio.ReadFile(this.path)
+}
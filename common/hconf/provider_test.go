@@ -0,0 +1,99 @@
+package hconf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memProvider struct {
+	lock    sync.Mutex
+	content string
+	changed func()
+}
+
+func (this *memProvider) Name() string {
+	return "mem"
+}
+
+func (this *memProvider) Read() ([]byte, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return []byte(this.content), nil
+}
+
+func (this *memProvider) Watch(changed func()) error {
+	this.changed = changed
+	return nil
+}
+
+func (this *memProvider) Close() {}
+
+func (this *memProvider) set(content string) {
+	this.lock.Lock()
+	this.content = content
+	this.lock.Unlock()
+	this.changed()
+}
+
+func TestProviders(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "hconf")
+	defer os.RemoveAll(dir)
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	_ = os.Chdir(dir)
+
+	_ = ioutil.WriteFile(confFile, []byte("Debug = false\n\n[TestSection]\nPort = 1976\nHost = \"localhost\"\nLimit = {a = 1}\n"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "secrets.toml"), []byte("[TestSection.Limit]\nb = 2\n"), 0644)
+	remote := &memProvider{content: "Debug = true\n\n[TestSection]\nHost = \"remote-host\"\nTags = [\"r\"]\n"}
+	SetProviders(remote, NewFileProvider(filepath.Join(dir, "secrets.toml")))
+	defer SetProviders()
+	args = nil
+	defer func() { args = os.Args[1:] }()
+
+	Init()
+	if !IsDebug() {
+		t.Error("Debug should be overridden by provider")
+	}
+	var conf TestSection
+	Load(&conf)
+	if conf.Port != 1976 || conf.Host != "remote-host" || len(conf.Tags) != 1 || conf.Limit["a"] != 1 || conf.Limit["b"] != 2 {
+		t.Fatalf("conf = %+v", conf)
+	}
+
+	//配置来源提供的值不写入配置文件
+	conf.Port = 9090
+	Save()
+	bs, _ := ioutil.ReadFile(confFile)
+	s := string(bs)
+	if strings.Contains(s, "remote-host") || strings.Contains(s, "Tags") || strings.Contains(s, "b = 2") || !strings.Contains(s, "localhost") || !strings.Contains(s, "9090") {
+		t.Fatalf("config file:\n%s", s)
+	}
+
+	reloaded := make(chan map[string]interface{}, 1)
+	if err := Watch(func(name string, section map[string]interface{}) {
+		if name == "TestSection" {
+			reloaded <- section
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer Unwatch()
+
+	remote.set("[TestSection]\nHost = \"new-host\"\n")
+	select {
+	case section := <-reloaded:
+		var c TestSection
+		_ = Decode(section, &c)
+		if c.Host != "new-host" || c.Port != 9090 || c.Limit["b"] != 2 {
+			t.Errorf("reloaded conf = %+v", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("provider change not reloaded")
+	}
+}
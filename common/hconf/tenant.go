@@ -0,0 +1,80 @@
+package hconf
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/drharryhe/has/utils/hruntime"
+)
+
+const (
+	tenantsSection = "Tenants" //租户的覆盖项，如 [Tenants.acme.WebConnector]
+)
+
+// LoadTenant 将conf的配置节与租户的覆盖项合并后解析到conf中，租户没有覆盖项时与配置节相同。
+// 覆盖项在配置文件的 [Tenants.租户.配置节] 中，对象按字段合并，其他值直接替换。配置文件修改后立即生效
+func LoadTenant(tenant string, conf interface{}) error {
+	name := hruntime.GetObjectName(conf)
+
+	config.lock.Lock()
+	base, ok := config.sections[name].(map[string]interface{})
+	overlay := tenantOverlay(tenant, name)
+	config.lock.Unlock()
+	if !ok && overlay == nil {
+		return fmt.Errorf("config section [%s] not found", name)
+	}
+	return Decode(mergeSection(withOverrides(name, base), overlay), conf)
+}
+
+// TenantValue 租户的配置项，租户没有覆盖该项时返回配置节中的值
+func TenantValue(tenant string, section string, key string) (interface{}, bool) {
+	config.lock.Lock()
+	defer config.lock.Unlock()
+
+	if v, ok := tenantOverlay(tenant, section)[key]; ok {
+		return v, true
+	}
+	base, _ := config.sections[section].(map[string]interface{})
+	v, ok := withOverrides(section, base)[key]
+	return v, ok
+}
+
+// Tenants 配置了覆盖项的租户，按名称排序
+func Tenants() []string {
+	config.lock.Lock()
+	defer config.lock.Unlock()
+
+	tenants, _ := config.sections[tenantsSection].(map[string]interface{})
+	ret := make([]string, 0, len(tenants))
+	for t := range tenants {
+		ret = append(ret, t)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// tenantOverlay 需持有config.lock
+func tenantOverlay(tenant string, section string) map[string]interface{} {
+	tenants, _ := config.sections[tenantsSection].(map[string]interface{})
+	sections, _ := tenants[tenant].(map[string]interface{})
+	overlay, _ := sections[section].(map[string]interface{})
+	return overlay
+}
+
+// mergeSection 返回base与overlay合并后的新配置节，不修改base
+func mergeSection(base map[string]interface{}, overlay map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(overlay))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		b, ok1 := merged[k].(map[string]interface{})
+		o, ok2 := v.(map[string]interface{})
+		if ok1 && ok2 {
+			merged[k] = mergeSection(b, o)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
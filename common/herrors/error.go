@@ -17,9 +17,52 @@ type Error struct {
 	Fingerprint string `json:"fingerprint,omitempty"`
 	Cause       string `json:"cause"`
 
+	Fields []FieldError `json:"fields,omitempty"` //字段级的错误，如参数校验不通过的各个字段
+
 	stack []string
 }
 
+// FieldError 单个字段的错误。ID为消息ID，用于查找译文，译文中的 {name} 以Params中的同名参数代入，
+// 如 param.min.length 译为 "{field}至少{min}个字符"；找不到译文时使用Message
+type FieldError struct {
+	Field   string                 `json:"field"`
+	ID      string                 `json:"id"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+// Format 以Params代入template中的 {name}，Params中没有的参数保留原样
+func (this FieldError) Format(template string) string {
+	if !strings.Contains(template, "{") {
+		return template
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.Index(template, "{")
+		if i < 0 {
+			break
+		}
+		j := strings.Index(template[i:], "}")
+		if j < 0 {
+			break
+		}
+		name := template[i+1 : i+j]
+		if v, ok := this.Params[name]; ok {
+			b.WriteString(template[:i])
+			b.WriteString(fmt.Sprint(v))
+		} else if name == "field" {
+			b.WriteString(template[:i])
+			b.WriteString(this.Field)
+		} else {
+			b.WriteString(template[:i+j+1])
+		}
+		template = template[i+j+1:]
+	}
+	b.WriteString(template)
+	return b.String()
+}
+
 func New(code int) *Error {
 	return &Error{
 		Code: code,
@@ -58,7 +101,36 @@ func (this *Error) D(format string, v ...interface{}) *Error {
 	return this
 }
 
+// WithFields 附加字段级的错误
+func (this *Error) WithFields(fields ...FieldError) *Error {
+	this.Fields = append(this.Fields, fields...)
+	return this
+}
+
+// Trace 记录调用栈和指纹，非调试模式下也可以通过指纹查询出错位置，用于panic等意外错误
+func (this *Error) Trace() *Error {
+	return this.withStack().withFingerprint()
+}
+
+// LogFields 作为hlogger的参数时，JSON格式的日志中以独立字段输出错误码、描述和指纹
+func (this *Error) LogFields() hlogger.Fields {
+	if this == nil {
+		return nil
+	}
+	fields := hlogger.Fields{"code": this.Code, "desc": this.Desc}
+	if this.Fingerprint != "" {
+		fields["fingerprint"] = this.Fingerprint
+	}
+	return fields
+}
+
 func (this *Error) log() {
+	if hlogger.IsJSON() {
+		_ = this.withStack().withFingerprint()
+		hlogger.WithFields(this.LogFields()).WithFields(hlogger.Fields{"stack": this.stack}).Error(this.Cause)
+		return
+	}
+
 	s := fmt.Sprintf("ERROR:%s", this.Desc)
 	s = fmt.Sprintf("%s\r\n\t|CODE: %d", s, this.Code)
 	if this.Cause != "" {
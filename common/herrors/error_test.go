@@ -24,3 +24,20 @@ func foo() *Error {
 func subFool() *Error {
 	return ErrCallerInvalidRequest.New("bad parameter").D("failed to subFool")
 }
+
+func TestTrace(t *testing.T) {
+	err := ErrSysInternal.New("panic").Trace()
+	if err.Fingerprint == "" {
+		t.Fatal("fingerprint not set")
+	}
+	if QueryFingerprint(err.Fingerprint) == "" {
+		t.Errorf("fingerprint %s not registered", err.Fingerprint)
+	}
+}
+
+func TestFieldErrorFormat(t *testing.T) {
+	f := FieldError{Field: "name", ID: "param.min.length", Params: map[string]interface{}{"min": 2}}
+	if s := f.Format("{field}至少{min}个字符，{max}"); s != "name至少2个字符，{max}" {
+		t.Errorf("format = %q", s)
+	}
+}
@@ -2,7 +2,9 @@ package herrors
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/drharryhe/has/utils/hencoder"
 )
@@ -12,6 +14,7 @@ var (
 	pointFingerPrints = make(map[string]*fingerprintItem)
 	filesMap          = make(map[string]string)
 	funcsMap          = make(map[string]string)
+	fingerprintLock   sync.RWMutex
 )
 
 type fingerprintItem struct {
@@ -21,7 +24,18 @@ type fingerprintItem struct {
 	Count    int
 }
 
+// FingerprintStatic 出错位置的统计
+type FingerprintStatic struct {
+	Fingerprint string `json:"fingerprint"`
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	Count       int    `json:"count"`
+}
+
 func addPointFingerprint(file string, fun string, line int) string {
+	fingerprintLock.Lock()
+	defer fingerprintLock.Unlock()
+
 	f1 := hencoder.Md5ToString([]byte(file))
 	if filesMap[f1] == "" {
 		filesMap[f1] = file
@@ -47,10 +61,16 @@ func addPointFingerprint(file string, fun string, line int) string {
 }
 
 func addStackFingerprint(sp string, errsps []string) {
+	fingerprintLock.Lock()
+	defer fingerprintLock.Unlock()
+
 	stackFingerprints[sp] = errsps
 }
 
 func QueryFingerprint(fp string) string {
+	fingerprintLock.RLock()
+	defer fingerprintLock.RUnlock()
+
 	fingerprint := stackFingerprints[fp]
 	if fingerprint == nil {
 		return ""
@@ -66,6 +86,9 @@ func QueryFingerprint(fp string) string {
 }
 
 func StaticsFingerprint() string {
+	fingerprintLock.RLock()
+	defer fingerprintLock.RUnlock()
+
 	res := "RESULTS: [\r\n"
 	for finger, item := range pointFingerPrints {
 		res += fmt.Sprintf("\t%s:%d\t\t%s:%d\r\n", finger, item.Count, filesMap[item.File], item.Line)
@@ -73,3 +96,23 @@ func StaticsFingerprint() string {
 
 	return res + "]"
 }
+
+// Statics 返回所有出错位置的统计，按指纹排序
+func Statics() []FingerprintStatic {
+	fingerprintLock.RLock()
+	defer fingerprintLock.RUnlock()
+
+	res := make([]FingerprintStatic, 0, len(pointFingerPrints))
+	for finger, item := range pointFingerPrints {
+		res = append(res, FingerprintStatic{
+			Fingerprint: finger,
+			File:        filesMap[item.File],
+			Line:        item.Line,
+			Count:       item.Count,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Fingerprint < res[j].Fingerprint
+	})
+	return res
+}
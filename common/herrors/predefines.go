@@ -7,13 +7,19 @@ const (
 	ECodeUnknown = -1 //未知错误编码
 
 	// 服务器错误代码
-	ECodeSysInternal  = 101 //服务器内部错误
-	ECodeSysBusy      = 102 //服务器忙
-	ECodeSysUnhandled = 103 //未处理. 这种报错多用于父类向子类返回，以便子类继续处理，
+	ECodeSysInternal    = 101 //服务器内部错误
+	ECodeSysBusy        = 102 //服务器忙
+	ECodeSysUnhandled   = 103 //未处理. 这种报错多用于父类向子类返回，以便子类继续处理，
+	ECodeSysTimeout     = 104 //处理超时
+	ECodeSysUnavailable = 105 //服务不可用，如熔断器打开
 
 	// 调用方错误代码
 	ECodeCallerInvalidRequest     = 201 //无效请求
 	ECodeCallerUnauthorizedAccess = 202 //非法请求
+	ECodeCallerTooManyRequests    = 203 //请求过于频繁
+	ECodeCallerForbidden          = 204 //禁止访问，如IP不在允许范围内
+	ECodeCallerConflict           = 205 //请求冲突，如相同Idempotency-Key的请求正在处理
+	ECodeCallerGone               = 206 //API版本已下线
 
 	// 用户端错误
 	ECodeUserInvalidAct      = 301 // 无效用户行为
@@ -24,13 +30,19 @@ var (
 	ErrOK = New(ECodeOK)
 
 	// Backend errors
-	ErrSysInternal  = New(ECodeSysInternal)
-	ErrSysBusy      = New(ECodeSysBusy)
-	ErrSysUnhandled = New(ECodeSysUnhandled)
+	ErrSysInternal    = New(ECodeSysInternal)
+	ErrSysBusy        = New(ECodeSysBusy)
+	ErrSysUnhandled   = New(ECodeSysUnhandled)
+	ErrSysTimeout     = New(ECodeSysTimeout)
+	ErrSysUnavailable = New(ECodeSysUnavailable)
 
 	// Caller errors
 	ErrCallerInvalidRequest     = New(ECodeCallerInvalidRequest)
 	ErrCallerUnauthorizedAccess = New(ECodeCallerUnauthorizedAccess)
+	ErrCallerTooManyRequests    = New(ECodeCallerTooManyRequests)
+	ErrCallerForbidden          = New(ECodeCallerForbidden)
+	ErrCallerConflict           = New(ECodeCallerConflict)
+	ErrCallerGone               = New(ECodeCallerGone)
 
 	// User errors
 	ErrUserInvalidAct      = New(ECodeUserInvalidAct)
@@ -48,7 +48,9 @@ var colors = []brush{
 type consoleWriter struct {
 	lg       *logWriter
 	Level    int  `json:"level"`
-	Colorful bool `json:"color"` //this filed is useful only when system's terminal supports color
+	Highest  int  `json:"highest"` //最高写入级别，如设为LevelWarning时error及以上级别的日志不写入
+	Colorful bool `json:"color"`   //this filed is useful only when system's terminal supports color
+	JSON     bool `json:"json"`    //消息已是JSON，不加时间前缀和颜色
 }
 
 // NewConsole create ConsoleWriter returning as LoggerInterface.
@@ -61,6 +63,13 @@ func NewConsole() Logger {
 	return cw
 }
 
+// NewStderr create ConsoleWriter writing to stderr.
+func NewStderr() Logger {
+	cw := NewConsole().(*consoleWriter)
+	cw.lg = newLogWriter(os.Stderr)
+	return cw
+}
+
 // Init init console hlogger.
 // jsonConfig like '{"level":LevelTrace}'.
 func (c *consoleWriter) Init(jsonConfig string) error {
@@ -76,7 +85,11 @@ func (c *consoleWriter) Init(jsonConfig string) error {
 
 // WriteMsg write message in console.
 func (c *consoleWriter) WriteMsg(when time.Time, msg string, level int) error {
-	if level > c.Level {
+	if level > c.Level || level < c.Highest {
+		return nil
+	}
+	if c.JSON {
+		c.lg.print(msg)
 		return nil
 	}
 	if c.Colorful {
@@ -98,4 +111,5 @@ func (c *consoleWriter) Flush() {
 
 func init() {
 	Register(AdapterConsole, NewConsole)
+	Register(AdapterStderr, NewStderr)
 }
@@ -0,0 +1,57 @@
+package hlogger
+
+import (
+	"context"
+)
+
+type requestIDKey struct{}
+
+// NewContext 返回携带请求ID的ctx，通过 XxxCtx 系列函数记录的日志会带上该ID，便于关联同一请求的日志
+func NewContext(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID 返回ctx中的请求ID，没有时返回空字符串
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func ErrorCtx(ctx context.Context, f interface{}, v ...interface{}) {
+	if !beeLogger.enabled(LevelError) {
+		return
+	}
+	beeLogger.log(LevelError, contextFields(ctx), f, v...)
+}
+
+func WarnCtx(ctx context.Context, f interface{}, v ...interface{}) {
+	if !beeLogger.enabled(LevelWarn) {
+		return
+	}
+	beeLogger.log(LevelWarn, contextFields(ctx), f, v...)
+}
+
+func InfoCtx(ctx context.Context, f interface{}, v ...interface{}) {
+	if !beeLogger.enabled(LevelInfo) {
+		return
+	}
+	beeLogger.log(LevelInfo, contextFields(ctx), f, v...)
+}
+
+func DebugCtx(ctx context.Context, f interface{}, v ...interface{}) {
+	if !beeLogger.enabled(LevelDebug) {
+		return
+	}
+	beeLogger.log(LevelDebug, contextFields(ctx), f, v...)
+}
+
+// contextFields ctx中的请求ID作为日志字段，文本格式下写在消息前面
+func contextFields(ctx context.Context) Fields {
+	if id := RequestID(ctx); id != "" {
+		return Fields{RequestIDField: id}
+	}
+	return nil
+}
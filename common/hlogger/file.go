@@ -52,7 +52,10 @@ type fileLogWriter struct {
 
 	Rotate bool `json:"rotate"`
 
-	Level int `json:"level"`
+	Level   int `json:"level"`
+	Highest int `json:"highest"` //最高写入级别，缺省为LevelEmergency
+
+	JSON bool `json:"json"` //消息已是JSON，不加时间前缀
 
 	Perm string `json:"perm"`
 
@@ -121,11 +124,15 @@ func (w *fileLogWriter) needRotate(size int, day int) bool {
 
 // WriteMsg write hlogger message into file.
 func (w *fileLogWriter) WriteMsg(when time.Time, msg string, level int) error {
-	if level > w.Level {
+	if level > w.Level || level < w.Highest {
 		return nil
 	}
 	h, d := formatTimeHeader(when)
-	msg = string(h) + msg + "\n"
+	if w.JSON {
+		msg = msg + "\n"
+	} else {
+		msg = string(h) + msg + "\n"
+	}
 	if w.Rotate {
 		w.RLock()
 		if w.needRotate(len(msg), d) {
@@ -0,0 +1,194 @@
+package hlogger
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 日志输出格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+
+	RequestIDField = "request_id" //XxxCtx系列函数写入的请求ID字段
+
+	jsonTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// Fields 日志的附加字段，JSON格式下与time、level、msg、caller并列输出，文本格式下以 key=value 附在消息后面
+type Fields map[string]interface{}
+
+// Fielder 作为日志参数时，JSON格式下以独立字段输出，如herrors.Error的错误码、描述和指纹
+type Fielder interface {
+	LogFields() Fields
+}
+
+// Entry 带附加字段的日志
+type Entry struct {
+	fields Fields
+}
+
+// WithFields 返回带附加字段的日志，如 hlogger.WithFields(hlogger.Fields{"user": id}).Info("login")
+func WithFields(fields Fields) *Entry {
+	return &Entry{fields: fields}
+}
+
+// WithFields 返回合并了附加字段的新日志
+func (this *Entry) WithFields(fields Fields) *Entry {
+	merged := make(Fields, len(this.fields)+len(fields))
+	for k, v := range this.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Entry{fields: merged}
+}
+
+func (this *Entry) Critical(f interface{}, v ...interface{}) {
+	if !beeLogger.enabled(LevelCritical) {
+		return
+	}
+	beeLogger.log(LevelCritical, this.fields, f, v...)
+}
+
+func (this *Entry) Error(f interface{}, v ...interface{}) {
+	if !beeLogger.enabled(LevelError) {
+		return
+	}
+	beeLogger.log(LevelError, this.fields, f, v...)
+}
+
+func (this *Entry) Warn(f interface{}, v ...interface{}) {
+	if !beeLogger.enabled(LevelWarn) {
+		return
+	}
+	beeLogger.log(LevelWarn, this.fields, f, v...)
+}
+
+func (this *Entry) Info(f interface{}, v ...interface{}) {
+	if !beeLogger.enabled(LevelInfo) {
+		return
+	}
+	beeLogger.log(LevelInfo, this.fields, f, v...)
+}
+
+func (this *Entry) Debug(f interface{}, v ...interface{}) {
+	if !beeLogger.enabled(LevelDebug) {
+		return
+	}
+	beeLogger.log(LevelDebug, this.fields, f, v...)
+}
+
+// SetFormat 设置日志格式 text 或 json，输出需同时以JSON方式初始化，见Init
+func SetFormat(format string) error {
+	switch format {
+	case "", FormatText:
+		atomic.StoreInt32(&beeLogger.json, 0)
+	case FormatJSON:
+		atomic.StoreInt32(&beeLogger.json, 1)
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	return nil
+}
+
+// IsJSON 是否以JSON格式输出日志
+func IsJSON() bool {
+	return beeLogger.isJSON()
+}
+
+func (bl *BeeLogger) isJSON() bool {
+	return atomic.LoadInt32(&bl.json) == 1
+}
+
+// log 格式化参数后写入，调用深度与BeeLogger的Error等方法相同。JSON格式下参数中的Fielder以独立字段输出
+func (bl *BeeLogger) log(level int, fields Fields, f interface{}, v ...interface{}) {
+	if bl.isJSON() {
+		fields = argFields(fields, f, v)
+	}
+	bl.writeMsg(level, fields, formatLog(f, v...))
+}
+
+func argFields(fields Fields, f interface{}, v []interface{}) Fields {
+	var merged Fields
+	for _, arg := range append([]interface{}{f}, v...) {
+		fielder, ok := arg.(Fielder)
+		if !ok {
+			continue
+		}
+		if merged == nil {
+			merged = make(Fields, len(fields))
+			for k, v := range fields {
+				merged[k] = v
+			}
+		}
+		for k, v := range fielder.LogFields() {
+			merged[k] = v
+		}
+	}
+	if merged == nil {
+		return fields
+	}
+	return merged
+}
+
+// formatText 文本格式：[文件:行号] [请求ID] 消息 key=value
+func formatText(caller string, msg string, fields Fields) string {
+	if id, ok := fields[RequestIDField]; ok {
+		msg = fmt.Sprintf("[%v] %s", id, msg)
+	}
+	if caller != "" {
+		msg = "[" + caller + "] " + msg
+	}
+
+	var keys []string
+	for k := range fields {
+		if k != RequestIDField {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return msg
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(msg)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, fields[k])
+	}
+	return b.String()
+}
+
+// formatJSON 每条日志一行JSON，附加字段不能覆盖time、level、msg、caller
+func formatJSON(when time.Time, level int, caller string, msg string, fields Fields) string {
+	rec := make(map[string]interface{}, len(fields)+4)
+	for k, v := range fields {
+		rec[k] = v
+	}
+	rec["time"] = when.Format(jsonTimeLayout)
+	rec["level"] = levelNames[level]
+	rec["msg"] = msg
+	if caller != "" {
+		rec["caller"] = caller
+	}
+
+	bs, err := json.Marshal(rec)
+	if err != nil {
+		//无法序列化的字段以字符串输出
+		for k, v := range fields {
+			switch k {
+			case "time", "level", "msg", "caller":
+			default:
+				rec[k] = fmt.Sprint(v)
+			}
+		}
+		bs, _ = json.Marshal(rec)
+	}
+	return string(bs)
+}
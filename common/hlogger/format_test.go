@@ -0,0 +1,59 @@
+package hlogger
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testError struct{}
+
+func (testError) Error() string {
+	return "failed"
+}
+
+func (testError) LogFields() Fields {
+	return Fields{"code": 101, "fingerprint": "abc"}
+}
+
+func TestFormatJSON(t *testing.T) {
+	fields := argFields(Fields{"user": "u1", "msg": "ignored"}, "login %s", []interface{}{testError{}})
+	line := formatJSON(time.Now(), LevelError, "a.go:10", "login failed", fields)
+
+	var rec map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &rec); err != nil {
+		t.Fatalf("invalid json %s: %v", line, err)
+	}
+	want := map[string]interface{}{
+		"level": "error", "msg": "login failed", "caller": "a.go:10",
+		"user": "u1", "code": float64(101), "fingerprint": "abc",
+	}
+	for k, v := range want {
+		if rec[k] != v {
+			t.Errorf("%s = %v, want %v", k, rec[k], v)
+		}
+	}
+	if _, ok := rec["time"]; !ok {
+		t.Error("time not set")
+	}
+
+	//无法序列化的字段以字符串输出
+	line = formatJSON(time.Now(), LevelInfo, "", "x", Fields{"f": func() {}})
+	if err := json.Unmarshal([]byte(line), &rec); err != nil {
+		t.Fatalf("invalid json %s: %v", line, err)
+	}
+}
+
+func TestFormatText(t *testing.T) {
+	msg := formatText("a.go:10", "hello", Fields{RequestIDField: "r1", "b": 2, "a": 1})
+	if msg != "[a.go:10] [r1] hello a=1 b=2" {
+		t.Errorf("text = %q", msg)
+	}
+	if msg = formatText("", "hello", nil); msg != "hello" {
+		t.Errorf("text = %q", msg)
+	}
+	if !strings.HasPrefix(formatText("a.go:1", "x", nil), "[a.go:1]") {
+		t.Error("caller not set")
+	}
+}
@@ -1,36 +1,70 @@
 package hlogger
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 const (
 	defaultLogFile = "has.log"
 )
 
+// Init 按outputs初始化日志输出，args依次为日志文件名、最低日志级别(如 info)和日志格式(text 或 json)。
+// 输出可以写成 名称:级别 或 名称:级别-级别，只写入该范围内的日志，
+// 如 ["console:debug-warning", "stderr:error", "file:info"] 将error及以上级别的日志写入stderr，其余写入stdout
 func Init(outputs []string, args ...interface{}) {
+	filePath := defaultLogFile
+	if len(args) > 0 {
+		if f, _ := args[0].(string); f != "" {
+			filePath = f
+		}
+	}
+
+	level := LevelDebug
+	if len(args) > 1 {
+		name, _ := args[1].(string)
+		l, err := ParseLevel(name)
+		if err != nil {
+			panic("init hlogger failed: " + err.Error())
+		}
+		level = l
+	}
+	SetLevel(level)
+
+	var format string
+	if len(args) > 2 {
+		format, _ = args[2].(string)
+	}
+	if err := SetFormat(format); err != nil {
+		panic("init hlogger failed: " + err.Error())
+	}
+
 	for _, o := range outputs {
-		switch o {
+		name, opts, err := parseOutput(o)
+		if err != nil {
+			panic("init hlogger failed: " + err.Error())
+		}
+		if IsJSON() {
+			opts += ",\"json\":true"
+		}
+		switch name {
 		case AdapterFile:
-			var filePath string
-			if len(args) == 0 {
-				filePath = defaultLogFile
-			} else {
-				filePath = args[0].(string)
-				if filePath == "" {
-					filePath = defaultLogFile
-				}
-			}
-			if err := SetLogger(AdapterMultiFiles, fmt.Sprintf("{\"filename\":\"%s\"}", filePath)); err != nil {
+			if err := SetLogger(AdapterMultiFiles, fmt.Sprintf("{\"filename\":\"%s\"%s}", filePath, opts)); err != nil {
 				panic("init hlogger failed." + err.Error())
 			}
+		case AdapterStderr:
+			if err := SetLogger(AdapterStderr, fmt.Sprintf("{%s}", strings.TrimPrefix(opts, ","))); err != nil {
+				panic("init hlogger failed: " + err.Error())
+			}
 		default:
-			if err := SetLogger(AdapterConsole); err != nil {
+			if err := SetLogger(AdapterConsole, fmt.Sprintf("{%s}", strings.TrimPrefix(opts, ","))); err != nil {
 				panic("init hlogger failed: " + err.Error())
 			}
 
 		}
 	}
 	if len(outputs) == 0 {
-		if err := SetLogger(AdapterConsole); err != nil {
+		if err := SetLogger(AdapterConsole, fmt.Sprintf("{\"json\":%t}", IsJSON())); err != nil {
 			panic("init hlogger failed.")
 		}
 	}
@@ -38,3 +72,52 @@ func Init(outputs []string, args ...interface{}) {
 	EnableFuncCallDepth(true)
 	SetLogFuncCallDepth(3)
 }
+
+// ParseLevel 返回级别名称对应的日志级别，名称为空时返回LevelDebug
+func ParseLevel(name string) (int, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "", "trace":
+		return LevelDebug, nil
+	case "warn":
+		return LevelWarning, nil
+	case "informational":
+		return LevelInformational, nil
+	}
+	for i, n := range levelNames {
+		if n == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// parseOutput 解析 名称:级别-级别 形式的输出设置，返回输出名称和写入adapter配置的级别范围
+func parseOutput(output string) (string, string, error) {
+	i := strings.Index(output, ":")
+	if i < 0 {
+		return output, "", nil
+	}
+
+	name, spec := output[:i], output[i+1:]
+	lowest, highest := spec, ""
+	if j := strings.Index(spec, "-"); j >= 0 {
+		lowest, highest = spec[:j], spec[j+1:]
+	}
+
+	low, err := ParseLevel(lowest)
+	if err != nil {
+		return "", "", err
+	}
+	high := LevelEmergency
+	if highest != "" {
+		if high, err = ParseLevel(highest); err != nil {
+			return "", "", err
+		}
+	}
+	//级别数值越小越严重，范围两端的顺序不限
+	if high > low {
+		low, high = high, low
+	}
+	return name, fmt.Sprintf(",\"level\":%d,\"highest\":%d", low, high), nil
+}
@@ -0,0 +1,66 @@
+package hlogger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLevel(t *testing.T) {
+	cases := map[string]int{
+		"":        LevelDebug,
+		"DEBUG":   LevelDebug,
+		"info":    LevelInfo,
+		"warn":    LevelWarning,
+		"warning": LevelWarning,
+		"error":   LevelError,
+	}
+	for name, want := range cases {
+		if l, err := ParseLevel(name); err != nil || l != want {
+			t.Errorf("ParseLevel(%q) = %d, %v, want %d", name, l, err, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("unknown level accepted")
+	}
+}
+
+func TestParseOutput(t *testing.T) {
+	name, levels, err := parseOutput("console:warning-debug")
+	if err != nil || name != AdapterConsole || levels != `,"level":7,"highest":4` {
+		t.Errorf("parseOutput = %s, %s, %v", name, levels, err)
+	}
+	if name, levels, _ = parseOutput("file"); name != AdapterFile || levels != "" {
+		t.Errorf("parseOutput = %s, %s", name, levels)
+	}
+	if _, _, err = parseOutput("stderr:loud"); err == nil {
+		t.Error("unknown level accepted")
+	}
+}
+
+func TestLevelRouting(t *testing.T) {
+	var out, errOut bytes.Buffer
+	stdout := NewConsole().(*consoleWriter)
+	stderr := NewStderr().(*consoleWriter)
+	_ = stdout.Init(`{"level":7,"highest":4,"color":false}`)
+	_ = stderr.Init(`{"level":3,"color":false}`)
+	stdout.lg, stderr.lg = newLogWriter(&out), newLogWriter(&errOut)
+
+	for _, l := range []int{LevelError, LevelWarning, LevelDebug} {
+		_ = stdout.WriteMsg(time.Now(), levelPrefix[l], l)
+		_ = stderr.WriteMsg(time.Now(), levelPrefix[l], l)
+	}
+	if s := out.String(); strings.Contains(s, "[E]") || !strings.Contains(s, "[W]") || !strings.Contains(s, "[D]") {
+		t.Errorf("stdout = %q", s)
+	}
+	if s := errOut.String(); !strings.Contains(s, "[E]") || strings.Contains(s, "[W]") {
+		t.Errorf("stderr = %q", s)
+	}
+
+	bl := NewLogger()
+	bl.SetLevel(LevelInfo)
+	if bl.enabled(LevelDebug) || !bl.enabled(LevelError) {
+		t.Error("level filter not applied")
+	}
+}
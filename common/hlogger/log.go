@@ -42,6 +42,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -64,6 +65,7 @@ const levelLoggerImpl = -1
 // Name for adapter with beego official support
 const (
 	AdapterConsole    = "console"
+	AdapterStderr     = "stderr"
 	AdapterFile       = "file"
 	AdapterMultiFiles = "multifiles"
 )
@@ -105,7 +107,8 @@ func Register(name string, log newLoggerFunc) {
 // it can contain several providers and log message into all providers.
 type BeeLogger struct {
 	lock                sync.Mutex
-	level               int
+	level               int32
+	json                int32 //1表示以JSON格式输出
 	init                bool
 	enableFuncCallDepth bool
 	loggerFuncCallDepth int
@@ -233,6 +236,7 @@ func (bl *BeeLogger) writeToLoggers(when time.Time, msg string, level int) {
 			fmt.Fprintf(os.Stderr, "unable to WriteMsg to adapter:%v,error:%v\n", l.name, err)
 		}
 	}
+	publish(when, msg, level)
 }
 
 func (bl *BeeLogger) Write(p []byte) (n int, err error) {
@@ -244,14 +248,14 @@ func (bl *BeeLogger) Write(p []byte) (n int, err error) {
 		p = p[0 : len(p)-1]
 	}
 	// set levelLoggerImpl to ensure all log message will be write out
-	err = bl.writeMsg(levelLoggerImpl, string(p))
+	err = bl.writeMsg(levelLoggerImpl, nil, string(p))
 	if err == nil {
 		return len(p), err
 	}
 	return 0, err
 }
 
-func (bl *BeeLogger) writeMsg(logLevel int, msg string, v ...interface{}) error {
+func (bl *BeeLogger) writeMsg(logLevel int, fields Fields, msg string, v ...interface{}) error {
 	if !bl.init {
 		bl.lock.Lock()
 		bl.setLogger(AdapterConsole)
@@ -262,6 +266,7 @@ func (bl *BeeLogger) writeMsg(logLevel int, msg string, v ...interface{}) error
 		msg = fmt.Sprintf(msg, v...)
 	}
 	when := time.Now()
+	var caller string
 	if bl.enableFuncCallDepth {
 		_, file, line, ok := runtime.Caller(bl.loggerFuncCallDepth)
 		if !ok {
@@ -269,15 +274,24 @@ func (bl *BeeLogger) writeMsg(logLevel int, msg string, v ...interface{}) error
 			line = 0
 		}
 		_, filename := path.Split(file)
-		msg = "[" + filename + ":" + strconv.Itoa(line) + "] " + msg
+		caller = filename + ":" + strconv.Itoa(line)
 	}
 
-	//set level info in front of filename info
-	if logLevel == levelLoggerImpl {
-		// set to emergency to ensure all log will be print out correctly
-		logLevel = LevelEmergency
+	if bl.isJSON() {
+		if logLevel == levelLoggerImpl {
+			logLevel = LevelEmergency
+		}
+		msg = formatJSON(when, logLevel, caller, msg, fields)
 	} else {
-		msg = levelPrefix[logLevel] + msg
+		msg = formatText(caller, msg, fields)
+
+		//set level info in front of filename info
+		if logLevel == levelLoggerImpl {
+			// set to emergency to ensure all log will be print out correctly
+			logLevel = LevelEmergency
+		} else {
+			msg = levelPrefix[logLevel] + msg
+		}
 	}
 
 	if bl.asynchronous {
@@ -296,7 +310,17 @@ func (bl *BeeLogger) writeMsg(logLevel int, msg string, v ...interface{}) error
 // If message level (such as LevelDebug) is higher than hlogger level (such as LevelWarning),
 // log providers will not even be sent the message.
 func (bl *BeeLogger) SetLevel(l int) {
-	bl.level = l
+	atomic.StoreInt32(&bl.level, int32(l))
+}
+
+// GetLevel returns the current log message level.
+func (bl *BeeLogger) GetLevel() int {
+	return int(atomic.LoadInt32(&bl.level))
+}
+
+// enabled 级别过滤在格式化日志之前进行，低于设置级别的日志几乎没有开销
+func (bl *BeeLogger) enabled(l int) bool {
+	return int32(l) <= atomic.LoadInt32(&bl.level)
 }
 
 // SetLogFuncCallDepth set log funcCallDepth
@@ -343,93 +367,93 @@ func (bl *BeeLogger) startLogger() {
 
 // Emergency Log EMERGENCY level message.
 func (bl *BeeLogger) Emergency(format string, v ...interface{}) {
-	if LevelEmergency > bl.level {
+	if !bl.enabled(LevelEmergency) {
 		return
 	}
-	bl.writeMsg(LevelEmergency, format, v...)
+	bl.writeMsg(LevelEmergency, nil, format, v...)
 }
 
 // Alert Log ALERT level message.
 func (bl *BeeLogger) Alert(format string, v ...interface{}) {
-	if LevelAlert > bl.level {
+	if !bl.enabled(LevelAlert) {
 		return
 	}
-	bl.writeMsg(LevelAlert, format, v...)
+	bl.writeMsg(LevelAlert, nil, format, v...)
 }
 
 // Critical Log CRITICAL level message.
 func (bl *BeeLogger) Critical(format string, v ...interface{}) {
-	if LevelCritical > bl.level {
+	if !bl.enabled(LevelCritical) {
 		return
 	}
-	bl.writeMsg(LevelCritical, format, v...)
+	bl.writeMsg(LevelCritical, nil, format, v...)
 }
 
 // Error Log ERROR level message.
 func (bl *BeeLogger) Error(format string, v ...interface{}) {
-	if LevelError > bl.level {
+	if !bl.enabled(LevelError) {
 		return
 	}
-	bl.writeMsg(LevelError, format, v...)
+	bl.writeMsg(LevelError, nil, format, v...)
 }
 
 // Warning Log WARNING level message.
 func (bl *BeeLogger) Warning(format string, v ...interface{}) {
-	if LevelWarn > bl.level {
+	if !bl.enabled(LevelWarn) {
 		return
 	}
-	bl.writeMsg(LevelWarn, format, v...)
+	bl.writeMsg(LevelWarn, nil, format, v...)
 }
 
 // Notice Log NOTICE level message.
 func (bl *BeeLogger) Notice(format string, v ...interface{}) {
-	if LevelNotice > bl.level {
+	if !bl.enabled(LevelNotice) {
 		return
 	}
-	bl.writeMsg(LevelNotice, format, v...)
+	bl.writeMsg(LevelNotice, nil, format, v...)
 }
 
 // Informational Log INFORMATIONAL level message.
 func (bl *BeeLogger) Informational(format string, v ...interface{}) {
-	if LevelInfo > bl.level {
+	if !bl.enabled(LevelInfo) {
 		return
 	}
-	bl.writeMsg(LevelInfo, format, v...)
+	bl.writeMsg(LevelInfo, nil, format, v...)
 }
 
 // Debug Log DEBUG level message.
 func (bl *BeeLogger) Debug(format string, v ...interface{}) {
-	if LevelDebug > bl.level {
+	if !bl.enabled(LevelDebug) {
 		return
 	}
-	bl.writeMsg(LevelDebug, format, v...)
+	bl.writeMsg(LevelDebug, nil, format, v...)
 }
 
 // Warn Log WARN level message.
 // compatibility alias for Warning()
 func (bl *BeeLogger) Warn(format string, v ...interface{}) {
-	if LevelWarn > bl.level {
+	if !bl.enabled(LevelWarn) {
 		return
 	}
-	bl.writeMsg(LevelWarn, format, v...)
+	bl.writeMsg(LevelWarn, nil, format, v...)
 }
 
 // Info Log INFO level message.
 // compatibility alias for Informational()
 func (bl *BeeLogger) Info(format string, v ...interface{}) {
-	if LevelInfo > bl.level {
+	if !bl.enabled(LevelInfo) {
 		return
 	}
-	bl.writeMsg(LevelInfo, format, v...)
+	bl.writeMsg(LevelInfo, nil, format, v...)
 }
 
 // Trace Log TRACE level message.
 // compatibility alias for Debug()
 func (bl *BeeLogger) Trace(format string, v ...interface{}) {
-	if LevelDebug > bl.level {
+	if !bl.enabled(LevelDebug) {
 		return
 	}
-	bl.writeMsg(LevelDebug, format, v...)
+	bl.writeMsg(LevelDebug, nil, format, v...)
 }
 
 // Flush flush all chan data.
@@ -538,6 +562,11 @@ func SetLevel(l int) {
 	beeLogger.SetLevel(l)
 }
 
+// GetLevel returns the global log level used by the simple hlogger.
+func GetLevel() int {
+	return beeLogger.GetLevel()
+}
+
 // EnableFuncCallDepth enable log funcCallDepth
 func EnableFuncCallDepth(b bool) {
 	beeLogger.enableFuncCallDepth = b
@@ -561,58 +590,91 @@ func SetLogger(adapter string, config ...string) error {
 
 // Emergency logs a message at emergency level.
 func Emergency(f interface{}, v ...interface{}) {
-	beeLogger.Emergency(formatLog(f, v...))
+	if !beeLogger.enabled(LevelEmergency) {
+		return
+	}
+	beeLogger.log(LevelEmergency, nil, f, v...)
 }
 
 // Alert logs a message at alert level.
 func Alert(f interface{}, v ...interface{}) {
-	beeLogger.Alert(formatLog(f, v...))
+	if !beeLogger.enabled(LevelAlert) {
+		return
+	}
+	beeLogger.log(LevelAlert, nil, f, v...)
 }
 
 // Critical logs a message at critical level.
 func Critical(f interface{}, v ...interface{}) {
-	beeLogger.Critical(formatLog(f, v...))
+	if !beeLogger.enabled(LevelCritical) {
+		return
+	}
+	beeLogger.log(LevelCritical, nil, f, v...)
 }
 
 // Error logs a message at error level.
 func Error(f interface{}, v ...interface{}) {
-	beeLogger.Error(formatLog(f, v...))
+	if !beeLogger.enabled(LevelError) {
+		return
+	}
+	beeLogger.log(LevelError, nil, f, v...)
 }
 
 // Warning logs a message at warning level.
 func Warning(f interface{}, v ...interface{}) {
-	beeLogger.Warn(formatLog(f, v...))
+	if !beeLogger.enabled(LevelWarn) {
+		return
+	}
+	beeLogger.log(LevelWarn, nil, f, v...)
 }
 
 // Warn compatibility alias for Warning()
 func Warn(f interface{}, v ...interface{}) {
-	beeLogger.Warn(formatLog(f, v...))
+	if !beeLogger.enabled(LevelWarn) {
+		return
+	}
+	beeLogger.log(LevelWarn, nil, f, v...)
 }
 
 // Notice logs a message at notice level.
 func Notice(f interface{}, v ...interface{}) {
-	beeLogger.Notice(formatLog(f, v...))
+	if !beeLogger.enabled(LevelNotice) {
+		return
+	}
+	beeLogger.log(LevelNotice, nil, f, v...)
 }
 
 // Informational logs a message at info level.
 func Informational(f interface{}, v ...interface{}) {
-	beeLogger.Info(formatLog(f, v...))
+	if !beeLogger.enabled(LevelInfo) {
+		return
+	}
+	beeLogger.log(LevelInfo, nil, f, v...)
 }
 
 // Info compatibility alias for Warning()
 func Info(f interface{}, v ...interface{}) {
-	beeLogger.Info(formatLog(f, v...))
+	if !beeLogger.enabled(LevelInfo) {
+		return
+	}
+	beeLogger.log(LevelInfo, nil, f, v...)
 }
 
 // Debug logs a message at debug level.
 func Debug(f interface{}, v ...interface{}) {
-	beeLogger.Debug(formatLog(f, v...))
+	if !beeLogger.enabled(LevelDebug) {
+		return
+	}
+	beeLogger.log(LevelDebug, nil, f, v...)
 }
 
 // Trace logs a message at trace level.
 // compatibility alias for Warning()
 func Trace(f interface{}, v ...interface{}) {
-	beeLogger.Trace(formatLog(f, v...))
+	if !beeLogger.enabled(LevelDebug) {
+		return
+	}
+	beeLogger.log(LevelDebug, nil, f, v...)
 }
 
 func formatLog(f interface{}, v ...interface{}) string {
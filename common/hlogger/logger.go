@@ -38,6 +38,13 @@ func (lg *logWriter) println(when time.Time, msg string) {
 	lg.Unlock()
 }
 
+// print 不加时间前缀，用于JSON格式的日志
+func (lg *logWriter) print(msg string) {
+	lg.Lock()
+	lg.writer.Write(append([]byte(msg), '\n'))
+	lg.Unlock()
+}
+
 type outputMode int
 
 // DiscardNonColorEscSeq supports the divided color escape sequence.
@@ -0,0 +1,82 @@
+package hlogger
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultSubscribeBuffer = 256
+)
+
+// Record 订阅者收到的日志，Msg与写入输出的内容一致
+type Record struct {
+	Time  time.Time
+	Level int
+	Msg   string
+}
+
+// Subscription 日志订阅，用于在进程内查看实时日志。订阅者处理不及时时丢弃日志，不影响日志写入
+type Subscription struct {
+	C       <-chan *Record
+	ch      chan *Record
+	dropped uint64
+	once    sync.Once
+}
+
+var (
+	subscribers     sync.Map //*Subscription -> struct{}
+	subscriberCount int32
+)
+
+// Subscribe 订阅之后写入的日志，buffer为缓存的日志条数，<=0时使用缺省值。不再使用时需调用Close
+func Subscribe(buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = defaultSubscribeBuffer
+	}
+	ch := make(chan *Record, buffer)
+	s := &Subscription{C: ch, ch: ch}
+	subscribers.Store(s, struct{}{})
+	atomic.AddInt32(&subscriberCount, 1)
+	return s
+}
+
+// Close 取消订阅，之后不再向C写入日志。C不会被关闭
+func (this *Subscription) Close() {
+	this.once.Do(func() {
+		subscribers.Delete(this)
+		atomic.AddInt32(&subscriberCount, -1)
+	})
+}
+
+// Dropped 因缓存已满而丢弃的日志条数
+func (this *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&this.dropped)
+}
+
+// publish 不阻塞写入日志的goroutine，缓存已满时丢弃
+func publish(when time.Time, msg string, level int) {
+	if atomic.LoadInt32(&subscriberCount) == 0 {
+		return
+	}
+
+	r := &Record{Time: when, Level: level, Msg: msg}
+	subscribers.Range(func(k, _ interface{}) bool {
+		s := k.(*Subscription)
+		select {
+		case s.ch <- r:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+		return true
+	})
+}
+
+// LevelName 返回日志级别的名称，如 error
+func LevelName(level int) string {
+	if level < 0 || level >= len(levelNames) {
+		return ""
+	}
+	return levelNames[level]
+}
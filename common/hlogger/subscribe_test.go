@@ -0,0 +1,36 @@
+package hlogger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	bl := &BeeLogger{}
+	bl.writeToLoggers(time.Now(), "before", LevelInfo)
+
+	sub := Subscribe(1)
+	bl.writeToLoggers(time.Now(), "first", LevelError)
+	bl.writeToLoggers(time.Now(), "second", LevelInfo)
+
+	select {
+	case r := <-sub.C:
+		if r.Msg != "first" || r.Level != LevelError {
+			t.Errorf("record = %+v", r)
+		}
+	default:
+		t.Fatal("no record received")
+	}
+	if n := sub.Dropped(); n != 1 {
+		t.Errorf("dropped = %d, want 1", n)
+	}
+
+	sub.Close()
+	sub.Close()
+	bl.writeToLoggers(time.Now(), "after", LevelInfo)
+	select {
+	case r := <-sub.C:
+		t.Errorf("record %q received after Close", r.Msg)
+	default:
+	}
+}
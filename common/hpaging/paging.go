@@ -0,0 +1,109 @@
+package hpaging
+
+import (
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hparam"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/utils/hruntime"
+)
+
+const (
+	DefaultPageSize    = 20
+	DefaultMaxPageSize = 100
+)
+
+// Paging 分页参数，Page从1开始。Offset、Limit与Page、PageSize相互换算，可直接用于数据库查询
+type Paging struct {
+	Page     int
+	PageSize int
+	Offset   int
+	Limit    int
+}
+
+// Page 列表的分页信息
+type Page struct {
+	Total    int64 `json:"total"`
+	Page     int   `json:"page"`
+	PageSize int   `json:"page_size"`
+}
+
+// List 标准的列表返回结果，connector返回时将Items作为data，分页信息单独返回
+type List struct {
+	Page
+	Items htypes.Any `json:"items"`
+}
+
+type pagingParams struct {
+	Page     *int `param:"page"`
+	PageSize *int `param:"pageSize"`
+	Offset   *int `param:"offset"`
+	Limit    *int `param:"limit"`
+}
+
+// Parse 从参数中解析 page/pageSize 或 offset/limit，同时提供时以 offset/limit 为准。
+// 页码小于1时按第1页处理，每页数量超过maxPageSize(<=0时为DefaultMaxPageSize)时按maxPageSize处理
+func Parse(ps htypes.Map, maxPageSize int) (*Paging, *herrors.Error) {
+	if maxPageSize <= 0 {
+		maxPageSize = DefaultMaxPageSize
+	}
+
+	var p pagingParams
+	if err := hparam.Bind(ps, &p); err != nil {
+		return nil, err
+	}
+
+	ret := &Paging{
+		Page:     1,
+		PageSize: DefaultPageSize,
+	}
+
+	if p.Offset != nil || p.Limit != nil {
+		if p.Limit != nil {
+			ret.PageSize = *p.Limit
+		}
+		ret.PageSize = bound(ret.PageSize, maxPageSize)
+		if p.Offset != nil && *p.Offset > 0 {
+			ret.Offset = *p.Offset
+		}
+		ret.Limit = ret.PageSize
+		ret.Page = ret.Offset/ret.PageSize + 1
+		return ret, nil
+	}
+
+	if p.Page != nil && *p.Page > 1 {
+		ret.Page = *p.Page
+	}
+	if p.PageSize != nil {
+		ret.PageSize = *p.PageSize
+	}
+	ret.PageSize = bound(ret.PageSize, maxPageSize)
+	ret.Limit = ret.PageSize
+	ret.Offset = (ret.Page - 1) * ret.PageSize
+	return ret, nil
+}
+
+// NewList 创建列表返回结果，total为符合条件的总数
+func NewList(items htypes.Any, total int64, paging *Paging) *List {
+	if hruntime.IsNil(items) {
+		items = []interface{}{}
+	}
+
+	return &List{
+		Page: Page{
+			Total:    total,
+			Page:     paging.Page,
+			PageSize: paging.PageSize,
+		},
+		Items: items,
+	}
+}
+
+func bound(size int, max int) int {
+	if size < 1 {
+		return DefaultPageSize
+	}
+	if size > max {
+		return max
+	}
+	return size
+}
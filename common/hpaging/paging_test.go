@@ -0,0 +1,35 @@
+package hpaging
+
+import (
+	"testing"
+
+	"github.com/drharryhe/has/common/htypes"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		ps       htypes.Map
+		page     int
+		pageSize int
+		offset   int
+	}{
+		{htypes.Map{}, 1, DefaultPageSize, 0},
+		{htypes.Map{"page": "3", "pageSize": float64(10)}, 3, 10, 20},
+		{htypes.Map{"page": -1, "pageSize": 1000}, 1, 50, 0},
+		{htypes.Map{"offset": 40, "limit": 20}, 3, 20, 40},
+	}
+
+	for _, c := range cases {
+		p, err := Parse(c.ps, 50)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Page != c.page || p.PageSize != c.pageSize || p.Offset != c.offset || p.Limit != c.pageSize {
+			t.Errorf("Parse(%v) = %+v", c.ps, p)
+		}
+	}
+
+	if _, err := Parse(htypes.Map{"page": "abc"}, 0); err == nil {
+		t.Error("invalid page should fail")
+	}
+}
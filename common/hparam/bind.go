@@ -0,0 +1,159 @@
+package hparam
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+const (
+	TagName     = "param"
+	TagRequired = "required"
+)
+
+// Bind 将参数绑定到out指向的结构体。
+// 字段通过 `param:"name"` 指定参数名，未指定时按字段名匹配(不区分大小写)；`param:"name,required"` 表示必填。
+// 支持嵌套结构体和切片，字符串与数值、布尔值之间自动转换，字符串按RFC3339转换为time.Time。
+// 所有无效参数汇总在一个ErrCallerInvalidRequest中返回
+func Bind(ps htypes.Map, out interface{}) *herrors.Error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return herrors.ErrSysInternal.New("bind target should be a pointer to struct, got %T", out)
+	}
+
+	errs := checkRequired(map[string]interface{}(ps), v.Elem().Type(), "")
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToTimeHookFunc(time.RFC3339),
+		),
+		WeaklyTypedInput: true,
+		TagName:          TagName,
+		Result:           out,
+	})
+	if err != nil {
+		return herrors.ErrSysInternal.New(err.Error())
+	}
+
+	if err = decoder.Decode(map[string]interface{}(ps)); err != nil {
+		if me, ok := err.(*mapstructure.Error); ok {
+			errs = append(errs, me.Errors...)
+		} else {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return herrors.ErrCallerInvalidRequest.New(strings.Join(errs, "; ")).D("invalid parameters")
+	}
+	return nil
+}
+
+// checkRequired 检查必填参数，嵌套结构体和切片元素只在其本身存在时检查
+func checkRequired(data map[string]interface{}, t reflect.Type, prefix string) []string {
+	var errs []string
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name, opts := parseTag(f)
+		if name == "-" {
+			continue
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if hasOpt(opts, "squash") && ft.Kind() == reflect.Struct {
+			errs = append(errs, checkRequired(data, ft, prefix)...)
+			continue
+		}
+
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		val, ok := lookup(data, name)
+		if !ok || val == nil {
+			if hasOpt(opts, TagRequired) {
+				errs = append(errs, fmt.Sprintf("required parameter '%s' not found", path))
+			}
+			continue
+		}
+
+		switch ft.Kind() {
+		case reflect.Struct:
+			if m, ok := val.(map[string]interface{}); ok {
+				errs = append(errs, checkRequired(m, ft, path)...)
+			} else if m, ok := val.(htypes.Map); ok {
+				errs = append(errs, checkRequired(m, ft, path)...)
+			}
+		case reflect.Slice, reflect.Array:
+			et := ft.Elem()
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+			if et.Kind() != reflect.Struct {
+				continue
+			}
+			items, ok := val.([]interface{})
+			if !ok {
+				continue
+			}
+			for j, item := range items {
+				if m, ok := item.(map[string]interface{}); ok {
+					errs = append(errs, checkRequired(m, et, fmt.Sprintf("%s[%d]", path, j))...)
+				} else if m, ok := item.(htypes.Map); ok {
+					errs = append(errs, checkRequired(m, et, fmt.Sprintf("%s[%d]", path, j))...)
+				}
+			}
+		}
+	}
+
+	return errs
+}
+
+func parseTag(f reflect.StructField) (string, []string) {
+	tag := f.Tag.Get(TagName)
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = f.Name
+	}
+	return name, parts[1:]
+}
+
+func hasOpt(opts []string, opt string) bool {
+	for _, o := range opts {
+		if strings.TrimSpace(o) == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup 与mapstructure一致，优先精确匹配，其次不区分大小写匹配
+func lookup(data map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := data[name]; ok {
+		return v, true
+	}
+	for k, v := range data {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,58 @@
+package hparam
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/drharryhe/has/common/htypes"
+)
+
+type address struct {
+	City string `param:"city,required"`
+	Zip  int    `param:"zip"`
+}
+
+type user struct {
+	Name    string    `param:"name,required"`
+	Age     int       `param:"age"`
+	Admin   bool      `param:"admin"`
+	Tags    []string  `param:"tags"`
+	Address address   `param:"address"`
+	Others  []address `param:"others"`
+}
+
+func TestBind(t *testing.T) {
+	var u user
+	err := Bind(htypes.Map{
+		"name":    "harry",
+		"age":     "18",
+		"admin":   "true",
+		"tags":    []interface{}{"a", "b"},
+		"address": map[string]interface{}{"city": "beijing", "zip": float64(100000)},
+		"others":  []interface{}{map[string]interface{}{"city": "shanghai"}},
+	}, &u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "harry" || u.Age != 18 || !u.Admin || len(u.Tags) != 2 || u.Address.Zip != 100000 || u.Others[0].City != "shanghai" {
+		t.Fatalf("unexpected result %+v", u)
+	}
+}
+
+func TestBindInvalid(t *testing.T) {
+	var u user
+	err := Bind(htypes.Map{
+		"age":     "abc",
+		"address": map[string]interface{}{"zip": "1"},
+		"others":  []interface{}{map[string]interface{}{}},
+	}, &u)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	for _, s := range []string{"'name'", "'address.city'", "'others[0].city'", "'age'"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("error should mention %s: %s", s, err.Error())
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package htrace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	exportTimeout = 10 //seconds
+
+	//OTLP的状态码与otel/codes的取值不同
+	otlpStatusUnset = 0
+	otlpStatusOk    = 1
+	otlpStatusError = 2
+)
+
+// Exporter 以OTLP/HTTP JSON格式发送span，兼容OpenTelemetry Collector、Jaeger、Tempo等的 /v1/traces 接口
+type Exporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+func NewExporter(endpoint string) (*Exporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("trace endpoint %s must be http or https url", endpoint)
+	}
+	return &Exporter{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: exportTimeout * time.Second},
+	}, nil
+}
+
+func (this *Exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	bs, err := json.Marshal(encodeSpans(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, this.endpoint, bytes.NewReader(bs))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := this.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to export spans to %s: %s", this.endpoint, resp.Status)
+	}
+	return nil
+}
+
+func (this *Exporter) Shutdown(ctx context.Context) error {
+	this.client.CloseIdleConnections()
+	return nil
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	TraceState        string         `json:"traceState,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// encodeSpans 同一TracerProvider的span资源相同，按instrumentation library分组
+func encodeSpans(spans []sdktrace.ReadOnlySpan) *otlpRequest {
+	rs := otlpResourceSpans{}
+	if res := spans[0].Resource(); res != nil {
+		rs.Resource.Attributes = encodeAttributes(res.Attributes())
+	}
+
+	scopes := make(map[string]int)
+	for _, s := range spans {
+		lib := s.InstrumentationLibrary()
+		key := lib.Name + "@" + lib.Version
+		i, ok := scopes[key]
+		if !ok {
+			i = len(rs.ScopeSpans)
+			scopes[key] = i
+			rs.ScopeSpans = append(rs.ScopeSpans, otlpScopeSpans{Scope: otlpScope{Name: lib.Name, Version: lib.Version}})
+		}
+		rs.ScopeSpans[i].Spans = append(rs.ScopeSpans[i].Spans, encodeSpan(s))
+	}
+	return &otlpRequest{ResourceSpans: []otlpResourceSpans{rs}}
+}
+
+func encodeSpan(s sdktrace.ReadOnlySpan) otlpSpan {
+	sc := s.SpanContext()
+	ret := otlpSpan{
+		TraceID:           sc.TraceID().String(),
+		SpanID:            sc.SpanID().String(),
+		TraceState:        sc.TraceState().String(),
+		Name:              s.Name(),
+		Kind:              int(s.SpanKind()), //otel的SpanKind与OTLP取值相同
+		StartTimeUnixNano: strconv.FormatInt(s.StartTime().UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.EndTime().UnixNano(), 10),
+		Attributes:        encodeAttributes(s.Attributes()),
+		Status:            otlpStatus{Message: s.Status().Description},
+	}
+	if p := s.Parent(); p.HasSpanID() {
+		ret.ParentSpanID = p.SpanID().String()
+	}
+	switch s.Status().Code {
+	case codes.Ok:
+		ret.Status.Code = otlpStatusOk
+	case codes.Error:
+		ret.Status.Code = otlpStatusError
+	default:
+		ret.Status.Code = otlpStatusUnset
+	}
+	for _, e := range s.Events() {
+		ret.Events = append(ret.Events, otlpEvent{
+			TimeUnixNano: strconv.FormatInt(e.Time.UnixNano(), 10),
+			Name:         e.Name,
+			Attributes:   encodeAttributes(e.Attributes),
+		})
+	}
+	return ret
+}
+
+func encodeAttributes(attrs []attribute.KeyValue) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	ret := make([]otlpKeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		ret = append(ret, otlpKeyValue{Key: string(a.Key), Value: encodeValue(a.Value)})
+	}
+	return ret
+}
+
+// encodeValue int64按OTLP JSON的约定编码为字符串
+func encodeValue(v attribute.Value) map[string]interface{} {
+	switch v.Type() {
+	case attribute.BOOL:
+		return map[string]interface{}{"boolValue": v.AsBool()}
+	case attribute.INT64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v.AsInt64(), 10)}
+	case attribute.FLOAT64:
+		return map[string]interface{}{"doubleValue": v.AsFloat64()}
+	case attribute.BOOLSLICE:
+		var vals []map[string]interface{}
+		for _, b := range v.AsBoolSlice() {
+			vals = append(vals, encodeValue(attribute.BoolValue(b)))
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": vals}}
+	case attribute.INT64SLICE:
+		var vals []map[string]interface{}
+		for _, n := range v.AsInt64Slice() {
+			vals = append(vals, encodeValue(attribute.Int64Value(n)))
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": vals}}
+	case attribute.FLOAT64SLICE:
+		var vals []map[string]interface{}
+		for _, f := range v.AsFloat64Slice() {
+			vals = append(vals, encodeValue(attribute.Float64Value(f)))
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": vals}}
+	case attribute.STRINGSLICE:
+		var vals []map[string]interface{}
+		for _, s := range v.AsStringSlice() {
+			vals = append(vals, encodeValue(attribute.StringValue(s)))
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": vals}}
+	}
+	return map[string]interface{}{"stringValue": v.Emit()}
+}
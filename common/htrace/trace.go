@@ -0,0 +1,95 @@
+package htrace
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	HeaderTraceParent = "traceparent" //W3C Trace Context header
+	HeaderTraceState  = "tracestate"
+
+	TracerName         = "github.com/drharryhe/has"
+	defaultServiceName = "has"
+	shutdownTimeout    = 5 * time.Second
+)
+
+var (
+	provider   *sdktrace.TracerProvider
+	propagator = propagation.TraceContext{}
+)
+
+// Init 设置全局的TracerProvider，span批量以OTLP/HTTP JSON格式发送到endpoint，如 http://127.0.0.1:4318/v1/traces。
+// endpoint为空时不记录trace；sampleRate为根span的采样率，不在(0,1]内时全部采样，有上游span时沿用上游的采样结果
+func Init(endpoint string, sampleRate float64, serviceName string) error {
+	if endpoint == "" {
+		return nil
+	}
+	exp, err := NewExporter(endpoint)
+	if err != nil {
+		return err
+	}
+	if sampleRate <= 0 || sampleRate > 1 {
+		sampleRate = 1
+	}
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRate))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+	return nil
+}
+
+// Shutdown 发送缓存的span并关闭TracerProvider
+func Shutdown() {
+	if provider == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	_ = provider.Shutdown(ctx)
+	provider = nil
+}
+
+func Enabled() bool {
+	return provider != nil
+}
+
+// Tracer 未初始化时返回不记录的Tracer
+func Tracer() trace.Tracer {
+	if provider == nil {
+		return trace.NewNoopTracerProvider().Tracer(TracerName)
+	}
+	return provider.Tracer(TracerName)
+}
+
+// Extract 将traceparent和tracestate表示的上游span放入ctx，格式无效时返回原ctx
+func Extract(ctx context.Context, traceParent string, traceState string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.MapCarrier{
+		HeaderTraceParent: traceParent,
+		HeaderTraceState:  traceState,
+	})
+}
+
+// Inject 返回ctx中span的traceparent和tracestate，没有有效的span时返回空字符串
+func Inject(ctx context.Context) (traceParent string, traceState string) {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier[HeaderTraceParent], carrier[HeaderTraceState]
+}
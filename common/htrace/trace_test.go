@@ -0,0 +1,84 @@
+package htrace
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestExporter(t *testing.T) {
+	var body otlpRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = otlpRequest{}
+		bs, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(bs, &body); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	exp, err := NewExporter(srv.URL + "/v1/traces")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	ctx, parent := tp.Tracer(TracerName).Start(context.Background(), "v1/echo", trace.WithSpanKind(trace.SpanKindServer))
+	_, child := tp.Tracer(TracerName).Start(ctx, "echo/Say", trace.WithAttributes(attribute.Int("has.error_code", 101)))
+	child.SetStatus(codes.Error, "failed")
+	child.End()
+
+	if len(body.ResourceSpans) != 1 || len(body.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request %+v", body)
+	}
+	scope := body.ResourceSpans[0].ScopeSpans[0]
+	if scope.Scope.Name != TracerName || len(scope.Spans) != 1 {
+		t.Fatalf("unexpected scope %+v", scope)
+	}
+	s := scope.Spans[0]
+	if s.TraceID != parent.SpanContext().TraceID().String() || s.ParentSpanID != parent.SpanContext().SpanID().String() {
+		t.Errorf("span %+v not child of %s", s, parent.SpanContext().SpanID())
+	}
+	if s.Status.Code != otlpStatusError || s.Status.Message != "failed" {
+		t.Errorf("status = %+v", s.Status)
+	}
+	if len(s.Attributes) != 1 || s.Attributes[0].Value["intValue"] != "101" {
+		t.Errorf("attributes = %+v", s.Attributes)
+	}
+
+	parent.End()
+	if s := body.ResourceSpans[0].ScopeSpans[0].Spans[0]; s.Kind != int(trace.SpanKindServer) || s.ParentSpanID != "" {
+		t.Errorf("root span = %+v", s)
+	}
+
+	if _, err := NewExporter("127.0.0.1:4318"); err == nil {
+		t.Error("endpoint without scheme should fail")
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	ctx := Extract(context.Background(), tp, "k=v")
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsRemote() || !sc.IsSampled() || sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("extracted %+v", sc)
+	}
+	if parent, state := Inject(ctx); parent != tp || state != "k=v" {
+		t.Errorf("Inject() = %s, %s", parent, state)
+	}
+
+	if parent, _ := Inject(Extract(context.Background(), "invalid", "")); parent != "" {
+		t.Errorf("invalid traceparent injected as %s", parent)
+	}
+	if Enabled() {
+		t.Error("trace should be disabled without endpoint")
+	}
+}
@@ -0,0 +1,106 @@
+package htypes
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+
+// Coerce 将查询参数、表单和header中的字符串转换为typ对应的类型：Bool、Number、Integer、Time，
+// 数组类型的单个字符串转换为只有一个元素的数组，NumberArray和NumberRange的字符串元素转换为数字。
+// 其他值原样返回，由Validate检查；字符串无法转换时返回错误
+func Coerce(v interface{}, typ HType) (interface{}, error) {
+	switch typ {
+	case HTypeBool:
+		if s, ok := v.(string); ok {
+			b, err := strconv.ParseBool(strings.TrimSpace(s))
+			if err != nil {
+				return v, fmt.Errorf("cannot convert %q to Bool", s)
+			}
+			return b, nil
+		}
+	case HTypeNumber:
+		if s, ok := v.(string); ok {
+			return parseNumber(s)
+		}
+	case HTypeInteger:
+		switch val := v.(type) {
+		case string:
+			n, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+			if err != nil {
+				return v, fmt.Errorf("cannot convert %q to Integer", val)
+			}
+			return n, nil
+		case float64:
+			if val == math.Trunc(val) && math.Abs(val) < 1<<63 {
+				return int64(val), nil
+			}
+		}
+	case HTypeTime:
+		return coerceTime(v)
+	case HTypeStringArray, HTypeDateArray, HTypeDateTimeArray:
+		if s, ok := v.(string); ok {
+			return []interface{}{s}, nil
+		}
+	case HTypeNumberArray, HTypeNumberRange:
+		if s, ok := v.(string); ok && typ == HTypeNumberArray {
+			v = []interface{}{s}
+		}
+		items, ok := v.([]interface{})
+		if !ok {
+			return v, nil
+		}
+		var ret []interface{}
+		for i, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				continue
+			}
+			f, err := parseNumber(s)
+			if err != nil {
+				return v, err
+			}
+			if ret == nil {
+				ret = append([]interface{}{}, items...)
+			}
+			ret[i] = f
+		}
+		if ret != nil {
+			return ret, nil
+		}
+	}
+	return v, nil
+}
+
+func parseNumber(s string) (interface{}, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return s, fmt.Errorf("cannot convert %q to Number", s)
+	}
+	return f, nil
+}
+
+// coerceTime 没有时区的字符串按本地时间解析，数字为unix秒
+func coerceTime(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		s := strings.TrimSpace(val)
+		for _, layout := range timeLayouts {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return t, nil
+			}
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.Unix(n, 0), nil
+		}
+		return v, fmt.Errorf("cannot convert %q to Time", val)
+	case float64:
+		sec, frac := math.Modf(val)
+		return time.Unix(int64(sec), int64(frac*1e9)), nil
+	}
+	return v, nil
+}
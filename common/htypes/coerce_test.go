@@ -0,0 +1,63 @@
+package htypes
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCoerce(t *testing.T) {
+	cases := []struct {
+		v    interface{}
+		typ  HType
+		want interface{}
+	}{
+		{"true", HTypeBool, true},
+		{"0", HTypeBool, false},
+		{" 12.5 ", HTypeNumber, 12.5},
+		{"42", HTypeInteger, int64(42)},
+		{float64(42), HTypeInteger, int64(42)},
+		{float64(4.2), HTypeInteger, float64(4.2)},
+		{"a", HTypeStringArray, []interface{}{"a"}},
+		{"3", HTypeNumberArray, []interface{}{float64(3)}},
+		{[]interface{}{"1", float64(2)}, HTypeNumberRange, []interface{}{float64(1), float64(2)}},
+		{"2022-01-02T03:04:05Z", HTypeTime, time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"2022-01-02", HTypeTime, time.Date(2022, 1, 2, 0, 0, 0, 0, time.Local)},
+		{"1641092645", HTypeTime, time.Unix(1641092645, 0)},
+		{float64(1641092645), HTypeTime, time.Unix(1641092645, 0)},
+		{"abc", HTypeString, "abc"},
+		{float64(1), HTypeBool, float64(1)},
+	}
+	for _, c := range cases {
+		got, err := Coerce(c.v, c.typ)
+		if err != nil {
+			t.Errorf("Coerce(%v, %s): %v", c.v, c.typ, err)
+			continue
+		}
+		if tm, ok := c.want.(time.Time); ok {
+			if gt, ok := got.(time.Time); !ok || !gt.Equal(tm) {
+				t.Errorf("Coerce(%v, %s) = %v, want %v", c.v, c.typ, got, c.want)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Coerce(%v, %s) = %#v, want %#v", c.v, c.typ, got, c.want)
+		}
+	}
+
+	for _, c := range []struct {
+		v   interface{}
+		typ HType
+	}{
+		{"yes please", HTypeBool},
+		{"12a", HTypeNumber},
+		{"NaN", HTypeNumber},
+		{"1.5", HTypeInteger},
+		{"tomorrow", HTypeTime},
+		{[]interface{}{"1", "x"}, HTypeNumberArray},
+	} {
+		if _, err := Coerce(c.v, c.typ); err == nil {
+			t.Errorf("Coerce(%v, %s) should fail", c.v, c.typ)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package htypes
 
 import (
 	"fmt"
+	"math"
 	"reflect"
+	"time"
 
 	"github.com/drharryhe/has/utils/htext"
 )
@@ -31,6 +33,11 @@ const (
 	HTypeObjectArray   = "ObjectArray"
 )
 
+const (
+	HTypeInteger = "Integer" //整数，校验后为int64
+	HTypeTime    = "Time"    //时间，校验后为time.Time，字符串为RFC3339、yyyy-mm-dd hh:MM:ss或yyyy-mm-dd格式，数字为unix秒
+)
+
 func Validate(v interface{}, typ HType) error {
 	switch typ {
 	case HTypeString:
@@ -71,6 +78,16 @@ func Validate(v interface{}, typ HType) error {
 			return fmt.Errorf("mismatched data type, Number expected, but got [%s]", GetKindName(reflect.ValueOf(v).Kind()))
 		}
 		return nil
+	case HTypeInteger:
+		if f, ok := ToNumber(v); !ok || f != math.Trunc(f) {
+			return fmt.Errorf("mismatched data type, Integer expected, but got [%v]", v)
+		}
+		return nil
+	case HTypeTime:
+		if _, ok := v.(time.Time); !ok {
+			return fmt.Errorf("mismatched data type, Time expected, but got [%s]", GetKindName(reflect.ValueOf(v).Kind()))
+		}
+		return nil
 	case HTypeDate:
 		if reflect.ValueOf(v).Kind() != reflect.String {
 			return fmt.Errorf("mismatched data type, Date string expected, but got [%s]", GetKindName(reflect.ValueOf(v).Kind()))
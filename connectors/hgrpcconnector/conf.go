@@ -0,0 +1,15 @@
+package hgrpcconnector
+
+import "github.com/drharryhe/has/core"
+
+type GrpcConnector struct {
+	core.ConnectorConf
+
+	Port            int
+	Tls             bool
+	TlsCertPath     string
+	TlsKeyPath      string
+	AddressField    string
+	MaxMessageSize  int // MB
+	ShutdownTimeout int // seconds, 关闭时等待处理中请求完成的时长
+}
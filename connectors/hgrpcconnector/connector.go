@@ -0,0 +1,188 @@
+package hgrpcconnector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+)
+
+const (
+	ErrorCodeKey = "has-error-code" //trailer中的herrors错误码
+
+	defaultPort            = 1978
+	defaultMaxMessageSize  = 4  //MB
+	defaultShutdownTimeout = 10 //seconds
+)
+
+func New() *Connector {
+	return new(Connector)
+}
+
+type Connector struct {
+	core.BaseConnector
+
+	conf GrpcConnector
+	Grpc *grpc.Server
+}
+
+func (this *Connector) Open(gw core.IAPIGateway, ins core.IAPIConnector) *herrors.Error {
+	if err := this.BaseConnector.Open(gw, ins); err != nil {
+		return err
+	}
+
+	if this.conf.Port == 0 {
+		this.conf.Port = defaultPort
+	}
+	if this.conf.MaxMessageSize <= 0 {
+		this.conf.MaxMessageSize = defaultMaxMessageSize
+	}
+	if this.conf.ShutdownTimeout <= 0 {
+		this.conf.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(this.conf.MaxMessageSize * 1024 * 1024),
+		grpc.MaxSendMsgSize(this.conf.MaxMessageSize * 1024 * 1024),
+	}
+	if this.conf.Tls {
+		creds, err := credentials.NewServerTLSFromFile(this.conf.TlsCertPath, this.conf.TlsKeyPath)
+		if err != nil {
+			return herrors.ErrSysInternal.New(err.Error()).D("failed to load tls certificate")
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", this.conf.Port))
+	if err != nil {
+		return herrors.ErrSysInternal.New(err.Error()).D("failed to listen grpc")
+	}
+
+	this.Grpc = grpc.NewServer(opts...)
+	this.Grpc.RegisterService(&serviceDesc, this)
+
+	go func() {
+		if err := this.Grpc.Serve(ln); err != nil {
+			panic(herrors.ErrSysInternal.New(err.Error()).D("failed to serve grpc"))
+		}
+	}()
+
+	return nil
+}
+
+// Close 停止接收新请求，并在ShutdownTimeout内等待处理中的请求完成
+func (this *Connector) Close() {
+	if this.Grpc == nil {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		this.Grpc.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Duration(this.conf.ShutdownTimeout) * time.Second):
+		hlogger.Warn("grpc connector shutdown timeout after %d seconds, in-flight requests force closed", this.conf.ShutdownTimeout)
+		this.Grpc.Stop()
+	}
+
+	this.BaseConnector.Close()
+}
+
+func (this *Connector) Request(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	fields := in.GetFields()
+	version := fields[VersionField].GetStringValue()
+	api := fields[APIField].GetStringValue()
+	if version == "" || api == "" {
+		return nil, this.error(ctx, herrors.ErrCallerInvalidRequest.New("version or api not found in request").D("bad request"))
+	}
+
+	ps := make(htypes.Map)
+	if params := fields[ParamsField].GetStructValue(); params != nil {
+		for k, v := range params.AsMap() {
+			ps[k] = v
+		}
+	}
+	core.StripScope(ps)
+	if this.conf.AddressField != "" {
+		if p, ok := peer.FromContext(ctx); ok {
+			if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+				ps[this.conf.AddressField] = host
+			} else {
+				ps[this.conf.AddressField] = p.Addr.String()
+			}
+		}
+	}
+
+	//客户端取消或超过deadline时不再等待服务返回
+	ret, err := this.Gateway.RequestAPIContext(ctx, version, api, ps)
+	if err != nil && err.Code != herrors.ECodeOK {
+		return nil, this.error(ctx, err)
+	}
+
+	data, err := toValue(ret)
+	if err != nil {
+		return nil, this.error(ctx, err)
+	}
+	return &structpb.Struct{Fields: map[string]*structpb.Value{DataField: data}}, nil
+}
+
+func (this *Connector) error(ctx context.Context, err *herrors.Error) error {
+	if this.conf.Lang != "" {
+		if trans := this.Gateway.I18n(); trans != nil {
+			err = err.D(trans.Translate(this.conf.Lang, err.Desc))
+		}
+	}
+
+	_ = grpc.SetTrailer(ctx, metadata.Pairs(ErrorCodeKey, strconv.Itoa(err.Code)))
+	return toStatus(err)
+}
+
+// toValue 服务返回的数据可能是任意结构，先转成JSON通用结构再转为structpb.Value
+func toValue(data htypes.Any) (*structpb.Value, *herrors.Error) {
+	bs, err := jsoniter.Marshal(data)
+	if err != nil {
+		return nil, herrors.ErrSysInternal.New(err.Error()).D("failed to marshal response")
+	}
+
+	var v interface{}
+	if err = jsoniter.Unmarshal(bs, &v); err != nil {
+		return nil, herrors.ErrSysInternal.New(err.Error()).D("failed to marshal response")
+	}
+
+	val, err := structpb.NewValue(v)
+	if err != nil {
+		return nil, herrors.ErrSysInternal.New(err.Error()).D("failed to marshal response")
+	}
+	return val, nil
+}
+
+func (this *Connector) EntityStub() *core.EntityStub {
+	return core.NewEntityStub(
+		&core.EntityStubOptions{
+			Owner:       this,
+			Ping:        nil,
+			GetLoad:     nil,
+			ResetConfig: nil,
+		})
+}
+
+func (this *Connector) Config() core.IEntityConf {
+	return &this.conf
+}
@@ -0,0 +1,49 @@
+package hgrpcconnector
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestRequestStripScope(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "echo", "demo", "Echo").
+		Handle("demo", "Echo", htest.Return(htypes.Map{"ok": true}))
+	c := New()
+	c.Gateway = gw
+	c.conf.AddressField = "Address"
+
+	in, err := structpb.NewStruct(map[string]interface{}{
+		VersionField: "v1",
+		APIField:     "echo",
+		ParamsField: map[string]interface{}{
+			"name":          "x",
+			core.ScopeField: map[string]interface{}{core.ScopeSubject: "root", core.ScopeClaims: map[string]interface{}{"roles": []interface{}{"admin"}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}})
+	if _, err := c.Request(ctx, in); err != nil {
+		t.Fatal(err)
+	}
+
+	ps := gw.Router().(*htest.Router).LastParams("demo", "Echo")
+	htest.AssertParams(t, ps, htypes.Map{"name": "x", "Address": "10.0.0.1"})
+	//调用方传入的请求作用域被丢弃
+	if subject := core.ScopedSubject(ps); subject != "" {
+		t.Errorf("scoped subject = %s, want empty", subject)
+	}
+	if _, ok := core.Scoped(ps, core.ScopeClaims); ok {
+		t.Error("caller supplied claims kept in scope")
+	}
+}
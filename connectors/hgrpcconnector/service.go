@@ -0,0 +1,57 @@
+package hgrpcconnector
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	ServiceName = "has.Gateway"
+	MethodName  = "Request"
+	FullMethod  = "/" + ServiceName + "/" + MethodName
+
+	VersionField = "version"
+	APIField     = "api"
+	ParamsField  = "params"
+	DataField    = "data"
+)
+
+// gatewayServer 通用的一元调用接口，请求为 {version, api, params}，响应为 {data}
+type gatewayServer interface {
+	Request(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error)
+}
+
+// 不依赖protoc生成代码，手工描述服务。客户端可以用 grpc.ClientConn.Invoke(ctx, FullMethod, req, res) 调用
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*gatewayServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: MethodName,
+			Handler:    requestHandler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "has.proto",
+}
+
+func requestHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(gatewayServer).Request(ctx, in)
+	}
+
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FullMethod,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(gatewayServer).Request(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
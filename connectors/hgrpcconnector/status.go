@@ -0,0 +1,43 @@
+package hgrpcconnector
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/drharryhe/has/common/herrors"
+)
+
+var statusCodes = map[int]codes.Code{
+	herrors.ECodeOK:                       codes.OK,
+	herrors.ECodeSysInternal:              codes.Internal,
+	herrors.ECodeSysBusy:                  codes.Unavailable,
+	herrors.ECodeSysUnhandled:             codes.Unimplemented,
+	herrors.ECodeSysTimeout:               codes.DeadlineExceeded,
+	herrors.ECodeSysUnavailable:           codes.Unavailable,
+	herrors.ECodeCallerInvalidRequest:     codes.InvalidArgument,
+	herrors.ECodeCallerUnauthorizedAccess: codes.Unauthenticated,
+	herrors.ECodeCallerTooManyRequests:    codes.ResourceExhausted,
+	herrors.ECodeCallerForbidden:          codes.PermissionDenied,
+	herrors.ECodeCallerConflict:           codes.Aborted,
+	herrors.ECodeCallerGone:               codes.NotFound,
+	herrors.ECodeUserInvalidAct:           codes.FailedPrecondition,
+	herrors.ECodeUserUnauthorizedAct:      codes.PermissionDenied,
+}
+
+// toStatus 将herrors错误转换为gRPC状态，未预定义的错误码按区间映射
+func toStatus(err *herrors.Error) error {
+	code, ok := statusCodes[err.Code]
+	if !ok {
+		if err.Code < 0 || (err.Code >= 100 && err.Code < 200) {
+			code = codes.Internal
+		} else {
+			code = codes.Unknown
+		}
+	}
+
+	msg := err.Desc
+	if msg == "" {
+		msg = err.Error()
+	}
+	return status.Error(code, msg)
+}
@@ -0,0 +1,53 @@
+package hmqconnector
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	BrokerNsq = "nsq"
+)
+
+// Message 从消息中间件收到的消息，处理完成后必须调用Ack或Nack之一
+type Message interface {
+	Body() []byte
+	Attempts() int //第几次投递，从1开始
+	Ack()
+	Nack(delay time.Duration) //delay后重新投递
+}
+
+// Broker 消息中间件的订阅和发布
+type Broker interface {
+	Subscribe(topic string, concurrency int, handler func(Message)) error
+	Publish(topic string, body []byte) error
+	Close() //停止接收消息，等待已收到的消息处理完
+}
+
+// BrokerFactory 按连接器配置创建Broker
+type BrokerFactory func(conf *MqConnector) (Broker, error)
+
+var (
+	brokers    = map[string]BrokerFactory{BrokerNsq: newNsqBroker}
+	brokerLock sync.RWMutex
+)
+
+// RegisterBroker 注册其他类型的消息中间件，如 kafka，需在连接器Open之前调用
+func RegisterBroker(name string, factory BrokerFactory) {
+	brokerLock.Lock()
+	defer brokerLock.Unlock()
+
+	brokers[name] = factory
+}
+
+func newBroker(conf *MqConnector) (Broker, error) {
+	brokerLock.RLock()
+	factory := brokers[conf.Broker]
+	brokerLock.RUnlock()
+
+	if factory == nil {
+		return nil, fmt.Errorf("broker %s not supported", conf.Broker)
+	}
+	return factory(conf)
+}
@@ -0,0 +1,26 @@
+package hmqconnector
+
+import "github.com/drharryhe/has/core"
+
+type MqConnector struct {
+	core.ConnectorConf
+
+	Broker          string           // 消息中间件类型，缺省为 nsq，其他类型通过RegisterBroker注册
+	Addrs           []string         // 消息中间件地址，如nsqd的 127.0.0.1:4150，发布回复时使用第一个地址
+	LookupAddrs     []string         // nsqlookupd的HTTP地址，配置后通过其发现订阅主题的nsqd
+	Channel         string           // NSQ的channel，同一channel的多个实例分摊消息，缺省为 has
+	Subscriptions   []MqSubscription // 订阅的主题及其对应的API
+	Concurrency     int              // 每个订阅同时处理的消息数，缺省为 4
+	MaxAttempts     int              // 可重试的错误最多处理次数，之后确认消息并记录日志，缺省为 5
+	RetryDelay      int              // milliseconds, 重新投递的延迟，缺省为 1000
+	ShutdownTimeout int              // seconds, 关闭时等待处理中消息完成的时长，缺省为 10
+}
+
+// MqSubscription 主题与API的对应关系。配置了Version和API时消息体为API的参数，
+// 否则消息体为 {"id": "1", "version": "v1", "api": "echo", "params": {}, "reply": "主题"}
+type MqSubscription struct {
+	Topic      string
+	Version    string
+	API        string
+	ReplyTopic string // 处理结果发布到的主题，消息中的reply优先，都为空时不回复
+}
@@ -0,0 +1,248 @@
+package hmqconnector
+
+/// 消息中间件connector，订阅主题，将消息作为API请求交给服务处理，处理结果可发布到回复主题
+
+import (
+	"context"
+	"time"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/hpaging"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/utils/hrandom"
+	"github.com/drharryhe/has/utils/hruntime"
+)
+
+const (
+	defaultChannel         = "has"
+	defaultConcurrency     = 4
+	defaultMaxAttempts     = 5
+	defaultRetryDelay      = 1000 //milliseconds
+	defaultShutdownTimeout = 10   //seconds
+)
+
+// RequestMessage 未配置Version和API的订阅中的消息
+type RequestMessage struct {
+	ID      string     `json:"id"`
+	Version string     `json:"version"`
+	API     string     `json:"api"`
+	Params  htypes.Map `json:"params"`
+	Reply   string     `json:"reply"` //回复主题，优先于订阅的ReplyTopic
+}
+
+// ResponseMessage 发布到回复主题的处理结果，ID与请求消息对应
+type ResponseMessage struct {
+	ID    string         `json:"id"`
+	Data  htypes.Any     `json:"data"`
+	Page  *hpaging.Page  `json:"page,omitempty"`
+	Error *herrors.Error `json:"error"`
+}
+
+func New() *Connector {
+	return new(Connector)
+}
+
+type Connector struct {
+	core.BaseConnector
+
+	conf   MqConnector
+	broker Broker
+	ctx    context.Context //关闭超时后取消处理中的请求
+	cancel context.CancelFunc
+}
+
+func (this *Connector) Open(gw core.IAPIGateway, ins core.IAPIConnector) *herrors.Error {
+	if err := this.BaseConnector.Open(gw, ins); err != nil {
+		return err
+	}
+
+	applyDefaults(&this.conf)
+	broker, err := newBroker(&this.conf)
+	if err != nil {
+		return herrors.ErrSysInternal.New(err.Error()).D("failed to open mq connector")
+	}
+	this.broker = broker
+	this.ctx, this.cancel = context.WithCancel(context.Background())
+
+	for i := range this.conf.Subscriptions {
+		sub := this.conf.Subscriptions[i]
+		if sub.Topic == "" {
+			this.broker.Close()
+			return herrors.ErrSysInternal.New("topic of subscription %d not configured", i).D("failed to open mq connector")
+		}
+		if err := this.broker.Subscribe(sub.Topic, this.conf.Concurrency, func(m Message) {
+			this.handleMessage(&sub, m)
+		}); err != nil {
+			this.broker.Close()
+			return herrors.ErrSysInternal.New("subscribe %s: %s", sub.Topic, err.Error()).D("failed to open mq connector")
+		}
+	}
+	return nil
+}
+
+func applyDefaults(conf *MqConnector) {
+	if conf.Broker == "" {
+		conf.Broker = BrokerNsq
+	}
+	if conf.Channel == "" {
+		conf.Channel = defaultChannel
+	}
+	if conf.Concurrency <= 0 {
+		conf.Concurrency = defaultConcurrency
+	}
+	if conf.MaxAttempts <= 0 {
+		conf.MaxAttempts = defaultMaxAttempts
+	}
+	if conf.RetryDelay <= 0 {
+		conf.RetryDelay = defaultRetryDelay
+	}
+	if conf.ShutdownTimeout <= 0 {
+		conf.ShutdownTimeout = defaultShutdownTimeout
+	}
+}
+
+// Close 停止接收消息，在ShutdownTimeout内等待处理中的消息完成，超时后取消处理中的请求
+func (this *Connector) Close() {
+	if this.broker == nil {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		this.broker.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Duration(this.conf.ShutdownTimeout) * time.Second):
+		hlogger.Warn("mq connector shutdown timeout after %d seconds, in-flight messages canceled", this.conf.ShutdownTimeout)
+	}
+	this.cancel()
+
+	this.BaseConnector.Close()
+}
+
+// handleMessage 服务处理成功或返回不可重试的错误时确认消息，返回系统错误或请求过于频繁时重新投递，
+// 达到MaxAttempts后确认消息并记录日志。最终结果发布到回复主题
+func (this *Connector) handleMessage(sub *MqSubscription, m Message) {
+	req, err := this.parseMessage(sub, m.Body())
+	if err != nil {
+		hlogger.Error("mq connector: invalid message from %s: %s", sub.Topic, err.Error())
+		this.reply(req, nil, err)
+		m.Ack()
+		return
+	}
+
+	ret, err := this.Gateway.RequestAPIContext(this.ctx, req.Version, req.API, req.Params)
+	if err != nil && err.Code == herrors.ECodeOK {
+		err = nil
+	}
+	if err != nil && retryable(err) {
+		if m.Attempts() < this.conf.MaxAttempts {
+			m.Nack(time.Duration(this.conf.RetryDelay) * time.Millisecond)
+			return
+		}
+		hlogger.Error("mq connector: message %s from %s failed after %d attempts: %s", req.ID, sub.Topic, m.Attempts(), err.Error())
+	}
+
+	this.reply(req, ret, err)
+	m.Ack()
+}
+
+func retryable(err *herrors.Error) bool {
+	return err.Code < 200 || err.Code == herrors.ECodeCallerTooManyRequests
+}
+
+// parseMessage 解析失败时返回的RequestMessage只带有订阅的回复主题
+func (this *Connector) parseMessage(sub *MqSubscription, body []byte) (*RequestMessage, *herrors.Error) {
+	req := &RequestMessage{Version: sub.Version, API: sub.API, Reply: sub.ReplyTopic}
+	val, err := this.Packer.Unmarshal(body)
+	if err != nil {
+		return req, err
+	}
+	m, ok := val.(map[string]interface{})
+	if !ok {
+		if hm, isMap := val.(htypes.Map); isMap {
+			m, ok = hm, true
+		}
+	}
+	if !ok {
+		return req, herrors.ErrCallerInvalidRequest.New("message should be an object").D("bad request")
+	}
+
+	if sub.Version != "" && sub.API != "" {
+		req.Params = m
+	} else {
+		req.ID, _ = m["id"].(string)
+		req.Version, _ = m["version"].(string)
+		req.API, _ = m["api"].(string)
+		if reply, _ := m["reply"].(string); reply != "" {
+			req.Reply = reply
+		}
+		switch ps := m["params"].(type) {
+		case map[string]interface{}:
+			req.Params = ps
+		case htypes.Map:
+			req.Params = ps
+		}
+		if req.Version == "" || req.API == "" {
+			return req, herrors.ErrCallerInvalidRequest.New("version or api not found in message").D("bad request")
+		}
+	}
+	if req.Params == nil {
+		req.Params = make(htypes.Map)
+	}
+	core.StripScope(req.Params)
+	if req.ID == "" {
+		req.ID = hrandom.UuidWithoutDash()
+	}
+	req.Params[core.RequestIDField] = req.ID
+	core.SetScoped(req.Params, core.ScopeRequestID, req.ID)
+	return req, nil
+}
+
+func (this *Connector) reply(req *RequestMessage, data htypes.Any, err *herrors.Error) {
+	if req.Reply == "" {
+		return
+	}
+
+	res := &ResponseMessage{ID: req.ID, Data: data, Error: err}
+	if list, ok := data.(*hpaging.List); ok {
+		res.Data, res.Page = list.Items, &list.Page
+	} else if data == nil || hruntime.IsNil(data) {
+		res.Data = htypes.Map{}
+	}
+	if err == nil {
+		res.Error = &herrors.Error{Code: herrors.ECodeOK}
+	} else if this.conf.Lang != "" {
+		if trans := this.Gateway.I18n(); trans != nil {
+			res.Error = core.TranslateFields(trans, err, this.conf.Lang)
+			res.Error = res.Error.D(trans.Translate(this.conf.Lang, res.Error.Desc))
+		}
+	}
+
+	bs, e := this.Packer.Marshal(res)
+	if e != nil {
+		hlogger.Error(e.D("mq connector: failed to marshal reply %s", req.ID))
+		return
+	}
+	if err := this.broker.Publish(req.Reply, bs); err != nil {
+		hlogger.Error("mq connector: failed to publish reply %s to %s: %s", req.ID, req.Reply, err.Error())
+	}
+}
+
+func (this *Connector) EntityStub() *core.EntityStub {
+	return core.NewEntityStub(
+		&core.EntityStubOptions{
+			Owner:       this,
+			Ping:        nil,
+			GetLoad:     nil,
+			ResetConfig: nil,
+		})
+}
+
+func (this *Connector) Config() core.IEntityConf {
+	return &this.conf
+}
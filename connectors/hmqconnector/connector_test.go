@@ -0,0 +1,152 @@
+package hmqconnector
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/core/htest"
+)
+
+type fakeMessage struct {
+	body     []byte
+	attempts int
+	acked    bool
+	nacked   time.Duration
+}
+
+func (this *fakeMessage) Body() []byte             { return this.body }
+func (this *fakeMessage) Attempts() int            { return this.attempts }
+func (this *fakeMessage) Ack()                     { this.acked = true }
+func (this *fakeMessage) Nack(delay time.Duration) { this.nacked = delay }
+
+type fakeBroker struct {
+	lock      sync.Mutex
+	published map[string][][]byte
+}
+
+func (this *fakeBroker) Subscribe(topic string, concurrency int, handler func(Message)) error {
+	return nil
+}
+
+func (this *fakeBroker) Publish(topic string, body []byte) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.published[topic] = append(this.published[topic], body)
+	return nil
+}
+
+func (this *fakeBroker) Close() {}
+
+func (this *fakeBroker) reply(t *testing.T, topic string) map[string]interface{} {
+	t.Helper()
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if len(this.published[topic]) != 1 {
+		t.Fatalf("replies to %s = %d, want 1", topic, len(this.published[topic]))
+	}
+	var res map[string]interface{}
+	if err := json.Unmarshal(this.published[topic][0], &res); err != nil {
+		t.Fatal(err)
+	}
+	this.published[topic] = nil
+	return res
+}
+
+func newTestConnector(gw *htest.Gateway) (*Connector, *fakeBroker) {
+	broker := &fakeBroker{published: make(map[string][][]byte)}
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	applyDefaults(&c.conf)
+	c.broker = broker
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	return c, broker
+}
+
+func TestHandleMessage(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "order.create", "order", "Create").
+		Handle("order", "Create", htest.Return(map[string]interface{}{"ok": true}))
+	c, broker := newTestConnector(gw)
+	sub := &MqSubscription{Topic: "orders", Version: "v1", API: "order.create", ReplyTopic: "orders.reply"}
+
+	m := &fakeMessage{body: []byte(`{"sku":"a1"}`), attempts: 1}
+	c.handleMessage(sub, m)
+	if !m.acked {
+		t.Fatal("message not acked")
+	}
+	htest.AssertParam(t, gw.LastCall().Params, "sku", "a1")
+	res := broker.reply(t, "orders.reply")
+	if res["id"] != gw.LastCall().Params[core.RequestIDField] || res["id"] == "" {
+		t.Fatalf("reply id = %v", res["id"])
+	}
+	if data, _ := res["data"].(map[string]interface{}); data["ok"] != true {
+		t.Fatalf("reply data = %v", res["data"])
+	}
+	if e, _ := res["error"].(map[string]interface{}); e["code"] != float64(herrors.ECodeOK) {
+		t.Fatalf("reply error = %v", res["error"])
+	}
+}
+
+func TestHandleMessageEnvelope(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "order.create", "order", "Create").
+		Handle("order", "Create", htest.Return("ok"))
+	c, broker := newTestConnector(gw)
+	sub := &MqSubscription{Topic: "requests"}
+
+	m := &fakeMessage{body: []byte(`{"id":"r1","version":"v1","api":"order.create","params":{"sku":"a1"},"reply":"inbox"}`), attempts: 1}
+	c.handleMessage(sub, m)
+	htest.AssertParam(t, gw.LastCall().Params, "sku", "a1")
+	if res := broker.reply(t, "inbox"); res["id"] != "r1" || res["data"] != "ok" {
+		t.Fatalf("reply = %v", res)
+	}
+
+	m = &fakeMessage{body: []byte(`{"id":"r2","params":{}}`), attempts: 1}
+	c.handleMessage(&MqSubscription{Topic: "requests", ReplyTopic: "errors"}, m)
+	if !m.acked {
+		t.Fatal("bad message not acked")
+	}
+	if e, _ := broker.reply(t, "errors")["error"].(map[string]interface{}); e["code"] != float64(herrors.ECodeCallerInvalidRequest) {
+		t.Fatalf("reply error = %v", e)
+	}
+}
+
+func TestHandleMessageRetry(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "order.create", "order", "Create").
+		Handle("order", "Create", htest.Fail(herrors.ErrSysUnavailable.New("db down")))
+	c, broker := newTestConnector(gw)
+	c.conf.MaxAttempts = 2
+	sub := &MqSubscription{Topic: "orders", Version: "v1", API: "order.create", ReplyTopic: "orders.reply"}
+
+	m := &fakeMessage{body: []byte(`{}`), attempts: 1}
+	c.handleMessage(sub, m)
+	if m.acked || m.nacked != time.Duration(defaultRetryDelay)*time.Millisecond {
+		t.Fatalf("acked = %v, nacked = %v", m.acked, m.nacked)
+	}
+	if len(broker.published["orders.reply"]) != 0 {
+		t.Fatal("replied before final attempt")
+	}
+
+	m = &fakeMessage{body: []byte(`{}`), attempts: 2}
+	c.handleMessage(sub, m)
+	if !m.acked || m.nacked != 0 {
+		t.Fatalf("acked = %v, nacked = %v", m.acked, m.nacked)
+	}
+	if e, _ := broker.reply(t, "orders.reply")["error"].(map[string]interface{}); e["code"] != float64(herrors.ECodeSysUnavailable) {
+		t.Fatalf("reply error = %v", e)
+	}
+
+	gw.Handle("order", "Create", htest.Fail(herrors.ErrCallerInvalidRequest.New("bad sku")))
+	m = &fakeMessage{body: []byte(`{}`), attempts: 1}
+	c.handleMessage(sub, m)
+	if !m.acked || m.nacked != 0 {
+		t.Fatalf("caller error: acked = %v, nacked = %v", m.acked, m.nacked)
+	}
+}
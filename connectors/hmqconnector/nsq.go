@@ -0,0 +1,100 @@
+package hmqconnector
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nsqio/go-nsq"
+)
+
+type nsqBroker struct {
+	conf      *MqConnector
+	producer  *nsq.Producer
+	consumers []*nsq.Consumer
+}
+
+type nsqMessage struct {
+	msg *nsq.Message
+}
+
+func (this *nsqMessage) Body() []byte {
+	return this.msg.Body
+}
+
+func (this *nsqMessage) Attempts() int {
+	return int(this.msg.Attempts)
+}
+
+func (this *nsqMessage) Ack() {
+	this.msg.Finish()
+}
+
+func (this *nsqMessage) Nack(delay time.Duration) {
+	this.msg.RequeueWithoutBackoff(delay)
+}
+
+func newNsqBroker(conf *MqConnector) (Broker, error) {
+	if len(conf.Addrs) == 0 && len(conf.LookupAddrs) == 0 {
+		return nil, fmt.Errorf("nsq addrs not configured")
+	}
+
+	b := &nsqBroker{conf: conf}
+	if len(conf.Addrs) > 0 {
+		producer, err := nsq.NewProducer(conf.Addrs[0], nsq.NewConfig())
+		if err != nil {
+			return nil, err
+		}
+		producer.SetLogger(nil, nsq.LogLevelError)
+		b.producer = producer
+	}
+	return b, nil
+}
+
+// Subscribe 关闭自动响应，由连接器按处理结果确认或重新投递
+func (this *nsqBroker) Subscribe(topic string, concurrency int, handler func(Message)) error {
+	cfg := nsq.NewConfig()
+	cfg.MaxInFlight = concurrency
+	//重试次数由连接器控制
+	cfg.MaxAttempts = 0
+	consumer, err := nsq.NewConsumer(topic, this.conf.Channel, cfg)
+	if err != nil {
+		return err
+	}
+	consumer.SetLogger(nil, nsq.LogLevelError)
+	consumer.AddConcurrentHandlers(nsq.HandlerFunc(func(m *nsq.Message) error {
+		m.DisableAutoResponse()
+		handler(&nsqMessage{msg: m})
+		return nil
+	}), concurrency)
+
+	if len(this.conf.LookupAddrs) > 0 {
+		err = consumer.ConnectToNSQLookupds(this.conf.LookupAddrs)
+	} else {
+		err = consumer.ConnectToNSQDs(this.conf.Addrs)
+	}
+	if err != nil {
+		consumer.Stop()
+		return err
+	}
+	this.consumers = append(this.consumers, consumer)
+	return nil
+}
+
+func (this *nsqBroker) Publish(topic string, body []byte) error {
+	if this.producer == nil {
+		return fmt.Errorf("nsq addrs not configured, cannot publish to %s", topic)
+	}
+	return this.producer.Publish(topic, body)
+}
+
+func (this *nsqBroker) Close() {
+	for _, c := range this.consumers {
+		c.Stop()
+	}
+	for _, c := range this.consumers {
+		<-c.StopChan
+	}
+	if this.producer != nil {
+		this.producer.Stop()
+	}
+}
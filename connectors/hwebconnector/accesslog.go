@@ -0,0 +1,65 @@
+package hwebconnector
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+)
+
+const (
+	AccessLogText = "text"
+	AccessLogJson = "json"
+)
+
+type accessLog struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Version string `json:"version,omitempty"`
+	API     string `json:"api,omitempty"`
+	IP      string `json:"ip"`
+	Status  int    `json:"status"`
+	Size    int    `json:"size"`
+	Latency string `json:"latency"`
+	Code    int    `json:"code"`
+	ID      string `json:"request_id,omitempty"`
+}
+
+// handleAccessLog 记录每个请求的访问日志，耗时包含参数解析和API处理的全过程
+func (this *Connector) handleAccessLog(c *fiber.Ctx) error {
+	start := time.Now()
+	err := c.Next()
+
+	l := accessLog{
+		Method:  c.Method(),
+		Path:    c.Path(),
+		Version: c.Params("version"),
+		API:     c.Params("api"),
+		IP:      this.clientIP(c),
+		Status:  c.Response().StatusCode(),
+		Latency: time.Since(start).String(),
+		Code:    herrors.ECodeOK,
+	}
+	//文件流不能调用Body()，否则会把整个流读入内存
+	if c.Response().IsBodyStream() {
+		l.Size = c.Response().Header.ContentLength()
+	} else {
+		l.Size = len(c.Response().Body())
+	}
+	if code, ok := c.Locals(errorCodeKey).(int); ok {
+		l.Code = code
+	}
+	l.ID, _ = c.Locals(requestIDKey).(string)
+
+	if this.config().AccessLogFormat == AccessLogJson {
+		bs, _ := jsoniter.Marshal(&l)
+		hlogger.Info(string(bs))
+	} else {
+		hlogger.Info("%s %s %s/%s %s %d %dB %s code=%d id=%s", l.Method, l.Path, l.Version, l.API, l.IP, l.Status, l.Size, l.Latency, l.Code, l.ID)
+	}
+
+	return err
+}
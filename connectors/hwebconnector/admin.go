@@ -0,0 +1,179 @@
+package hwebconnector
+
+import (
+	"crypto/subtle"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/hconf"
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/utils/hruntime"
+)
+
+const (
+	adminTokenHeader = "X-Admin-Token"
+)
+
+type adminService struct {
+	Name     string      `json:"name"`
+	Class    string      `json:"class"`
+	Disabled bool        `json:"disabled"`
+	Slots    []adminSlot `json:"slots"`
+}
+
+type adminSlot struct {
+	Name     string       `json:"name"`
+	Desc     string       `json:"desc"`
+	Disabled bool         `json:"disabled"`
+	Lang     string       `json:"lang"`
+	Impl     string       `json:"impl"`
+	NoRetry  bool         `json:"no_retry"`
+	Params   []adminParam `json:"params"`
+}
+
+type adminParam struct {
+	Name            string       `json:"name"`
+	Desc            string       `json:"desc"`
+	Format          string       `json:"format"`
+	Type            htypes.HType `json:"type"`
+	Required        bool         `json:"required"`
+	CaseInSensitive bool         `json:"case_insensitive"`
+	Validator       string       `json:"validator"`
+	Default         string       `json:"default"`
+}
+
+// handleAdminServices 列出所有已注册的服务及其slot，仅在Debug模式下可用
+func (this *Connector) handleAdminServices(c *fiber.Ctx) error {
+	if !this.checkAdmin(c) {
+		return nil
+	}
+
+	services := this.Gateway.Server().Services()
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ret := make([]adminService, 0, len(names))
+	for _, name := range names {
+		ret = append(ret, newAdminService(name, services[name]))
+	}
+
+	this.SendResponse(c, ret, nil)
+	return nil
+}
+
+// handleAdminService 查看单个服务的slot及参数定义，仅在Debug模式下可用
+func (this *Connector) handleAdminService(c *fiber.Ctx) error {
+	if !this.checkAdmin(c) {
+		return nil
+	}
+
+	name := c.Params("service")
+	s := this.Gateway.Server().Services()[name]
+	if s == nil {
+		this.SendResponse(c, nil, herrors.ErrCallerInvalidRequest.New("service %s not found", name).D("service not found"))
+		return nil
+	}
+
+	this.SendResponse(c, newAdminService(name, s), nil)
+	return nil
+}
+
+// handleAdminLoad 本地服务器、连接器等实体的负载，仅在Debug模式下可用
+func (this *Connector) handleAdminLoad(c *fiber.Ctx) error {
+	if !this.checkAdmin(c) {
+		return nil
+	}
+
+	this.SendResponse(c, this.Gateway.Server().Router().Loads(), nil)
+	return nil
+}
+
+// handleAdminHealth 本地实体(路由、插件、服务等)Ping的结果，仅在Debug模式下可用
+func (this *Connector) handleAdminHealth(c *fiber.Ctx) error {
+	if !this.checkAdmin(c) {
+		return nil
+	}
+
+	this.SendResponse(c, this.Gateway.Server().Health(), nil)
+	return nil
+}
+
+// handleAdminBuild 构建信息(版本、git commit、构建时间、Go版本)、GOMAXPROCS、启动时间和运行时长，仅在Debug模式下可用
+func (this *Connector) handleAdminBuild(c *fiber.Ctx) error {
+	if !this.checkAdmin(c) {
+		return nil
+	}
+
+	this.SendResponse(c, this.Gateway.Server().BuildInfo(), nil)
+	return nil
+}
+
+func (this *Connector) checkAdmin(c *fiber.Ctx) bool {
+	return this.checkAdminToken(c, c.Get(adminTokenHeader))
+}
+
+func (this *Connector) checkAdminToken(c *fiber.Ctx, token string) bool {
+	conf := this.config()
+	if !hconf.IsDebug() {
+		_ = c.SendString("admin api not available")
+		return false
+	}
+
+	if conf.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(conf.AdminToken)) != 1 {
+		this.SendResponse(c, nil, herrors.ErrCallerUnauthorizedAccess.New("invalid admin token").D("unauthorized access"))
+		return false
+	}
+	return true
+}
+
+func newAdminService(name string, s core.IService) adminService {
+	ret := adminService{
+		Name:  name,
+		Class: hruntime.GetObjectName(s),
+		Slots: []adminSlot{},
+	}
+	if e, ok := s.(core.IEntity); ok {
+		ret.Class = e.Class()
+		ret.Disabled = e.Config().GetDisabled()
+	}
+
+	names := s.SlotNames()
+	sort.Strings(names)
+	for _, n := range names {
+		slot := s.Slot(n)
+		if slot == nil {
+			continue
+		}
+
+		as := adminSlot{
+			Name:     slot.Name,
+			Desc:     slot.Desc,
+			Disabled: slot.Disabled,
+			Lang:     slot.Lang,
+			Impl:     slot.Impl,
+			NoRetry:  slot.NoRetry,
+			Params:   []adminParam{},
+		}
+		for _, p := range slot.Params {
+			as.Params = append(as.Params, adminParam{
+				Name:            p.Name,
+				Desc:            p.Desc,
+				Format:          p.Format,
+				Type:            p.Type,
+				Required:        p.Required,
+				CaseInSensitive: p.CaseInSensitive,
+				Validator:       p.Validator,
+				Default:         p.Default,
+			})
+		}
+		ret.Slots = append(ret.Slots, as)
+	}
+
+	return ret
+}
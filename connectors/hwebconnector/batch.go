@@ -0,0 +1,184 @@
+package hwebconnector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+)
+
+const (
+	defaultBatchPath        = "/batch"
+	defaultBatchConcurrency = 8
+	defaultBatchMaxCalls    = 50
+)
+
+// batchCall 批量请求中的一次调用，如 {"version":"v1","api":"user","params":{"id":1}}
+type batchCall struct {
+	Version string
+	API     string
+	Params  htypes.Map
+}
+
+// handleBatch 请求体为调用数组，按顺序返回每次调用的ResponseData，单次调用失败不影响其他调用。
+// 每次调用单独校验JWT，不支持文件下载、按流读取请求体和Idempotency-Key
+func (this *Connector) handleBatch(c *fiber.Ctx) error {
+	conf := this.config()
+	this.load.Begin()
+	defer this.load.End()
+	requestID := this.requestID(c)
+
+	limit := conf.BodyLimit * 1024 * 1024
+	if l, err := readBodyLimit(c, limit); err != nil {
+		this.SendResponse(c, nil, err)
+		return nil
+	} else if l > limit {
+		this.SendResponse(c, nil, herrors.ErrCallerInvalidRequest.New("request body size %d exceeds limit %d of batch", l, limit).D("request body too large"))
+		return nil
+	}
+
+	calls, err := this.parseBatchCalls(c)
+	if err != nil {
+		this.SendResponse(c, nil, err)
+		return nil
+	}
+
+	headers := make(htypes.Map)
+	_ = this.ParseHeaderParams(c, headers)
+	//会话和租户对所有调用有效，与header参数一起覆盖调用方传入的同名参数
+	if err = this.loadSession(c, headers); err != nil {
+		this.SendResponse(c, nil, err)
+		return nil
+	}
+	if err = this.resolveTenant(c, headers); err != nil {
+		this.SendResponse(c, nil, err)
+		return nil
+	}
+	address := this.clientIP(c)
+	ctx, cancel := this.requestContext(c, requestID)
+	defer cancel()
+
+	rets := make([]htypes.Any, len(calls))
+	errs := make([]*herrors.Error, len(calls))
+	sem := make(chan struct{}, conf.BatchConcurrency)
+	var wg sync.WaitGroup
+	for i := range calls {
+		ps := make(htypes.Map, len(calls[i].Params)+len(headers)+2)
+		for k, v := range calls[i].Params {
+			ps[k] = v
+		}
+		core.StripScope(ps)
+		for k, v := range headers {
+			if k != core.ScopeField {
+				ps[k] = v
+			}
+		}
+		//每个调用使用单独的作用域，避免并发调用共用同一个map
+		for k, v := range core.ScopedValues(headers) {
+			core.SetScoped(ps, k, v)
+		}
+		//JWT的subject和claims覆盖调用方传入的同名参数
+		if errs[i] = this.verifyJwt(c, calls[i].Version, calls[i].API, ps); errs[i] != nil {
+			continue
+		}
+		ps[conf.AddressField] = address
+		ps[core.RequestIDField] = requestID
+		core.SetScoped(ps, core.ScopeAddress, address)
+		core.SetScoped(ps, core.ScopeRequestID, requestID)
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, ps htypes.Map) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			rets[i], errs[i] = this.requestBatchCall(ctx, &calls[i], ps)
+		}(i, ps)
+	}
+	wg.Wait()
+
+	//翻译错误信息需要读取请求header，在所有调用完成后进行
+	results := make([]htypes.Any, len(calls))
+	for i := range calls {
+		if errs[i] != nil && errs[i].Code != herrors.ECodeOK {
+			errs[i] = this.translate(c, errs[i])
+		}
+		results[i] = this.envelope(NewResponseData(rets[i], errs[i]))
+	}
+
+	this.SendResponse(c, results, nil)
+	return nil
+}
+
+func (this *Connector) requestBatchCall(ctx context.Context, call *batchCall, ps htypes.Map) (htypes.Any, *herrors.Error) {
+	start := time.Now()
+	ret, err := this.Gateway.RequestAPIContext(ctx, call.Version, call.API, ps)
+	if err == nil {
+		this.invalidateCache(call.Version, call.API, ps)
+		if val, ok := ret.(htypes.Map); ok && (val[DownloadFlag] != nil || val[PreviewFlag] != nil) {
+			ret, err = nil, herrors.ErrCallerInvalidRequest.New("api %s/%s returns file, not supported in batch", call.Version, call.API).D("bad request")
+		} else if closeNDJSON(ret) {
+			ret, err = nil, herrors.ErrCallerInvalidRequest.New("api %s/%s returns stream, not supported in batch", call.Version, call.API).D("bad request")
+		}
+	}
+
+	if this.config().Metrics {
+		code := herrors.ECodeOK
+		if err != nil {
+			code = err.Code
+		}
+		observeAPI(call.Version, call.API, code, start)
+	}
+	return ret, err
+}
+
+// parseBatchCalls 请求体可以是JSON或ContentPackers中配置的格式
+func (this *Connector) parseBatchCalls(c *fiber.Ctx) ([]batchCall, *herrors.Error) {
+	conf := this.config()
+	var val interface{}
+	if packer := this.requestPacker(c); packer != nil {
+		v, err := packer.Unmarshal(c.Body())
+		if err != nil {
+			return nil, herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to parse body")
+		}
+		val = v
+	} else if err := jsoniter.Unmarshal(c.Body(), &val); err != nil {
+		return nil, jsonBodyError(c.Body(), err, "request body should be an array")
+	}
+
+	items, ok := val.([]interface{})
+	if !ok {
+		return nil, herrors.ErrCallerInvalidRequest.New("request body should be an array").D("failed to parse body")
+	}
+	if len(items) > conf.BatchMaxCalls {
+		return nil, herrors.ErrCallerInvalidRequest.New("too many calls %d, limit %d", len(items), conf.BatchMaxCalls).D("batch too large")
+	}
+
+	calls := make([]batchCall, len(items))
+	for i, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, herrors.ErrCallerInvalidRequest.New("call %d should be a map", i).D("failed to parse body")
+		}
+		calls[i].Version, _ = m["version"].(string)
+		calls[i].API, _ = m["api"].(string)
+		if calls[i].Version == "" || calls[i].API == "" {
+			return nil, herrors.ErrCallerInvalidRequest.New("version or api of call %d not specified", i).D("failed to parse body")
+		}
+		if m["params"] != nil {
+			ps, ok := m["params"].(map[string]interface{})
+			if !ok {
+				return nil, herrors.ErrCallerInvalidRequest.New("params of call %d should be a map", i).D("failed to parse body")
+			}
+			calls[i].Params = ps
+		}
+	}
+	return calls, nil
+}
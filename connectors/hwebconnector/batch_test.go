@@ -0,0 +1,75 @@
+package hwebconnector
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestHandleBatch(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "user", "demo", "User").
+		Route("v1", "busy", "demo", "Busy").
+		Handle("demo", "User", func(ps htypes.Map) (htypes.Any, *herrors.Error) {
+			return htypes.Map{"id": ps["id"]}, nil
+		}).
+		Handle("demo", "Busy", htest.Fail(herrors.ErrSysBusy.New("busy")))
+
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.BatchMaxCalls = 3
+	applyDefaults(&c.conf)
+	app := fiber.New()
+	app.Post(c.conf.BatchPath, c.handleBatch)
+
+	batch := func(body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("POST", "/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res map[string]interface{}
+		bs, _ := ioutil.ReadAll(resp.Body)
+		_ = jsoniter.Unmarshal(bs, &res)
+		return resp.StatusCode, res
+	}
+
+	status, res := batch(`[{"version":"v1","api":"user","params":{"id":1}},{"version":"v1","api":"busy"},{"version":"v1","api":"user","params":{"id":2}}]`)
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	results, _ := res["data"].([]interface{})
+	if len(results) != 3 {
+		t.Fatalf("results = %v", res)
+	}
+	for i, want := range []interface{}{float64(1), nil, float64(2)} {
+		r := results[i].(map[string]interface{})
+		code := r["error"].(map[string]interface{})["code"]
+		if want == nil {
+			if code != float64(herrors.ECodeSysBusy) {
+				t.Errorf("call %d code = %v, want %d", i, code, herrors.ECodeSysBusy)
+			}
+			continue
+		}
+		if id := r["data"].(map[string]interface{})["id"]; id != want || code != float64(herrors.ECodeOK) {
+			t.Errorf("call %d = %v, want id %v", i, r, want)
+		}
+	}
+
+	if status, _ = batch(`{"version":"v1","api":"user"}`); status != fiber.StatusBadRequest {
+		t.Errorf("non-array body: status = %d, want 400", status)
+	}
+	if status, _ = batch(`[{},{},{},{}]`); status != fiber.StatusBadRequest {
+		t.Errorf("too many calls: status = %d, want 400", status)
+	}
+}
@@ -0,0 +1,139 @@
+package hwebconnector
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drharryhe/has/common/hconf"
+	"github.com/drharryhe/has/common/hlogger"
+)
+
+const (
+	redactedValue = "***"
+)
+
+var defaultBodyLogRedact = []string{"password", "secret", "token"}
+
+var bodyLogJson = jsoniter.Config{UseNumber: true}.Froze()
+
+// handleBodyLog 调试时记录请求和响应体，只在Debug模式下生效。
+// 字段名包含BodyLogRedact中任一项(不区分大小写)的值被替换为***，超过BodyLogMaxSize的部分被截断
+func (this *Connector) handleBodyLog(c *fiber.Ctx) error {
+	if !hconf.IsDebug() {
+		return c.Next()
+	}
+
+	//按流读取的请求体不能调用Body()，需在Next之前记录
+	req := this.requestBodyLog(c)
+	err := c.Next()
+
+	id, _ := c.Locals(requestIDKey).(string)
+	hlogger.Info("body %s %s id=%s request=%s response=%s", c.Method(), c.Path(), id, req, this.responseBodyLog(c))
+	return err
+}
+
+func (this *Connector) requestBodyLog(c *fiber.Ctx) string {
+	conf := this.config()
+	var b strings.Builder
+	if q := c.Request().URI().QueryString(); len(q) > 0 {
+		b.WriteString("?")
+		b.WriteString(redactForm(q, conf.BodyLogRedact))
+		b.WriteString(" ")
+	}
+
+	ct := string(c.Request().Header.ContentType())
+	switch {
+	case c.Request().IsBodyStream():
+		b.WriteString(fmt.Sprintf("<stream %s>", ct))
+	case len(c.Body()) == 0:
+		b.WriteString("<empty>")
+	case strings.HasPrefix(ct, fiber.MIMEApplicationJSON):
+		b.Write(redactJson(c.Body(), conf.BodyLogRedact))
+	case strings.HasPrefix(ct, fiber.MIMEApplicationForm):
+		b.WriteString(redactForm(c.Body(), conf.BodyLogRedact))
+	default:
+		b.WriteString(fmt.Sprintf("<%s %dB>", ct, len(c.Body())))
+	}
+	return truncateBodyLog(b.String(), conf.BodyLogMaxSize)
+}
+
+func (this *Connector) responseBodyLog(c *fiber.Ctx) string {
+	conf := this.config()
+	res := c.Response()
+	ct := string(res.Header.ContentType())
+	switch {
+	case res.IsBodyStream():
+		return fmt.Sprintf("<stream %s>", ct)
+	case len(res.Body()) == 0:
+		return "<empty>"
+	case strings.HasPrefix(ct, fiber.MIMEApplicationJSON) && len(res.Header.Peek(fiber.HeaderContentEncoding)) == 0:
+		return truncateBodyLog(string(redactJson(res.Body(), conf.BodyLogRedact)), conf.BodyLogMaxSize)
+	default:
+		return fmt.Sprintf("<%s %dB>", ct, len(res.Body()))
+	}
+}
+
+// redactJson 替换敏感字段的值，无法解析的JSON原样返回
+func redactJson(body []byte, keys []string) []byte {
+	var v interface{}
+	if err := bodyLogJson.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	bs, err := bodyLogJson.Marshal(redactValue(v, keys))
+	if err != nil {
+		return body
+	}
+	return bs
+}
+
+func redactValue(v interface{}, keys []string) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if isRedacted(k, keys) {
+				val[k] = redactedValue
+			} else {
+				val[k] = redactValue(item, keys)
+			}
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactValue(item, keys)
+		}
+	}
+	return v
+}
+
+func redactForm(body []byte, keys []string) string {
+	vs, err := url.ParseQuery(string(body))
+	if err != nil {
+		return fmt.Sprintf("<invalid form %dB>", len(body))
+	}
+	for k := range vs {
+		if isRedacted(k, keys) {
+			vs[k] = []string{redactedValue}
+		}
+	}
+	return vs.Encode()
+}
+
+func isRedacted(key string, keys []string) bool {
+	key = strings.ToLower(key)
+	for _, k := range keys {
+		if strings.Contains(key, strings.ToLower(k)) {
+			return true
+		}
+	}
+	return false
+}
+
+func truncateBodyLog(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	return fmt.Sprintf("%s...(%dB)", s[:max], len(s))
+}
@@ -0,0 +1,60 @@
+package hwebconnector
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestBodyLog(t *testing.T) {
+	c := New()
+	applyDefaults(&c.conf)
+
+	var req, res string
+	app := fiber.New()
+	app.Post("/v1/login", func(ctx *fiber.Ctx) error {
+		req = c.requestBodyLog(ctx)
+		ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		_ = ctx.SendString(`{"data":{"user":"u1","Token":"abc"}}`)
+		res = c.responseBodyLog(ctx)
+		return nil
+	})
+
+	r := httptest.NewRequest("POST", "/v1/login?api_secret=s1&q=x", strings.NewReader(`{"user":"u1","password":"p1","id":9007199254740993,"list":[{"new_password":"p2"}]}`))
+	r.Header.Set("Content-Type", "application/json")
+	if _, err := app.Test(r); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"p1", "p2", "s1"} {
+		if strings.Contains(req, s) {
+			t.Errorf("request log %s contains %s", req, s)
+		}
+	}
+	if !strings.Contains(req, "9007199254740993") || !strings.Contains(req, "q=x") {
+		t.Errorf("request log = %s", req)
+	}
+	if strings.Contains(res, "abc") || !strings.Contains(res, `"user":"u1"`) {
+		t.Errorf("response log = %s", res)
+	}
+
+	r = httptest.NewRequest("POST", "/v1/login", strings.NewReader("user=u1&password=p1"))
+	r.Header.Set("Content-Type", fiber.MIMEApplicationForm)
+	if _, err := app.Test(r); err != nil {
+		t.Fatal(err)
+	}
+	if req != "password=%2A%2A%2A&user=u1" {
+		t.Errorf("form log = %s", req)
+	}
+
+	c.conf.BodyLogMaxSize = 10
+	r = httptest.NewRequest("POST", "/v1/login", strings.NewReader(`{"user":"a long user name"}`))
+	r.Header.Set("Content-Type", "application/json")
+	if _, err := app.Test(r); err != nil {
+		t.Fatal(err)
+	}
+	if req != `{"user":"a...(27B)` {
+		t.Errorf("truncated log = %s", req)
+	}
+}
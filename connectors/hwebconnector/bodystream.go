@@ -0,0 +1,123 @@
+package hwebconnector
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+const (
+	BodyStreamField = "BodyStream" //StreamBodyAPIs中的API收到NDJSON请求体时，请求体的io.Reader，仅在请求处理期间有效
+
+	mimeNDJSON    = "application/x-ndjson"
+	bodyStreamKey = "has-body-stream" //按流读取的请求体，请求结束后检查是否读完
+)
+
+// bodyStream 记录请求体是否读完，没有读完的连接不能继续用于后续请求
+type bodyStream struct {
+	reader io.Reader
+	eof    bool
+}
+
+func (this *bodyStream) Read(p []byte) (int, error) {
+	n, err := this.reader.Read(p)
+	if err == io.EOF {
+		this.eof = true
+	}
+	return n, err
+}
+
+// streamBody 是否按流读取该API的请求体，上传到对象存储的API也按流读取
+func (this *Connector) streamBody(version string, api string) bool {
+	if this.uploadAPI(version, api) {
+		return true
+	}
+	name := fmt.Sprintf("%s/%s", version, api)
+	for _, a := range this.config().StreamBodyAPIs {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// streamRequestBody fiber App是否开启StreamRequestBody
+func streamRequestBody(conf *WebConnector) bool {
+	return len(conf.StreamBodyAPIs) > 0 || len(conf.UploadAPIs) > 0
+}
+
+// requestBodyStream 开启StreamRequestBody后，fasthttp只预读请求体的开头部分，其余部分需要从流中读取
+func requestBodyStream(c *fiber.Ctx) io.Reader {
+	if !c.Request().IsBodyStream() {
+		return nil
+	}
+	return c.Context().RequestBodyStream()
+}
+
+// readBodyLimit 不按流处理的API，请求体最多读取limit字节，超过时返回错误，避免chunked请求体被整体读入内存
+func readBodyLimit(c *fiber.Ctx, limit int) (int, *herrors.Error) {
+	r := requestBodyStream(c)
+	if r == nil {
+		return len(c.Body()), nil
+	}
+
+	bs, err := ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		c.Context().SetConnectionClose()
+		return 0, herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to read body")
+	}
+	if len(bs) > limit {
+		c.Context().SetConnectionClose()
+	} else {
+		c.Request().SetBody(bs)
+	}
+	return len(bs), nil
+}
+
+// parseBodyStream JSON请求体边读边解码，NDJSON请求体以io.Reader传给服务，由服务逐行读取。
+// 其他类型的请求体返回false，按普通请求体处理
+func (this *Connector) parseBodyStream(c *fiber.Ctx, ps htypes.Map) (bool, *herrors.Error) {
+	r := requestBodyStream(c)
+	if r == nil {
+		return false, nil
+	}
+
+	contentType := string(c.Request().Header.ContentType())
+	switch {
+	case strings.Contains(contentType, mimeNDJSON):
+		body := &bodyStream{reader: r}
+		c.Locals(bodyStreamKey, body)
+		ps[BodyStreamField] = body
+		return true, nil
+	case strings.Contains(contentType, "application/json"):
+		body := &bodyStream{reader: r}
+		c.Locals(bodyStreamKey, body)
+		res := make(htypes.Map)
+		if err := jsoniter.NewDecoder(body).Decode(&res); err != nil && err != io.EOF {
+			return true, herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to parse body")
+		}
+		for k, v := range res {
+			ps[k] = v
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// closeBodyStream 请求体没有读完时关闭连接，剩余的请求体不能被当作下一个请求解析
+func closeBodyStream(c *fiber.Ctx) {
+	body, _ := c.Locals(bodyStreamKey).(*bodyStream)
+	if body == nil || body.eof {
+		return
+	}
+	if _, err := body.reader.Read(make([]byte, 1)); err != io.EOF {
+		c.Context().SetConnectionClose()
+	}
+}
@@ -0,0 +1,75 @@
+package hwebconnector
+
+import (
+	"bufio"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestStreamBody(t *testing.T) {
+	var lines []string
+	gw := htest.NewGateway().
+		Route("v1", "import", "demo", "Import").
+		Route("v1", "echo", "demo", "Echo").
+		Handle("demo", "Import", func(ps htypes.Map) (htypes.Any, *herrors.Error) {
+			r, ok := ps[BodyStreamField].(io.Reader)
+			if !ok {
+				return nil, herrors.ErrCallerInvalidRequest.New("no body stream")
+			}
+			scanner := bufio.NewScanner(r)
+			for scanner.Scan() {
+				lines = append(lines, scanner.Text())
+			}
+			return len(lines), nil
+		}).
+		Handle("demo", "Echo", htest.Return(nil))
+
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.StreamBodyAPIs = []string{"v1/import"}
+	c.conf.APIBodyLimits = map[string]int{"v1/echo": 1}
+	applyDefaults(&c.conf)
+	app := fiber.New(fiber.Config{BodyLimit: 1024, StreamRequestBody: true})
+	app.Post("/:version/:api", c.handleServiceAPI)
+
+	//超过fiber BodyLimit的NDJSON请求体按流传给服务
+	body := strings.Repeat(`{"n":1}`+"\n", 500)
+	req := httptest.NewRequest("POST", "/v1/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", mimeNDJSON)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK || len(lines) != 500 {
+		t.Fatalf("status = %d, lines = %d, want 200 and 500 lines", resp.StatusCode, len(lines))
+	}
+
+	req = httptest.NewRequest("POST", "/v1/import", strings.NewReader(`{"s":"`+strings.Repeat("x", 4096)+`","n":2}`))
+	req.Header.Set("Content-Type", "application/json")
+	if _, err = app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+	ps := gw.Router().(*htest.Router).LastParams("demo", "Import")
+	htest.AssertParam(t, ps, "n", 2)
+
+	//其他API仍受请求体上限限制
+	req = httptest.NewRequest("POST", "/v1/echo", strings.NewReader(`{"s":"`+strings.Repeat("x", 2048)+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.TransferEncoding = []string{"chunked"}
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.StatusCode)
+	}
+}
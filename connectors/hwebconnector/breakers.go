@@ -0,0 +1,98 @@
+package hwebconnector
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/utils/hjwt"
+)
+
+// handleAdminBreakers 熔断器的状态和最近10秒的调用统计，仅在Debug模式下可用
+func (this *Connector) handleAdminBreakers(c *fiber.Ctx) error {
+	if !this.checkAdmin(c) {
+		return nil
+	}
+
+	m, err := this.breakerManager()
+	if err != nil {
+		this.SendResponse(c, nil, err)
+		return nil
+	}
+	this.SendResponse(c, m.Breakers(), nil)
+	return nil
+}
+
+// handleAdminTripBreaker 手动打开熔断器，仅在Debug模式下可用
+func (this *Connector) handleAdminTripBreaker(c *fiber.Ctx) error {
+	return this.controlBreaker(c, func(m core.IBreakerManager, name string, operator string) *herrors.Error {
+		return m.TripBreaker(name, operator)
+	})
+}
+
+// handleAdminResetBreaker 重置熔断器，仅在Debug模式下可用
+func (this *Connector) handleAdminResetBreaker(c *fiber.Ctx) error {
+	return this.controlBreaker(c, func(m core.IBreakerManager, name string, operator string) *herrors.Error {
+		return m.ResetBreaker(name, operator)
+	})
+}
+
+func (this *Connector) controlBreaker(c *fiber.Ctx, f func(m core.IBreakerManager, name string, operator string) *herrors.Error) error {
+	if !this.checkAdmin(c) {
+		return nil
+	}
+
+	m, err := this.breakerManager()
+	if err != nil {
+		this.SendResponse(c, nil, err)
+		return nil
+	}
+	//熔断命令包含 / ，需要转义
+	name, e := url.PathUnescape(c.Params("name"))
+	if e != nil {
+		this.SendResponse(c, nil, herrors.ErrCallerInvalidRequest.New(e.Error()).D("invalid breaker name"))
+		return nil
+	}
+	if err := f(m, name, this.adminOperator(c)); err != nil {
+		this.SendResponse(c, nil, err)
+		return nil
+	}
+
+	for _, s := range m.Breakers() {
+		if s.Name == name {
+			this.SendResponse(c, s, nil)
+			return nil
+		}
+	}
+	this.SendResponse(c, nil, nil)
+	return nil
+}
+
+func (this *Connector) breakerManager() (core.IBreakerManager, *herrors.Error) {
+	m, ok := this.Gateway.(core.IBreakerManager)
+	if !ok {
+		return nil, herrors.ErrCallerInvalidRequest.New("breaker not supported").D("breaker not supported")
+	}
+	return m, nil
+}
+
+// adminOperator 管理操作者，携带有效jwt时为其subject，并附带客户端IP
+func (this *Connector) adminOperator(c *fiber.Ctx) string {
+	ip := this.clientIP(c)
+	s := this.current()
+	if s.jwt == nil {
+		return ip
+	}
+	token := jwtToken(c, s.conf.JwtHeader)
+	if token == "" {
+		return ip
+	}
+	claims, err := s.jwt.Verify(token)
+	if err != nil || claims[hjwt.ClaimSubject] == nil {
+		return ip
+	}
+	return fmt.Sprintf("%v@%s", claims[hjwt.ClaimSubject], ip)
+}
@@ -0,0 +1,45 @@
+package hwebconnector
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// previewCache 设置预览响应的缓存头，客户端携带的If-None-Match与数据的ETag一致时返回true，此时只需返回304
+func (this *Connector) previewCache(c *fiber.Ctx, data []byte) bool {
+	conf := this.config()
+	if conf.PreviewMaxAge > 0 {
+		c.Set(fiber.HeaderCacheControl, fmt.Sprintf("private, max-age=%d", conf.PreviewMaxAge))
+	}
+
+	//数据较大时计算ETag的开销可能超过节省的带宽
+	if !conf.PreviewETag || (conf.PreviewETagMaxSize > 0 && len(data) > conf.PreviewETagMaxSize*1024) {
+		return false
+	}
+	if conf.PreviewMaxAge <= 0 {
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+	}
+
+	sum := sha1.Sum(data)
+	etag := "\"" + hex.EncodeToString(sum[:]) + "\""
+	c.Set(fiber.HeaderETag, etag)
+
+	return etagMatch(c.Get(fiber.HeaderIfNoneMatch), etag)
+}
+
+func etagMatch(noneMatch string, etag string) bool {
+	if noneMatch == "" {
+		return false
+	}
+	for _, t := range strings.Split(noneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
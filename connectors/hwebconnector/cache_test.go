@@ -0,0 +1,21 @@
+package hwebconnector
+
+import "testing"
+
+func TestEtagMatch(t *testing.T) {
+	etag := "\"abc\""
+
+	cases := map[string]bool{
+		"":                 false,
+		"\"abc\"":          true,
+		"W/\"abc\"":        true,
+		"\"xyz\", \"abc\"": true,
+		"\"xyz\"":          false,
+		"*":                true,
+	}
+	for h, want := range cases {
+		if got := etagMatch(h, etag); got != want {
+			t.Errorf("etagMatch(%q) = %v, want %v", h, got, want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package hwebconnector
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	noCompressKey = "has-no-compress"
+)
+
+// handleCompress 根据客户端的Accept-Encoding对响应进行gzip/deflate压缩。
+// 压缩只作用于响应，BodyLimit和APIBodyLimits限制的是请求体，两者互不影响。
+func (this *Connector) handleCompress(c *fiber.Ctx) error {
+	s := this.current()
+	if err := c.Next(); err != nil {
+		return err
+	}
+
+	if c.Locals(noCompressKey) != nil {
+		return nil
+	}
+	//文件流大小未知时直接流式压缩，不能调用Body()读取整个流
+	if c.Response().IsBodyStream() {
+		if size := c.Response().Header.ContentLength(); size >= 0 && size < s.conf.CompressMinSize {
+			return nil
+		}
+	} else if len(c.Response().Body()) < s.conf.CompressMinSize {
+		return nil
+	}
+
+	s.compressor(c.Context())
+	return nil
+}
@@ -5,14 +5,125 @@ import "github.com/drharryhe/has/core"
 type WebConnector struct {
 	core.ConnectorConf
 
-	AppKey       string
-	AppSecret    string
-	SignMethod   string
-	Port         int
-	Timeout      int
-	BodyLimit    int // Mbit
-	Tls          bool
-	TlsCertPath  string
-	TlsKeyPath   string
-	AddressField string
+	AppKey               string
+	AppSecret            string
+	SignMethod           string
+	Port                 int
+	Timeout              int
+	BodyLimit            int // Mbit
+	Tls                  bool
+	TlsCertPath          string
+	TlsKeyPath           string
+	TlsCertificates      []TlsCertificate // 其他域名的证书，按SNI选择，TlsCertPath为缺省证书
+	TlsMinVersion        string           // 1.0, 1.1, 1.2, 1.3，缺省为 1.2
+	TlsMaxVersion        string           // 不配置则不限制
+	TlsCipherSuites      []string         // TLS 1.2及以下使用的套件，如 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256，不配置则使用Go的缺省套件
+	AddressField         string
+	MethodField          string            // HTTP方法(GET、POST、PUT、PATCH、DELETE)写入参数的字段名，为空时不写入
+	StreamBufferSize     int               // KB, 文件流发送缓冲区大小
+	NDJSONFlushItems     int               // 返回NDJSONFlag的API每发送多少条数据刷新一次，缺省为 100，数据源暂时没有数据时也会刷新
+	ShutdownTimeout      int               // seconds, 关闭时等待处理中请求完成的时长
+	ReadTimeout          int               // seconds, 读取请求(含请求体)的超时时长，缺省为 30，防止慢速攻击
+	WriteTimeout         int               // seconds, 发送响应的超时时长，缺省为 60，下载大文件和NDJSON等长时间的流式响应需相应调大
+	IdleTimeout          int               // seconds, keep-alive连接空闲的最长时长，缺省为 120
+	Concurrency          int               // 最大并发连接数，缺省为 262144
+	IgnoreDisconnect     bool              // 客户端断开连接时不取消处理中的请求
+	DisconnectInterval   int               // milliseconds, 检查客户端是否断开的间隔，缺省为 200
+	RequestsPerSecond    float64           // 每个IP每秒允许的请求数，0表示不限流
+	Burst                int               // 每个IP允许的突发请求数
+	RateLimitWhitelist   []string          // 不限流的IP或CIDR
+	AllowIPs             []string          // 允许访问的IP或CIDR，支持IPv4和IPv6，不配置则允许所有IP
+	DenyIPs              []string          // 拒绝访问的IP或CIDR，优先于AllowIPs
+	TrustedProxies       []string          // 可信代理的IP或CIDR，来自这些地址的请求按X-Forwarded-For或X-Real-IP确定客户端IP
+	LazyFormFiles        bool              // 上传文件不读入内存，以*multipart.FileHeader传给服务
+	ArrayParams          bool              // 查询参数和表单字段总是以数组传给服务，否则只有重复的参数为数组
+	AlwaysStatusOK       bool              // 总是返回HTTP 200，兼容旧客户端
+	OmitEmpty            bool              // 响应中去掉值为null、空字符串、空数组和空对象的字段，0和false保留
+	FieldsQuery          string            // 选择返回字段的查询参数名，如 fields，参数值为逗号分隔的字段，嵌套字段以点分隔，只对返回Map的API有效
+	StatusCodes          map[string]int    // 按herrors错误码覆盖HTTP状态码
+	ResponseFields       map[string]string // 响应字段名，标准字段名 -> 输出的字段名，如 data -> result，可设置data、page、error、code、desc、fingerprint、cause、fields
+	FlattenError         bool              // 错误码等字段与data同级输出，不嵌套在error中
+	AccessLog            bool              // 是否记录访问日志
+	AccessLogFormat      string            // 访问日志格式，text 或 json
+	BodyLog              bool              // 记录请求和响应体，用于调试客户端请求，只在Debug模式下生效
+	BodyLogMaxSize       int               // bytes, 请求体和响应体日志的最大长度，超过时截断，缺省为 4096
+	BodyLogRedact        []string          // 字段名包含这些字符串(不区分大小写)时记录为***，缺省为 password、secret、token
+	Compression          int               // 响应压缩级别 1-9，0表示不压缩
+	CompressMinSize      int               // bytes, 小于该大小的响应不压缩
+	CompressFiles        bool              // 文件下载和预览是否压缩
+	PreviewETag          bool              // 文件预览是否计算ETag，支持If-None-Match返回304
+	PreviewETagMaxSize   int               // KB, 超过该大小的预览不计算ETag，0表示不限制
+	PreviewMaxAge        int               // seconds, 文件预览的Cache-Control max-age，0表示每次需要重新验证
+	HeaderParams         []string          // 作为API参数导入的header
+	HeaderParamPrefix    string            // 导入header参数时添加的前缀，如 header_
+	DisableHealth        bool              // 关闭健康检查接口
+	HealthPath           string            // 存活检查路径，缺省为 /healthz
+	ReadyPath            string            // 就绪检查路径，缺省为 /readyz
+	CorsAllowOrigins     []string          // 允许跨域访问的来源，不配置则允许所有来源
+	CorsAllowMethods     []string
+	CorsAllowHeaders     []string
+	CorsExposeHeaders    []string
+	CorsAllowCredentials bool
+	CorsMaxAge           int    // seconds, 预检请求结果的缓存时长
+	JwtSecret            string // 配置后校验请求携带的JWT
+	JwtAlgorithm         string // HS256, HS384, HS512
+	JwtIssuer            string
+	JwtHeader            string            // 携带JWT的header，缺省为 Authorization
+	JwtRequired          bool              // 未携带JWT的请求是否拒绝
+	JwtExcludeAPIs       []string          // 不要求JWT的API，如 v1/login
+	JwtSubjectField      string            // JWT subject写入参数的字段名
+	JwtClaimsField       string            // JWT claims写入参数的字段名
+	LangQuery            string            // 指定错误信息语言的查询参数名，如 lang
+	LangFromHeader       bool              // 根据Accept-Language选择错误信息语言
+	Metrics              bool              // 是否开启Prometheus监控接口
+	MetricsPath          string            // 监控接口路径，缺省为 /metrics
+	RequestIDHeader      string            // 携带请求ID的header，缺省为 X-Request-Id，请求未携带时自动生成
+	APIBodyLimits        map[string]int    // KB, 按 version/api 单独设置的请求体上限，未设置的API使用BodyLimit
+	StreamBodyAPIs       []string          // 按流读取请求体的API，如 v1/import，JSON请求体边读边解码，NDJSON请求体以io.Reader传给服务，只受APIBodyLimits限制
+	UploadAPIs           []string          // 上传文件直接写入对象存储的API，如 v1/avatar，multipart请求体按流读取，文件以对象key和URL传给服务
+	UploadStore          string            // 保存上传文件的插件，如 MinioPlugin，需实现ObjectStore
+	UploadPrefix         string            // 上传文件对象key的前缀，如 uploads/
+	MultipartMaxParts    int               // multipart请求体最多包含的part数(文件和字段)，缺省为 100
+	MultipartMaxFileSize int               // KB, multipart请求体中单个文件的上限，0表示只受请求体上限限制
+	MultipartMaxSize     int               // KB, multipart请求体中文件的总大小上限，0表示只受请求体上限限制
+	IdempotentAPIs       []string          // 支持Idempotency-Key的API，如 v1/pay，相同key的重试在IdempotencyTTL内返回第一次的响应
+	IdempotencyHeader    string            // 携带幂等key的header，缺省为 Idempotency-Key
+	IdempotencyTTL       int               // seconds, 响应保留时长，缺省为 86400
+	IdempotencyStore     string            // 保存响应的插件，如 CachePlugin，需实现IdempotencyStore，不配置则保存在内存中
+	Batch                bool              // 是否开启批量调用接口，一次请求调用多个API
+	BatchPath            string            // 批量调用接口路径，缺省为 /batch
+	BatchConcurrency     int               // 每个批量请求同时执行的调用数，缺省为 8
+	BatchMaxCalls        int               // 每个批量请求最多包含的调用数，缺省为 50
+	SessionPlugin        string            // 会话插件，如 SessionPlugin，需实现SessionProvider，配置后按SessionHeader或SessionCookie读取会话
+	SessionCookie        string            // 携带会话ID的cookie，缺省为 session_id
+	SessionHeader        string            // 携带会话ID的header，优先于cookie，缺省为 X-Session-Id
+	SessionIDField       string            // 会话ID写入参数的字段名，缺省为 SessionID，退出登录时据此销毁会话
+	SessionSubjectField  string            // 会话subject写入参数的字段名，缺省为 SessionSubject
+	TenantHeader         string            // 携带租户ID的header，如 X-Tenant-Id，优先于TenantSubdomain
+	TenantSubdomain      string            // 从Host解析租户的模式，如 {tenant}.example.com
+	TenantField          string            // 租户ID写入参数的字段名，缺省为 tenant，服务可据此通过hconf.LoadTenant读取租户的配置
+	TenantRequired       bool              // 配置了租户解析时，是否拒绝无法确定租户的请求
+	SignedURLSecret      string            // 签名URL的密钥，配置后开启 SignedURLPath/:version/:api 路由，签名有效时不校验JWT
+	SignedURLPath        string            // 签名URL的路径前缀，缺省为 /signed
+	SignedURLTTL         int               // seconds, 签名URL的缺省有效期，缺省为 300
+	ErrorStreamInterval  int               // seconds, /error/stream 推送错误统计的间隔，缺省为 5
+	AdminToken           string            // 配置后管理接口(/admin/services、/admin/openapi.json、/admin/client.go、/admin/load、/admin/health、/admin/build、/admin/logs、/admin/breakers)需在X-Admin-Token header中携带该token
+	Listeners            []Listener        // 多个监听，如公开API和管理接口使用不同端口，配置后忽略Port和Tls设置
+	ContentPackers       map[string]string // MIME类型 -> 打包器，按请求的Content-Type和Accept选择，未匹配时使用Packer
+	CSVQuery             string            // 指定以CSV返回的查询参数名，如 format，请求 ?format=csv 时返回的对象列表以CSV附件下载，Accept: text/csv 同样有效
+	CSVBOM               bool              // CSV以UTF-8 BOM开头，Excel打开时中文不乱码
+
+	CacheAPIs        map[string]int      // seconds, 缓存GET响应的API及缓存时长，如 v1/products = 60，只应用于只读的API
+	CacheTags        map[string][]string // 缓存响应的标签，可引用参数，如 v1/product = ["product:{id}"]
+	CacheInvalidates map[string][]string // API调用成功后使这些标签的缓存失效，如 v1/updateProduct = ["product:{id}", "catalog"]
+	CacheStore       string              // 保存缓存的插件，如 CachePlugin，需实现ResponseCacheStore，不配置则保存在内存中
+	CachePublic      bool                // 缓存响应的Cache-Control为public，缺省为private，响应与用户有关时不应开启
+
+	ContentTypes map[string]string // 按 version/api 要求请求体的Content-Type，如 v1/order = "application/json"，多个类型以逗号分隔，* 对应所有API。未配置的API不检查
+
+	ProblemDetails  string // 错误以RFC 7807 application/problem+json输出，always 总是输出，negotiate 请求的Accept包含application/problem+json时输出，成功的响应不变
+	ProblemTypeBase string // Problem的type为该前缀加错误码，如 https://example.com/errors/ -> https://example.com/errors/201，缺省为 about:blank
+
+	MaxResponseSize int // MB, 序列化后的响应体上限，超过时返回101错误，缺省为 64，负数表示不限制
+	MaxFileSize     int // MB, 以data返回的文件(下载和预览)的上限，超过时返回101错误，缺省为 256，负数表示不限制。文件流不受限制
 }
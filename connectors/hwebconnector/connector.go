@@ -5,27 +5,59 @@ import (
 	"crypto/tls"
 	"fmt"
 	"io"
+	"net"
 	"net/url"
-	"strings"
+	"regexp"
+	"sync"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/atomic"
 
 	"github.com/drharryhe/has/common/hconf"
 	"github.com/drharryhe/has/common/herrors"
 	"github.com/drharryhe/has/common/hlogger"
 	"github.com/drharryhe/has/common/htypes"
 	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/utils/hjwt"
+	"github.com/drharryhe/has/utils/hrandom"
+	"github.com/drharryhe/has/utils/hsignurl"
 )
 
 const (
-	DownloadFlag     = "FILE-DOWNLOAD"
-	PreviewFlag      = "FILE-PREVIEW"
-	defaultBodyLimit = 10
-	defaultPort      = 1976
+	DownloadFlag       = "FILE-DOWNLOAD"
+	PreviewFlag        = "FILE-PREVIEW"
+	DownloadStreamFlag = "FILE-STREAM" //文件流，值为io.Reader或文件路径，不需要将文件整体读入内存
+	FormFileField      = "file"        //LazyFormFiles模式下上传文件的*multipart.FileHeader，仅在请求处理期间有效
+
+	defaultBodyLimit           = 10
+	defaultPort                = 1976
+	defaultStreamBufferSize    = 32 //KB
+	defaultShutdownTimeout     = 10 //seconds
+	defaultHealthPath          = "/healthz"
+	defaultMetricsPath         = "/metrics"
+	defaultRequestIDHeader     = "X-Request-Id"
+	defaultErrorStreamInterval = 5 //seconds
+	defaultBodyLogMaxSize      = 4096
+
+	errorCodeKey     = "has-error-code" //SendResponse记录的herrors错误码，供访问日志和监控使用
+	requestIDKey     = "has-request-id" //请求ID，供访问日志使用
+	defaultReadyPath = "/readyz"
+
+	defaultReadTimeout  = 30  //seconds
+	defaultWriteTimeout = 60  //seconds
+	defaultIdleTimeout  = 120 //seconds
+	defaultConcurrency  = 256 * 1024
 )
 
+// apiMethods /:version/:api 接受的HTTP方法，配置了MethodField时服务可据此区分
+var apiMethods = []string{fiber.MethodGet, fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete}
+
+// validRequestID 请求携带的请求ID只接受有限长度的字母、数字和 ._:- ，避免写入日志和响应header的内容不受控制
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
 func New() *Connector {
 	return new(Connector)
 }
@@ -33,8 +65,48 @@ func New() *Connector {
 type Connector struct {
 	core.BaseConnector
 
-	conf WebConnector
-	App  *fiber.App
+	conf        WebConnector
+	App         *fiber.App                     //第一个监听的fiber App
+	Apps        []*fiber.App                   //每个监听一个fiber App
+	packers     map[string]core.IAPIDataPacker //MIME类型 -> 打包器
+	mimes       []string                       //内容协商时可选的MIME类型
+	closing     chan struct{}                  //关闭时通知长连接(如错误统计推送)结束
+	sessions    SessionProvider
+	uploads     ObjectStore
+	load        core.LoadCounter //API请求的负载，批量请求计为一次
+	certs       []*certHolder    //TLS监听的证书，可重新加载
+	certWatcher *certWatcher
+
+	settings atomic.Value //*settings, 请求处理时读取的设置快照，resetConfig时整体替换
+	confLock sync.Mutex
+}
+
+// settings 请求处理时读取的配置及按配置创建的对象，重新加载时整体替换，发布后不再修改
+type settings struct {
+	conf        WebConnector
+	limiter     *ipRateLimiter
+	compressor  fasthttp.RequestHandler
+	jwt         *hjwt.Signer
+	jwtExcludes map[string]bool
+	ipFilter    *ipFilter
+	proxies     []*net.IPNet //可信代理，来自这些地址的请求按X-Forwarded-For确定客户端IP
+	idempotency IdempotencyStore
+	cache       ResponseCacheStore
+	signer      *hsignurl.Signer
+	tenantHost  *regexp.Regexp //按TenantSubdomain匹配Host
+}
+
+// current 当前的设置快照，调用方不能修改。未发布快照时(如测试中直接构造)按conf创建
+func (this *Connector) current() *settings {
+	if s, ok := this.settings.Load().(*settings); ok {
+		return s
+	}
+	return &settings{conf: this.conf}
+}
+
+// config 当前设置快照中的配置，调用方不能修改
+func (this *Connector) config() *WebConnector {
+	return &this.current().conf
 }
 
 func (this *Connector) Open(gw core.IAPIGateway, ins core.IAPIConnector) *herrors.Error {
@@ -42,53 +114,220 @@ func (this *Connector) Open(gw core.IAPIGateway, ins core.IAPIConnector) *herror
 		return err
 	}
 
-	if this.conf.Port == 0 {
-		this.conf.Port = defaultPort
+	applyDefaults(&this.conf)
+
+	if err := this.initPackers(); err != nil {
+		return err
 	}
 
-	if this.conf.BodyLimit <= 0 {
-		this.conf.BodyLimit = defaultBodyLimit
+	if err := checkResponseFields(&this.conf); err != nil {
+		return err
 	}
 
-	this.App = fiber.New(fiber.Config{
-		BodyLimit: this.conf.BodyLimit * 1024 * 1024,
-	})
+	if err := this.initSession(); err != nil {
+		return err
+	}
 
-	this.App.Use(cors.New())
-	this.App.Get("/error/query/:fingerprint", this.handleErrFingerprint)
-	this.App.Get("/error/statics", this.handleErrStatics)
-	this.App.Get("/:version/:api", this.handleServiceAPI)
-	this.App.Post("/:version/:api", this.handleServiceAPI)
+	if err := this.initUpload(); err != nil {
+		return err
+	}
 
-	go func() {
-		if this.conf.Tls {
-			// Create tls certificate
-			cer, err := tls.LoadX509KeyPair(this.conf.TlsCertPath, this.conf.TlsKeyPath)
-			if err != nil {
-				panic(herrors.ErrSysInternal.New(err.Error()).D("failed to load tls certificate"))
-			}
+	//会话的缺省设置在initSession中写入conf，之后再发布
+	if err := this.publishSettings(this.conf); err != nil {
+		return err
+	}
 
-			config := &tls.Config{Certificates: []tls.Certificate{cer}}
+	if this.conf.Metrics {
+		initMetrics()
+	}
 
-			// Create custom listener
-			ln, err := tls.Listen("tcp", fmt.Sprintf(":%d", this.conf.Port), config)
+	listeners := this.listeners()
+	this.certs = nil
+	tlsConfigs := make([]*tls.Config, len(listeners))
+	for i := range listeners {
+		if listeners[i].Port == 0 {
+			return herrors.ErrSysInternal.New("port of listener %d not configured", i).D("failed to open web connector")
+		}
+		if listeners[i].Tls {
+			config, err := this.tlsConfig(&listeners[i])
 			if err != nil {
-				panic(herrors.ErrSysInternal.New(err.Error()).D("failed to listen tls"))
+				return herrors.ErrSysInternal.New(err.Error()).D("failed to open web connector")
 			}
+			tlsConfigs[i] = config
+		}
+	}
 
-			err = this.App.Listener(ln)
-			if err != nil {
-				panic(herrors.ErrSysInternal.New(err.Error()).D("failed to listen Fiber App"))
-			}
-		} else {
-			err := this.App.Listen(fmt.Sprintf(":%d", this.conf.Port))
+	this.watchCerts()
+
+	this.closing = make(chan struct{})
+	this.Apps = nil
+	for i := range listeners {
+		app := this.newApp(&listeners[i])
+		this.Apps = append(this.Apps, app)
+		go this.listen(app, &listeners[i], tlsConfigs[i])
+	}
+	this.App = this.Apps[0]
+
+	return nil
+}
+
+func applyDefaults(conf *WebConnector) {
+	if conf.Port == 0 {
+		conf.Port = defaultPort
+	}
+
+	if conf.BodyLimit <= 0 {
+		conf.BodyLimit = defaultBodyLimit
+	}
+
+	if conf.StreamBufferSize <= 0 {
+		conf.StreamBufferSize = defaultStreamBufferSize
+	}
+	if conf.NDJSONFlushItems <= 0 {
+		conf.NDJSONFlushItems = defaultNDJSONFlushItems
+	}
+
+	if conf.ShutdownTimeout <= 0 {
+		conf.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	if conf.ReadTimeout <= 0 {
+		conf.ReadTimeout = defaultReadTimeout
+	}
+	if conf.WriteTimeout <= 0 {
+		conf.WriteTimeout = defaultWriteTimeout
+	}
+	if conf.IdleTimeout <= 0 {
+		conf.IdleTimeout = defaultIdleTimeout
+	}
+	if conf.Concurrency <= 0 {
+		conf.Concurrency = defaultConcurrency
+	}
+
+	if conf.DisconnectInterval <= 0 {
+		conf.DisconnectInterval = defaultDisconnectInterval
+	}
+
+	if conf.HealthPath == "" {
+		conf.HealthPath = defaultHealthPath
+	}
+
+	if conf.ReadyPath == "" {
+		conf.ReadyPath = defaultReadyPath
+	}
+
+	if conf.MetricsPath == "" {
+		conf.MetricsPath = defaultMetricsPath
+	}
+
+	if conf.RequestIDHeader == "" {
+		conf.RequestIDHeader = defaultRequestIDHeader
+	}
+
+	if conf.ErrorStreamInterval <= 0 {
+		conf.ErrorStreamInterval = defaultErrorStreamInterval
+	}
+
+	if conf.BatchPath == "" {
+		conf.BatchPath = defaultBatchPath
+	}
+
+	if conf.BatchConcurrency <= 0 {
+		conf.BatchConcurrency = defaultBatchConcurrency
+	}
+
+	if conf.BatchMaxCalls <= 0 {
+		conf.BatchMaxCalls = defaultBatchMaxCalls
+	}
+	if conf.TenantField == "" {
+		conf.TenantField = defaultTenantField
+	}
+	if conf.MultipartMaxParts <= 0 {
+		conf.MultipartMaxParts = defaultMultipartMaxParts
+	}
+
+	if conf.TlsMinVersion == "" {
+		conf.TlsMinVersion = defaultTlsMinVersion
+	}
+
+	if conf.SignedURLPath == "" {
+		conf.SignedURLPath = defaultSignedURLPath
+	}
+
+	if conf.SignedURLTTL <= 0 {
+		conf.SignedURLTTL = defaultSignedURLTTL
+	}
+
+	if conf.BodyLogMaxSize <= 0 {
+		conf.BodyLogMaxSize = defaultBodyLogMaxSize
+	}
+	if len(conf.BodyLogRedact) == 0 {
+		conf.BodyLogRedact = defaultBodyLogRedact
+	}
+
+	if conf.IdempotencyHeader == "" {
+		conf.IdempotencyHeader = defaultIdempotencyHeader
+	}
+
+	if conf.IdempotencyTTL <= 0 {
+		conf.IdempotencyTTL = defaultIdempotencyTTL
+	}
+
+	if conf.MaxResponseSize == 0 {
+		conf.MaxResponseSize = defaultMaxResponseSize
+	}
+	if conf.MaxFileSize == 0 {
+		conf.MaxFileSize = defaultMaxFileSize
+	}
+}
+
+// fiberBodyLimit fiber的全局上限需要容纳所有单独设置的API上限，具体API的限制在handleServiceAPI中检查
+func fiberBodyLimit(conf *WebConnector) int {
+	bodyLimit := conf.BodyLimit * 1024 * 1024
+	for _, limit := range conf.APIBodyLimits {
+		if limit*1024 > bodyLimit {
+			bodyLimit = limit * 1024
+		}
+	}
+	return bodyLimit
+}
+
+// Close 停止接收新连接，并在ShutdownTimeout内等待处理中的请求完成。超时后不再等待，未完成的请求不会被中断
+func (this *Connector) Close() {
+	if len(this.Apps) == 0 {
+		return
+	}
+
+	close(this.closing)
+	if this.certWatcher != nil {
+		this.certWatcher.close()
+	}
+	done := make(chan error, len(this.Apps))
+	for _, app := range this.Apps {
+		go func(app *fiber.App) {
+			done <- app.Shutdown()
+		}(app)
+	}
+
+	timeout := time.After(time.Duration(this.conf.ShutdownTimeout) * time.Second)
+wait:
+	for range this.Apps {
+		select {
+		case err := <-done:
 			if err != nil {
-				panic(herrors.ErrSysInternal.New(err.Error()).D("failed to listen Fiber App"))
+				hlogger.Warn("web connector shutdown: %s", err.Error())
 			}
+		case <-timeout:
+			hlogger.Warn("web connector shutdown timeout after %d seconds, stopped waiting for in-flight requests", this.conf.ShutdownTimeout)
+			break wait
 		}
-	}()
+	}
 
-	return nil
+	if s := this.current(); s.limiter != nil {
+		s.limiter.close()
+	}
+
+	this.BaseConnector.Close()
 }
 
 func (this *Connector) handleErrFingerprint(c *fiber.Ctx) error {
@@ -119,17 +358,44 @@ func (this *Connector) handleErrStatics(c *fiber.Ctx) error {
 }
 
 func (this *Connector) handleServiceAPI(c *fiber.Ctx) error {
+	conf := this.config()
 	api := c.Params("api")
 	version := c.Params("version")
 
+	if conf.Metrics {
+		defer observeRequest(c, version, api, time.Now())
+	}
+	this.load.Begin()
+	defer this.load.End()
+
+	requestID := this.requestID(c)
+	this.deprecationHeaders(c, version, api)
+	defer closeBodyStream(c)
+	span := this.startSpan(c, version, api)
+	defer endSpan(c, span)
+
+	if err := this.checkBodyLimit(c, version, api); err != nil {
+		this.SendResponse(c, nil, err)
+		return nil
+	}
+	if err := this.checkContentType(c, version, api); err != nil {
+		this.SendResponse(c, nil, err)
+		return nil
+	}
+
 	ps, err := this.ParseQueryParams(c)
 	if err != nil {
 		return err
 	}
 
-	err = this.ParseFormParams(c, ps)
-	if err != nil {
-		return err
+	//没有请求体时(如GET)跳过表单和请求体的解析
+	upload := this.uploadAPI(version, api)
+	body := hasRequestBody(c)
+	if !upload && body {
+		err = this.ParseFormParams(c, ps)
+		if err != nil {
+			return err
+		}
 	}
 
 	err = this.ParseHeaderParams(c, ps)
@@ -137,18 +403,99 @@ func (this *Connector) handleServiceAPI(c *fiber.Ctx) error {
 		return err
 	}
 
-	err = this.ParseBodyParams(c, ps)
+	if body {
+		err = this.ParseBodyParams(c, ps)
+		if err != nil {
+			return err
+		}
+	}
+	core.StripScope(ps)
+
+	err = this.verifyJwt(c, version, api, ps)
 	if err != nil {
-		return err
+		this.SendResponse(c, nil, err)
+		return nil
 	}
 
-	ps[this.conf.AddressField] = c.IP()
-	ret, err := this.Gateway.RequestAPI(version, api, ps)
+	err = this.loadSession(c, ps)
 	if err != nil {
 		this.SendResponse(c, nil, err)
 		return nil
 	}
 
+	err = this.resolveTenant(c, ps)
+	if err != nil {
+		this.SendResponse(c, nil, err)
+		return nil
+	}
+
+	if cacheKey := this.responseCacheKey(c, version, api, ps); cacheKey != "" {
+		if this.replayCache(c, cacheKey) {
+			return nil
+		}
+		defer this.saveCache(c, cacheKey, conf.CacheAPIs[version+"/"+api])
+	}
+
+	key, err := this.idempotencyKey(c, version, api)
+	if err != nil {
+		this.SendResponse(c, nil, err)
+		return nil
+	}
+	if key != "" {
+		sent, err := this.beginIdempotent(c, key)
+		if err != nil {
+			this.SendResponse(c, nil, err)
+			return nil
+		}
+		if sent {
+			return nil
+		}
+		defer this.endIdempotent(c, key)
+	}
+
+	ctx, cancel := this.requestContext(c, requestID)
+	defer cancel()
+	//文件在校验JWT和会话之后上传
+	if upload {
+		if err := this.parseUpload(c, ctx, ps); err != nil {
+			this.SendResponse(c, nil, err)
+			return nil
+		}
+	}
+
+	ps[conf.AddressField] = this.clientIP(c)
+	if conf.MethodField != "" {
+		ps[conf.MethodField] = c.Method()
+	}
+	ps[core.RequestIDField] = requestID
+	core.SetScoped(ps, core.ScopeAddress, ps[conf.AddressField])
+	core.SetScoped(ps, core.ScopeRequestID, requestID)
+	traceParams(span, ps)
+	ret, err := this.Gateway.RequestAPIContext(ctx, version, api, ps)
+	if err != nil {
+		this.SendResponse(c, nil, err)
+		return nil
+	}
+	this.invalidateCache(version, api, ps)
+
+	if ok, err := this.HandleNDJSONRequest(c, ret); ok || err != nil {
+		if err != nil {
+			this.SendResponse(c, nil, err)
+		}
+		return nil
+	}
+	if ok, err := this.HandleRawRequest(c, ret); ok || err != nil {
+		if err != nil {
+			this.SendResponse(c, nil, err)
+		}
+		return nil
+	}
+	if ok, err := this.HandleCSVRequest(c, api, ret); ok || err != nil {
+		if err != nil {
+			this.SendResponse(c, nil, err)
+		}
+		return nil
+	}
 	if ok, err := this.HandleFileRequest(c, ret); ok {
 		if err != nil {
 			this.SendResponse(c, nil, err)
@@ -159,16 +506,79 @@ func (this *Connector) handleServiceAPI(c *fiber.Ctx) error {
 	return nil
 }
 
+// requestID 返回请求携带的请求ID，未携带或格式无效时自动生成，并写入响应header
+func (this *Connector) requestID(c *fiber.Ctx) string {
+	conf := this.config()
+	requestID := c.Get(conf.RequestIDHeader)
+	if !validRequestID.MatchString(requestID) {
+		requestID = hrandom.UuidWithoutDash()
+	}
+	c.Set(conf.RequestIDHeader, requestID)
+	c.Locals(requestIDKey, requestID)
+	return requestID
+}
+
+func (this *Connector) checkBodyLimit(c *fiber.Ctx, version string, api string) *herrors.Error {
+	conf := this.config()
+	stream := this.streamBody(version, api)
+	limit := conf.BodyLimit * 1024 * 1024
+	if l, ok := conf.APIBodyLimits[fmt.Sprintf("%s/%s", version, api)]; ok && l > 0 {
+		limit = l * 1024
+	} else if stream {
+		return nil
+	}
+
+	//按流读取的请求体只检查Content-Length，不能读入内存
+	size := c.Request().Header.ContentLength()
+	if !stream {
+		l, err := readBodyLimit(c, limit)
+		if err != nil {
+			return err
+		}
+		if l > size {
+			size = l
+		}
+	}
+	if size > limit {
+		return herrors.ErrCallerInvalidRequest.New("request body size %d exceeds limit %d of api %s/%s", size, limit, version, api).D("request body too large")
+	}
+	return nil
+}
+
 func (this *Connector) SendResponse(c *fiber.Ctx, data htypes.Any, err *herrors.Error) {
+	conf := this.config()
 	if err != nil && err.Code != herrors.ECodeOK {
-		if this.conf.Lang != "" {
-			if trans := this.Gateway.I18n(); trans != nil {
-				err = err.D(trans.Translate(this.conf.Lang, err.Desc))
+		err = this.translate(c, err)
+	}
+
+	if err != nil {
+		c.Locals(errorCodeKey, err.Code)
+		if err.Code != herrors.ECodeOK && this.wantsProblem(c) {
+			this.sendProblem(c, err)
+			return
+		}
+	}
+
+	if conf.FieldsQuery != "" {
+		if val, ok := data.(htypes.Map); ok {
+			if fields := c.Query(conf.FieldsQuery); fields != "" {
+				data = selectFields(val, parseFields(fields))
 			}
 		}
 	}
 
-	bs, _ := this.Packer.Marshal(NewResponseData(data, err))
+	res := this.envelope(NewResponseData(data, err))
+	if conf.OmitEmpty {
+		res = omitEmpty(res)
+	}
+
+	packer := this.responsePacker(c)
+	bs, _ := packer.Marshal(res)
+	if e := this.checkResponseSize(c, len(bs)); e != nil {
+		this.SendResponse(c, nil, e)
+		return
+	}
+	c.Status(this.httpStatus(err))
 	if e := c.Send(bs); e != nil {
 		hlogger.Error(herrors.ErrSysInternal.New(e.Error()).D("failed to send data"))
 	}
@@ -188,13 +598,28 @@ func (this *Connector) HandleFileRequest(c *fiber.Ctx, data htypes.Any) (bool, *
 		return false, herrors.ErrCallerInvalidRequest.New("parameter [name] unavailable or invalid type").D("bad parameter")
 	}
 
+	fname := val["name"].(string)
+	preview, _ := val[PreviewFlag].(bool)
+	if !this.config().CompressFiles {
+		c.Locals(noCompressKey, true)
+	}
+	if val[DownloadStreamFlag] != nil {
+		return true, this.sendFileStream(c, fname, preview, val[DownloadStreamFlag])
+	}
+
 	if _, ok = val["data"].([]byte); !ok {
 		return false, herrors.ErrCallerInvalidRequest.New("parameter [data] unavailable or invalid type").D("bad parameter")
 	}
 
-	fname := val["name"].(string)
 	fdata := val["data"].([]byte)
-	if val[PreviewFlag] != nil && val[PreviewFlag].(bool) {
+	if err := this.checkFileSize(c, len(fdata)); err != nil {
+		return true, err
+	}
+	if preview {
+		if this.previewCache(c, fdata) {
+			c.Status(fiber.StatusNotModified)
+			return true, nil
+		}
 		c.Response().SetBodyRaw(fdata)
 	} else {
 		c.Response().Header.Set("Content-Type", "application/octet-stream")
@@ -212,25 +637,37 @@ func (this *Connector) ParseFormParams(c *fiber.Ctx, ps htypes.Map) *herrors.Err
 	if len(c.Request().Header.MultipartFormBoundary()) == 0 || len(c.Request().Body()) == 0 {
 		return nil
 	}
+	if err := this.checkMultipart(c.Request().Body(), string(c.Request().Header.MultipartFormBoundary())); err != nil {
+		return err
+	}
 
 	f, err := c.MultipartForm()
 	if err != nil {
 		return herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to get data of form")
 	}
 	for k, v := range f.Value {
-		ps[k] = v[0]
+		ps[k] = this.paramValue(v)
 	}
 	if f.File != nil {
 		for key, ms := range f.File {
 			var ff []htypes.Any
 			for _, f := range ms {
 				v := make(htypes.Map)
+				if this.config().LazyFormFiles {
+					v["name"] = f.Filename
+					v["size"] = f.Size
+					v[FormFileField] = f
+					ff = append(ff, v)
+					continue
+				}
+
 				file, err := f.Open()
 				if err != nil {
 					return herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to open file")
 				}
 				buffer := make([]byte, f.Size)
-				_, err = file.Read(buffer)
+				_, err = io.ReadFull(file, buffer)
+				_ = file.Close()
 				if err != nil {
 					return herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to read file data")
 				}
@@ -244,8 +681,23 @@ func (this *Connector) ParseFormParams(c *fiber.Ctx, ps htypes.Map) *herrors.Err
 	return nil
 }
 
+// hasRequestBody 请求是否带有请求体，分块传输时长度未知，按有请求体处理
+func hasRequestBody(c *fiber.Ctx) bool {
+	return c.Request().Header.ContentLength() != 0
+}
+
 func (this *Connector) ParseBodyParams(c *fiber.Ctx, ps htypes.Map) *herrors.Error {
-	if c.Request().Header.ContentType() == nil || strings.Index(string(c.Request().Header.ContentType()), "application/json") < 0 {
+	if this.streamBody(c.Params("version"), c.Params("api")) {
+		if ok, err := this.parseBodyStream(c, ps); ok || err != nil {
+			return err
+		}
+	}
+
+	if packer := this.requestPacker(c); packer != nil {
+		return this.unpackBody(c, packer, ps)
+	}
+
+	if !bytes.Contains(c.Request().Header.ContentType(), []byte(fiber.MIMEApplicationJSON)) {
 		return nil
 	}
 	bs := c.Request().Body()
@@ -253,7 +705,7 @@ func (this *Connector) ParseBodyParams(c *fiber.Ctx, ps htypes.Map) *herrors.Err
 		res := make(htypes.Map)
 		err := jsoniter.Unmarshal(bs, &res)
 		if err != nil {
-			return herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to parse body")
+			return jsonBodyError(bs, err, "request body should be a JSON object")
 		}
 
 		for k, v := range res {
@@ -263,37 +715,63 @@ func (this *Connector) ParseBodyParams(c *fiber.Ctx, ps htypes.Map) *herrors.Err
 	return nil
 }
 
+// ParseHeaderParams 只导入HeaderParams中列出的header，可通过HeaderParamPrefix加前缀避免与其他参数冲突
 func (this *Connector) ParseHeaderParams(c *fiber.Ctx, ps htypes.Map) *herrors.Error {
-	c.Request().Header.VisitAll(func(key []byte, val []byte) {
-		ps[string(key)] = string(val)
-	})
+	conf := this.config()
+	for _, key := range conf.HeaderParams {
+		if val := c.Get(key); val != "" {
+			ps[conf.HeaderParamPrefix+key] = val
+		}
+	}
 	return nil
 }
 
+// ParseQueryParams 直接解析原始查询字符串，没有查询参数时不做解析
 func (this *Connector) ParseQueryParams(c *fiber.Ctx) (htypes.Map, *herrors.Error) {
-	u, err := url.Parse(c.Request().URI().String())
-	if err != nil {
-		return nil, herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to parse URL")
+	ps := make(htypes.Map)
+	qs := c.Request().URI().QueryString()
+	if len(qs) == 0 {
+		return ps, nil
 	}
 
-	ps := make(htypes.Map)
-	m, err := url.ParseQuery(u.RawQuery)
+	//忽略无法解析的参数
+	m, _ := url.ParseQuery(string(qs))
 	for k, v := range m {
-		ps[k] = v[0]
+		ps[k] = this.paramValue(v)
 	}
 	return ps, nil
 }
 
+// paramValue 重复的查询参数或表单字段(如 tag=a&tag=b)以[]interface{}传给服务，只有一个值时为字符串。
+// ArrayParams为true时总是使用数组
+func (this *Connector) paramValue(vals []string) htypes.Any {
+	if len(vals) == 1 && !this.config().ArrayParams {
+		return vals[0]
+	}
+	ret := make([]interface{}, len(vals))
+	for i, v := range vals {
+		ret[i] = v
+	}
+	return ret
+}
+
 func (this *Connector) EntityStub() *core.EntityStub {
 	return core.NewEntityStub(
 		&core.EntityStubOptions{
 			Owner:       this,
 			Ping:        nil,
-			GetLoad:     nil,
-			ResetConfig: nil,
+			GetLoad:     this.getLoad,
+			ResetConfig: this.resetConfig,
 		})
 }
 
+// getLoad 处理中的API请求数和请求速率
+func (this *Connector) getLoad(params htypes.Map) (htypes.Any, *herrors.Error) {
+	l := this.load.Load()
+	l.EntityMeta = *this.EntityMeta()
+	return l, nil
+}
+
 func (this *Connector) Config() core.IEntityConf {
 	return &this.conf
 }
@@ -0,0 +1,212 @@
+package hwebconnector
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/valyala/fasthttp"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func newTestApp(gw *htest.Gateway, config ...fiber.Config) *fiber.App {
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.AddressField = "Address"
+	c.conf.HeaderParams = []string{"X-Tenant"}
+	c.conf.MethodField = "Method"
+	applyDefaults(&c.conf)
+
+	app := fiber.New(config...)
+	for _, m := range apiMethods {
+		app.Add(m, "/:version/:api", c.handleServiceAPI)
+	}
+	return app
+}
+
+func TestHandleServiceAPI(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "echo", "demo", "Echo").
+		Handle("demo", "Echo", htest.Return(htypes.Map{"ok": true}))
+	app := newTestApp(gw)
+
+	req := httptest.NewRequest("POST", "/v1/echo?q=abc", strings.NewReader(`{"n":1,"s":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant", "t1")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	ps := gw.Router().(*htest.Router).LastParams("demo", "Echo")
+	htest.AssertParams(t, ps, htypes.Map{"q": "abc", "n": 1, "s": "x", "X-Tenant": "t1"})
+	htest.AssertParam(t, ps, core.RequestIDField, resp.Header.Get(defaultRequestIDHeader))
+	if _, ok := ps["Address"]; !ok {
+		t.Error("address param not set")
+	}
+
+	var res map[string]interface{}
+	bs, _ := ioutil.ReadAll(resp.Body)
+	_ = jsoniter.Unmarshal(bs, &res)
+	if data, _ := res["data"].(map[string]interface{}); data["ok"] != true {
+		t.Errorf("response = %s", bs)
+	}
+}
+
+func TestHandleServiceAPIMethods(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "user", "demo", "User").
+		Handle("demo", "User", htest.Return(htypes.Map{"ok": true}))
+	app := newTestApp(gw)
+
+	for _, m := range []string{"PUT", "PATCH", "DELETE"} {
+		req := httptest.NewRequest(m, "/v1/user?id=1", strings.NewReader(`{"name":"x"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("%s status = %d, want 200", m, resp.StatusCode)
+		}
+		htest.AssertParams(t, gw.LastCall().Params, htypes.Map{"id": "1", "name": "x", "Method": m})
+	}
+}
+
+func TestHandleServiceAPIError(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "busy", "demo", "Busy").
+		Handle("demo", "Busy", htest.Fail(herrors.ErrSysBusy.New("busy")))
+	app := newTestApp(gw)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/v1/busy", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", resp.StatusCode)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/unknown", nil))
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.StatusCode)
+	}
+	if call := gw.LastCall(); call == nil || call.API != "unknown" {
+		t.Errorf("last call = %+v", call)
+	}
+}
+
+func TestHandleServiceAPIArrayParams(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "echo", "demo", "Echo").
+		Handle("demo", "Echo", htest.Return(htypes.Map{}))
+	app := newTestApp(gw)
+
+	if _, err := app.Test(httptest.NewRequest("GET", "/v1/echo?tag=a&tag=b&q=x", nil)); err != nil {
+		t.Fatal(err)
+	}
+	ps := gw.Router().(*htest.Router).LastParams("demo", "Echo")
+	if tags, _ := ps["tag"].([]interface{}); len(tags) != 2 || tags[0] != "a" || tags[1] != "b" {
+		t.Errorf("tag = %#v", ps["tag"])
+	}
+	if ps["q"] != "x" {
+		t.Errorf("q = %#v", ps["q"])
+	}
+}
+
+func TestHandleServiceAPIWithoutBody(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "echo", "demo", "Echo").
+		Handle("demo", "Echo", htest.Return(htypes.Map{}))
+	app := newTestApp(gw)
+
+	//没有请求体时不解析，无法解析的查询参数被忽略
+	req := httptest.NewRequest("GET", "/v1/echo?a=1&b=%zz&c=%E4%B8%AD", nil)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	ps := gw.LastCall().Params
+	if ps["a"] != "1" || ps["c"] != "中" || ps["b"] != nil {
+		t.Errorf("params = %v", ps)
+	}
+}
+
+func BenchmarkHandleServiceAPI(b *testing.B) {
+	gw := htest.NewGateway().
+		Route("v1", "echo", "demo", "Echo").
+		Handle("demo", "Echo", htest.Return(htypes.Map{"ok": true}))
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	applyDefaults(&c.conf)
+	app := fiber.New()
+	app.Get("/:version/:api", c.handleServiceAPI)
+	app.Post("/:version/:api", c.handleServiceAPI)
+	h := app.Handler()
+
+	for _, bc := range []struct {
+		name   string
+		method string
+		body   string
+	}{
+		{"GET", "GET", ""},
+		{"POST", "POST", `{"n":1,"s":"x"}`},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var ctx fasthttp.RequestCtx
+			for i := 0; i < b.N; i++ {
+				ctx.Request.Reset()
+				ctx.Response.Reset()
+				ctx.Request.Header.SetMethod(bc.method)
+				ctx.Request.SetRequestURI("/v1/echo?q=abc&page=1")
+				if bc.body != "" {
+					ctx.Request.Header.SetContentType(fiber.MIMEApplicationJSON)
+					ctx.Request.SetBodyString(bc.body)
+				}
+				h(&ctx)
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "echo", "demo", "Echo").
+		Handle("demo", "Echo", htest.Return(nil))
+	app := newTestApp(gw)
+
+	cases := map[string]bool{
+		"abc-123.x:y":            true,
+		strings.Repeat("a", 129): false,
+		"a b":                    false,
+		"aé":                     false,
+	}
+	for id, keep := range cases {
+		req := httptest.NewRequest("GET", "/v1/echo", nil)
+		req.Header.Set(defaultRequestIDHeader, id)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := resp.Header.Get(defaultRequestIDHeader)
+		if (got == id) != keep || got == "" {
+			t.Errorf("request id %q: response id = %q, want kept %v", id, got, keep)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package hwebconnector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+)
+
+const (
+	contentTypeAllAPIs = "*"
+)
+
+// checkContentType API配置了ContentTypes时，有请求体的请求的Content-Type需为其中之一，
+// 否则返回错误，而不是忽略无法解析的请求体。没有请求体的请求(如GET)不检查
+func (this *Connector) checkContentType(c *fiber.Ctx, version string, api string) *herrors.Error {
+	conf := this.config()
+	want, ok := conf.ContentTypes[fmt.Sprintf("%s/%s", version, api)]
+	if !ok {
+		want, ok = conf.ContentTypes[contentTypeAllAPIs]
+	}
+	if !ok || want == "" {
+		return nil
+	}
+	if c.Request().Header.ContentLength() == 0 && len(c.Request().Body()) == 0 {
+		return nil
+	}
+
+	got := strings.ToLower(strings.TrimSpace(strings.Split(string(c.Request().Header.ContentType()), ";")[0]))
+	for _, t := range strings.Split(want, ",") {
+		if strings.ToLower(strings.TrimSpace(t)) == got {
+			return nil
+		}
+	}
+	if got == "" {
+		got = "none"
+	}
+	return herrors.ErrCallerInvalidRequest.New("expected %s, got %s", strings.TrimSpace(want), got).D("unsupported content type")
+}
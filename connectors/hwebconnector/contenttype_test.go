@@ -0,0 +1,70 @@
+package hwebconnector
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestCheckContentType(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "order", "order", "Create").
+		Route("v1", "import", "order", "Import").
+		Route("v1", "echo", "demo", "Echo").
+		Handle("order", "Create", htest.Return(nil)).
+		Handle("order", "Import", htest.Return(nil)).
+		Handle("demo", "Echo", htest.Return(nil))
+
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.ContentTypes = map[string]string{
+		"v1/order":  "application/json",
+		"v1/import": "application/json, application/x-ndjson",
+	}
+	applyDefaults(&c.conf)
+	app := fiber.New()
+	app.Add("GET", "/:version/:api", c.handleServiceAPI)
+	app.Add("POST", "/:version/:api", c.handleServiceAPI)
+
+	cases := []struct {
+		method string
+		path   string
+		ctype  string
+		body   string
+		status int
+	}{
+		{"POST", "/v1/order", "application/json; charset=utf-8", `{"sku":"a"}`, fiber.StatusOK},
+		{"POST", "/v1/order", "text/plain", `{"sku":"a"}`, fiber.StatusBadRequest},
+		{"POST", "/v1/order", "", `{"sku":"a"}`, fiber.StatusBadRequest},
+		{"GET", "/v1/order?sku=a", "", "", fiber.StatusOK},
+		{"POST", "/v1/import", "application/x-ndjson", "{}\n", fiber.StatusOK},
+		//未配置的API保持原来的行为，忽略无法解析的请求体
+		{"POST", "/v1/echo", "text/plain", `{"sku":"a"}`, fiber.StatusOK},
+	}
+	for _, cs := range cases {
+		req := httptest.NewRequest(cs.method, cs.path, strings.NewReader(cs.body))
+		if cs.ctype != "" {
+			req.Header.Set("Content-Type", cs.ctype)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != cs.status {
+			t.Errorf("%s %s %q: status = %d, want %d", cs.method, cs.path, cs.ctype, resp.StatusCode, cs.status)
+		}
+	}
+
+	c.conf.ContentTypes["*"] = "application/json"
+	req := httptest.NewRequest("POST", "/v1/echo", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "text/plain")
+	resp, _ := app.Test(req)
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("wildcard: status = %d, want 400", resp.StatusCode)
+	}
+}
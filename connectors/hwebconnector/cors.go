@@ -0,0 +1,33 @@
+package hwebconnector
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2/middleware/cors"
+
+	"github.com/drharryhe/has/common/hlogger"
+)
+
+// corsConfig 根据配置生成CORS设置，未配置AllowOrigins时保持允许所有来源的缺省行为
+func (this *Connector) corsConfig() cors.Config {
+	cfg := cors.ConfigDefault
+	if len(this.conf.CorsAllowOrigins) == 0 {
+		hlogger.Warn("web connector CORS allows all origins, configure CorsAllowOrigins to restrict it")
+		return cfg
+	}
+
+	cfg.AllowOrigins = strings.Join(this.conf.CorsAllowOrigins, ",")
+	if len(this.conf.CorsAllowMethods) > 0 {
+		cfg.AllowMethods = strings.Join(this.conf.CorsAllowMethods, ",")
+	}
+	cfg.AllowHeaders = strings.Join(this.conf.CorsAllowHeaders, ",")
+	cfg.ExposeHeaders = strings.Join(this.conf.CorsExposeHeaders, ",")
+	cfg.AllowCredentials = this.conf.CorsAllowCredentials
+	cfg.MaxAge = this.conf.CorsMaxAge
+
+	if cfg.AllowCredentials && cfg.AllowOrigins == "*" {
+		hlogger.Warn("web connector CORS allows credentials from all origins")
+	}
+
+	return cfg
+}
@@ -0,0 +1,179 @@
+package hwebconnector
+
+import (
+	"encoding/csv"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+const (
+	mimeCSV    = "text/csv"
+	formatCSV  = "csv"
+	csvUTF8BOM = "\xef\xbb\xbf"
+)
+
+// wantCSV 请求是否要求以CSV返回，explicit表示通过CSVQuery指定，否则为Accept中text/csv优先于JSON
+func (this *Connector) wantCSV(c *fiber.Ctx) (want bool, explicit bool) {
+	conf := this.config()
+	if conf.CSVQuery != "" && strings.EqualFold(c.Query(conf.CSVQuery), formatCSV) {
+		return true, true
+	}
+	if c.Get(fiber.HeaderAccept) == "" {
+		return false, false
+	}
+	return c.Accepts(fiber.MIMEApplicationJSON, mimeCSV) == mimeCSV, false
+}
+
+// HandleCSVRequest 请求要求CSV且slot返回对象列表时，以CSV作为附件发送，表头为对象的字段名。
+// 通过Accept要求CSV而返回的数据不是列表时按JSON返回；通过CSVQuery要求时返回错误
+func (this *Connector) HandleCSVRequest(c *fiber.Ctx, api string, data htypes.Any) (bool, *herrors.Error) {
+	conf := this.config()
+	want, explicit := this.wantCSV(c)
+	if !want {
+		return false, nil
+	}
+	rows, ok := csvRows(data)
+	if !ok {
+		if explicit {
+			return false, herrors.ErrCallerInvalidRequest.New("response of api %s is not a list of flat objects", api).D("csv not supported")
+		}
+		return false, nil
+	}
+
+	var fields string
+	if conf.FieldsQuery != "" {
+		fields = c.Query(conf.FieldsQuery)
+	}
+	header := csvHeader(rows, fields)
+	c.Vary(fiber.HeaderAccept)
+	c.Set(fiber.HeaderContentType, mimeCSV+"; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, "attachment; filename=\""+api+".csv\"")
+
+	out := c.Response().BodyWriter()
+	if conf.CSVBOM {
+		_, _ = out.Write([]byte(csvUTF8BOM))
+	}
+	w := csv.NewWriter(out)
+	_ = w.Write(header)
+	record := make([]string, len(header))
+	for _, row := range rows {
+		for i, k := range header {
+			record[i] = csvValue(row[k])
+		}
+		_ = w.Write(record)
+	}
+	w.Flush()
+	if e := w.Error(); e != nil {
+		return true, herrors.ErrSysInternal.New(e.Error()).D("failed to send data")
+	}
+	return true, nil
+}
+
+// csvRows 数据为对象列表且字段值都不是对象或数组时返回各行
+func csvRows(data htypes.Any) ([]htypes.Map, bool) {
+	var items []htypes.Any
+	switch val := data.(type) {
+	case []htypes.Map:
+		for _, m := range val {
+			items = append(items, m)
+		}
+	case []map[string]interface{}:
+		for _, m := range val {
+			items = append(items, htypes.Map(m))
+		}
+	case []htypes.Any:
+		items = val
+	default:
+		return nil, false
+	}
+
+	rows := make([]htypes.Map, 0, len(items))
+	for _, item := range items {
+		var row htypes.Map
+		switch m := item.(type) {
+		case htypes.Map:
+			row = m
+		case map[string]interface{}:
+			row = m
+		default:
+			return nil, false
+		}
+		for _, v := range row {
+			if !csvScalar(v) {
+				return nil, false
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, true
+}
+
+func csvScalar(v htypes.Any) bool {
+	if v == nil {
+		return true
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Map, reflect.Array:
+		return false
+	case reflect.Struct:
+		_, ok := v.(time.Time)
+		return ok
+	case reflect.Slice:
+		_, ok := v.([]byte)
+		return ok
+	}
+	return true
+}
+
+// csvHeader 请求通过FieldsQuery选择了字段时按选择的顺序输出这些列，否则输出所有行的字段，按字段名排序
+func csvHeader(rows []htypes.Map, fields string) []string {
+	var header []string
+	if fields != "" {
+		for _, f := range strings.Split(fields, ",") {
+			if f = strings.TrimSpace(f); f != "" && !strings.Contains(f, ".") {
+				header = append(header, f)
+			}
+		}
+		if len(header) > 0 {
+			return header
+		}
+	}
+
+	keys := make(map[string]bool)
+	for _, row := range rows {
+		for k := range row {
+			if !keys[k] {
+				keys[k] = true
+				header = append(header, k)
+			}
+		}
+	}
+	sort.Strings(header)
+	return header
+}
+
+// csvValue 字符串以 = + - @ 开头时加单引号，避免在电子表格中作为公式执行
+func csvValue(v htypes.Any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		if val != "" && strings.ContainsRune("=+-@", rune(val[0])) {
+			return "'" + val
+		}
+		return val
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
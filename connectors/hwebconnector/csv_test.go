@@ -0,0 +1,72 @@
+package hwebconnector
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestCSVResponse(t *testing.T) {
+	rows := []htypes.Any{
+		htypes.Map{"id": 1, "name": "Smith, John", "note": "=SUM(A1)"},
+		htypes.Map{"id": 2, "name": "王五", "email": "w@example.com"},
+	}
+	gw := htest.NewGateway().
+		Route("v1", "users", "demo", "Users").
+		Route("v1", "user", "demo", "User").
+		Handle("demo", "Users", htest.Return(rows)).
+		Handle("demo", "User", htest.Return(htypes.Map{"id": 1, "tags": []string{"a"}}))
+
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.CSVQuery = "format"
+	c.conf.FieldsQuery = "fields"
+	applyDefaults(&c.conf)
+	app := fiber.New()
+	app.Get("/:version/:api", c.handleServiceAPI)
+
+	get := func(url string, accept string) (int, string, string) {
+		t.Helper()
+		req := httptest.NewRequest("GET", url, nil)
+		if accept != "" {
+			req.Header.Set(fiber.HeaderAccept, accept)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, resp.Header.Get(fiber.HeaderContentType), string(bs)
+	}
+
+	want := "email,id,name,note\n,1,\"Smith, John\",'=SUM(A1)\nw@example.com,2,王五,\n"
+	for _, url := range []string{"/v1/users?format=csv", "/v1/users"} {
+		status, ctype, body := get(url, "text/csv")
+		if status != fiber.StatusOK || !strings.HasPrefix(ctype, mimeCSV) || body != want {
+			t.Errorf("GET %s: status = %d, Content-Type = %s, body = %q", url, status, ctype, body)
+		}
+	}
+
+	//按选择的字段顺序输出列
+	if _, _, body := get("/v1/users?format=csv&fields=name,id", ""); body != "name,id\n\"Smith, John\",1\n王五,2\n" {
+		t.Errorf("selected fields: body = %q", body)
+	}
+
+	//不是对象列表时，Accept要求CSV按JSON返回，查询参数要求CSV返回错误
+	if status, ctype, _ := get("/v1/user", "text/csv"); status != fiber.StatusOK || strings.HasPrefix(ctype, mimeCSV) {
+		t.Errorf("non-tabular with Accept: status = %d, Content-Type = %s", status, ctype)
+	}
+	if status, _, body := get("/v1/user?format=csv", ""); status == fiber.StatusOK || !strings.Contains(body, "csv not supported") {
+		t.Errorf("non-tabular with query: status = %d, body = %s", status, body)
+	}
+	if _, ctype, _ := get("/v1/users", "application/json, text/csv;q=0.5"); strings.HasPrefix(ctype, mimeCSV) {
+		t.Errorf("JSON preferred: Content-Type = %s", ctype)
+	}
+}
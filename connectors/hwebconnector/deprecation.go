@@ -0,0 +1,35 @@
+package hwebconnector
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	HeaderDeprecation = "Deprecation"
+	HeaderSunset      = "Sunset"
+)
+
+// deprecationHeaders API映射的slot已废弃时写入 Deprecation header，设置了Sunset和Replacement时
+// 分别写入 Sunset header(RFC 8594) 和指向替代API的 Link header
+func (this *Connector) deprecationHeaders(c *fiber.Ctx, version string, api string) {
+	a := this.Gateway.API(version, api)
+	if a == nil {
+		return
+	}
+	slot := this.Gateway.Server().Slot(a.EndPoint.Service, a.EndPoint.Slot)
+	if slot == nil || !slot.Deprecated {
+		return
+	}
+
+	c.Set(HeaderDeprecation, "true")
+	if t := slot.SunsetTime(); !t.IsZero() {
+		c.Set(HeaderSunset, t.UTC().Format(http.TimeFormat))
+	}
+	if slot.Replacement != "" {
+		c.Set(fiber.HeaderLink, fmt.Sprintf(`</%s>; rel="successor-version"`, strings.TrimPrefix(slot.Replacement, "/")))
+	}
+}
@@ -0,0 +1,41 @@
+package hwebconnector
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestDeprecationHeaders(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "user.list", "user", "ListV1").
+		Route("v2", "user.list", "user", "List").
+		Define("user", core.Slot{Name: "ListV1", Deprecated: true, Sunset: "2027-01-31", Replacement: "v2/user.list"}).
+		Handle("user", "ListV1", htest.Return(nil)).
+		Handle("user", "List", htest.Return(nil))
+	app := newTestApp(gw)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/v1/user.list", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header.Get(HeaderDeprecation); got != "true" {
+		t.Errorf("Deprecation = %q, want true", got)
+	}
+	if got := resp.Header.Get(HeaderSunset); got != "Sun, 31 Jan 2027 00:00:00 GMT" {
+		t.Errorf("Sunset = %q", got)
+	}
+	if got := resp.Header.Get("Link"); got != `</v2/user.list>; rel="successor-version"` {
+		t.Errorf("Link = %q", got)
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/v2/user.list", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Get(HeaderDeprecation) != "" || resp.Header.Get(HeaderSunset) != "" {
+		t.Error("current api should not have deprecation headers")
+	}
+}
@@ -0,0 +1,48 @@
+package hwebconnector
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/hlogger"
+)
+
+const (
+	defaultDisconnectInterval = 200 //milliseconds
+)
+
+// requestContext 返回客户端断开连接时取消的ctx，传给Gateway后服务可通过core.RequestContext(params)感知并中止处理。
+// 请求处理完成后需调用返回的cancel。连接不支持检查(如TLS)或关闭了该功能时ctx只在cancel时取消
+func (this *Connector) requestContext(c *fiber.Ctx, requestID string) (context.Context, context.CancelFunc) {
+	conf := this.config()
+	ctx, cancel := context.WithCancel(hlogger.NewContext(context.Background(), requestID))
+	if conf.IgnoreDisconnect {
+		return ctx, cancel
+	}
+	closed := peerClosedChecker(c.Context().Conn())
+	if closed == nil {
+		return ctx, cancel
+	}
+
+	//fiber.Ctx在请求结束后会被复用，goroutine中不能再访问
+	interval := time.Duration(conf.DisconnectInterval) * time.Millisecond
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if closed() {
+					hlogger.InfoCtx(ctx, "client disconnected, request canceled")
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package hwebconnector
+
+import "net"
+
+// peerClosedChecker 当前平台不支持检查客户端断开
+func peerClosedChecker(conn net.Conn) func() bool {
+	return nil
+}
@@ -0,0 +1,50 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package hwebconnector
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestCancelOnDisconnect(t *testing.T) {
+	canceled := make(chan bool, 1)
+	gw := htest.NewGateway().Route("v1", "slow", "demo", "Slow")
+	gw.Handle("demo", "Slow", func(params htypes.Map) (htypes.Any, *herrors.Error) {
+		select {
+		case <-gw.LastCall().Ctx.Done():
+			canceled <- true
+		case <-time.After(2 * time.Second):
+			canceled <- false
+		}
+		return nil, nil
+	})
+	app := newTestApp(gw, fiber.Config{DisableStartupMessage: true})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = app.Listener(ln) }()
+	defer func() { _ = app.Shutdown() }()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = conn.Write([]byte("GET /v1/slow HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	time.Sleep(100 * time.Millisecond)
+	_ = conn.Close()
+
+	if !<-canceled {
+		t.Error("request not canceled after client disconnected")
+	}
+}
@@ -0,0 +1,39 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package hwebconnector
+
+import (
+	"net"
+	"syscall"
+)
+
+// peerClosedChecker 返回检查对端是否已关闭连接的函数，以MSG_PEEK读取，不影响后续读取请求。
+// 对端关闭写方向(半关闭)也视为断开。conn不是系统socket(如TLS)时返回nil
+func peerClosedChecker(conn net.Conn) func() bool {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return nil
+	}
+
+	buf := make([]byte, 1)
+	return func() bool {
+		closed := false
+		//Control不受连接读超时的影响，MSG_DONTWAIT保证不阻塞
+		_ = rc.Control(func(fd uintptr) {
+			n, _, err := syscall.Recvfrom(int(fd), buf, syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+			switch {
+			case err == syscall.EAGAIN || err == syscall.EWOULDBLOCK || err == syscall.EINTR:
+			case err != nil:
+				closed = true
+			case n == 0:
+				closed = true
+			}
+		})
+		return closed
+	}
+}
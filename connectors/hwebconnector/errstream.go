@@ -0,0 +1,72 @@
+package hwebconnector
+
+import (
+	"bufio"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drharryhe/has/common/hconf"
+	"github.com/drharryhe/has/common/herrors"
+)
+
+const (
+	errEventSnapshot = "snapshot" //连接建立时推送全部统计
+	errEventDelta    = "delta"    //之后只推送新增或次数变化的统计
+)
+
+// handleErrStream 以Server-Sent Events推送错误统计，与/error/statics一样只在Debug模式下可用
+func (this *Connector) handleErrStream(c *fiber.Ctx) error {
+	if !hconf.IsDebug() {
+		_ = c.SendString("error stream not available")
+		return nil
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Locals(noCompressKey, true)
+
+	interval := time.Duration(this.config().ErrorStreamInterval) * time.Second
+	closing := this.closing
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		counts := make(map[string]int)
+		event := errEventSnapshot
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			var changed []herrors.FingerprintStatic
+			for _, s := range herrors.Statics() {
+				if counts[s.Fingerprint] != s.Count {
+					counts[s.Fingerprint] = s.Count
+					changed = append(changed, s)
+				}
+			}
+
+			if event == errEventSnapshot || len(changed) > 0 {
+				if changed == nil {
+					changed = []herrors.FingerprintStatic{}
+				}
+				bs, _ := jsoniter.Marshal(changed)
+				_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, bs)
+				event = errEventDelta
+			} else {
+				//保持连接，同时发现客户端断开
+				_, _ = w.WriteString(": ping\n\n")
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-closing:
+				return
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,95 @@
+package hwebconnector
+
+import (
+	"strings"
+
+	"github.com/drharryhe/has/common/htypes"
+)
+
+// fieldTree 要返回的字段，值为nil表示返回整个字段
+type fieldTree map[string]fieldTree
+
+// parseFields 解析逗号分隔的字段列表，嵌套字段以点分隔，如 id,user.name,items.price。
+// 同时选择了字段和其子字段时返回整个字段，格式不正确的路径忽略
+func parseFields(s string) fieldTree {
+	tree := fieldTree{}
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		parts := strings.Split(f, ".")
+		valid := true
+		for _, p := range parts {
+			if p == "" {
+				valid = false
+				break
+			}
+		}
+		if !valid {
+			continue
+		}
+
+		node := tree
+		for i, p := range parts {
+			if i == len(parts)-1 {
+				node[p] = nil
+				break
+			}
+			child, ok := node[p]
+			if ok && child == nil {
+				break
+			}
+			if !ok {
+				child = fieldTree{}
+				node[p] = child
+			}
+			node = child
+		}
+	}
+	return tree
+}
+
+// selectFields 返回只包含指定字段的新Map，不修改服务返回的数据。
+// 数组中的对象按相同路径选择；不存在的字段忽略，无法继续选择的值(如结构体)整体返回
+func selectFields(data htypes.Map, tree fieldTree) htypes.Map {
+	ret := make(htypes.Map, len(tree))
+	for k, sub := range tree {
+		if v, ok := data[k]; ok {
+			ret[k] = pickFields(v, sub)
+		}
+	}
+	return ret
+}
+
+func pickFields(v interface{}, tree fieldTree) interface{} {
+	if tree == nil {
+		return v
+	}
+
+	switch val := v.(type) {
+	case htypes.Map:
+		return selectFields(val, tree)
+	case map[string]interface{}:
+		return selectFields(val, tree)
+	case []htypes.Map:
+		ret := make([]htypes.Map, len(val))
+		for i, item := range val {
+			ret[i] = selectFields(item, tree)
+		}
+		return ret
+	case []map[string]interface{}:
+		ret := make([]htypes.Map, len(val))
+		for i, item := range val {
+			ret[i] = selectFields(item, tree)
+		}
+		return ret
+	case []interface{}:
+		ret := make([]interface{}, len(val))
+		for i, item := range val {
+			ret[i] = pickFields(item, tree)
+		}
+		return ret
+	}
+	return v
+}
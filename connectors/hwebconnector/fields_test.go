@@ -0,0 +1,41 @@
+package hwebconnector
+
+import (
+	"reflect"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drharryhe/has/common/htypes"
+)
+
+func TestSelectFields(t *testing.T) {
+	data := htypes.Map{
+		"id":   1,
+		"name": "alice",
+		"user": htypes.Map{"name": "bob", "email": "b@x.com"},
+		"items": []htypes.Map{
+			{"sku": "a", "price": 1, "stock": 3},
+			{"sku": "b", "price": 2},
+		},
+		"tags": []interface{}{"x", map[string]interface{}{"k": 1, "v": 2}},
+	}
+
+	got := selectFields(data, parseFields(" id, user.name ,items.price,tags.k,missing,name.first,a..b,"))
+	bs, _ := jsoniter.Marshal(got)
+	want := `{"id":1,"user":{"name":"bob"},"items":[{"price":1},{"price":2}],"tags":["x",{"k":1}],"name":"alice"}`
+
+	var g, w interface{}
+	_ = jsoniter.Unmarshal(bs, &g)
+	_ = jsoniter.Unmarshal([]byte(want), &w)
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("selectFields = %s, want %s", bs, want)
+	}
+	if _, ok := data["user"].(htypes.Map)["email"]; !ok {
+		t.Error("original data modified")
+	}
+
+	if tree := parseFields("user.name,user"); tree["user"] != nil {
+		t.Errorf("parent field should select the whole value, got %v", tree["user"])
+	}
+}
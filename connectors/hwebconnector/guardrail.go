@@ -0,0 +1,34 @@
+package hwebconnector
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+)
+
+const (
+	defaultMaxResponseSize = 64  //MB
+	defaultMaxFileSize     = 256 //MB
+)
+
+// checkResponseSize 序列化后的响应超过MaxResponseSize时返回错误，避免单个请求生成过大的响应
+func (this *Connector) checkResponseSize(c *fiber.Ctx, size int) *herrors.Error {
+	return checkSizeLimit(c, "response", size, this.config().MaxResponseSize)
+}
+
+// checkFileSize 内存中的文件超过MaxFileSize时返回错误，大文件应以DownloadStreamFlag返回
+func (this *Connector) checkFileSize(c *fiber.Ctx, size int) *herrors.Error {
+	return checkSizeLimit(c, "file", size, this.config().MaxFileSize)
+}
+
+// checkSizeLimit limit单位为MB，负数表示不限制。超限通常是slot的缺陷，记录错误日志
+func checkSizeLimit(c *fiber.Ctx, what string, size int, limit int) *herrors.Error {
+	if limit < 0 || size <= limit*1024*1024 {
+		return nil
+	}
+
+	err := herrors.ErrSysInternal.New("%s size %d bytes exceeds limit %d MB, path %s", what, size, limit, c.Path()).D("%s too large", what)
+	hlogger.Error(err)
+	return err
+}
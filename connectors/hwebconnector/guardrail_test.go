@@ -0,0 +1,58 @@
+package hwebconnector
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestSizeGuardrails(t *testing.T) {
+	big := strings.Repeat("x", 2*1024*1024)
+	gw := htest.NewGateway().
+		Route("v1", "big", "demo", "Big").
+		Route("v1", "small", "demo", "Small").
+		Route("v1", "download", "demo", "Download").
+		Handle("demo", "Big", htest.Return(htypes.Map{"s": big})).
+		Handle("demo", "Small", htest.Return(htypes.Map{"s": "ok"})).
+		Handle("demo", "Download", htest.Return(htypes.Map{DownloadFlag: true, "name": "a.bin", "data": []byte(big)}))
+
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.MaxResponseSize = 1
+	c.conf.MaxFileSize = 1
+	applyDefaults(&c.conf)
+	app := fiber.New()
+	app.Get("/:version/:api", c.handleServiceAPI)
+
+	for _, cs := range []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{"/v1/big", fiber.StatusInternalServerError, "response too large"},
+		{"/v1/small", fiber.StatusOK, `"ok"`},
+		{"/v1/download", fiber.StatusInternalServerError, "file too large"},
+	} {
+		resp, err := app.Test(httptest.NewRequest("GET", cs.path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs, _ := ioutil.ReadAll(resp.Body)
+		if resp.StatusCode != cs.status || !strings.Contains(string(bs), cs.want) {
+			t.Errorf("%s: status = %d, body = %.100s", cs.path, resp.StatusCode, bs)
+		}
+	}
+
+	//负数表示不限制
+	c.conf.MaxResponseSize = -1
+	if resp, _ := app.Test(httptest.NewRequest("GET", "/v1/big", nil)); resp.StatusCode != fiber.StatusOK {
+		t.Errorf("unlimited: status = %d", resp.StatusCode)
+	}
+}
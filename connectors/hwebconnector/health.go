@@ -0,0 +1,17 @@
+package hwebconnector
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+func (this *Connector) handleHealth(c *fiber.Ctx) error {
+	return c.SendString("ok")
+}
+
+// handleReady 服务器启动完成前和关闭过程中返回503，避免流量被路由到未就绪的实例
+func (this *Connector) handleReady(c *fiber.Ctx) error {
+	if !this.Gateway.Server().Ready() {
+		return c.Status(fiber.StatusServiceUnavailable).SendString("not ready")
+	}
+	return c.SendString("ok")
+}
@@ -0,0 +1,30 @@
+package hwebconnector
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestReady(t *testing.T) {
+	gw := htest.NewGateway()
+	c := New()
+	c.Gateway = gw
+	app := fiber.New()
+	app.Get("/readyz", c.handleReady)
+
+	if resp, _ := app.Test(httptest.NewRequest("GET", "/readyz", nil)); resp.StatusCode != fiber.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+
+	gw.SetHealth(&core.Health{Healthy: false, Entities: []*core.EntityHealth{
+		{EntityMeta: core.EntityMeta{Type: core.EntityTypePlugin, Class: "GormPlugin"}, Error: "ping timeout after 3s"},
+	}})
+	if resp, _ := app.Test(httptest.NewRequest("GET", "/readyz", nil)); resp.StatusCode != fiber.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", resp.StatusCode)
+	}
+}
@@ -0,0 +1,191 @@
+package hwebconnector
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/patrickmn/go-cache"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+const (
+	defaultIdempotencyHeader = "Idempotency-Key"
+	defaultIdempotencyTTL    = 86400 //seconds
+	idempotencyLockTTL       = 60    //seconds, 处理中的锁最长保留时间，避免进程退出后key一直被锁住
+	idempotencyPrefix        = "idempotency:"
+	idempotencyReplayed      = "Idempotent-Replayed"
+	maxIdempotencyKeyLen     = 255
+)
+
+// IdempotencyStore 保存幂等请求的响应，方法与hcacheplugin.Plugin一致，可直接使用缓存插件
+type IdempotencyStore interface {
+	Get(key string) (htypes.Any, bool, *herrors.Error)
+	Set(key string, val htypes.Any, ttl time.Duration) *herrors.Error
+	SetNX(key string, val htypes.Any, ttl time.Duration) (bool, *herrors.Error)
+	Del(keys ...string) *herrors.Error
+}
+
+// idempotentResponse 缓存的响应，以JSON字符串保存，不依赖存储的序列化方式
+type idempotentResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"type"`
+	Body        []byte `json:"body"`
+}
+
+// memoryIdempotencyStore 没有配置IdempotencyStore时使用，只在单个进程内有效
+type memoryIdempotencyStore struct {
+	cache *cache.Cache
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{cache: cache.New(time.Duration(defaultIdempotencyTTL)*time.Second, 10*time.Minute)}
+}
+
+func (this *memoryIdempotencyStore) Get(key string) (htypes.Any, bool, *herrors.Error) {
+	val, ok := this.cache.Get(key)
+	return val, ok, nil
+}
+
+func (this *memoryIdempotencyStore) Set(key string, val htypes.Any, ttl time.Duration) *herrors.Error {
+	this.cache.Set(key, val, ttl)
+	return nil
+}
+
+func (this *memoryIdempotencyStore) SetNX(key string, val htypes.Any, ttl time.Duration) (bool, *herrors.Error) {
+	return this.cache.Add(key, val, ttl) == nil, nil
+}
+
+func (this *memoryIdempotencyStore) Del(keys ...string) *herrors.Error {
+	for _, k := range keys {
+		this.cache.Delete(k)
+	}
+	return nil
+}
+
+// initIdempotency IdempotencyStore为插件名，插件需实现IdempotencyStore接口
+func (this *Connector) initIdempotency(s *settings) *herrors.Error {
+	if len(s.conf.IdempotentAPIs) == 0 {
+		return nil
+	}
+	if s.conf.IdempotencyStore == "" {
+		if s.idempotency == nil {
+			s.idempotency = newMemoryIdempotencyStore()
+		}
+		return nil
+	}
+
+	store, ok := this.Gateway.Server().Plugin(s.conf.IdempotencyStore).(IdempotencyStore)
+	if !ok {
+		return herrors.ErrSysInternal.New("plugin %s not found or not implement IdempotencyStore", s.conf.IdempotencyStore).D("failed to open web connector")
+	}
+	s.idempotency = store
+	return nil
+}
+
+// idempotencyKey 返回该请求在存储中的key，API未开启或请求未携带Idempotency-Key时返回空字符串
+func (this *Connector) idempotencyKey(c *fiber.Ctx, version string, api string) (string, *herrors.Error) {
+	s := this.current()
+	if s.idempotency == nil {
+		return "", nil
+	}
+	name := fmt.Sprintf("%s/%s", version, api)
+	found := false
+	for _, a := range s.conf.IdempotentAPIs {
+		if a == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", nil
+	}
+
+	key := c.Get(s.conf.IdempotencyHeader)
+	if key == "" {
+		return "", nil
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		return "", herrors.ErrCallerInvalidRequest.New("%s too long", s.conf.IdempotencyHeader).D("invalid idempotency key")
+	}
+	return idempotencyPrefix + name + ":" + key, nil
+}
+
+// beginIdempotent 已有缓存的响应时直接返回该响应，相同key的请求正在处理时返回ErrCallerConflict。
+// 返回true表示已发送响应，不需要再调用服务
+func (this *Connector) beginIdempotent(c *fiber.Ctx, key string) (bool, *herrors.Error) {
+	if sent, err := this.replayIdempotent(c, key); sent || err != nil {
+		return sent, err
+	}
+
+	s := this.current()
+	ok, err := s.idempotency.SetNX(key+":lock", 1, idempotencyLockTTL*time.Second)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, herrors.ErrCallerConflict.New("request with %s %s is in progress", s.conf.IdempotencyHeader, key).D("request in progress")
+	}
+
+	//获得锁之前的请求可能刚好完成
+	if sent, err := this.replayIdempotent(c, key); sent || err != nil {
+		_ = s.idempotency.Del(key + ":lock")
+		return sent, err
+	}
+	return false, nil
+}
+
+func (this *Connector) replayIdempotent(c *fiber.Ctx, key string) (bool, *herrors.Error) {
+	val, ok, err := this.current().idempotency.Get(key)
+	if err != nil || !ok {
+		return false, err
+	}
+	s, _ := val.(string)
+
+	var res idempotentResponse
+	if e := jsoniter.UnmarshalFromString(s, &res); e != nil {
+		hlogger.Warn("invalid idempotent response of %s: %s", key, e.Error())
+		return false, nil
+	}
+
+	c.Status(res.Status)
+	if res.ContentType != "" {
+		c.Set(fiber.HeaderContentType, res.ContentType)
+	}
+	c.Set(idempotencyReplayed, "true")
+	if e := c.Send(res.Body); e != nil {
+		hlogger.Error(herrors.ErrSysInternal.New(e.Error()).D("failed to send data"))
+	}
+	return true, nil
+}
+
+// endIdempotent 保存响应并释放锁。服务器错误和文件流不保存，客户端可以重试
+func (this *Connector) endIdempotent(c *fiber.Ctx, key string) {
+	store := this.current().idempotency
+	defer func() {
+		if err := store.Del(key + ":lock"); err != nil {
+			hlogger.Warn("failed to release idempotency lock %s: %s", key, err.Error())
+		}
+	}()
+
+	if code, ok := c.Locals(errorCodeKey).(int); ok && (code < 0 || (code >= 100 && code < 200)) {
+		return
+	}
+	if c.Response().IsBodyStream() {
+		return
+	}
+
+	res := idempotentResponse{
+		Status:      c.Response().StatusCode(),
+		ContentType: string(c.Response().Header.ContentType()),
+		Body:        c.Response().Body(),
+	}
+	s, _ := jsoniter.MarshalToString(res)
+	if err := store.Set(key, s, time.Duration(this.config().IdempotencyTTL)*time.Second); err != nil {
+		hlogger.Warn("failed to save idempotent response %s: %s", key, err.Error())
+	}
+}
@@ -0,0 +1,64 @@
+package hwebconnector
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestIdempotency(t *testing.T) {
+	calls := 0
+	gw := htest.NewGateway().
+		Route("v1", "pay", "demo", "Pay").
+		Handle("demo", "Pay", func(ps htypes.Map) (htypes.Any, *herrors.Error) {
+			calls++
+			return calls, nil
+		})
+
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.IdempotentAPIs = []string{"v1/pay"}
+	applyDefaults(&c.conf)
+	if err := c.publishSettings(c.conf); err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Post("/:version/:api", c.handleServiceAPI)
+
+	pay := func(key string) (int, string, string) {
+		req := httptest.NewRequest("POST", "/v1/pay", nil)
+		if key != "" {
+			req.Header.Set(defaultIdempotencyHeader, key)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, resp.Header.Get(idempotencyReplayed), string(bs)
+	}
+
+	_, _, first := pay("k1")
+	status, replayed, second := pay("k1")
+	if calls != 1 || status != fiber.StatusOK || replayed != "true" || second != first {
+		t.Errorf("retry: calls = %d, status = %d, replayed = %q, body = %s, want %s", calls, status, replayed, second, first)
+	}
+
+	if pay(""); calls != 2 {
+		t.Errorf("request without key should not be cached, calls = %d", calls)
+	}
+
+	//相同key的请求正在处理
+	_, _ = c.current().idempotency.SetNX(idempotencyPrefix+"v1/pay:k2:lock", 1, time.Minute)
+	if status, _, _ = pay("k2"); status != fiber.StatusConflict || calls != 2 {
+		t.Errorf("concurrent: status = %d, calls = %d, want 409 and 2", status, calls)
+	}
+}
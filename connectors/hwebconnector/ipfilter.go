@@ -0,0 +1,127 @@
+package hwebconnector
+
+import (
+	"net"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+)
+
+const (
+	headerXRealIP = "X-Real-IP"
+)
+
+// ipFilter 按CIDR允许或拒绝客户端IP，拒绝列表优先，允许列表为空时允许所有不在拒绝列表中的IP
+type ipFilter struct {
+	allows []*net.IPNet
+	denies []*net.IPNet
+}
+
+func newIPFilter(allows []string, denies []string) *ipFilter {
+	if len(allows) == 0 && len(denies) == 0 {
+		return nil
+	}
+
+	return &ipFilter{
+		allows: parseCIDRs(allows, "allow ip"),
+		denies: parseCIDRs(denies, "deny ip"),
+	}
+}
+
+func (this *ipFilter) allow(ip string) bool {
+	if ipInNets(ip, this.denies) {
+		return false
+	}
+	return len(this.allows) == 0 || ipInNets(ip, this.allows)
+}
+
+// parseCIDRs 解析IP或CIDR列表，单个IP按/32或/128处理，无效项记录日志后忽略
+func parseCIDRs(items []string, what string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, item := range items {
+		if !strings.Contains(item, "/") {
+			if strings.Contains(item, ":") {
+				item += "/128"
+			} else {
+				item += "/32"
+			}
+		}
+		_, n, err := net.ParseCIDR(item)
+		if err != nil {
+			hlogger.Error(herrors.ErrSysInternal.New("invalid %s item %s", what, item).D(err.Error()))
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+func ipInNets(ip string, nets []*net.IPNet) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *Connector) initIPFilter(s *settings) {
+	s.ipFilter = newIPFilter(s.conf.AllowIPs, s.conf.DenyIPs)
+	s.proxies = parseCIDRs(s.conf.TrustedProxies, "trusted proxy")
+}
+
+// clientIP 请求来自TrustedProxies中的代理时，从X-Forwarded-For中自右向左取第一个不是代理的地址，
+// 没有X-Forwarded-For时使用X-Real-IP。请求不是来自可信代理时忽略这些header，使用连接的对端地址，避免客户端伪造
+func (this *Connector) clientIP(c *fiber.Ctx) string {
+	ip := c.IP()
+	proxies := this.current().proxies
+	if len(proxies) == 0 || !ipInNets(ip, proxies) {
+		return ip
+	}
+
+	if xff := c.Get(fiber.HeaderXForwardedFor); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr := strings.TrimSpace(hops[i])
+			if net.ParseIP(addr) == nil {
+				continue
+			}
+			ip = addr
+			if !ipInNets(addr, proxies) {
+				break
+			}
+		}
+		return ip
+	}
+
+	if realIP := strings.TrimSpace(c.Get(headerXRealIP)); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	return ip
+}
+
+// handleIPFilter 监听单独配置了AllowIPs或DenyIPs时使用监听的设置，否则使用连接器的设置
+func (this *Connector) handleIPFilter(l *Listener) fiber.Handler {
+	own := newIPFilter(l.AllowIPs, l.DenyIPs)
+
+	return func(c *fiber.Ctx) error {
+		filter := own
+		if filter == nil {
+			filter = this.current().ipFilter
+		}
+		if filter != nil {
+			if ip := this.clientIP(c); !filter.allow(ip) {
+				this.SendResponse(c, nil, herrors.ErrCallerForbidden.New("ip %s forbidden", ip).D("access forbidden"))
+				return nil
+			}
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,102 @@
+package hwebconnector
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestIPFilter(t *testing.T) {
+	f := newIPFilter([]string{"10.0.0.0/8", "2001:db8::/32"}, []string{"10.0.0.1"})
+
+	cases := map[string]bool{
+		"10.1.2.3":    true,
+		"10.0.0.1":    false,
+		"192.168.1.1": false,
+		"2001:db8::1": true,
+		"2001:db9::1": false,
+		"bad-ip":      false,
+	}
+	for ip, want := range cases {
+		if got := f.allow(ip); got != want {
+			t.Errorf("allow(%s) = %v, want %v", ip, got, want)
+		}
+	}
+
+	if newIPFilter(nil, nil) != nil {
+		t.Error("empty filter should be nil")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	c := New()
+	c.conf.TrustedProxies = []string{"0.0.0.0/0"}
+	if err := c.publishSettings(c.conf); err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	app := fiber.New()
+	app.Get("/", func(ctx *fiber.Ctx) error {
+		got = c.clientIP(ctx)
+		return nil
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(fiber.HeaderXForwardedFor, "1.2.3.4, 0.0.0.1")
+	if _, err := app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+	//所有地址都是可信代理时取最左边的地址
+	if got != "1.2.3.4" {
+		t.Errorf("clientIP = %s, want 1.2.3.4", got)
+	}
+
+	c.conf.TrustedProxies = []string{"10.0.0.0/8"}
+	if err := c.publishSettings(c.conf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+	if got != "0.0.0.0" {
+		t.Errorf("clientIP = %s, want remote address 0.0.0.0", got)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(headerXRealIP, "5.6.7.8")
+	c.conf.TrustedProxies = []string{"0.0.0.0"}
+	if err := c.publishSettings(c.conf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+	if got != "5.6.7.8" {
+		t.Errorf("clientIP = %s, want X-Real-IP 5.6.7.8", got)
+	}
+}
+
+func TestIPFilterMetrics(t *testing.T) {
+	gw := htest.NewGateway()
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.Metrics = true
+	applyDefaults(&c.conf)
+	initMetrics()
+
+	for allow, status := range map[string]int{"10.0.0.0/8": fiber.StatusForbidden, "0.0.0.0/32": fiber.StatusOK} {
+		app := c.newApp(&Listener{AllowIPs: []string{allow}})
+		resp, err := app.Test(httptest.NewRequest("GET", c.conf.MetricsPath, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != status {
+			t.Errorf("allow %s: status = %d, want %d", allow, resp.StatusCode, status)
+		}
+	}
+}
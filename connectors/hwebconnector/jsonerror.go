@@ -0,0 +1,129 @@
+package hwebconnector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/drharryhe/has/common/herrors"
+)
+
+// jsonFrame 扫描JSON时所在的对象或数组
+type jsonFrame struct {
+	array    bool
+	index    int    //数组中当前元素的下标
+	key      string //对象中当前或最后一个字段
+	valueDue bool   //对象中已读到key，等待value
+}
+
+// jsonBodyError 请求体JSON解析失败时，重新扫描请求体，在错误描述中给出出错的行、列和字段。
+// 语法正确时是请求体类型不符，描述为mismatch
+func jsonBodyError(bs []byte, cause error, mismatch string) *herrors.Error {
+	reason := describeJSONError(bs)
+	if reason == "" {
+		reason = mismatch
+	}
+	return herrors.ErrCallerInvalidRequest.New(cause.Error()).D("failed to parse body: %s", reason)
+}
+
+// describeJSONError 返回语法错误的位置和字段，
+// 如 line 3, column 12, field user.tags[1]: invalid character '}' looking for beginning of value。
+// 语法正确时返回空字符串
+func describeJSONError(bs []byte) string {
+	var v interface{}
+	err := json.Unmarshal(bs, &v)
+	if err == nil {
+		return ""
+	}
+	se, ok := err.(*json.SyntaxError)
+	if !ok {
+		return err.Error()
+	}
+	return jsonErrorAt(bs, int(se.Offset), jsonStack(bs, se.Offset), se.Error())
+}
+
+// jsonStack 逐个token扫描到offset，返回所在的对象和数组
+func jsonStack(bs []byte, offset int64) []*jsonFrame {
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.UseNumber()
+
+	var stack []*jsonFrame
+	for dec.InputOffset() < offset {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+
+		var top *jsonFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		if top != nil && !top.array && !top.valueDue {
+			//对象中的key
+			if key, ok := tok.(string); ok {
+				top.key = key
+				top.valueDue = true
+				continue
+			}
+		}
+
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			stack = append(stack, &jsonFrame{array: tok == json.Delim('[')})
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return nil
+			}
+			top = stack[len(stack)-1]
+		}
+
+		//一个完整的value结束
+		switch {
+		case top == nil:
+			return nil
+		case top.array:
+			top.index++
+		default:
+			top.valueDue = false
+		}
+	}
+	return stack
+}
+
+func jsonErrorAt(bs []byte, offset int, stack []*jsonFrame, reason string) string {
+	if offset > len(bs) {
+		offset = len(bs)
+	}
+	line := 1 + bytes.Count(bs[:offset], []byte("\n"))
+	column := 1 + utf8.RuneCount(bs[bytes.LastIndexByte(bs[:offset], '\n')+1:offset])
+	//SyntaxError.Offset为出错字符之后的位置
+	if column > 1 {
+		column--
+	}
+
+	if field := jsonPath(stack); field != "" {
+		return fmt.Sprintf("line %d, column %d, field %s: %s", line, column, field, reason)
+	}
+	return fmt.Sprintf("line %d, column %d: %s", line, column, reason)
+}
+
+// jsonPath 出错位置所在的字段，如 user.tags[1]
+func jsonPath(stack []*jsonFrame) string {
+	var b strings.Builder
+	for _, f := range stack {
+		switch {
+		case f.array:
+			fmt.Fprintf(&b, "[%d]", f.index)
+		case f.key != "":
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(f.key)
+		}
+	}
+	return b.String()
+}
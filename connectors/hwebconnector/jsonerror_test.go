@@ -0,0 +1,65 @@
+package hwebconnector
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/htypes"
+)
+
+func TestDescribeJSONError(t *testing.T) {
+	cases := []struct {
+		body string
+		want string
+	}{
+		{`{"a":1}`, ""},
+		{`[1,2]`, ""},
+		{``, "line 1, column 1: unexpected end of JSON input"},
+		{`{"a":1,}`, "line 1, column 8, field a: invalid character '}' looking for beginning of object key string"},
+		{"{\n  \"user\": {\n    \"name\": \"x\",\n    \"tags\": [1, 2 3]\n  }\n}", "line 4, column 19, field user.tags[2]: invalid character '3' after array element"},
+		{`{"a":tru}`, "line 1, column 9, field a: invalid character '}' in literal true (expecting 'e')"},
+		{`{"a":[{"b":1},{"c" 2}]}`, "line 1, column 20, field a[1].c: invalid character '2' after object key"},
+		{`{"a":"x"`, "line 1, column 8, field a: unexpected end of JSON input"},
+		{`{"a":1} {"b":2}`, "line 1, column 9: invalid character '{' after top-level value"},
+		{"{\"名\":\"值\",x}", "line 1, column 10, field 名: invalid character 'x' looking for beginning of object key string"},
+	}
+	for _, c := range cases {
+		if got := describeJSONError([]byte(c.body)); got != c.want {
+			t.Errorf("%q: got %q, want %q", c.body, got, c.want)
+		}
+	}
+}
+
+func TestParseBodyParamsJSONError(t *testing.T) {
+	c := New()
+	applyDefaults(&c.conf)
+	app := fiber.New()
+	app.Post("/", func(ctx *fiber.Ctx) error {
+		if err := c.ParseBodyParams(ctx, htypes.Map{}); err != nil {
+			return ctx.SendString(err.Desc)
+		}
+		return ctx.SendString("ok")
+	})
+
+	cases := map[string]string{
+		`{"a":1}`:       "ok",
+		`[1]`:           "failed to parse body: request body should be a JSON object",
+		`{"a":{"b":x}}`: "failed to parse body: line 1, column 11, field a.b: invalid character 'x' looking for beginning of value",
+	}
+	for body, want := range cases {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs, _ := ioutil.ReadAll(resp.Body)
+		if string(bs) != want {
+			t.Errorf("%s: got %q, want %q", body, bs, want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package hwebconnector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/utils/hjwt"
+)
+
+const (
+	defaultJwtHeader       = "Authorization"
+	defaultJwtSubjectField = "JwtSubject"
+	defaultJwtClaimsField  = "JwtClaims"
+)
+
+func (this *Connector) initJwt(s *settings) *herrors.Error {
+	if s.conf.JwtSecret == "" {
+		return nil
+	}
+
+	if s.conf.JwtHeader == "" {
+		s.conf.JwtHeader = defaultJwtHeader
+	}
+	if s.conf.JwtSubjectField == "" {
+		s.conf.JwtSubjectField = defaultJwtSubjectField
+	}
+	if s.conf.JwtClaimsField == "" {
+		s.conf.JwtClaimsField = defaultJwtClaimsField
+	}
+
+	signer, err := hjwt.New(s.conf.JwtSecret, s.conf.JwtAlgorithm, 0, s.conf.JwtIssuer)
+	if err != nil {
+		return herrors.ErrSysInternal.New(err.Error()).D("failed to init jwt")
+	}
+	s.jwt = signer
+
+	s.jwtExcludes = make(map[string]bool)
+	for _, api := range s.conf.JwtExcludeAPIs {
+		s.jwtExcludes[api] = true
+	}
+	return nil
+}
+
+// verifyJwt 校验请求携带的JWT，并将subject和claims写入参数，客户端提交的同名参数被丢弃。
+// 携带了无效token的请求总是被拒绝；未携带token时，只有JwtRequired且不在JwtExcludeAPIs中的API被拒绝
+func (this *Connector) verifyJwt(c *fiber.Ctx, version string, api string, ps htypes.Map) *herrors.Error {
+	s := this.current()
+	if s.conf.JwtSecret == "" {
+		return nil
+	}
+	delete(ps, s.conf.JwtSubjectField)
+	delete(ps, s.conf.JwtClaimsField)
+	//配置了JWT时不能因校验器缺失而跳过认证
+	if s.jwt == nil {
+		return herrors.ErrSysInternal.New("jwt verifier not initialized").D("unauthorized access")
+	}
+
+	token := jwtToken(c, s.conf.JwtHeader)
+	if token == "" {
+		if s.conf.JwtRequired && !s.jwtExcludes[fmt.Sprintf("%s/%s", version, api)] {
+			return herrors.ErrCallerUnauthorizedAccess.New("jwt not found").D("unauthorized access")
+		}
+		return nil
+	}
+
+	claims, err := s.jwt.Verify(token)
+	if err != nil {
+		return herrors.ErrCallerUnauthorizedAccess.New(err.Error()).D("invalid token")
+	}
+
+	ps[s.conf.JwtSubjectField] = claims[hjwt.ClaimSubject]
+	ps[s.conf.JwtClaimsField] = htypes.Map(claims)
+	core.SetScoped(ps, core.ScopeSubject, claims[hjwt.ClaimSubject])
+	core.SetScoped(ps, core.ScopeClaims, htypes.Map(claims))
+	return nil
+}
+
+// jwtToken 从JwtHeader中取token，去掉Bearer前缀
+func jwtToken(c *fiber.Ctx, header string) string {
+	token := strings.TrimSpace(c.Get(header))
+	if len(token) > 7 && strings.EqualFold(token[:7], "Bearer ") {
+		token = strings.TrimSpace(token[7:])
+	}
+	return token
+}
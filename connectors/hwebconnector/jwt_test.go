@@ -0,0 +1,64 @@
+package hwebconnector
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestVerifyJwt(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "echo", "demo", "Echo").
+		Handle("demo", "Echo", htest.Return(htypes.Map{"ok": true}))
+
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.JwtSecret = "secret"
+	applyDefaults(&c.conf)
+	if err := c.publishSettings(c.conf); err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Post("/:version/:api", c.handleServiceAPI)
+
+	send := func(token string) htypes.Map {
+		req := httptest.NewRequest("POST", "/v1/echo", strings.NewReader(`{"JwtClaims":{"roles":["admin"]},"JwtSubject":"root"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set(defaultJwtHeader, "Bearer "+token)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("status = %d, want 200", resp.StatusCode)
+		}
+		return gw.Router().(*htest.Router).LastParams("demo", "Echo")
+	}
+
+	//未携带token时丢弃客户端提交的subject和claims
+	ps := send("")
+	htest.AssertNoParam(t, ps, defaultJwtSubjectField)
+	htest.AssertNoParam(t, ps, defaultJwtClaimsField)
+	if _, ok := core.Scoped(ps, core.ScopeClaims); ok {
+		t.Error("claims scoped without token")
+	}
+
+	token, err := c.current().jwt.Issue("alice", map[string]interface{}{"roles": []string{"user"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps = send(token)
+	htest.AssertParam(t, ps, defaultJwtSubjectField, "alice")
+	if subject := core.ScopedSubject(ps); subject != "alice" {
+		t.Errorf("scoped subject = %s, want alice", subject)
+	}
+}
@@ -0,0 +1,66 @@
+package hwebconnector
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/core"
+)
+
+// translate 翻译错误描述和字段错误。语言优先取LangQuery指定的查询参数，其次是Accept-Language，最后是配置的Lang，
+// 找不到译文时保留原始描述
+func (this *Connector) translate(c *fiber.Ctx, err *herrors.Error) *herrors.Error {
+	trans := this.Gateway.I18n()
+	if trans == nil {
+		return err
+	}
+	langs := this.requestLangs(c)
+	err = core.TranslateFields(trans, err, langs...)
+
+	//描述中带细节时，如 failed to parse body: line 1, column 2: ...，只翻译前半部分
+	prefix, detail := err.Desc, ""
+	if i := strings.Index(err.Desc, ": "); i > 0 {
+		prefix, detail = err.Desc[:i], err.Desc[i:]
+	}
+	for _, lang := range langs {
+		if t := trans.Translate(lang, err.Desc); t != err.Desc {
+			return err.D(t)
+		}
+		if detail == "" {
+			continue
+		}
+		if t := trans.Translate(lang, prefix); t != prefix {
+			return err.D("%s%s", t, detail)
+		}
+	}
+	return err
+}
+
+func (this *Connector) requestLangs(c *fiber.Ctx) []string {
+	conf := this.config()
+	var langs []string
+	if conf.LangQuery != "" {
+		if lang := c.Query(conf.LangQuery); lang != "" {
+			langs = append(langs, strings.ToLower(lang))
+		}
+	}
+
+	if conf.LangFromHeader {
+		for _, tag := range strings.Split(c.Get(fiber.HeaderAcceptLanguage), ",") {
+			//去掉权重，如 zh-CN;q=0.9
+			if i := strings.Index(tag, ";"); i >= 0 {
+				tag = tag[:i]
+			}
+			if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" && tag != "*" {
+				langs = append(langs, tag)
+			}
+		}
+	}
+
+	if conf.Lang != "" {
+		langs = append(langs, conf.Lang)
+	}
+	return langs
+}
@@ -0,0 +1,146 @@
+package hwebconnector
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+
+	"github.com/drharryhe/has/common/herrors"
+)
+
+// 监听可以提供的路由分组
+const (
+	RouteAPI     = "api"     // /:version/:api
+	RouteAdmin   = "admin"   // /admin/...
+	RouteError   = "error"   // /error/...
+	RouteHealth  = "health"  // HealthPath, ReadyPath
+	RouteMetrics = "metrics" // MetricsPath
+)
+
+// Listener 监听设置，每个监听使用独立的fiber App
+type Listener struct {
+	Port            int
+	Tls             bool
+	TlsCertPath     string
+	TlsKeyPath      string
+	TlsCertificates []TlsCertificate // 其他域名的证书，按SNI选择
+	Routes          []string         // 提供的路由分组，不配置则提供全部路由
+	AllowIPs        []string         // 单独设置该监听允许的IP或CIDR，不配置则使用连接器的AllowIPs和DenyIPs
+	DenyIPs         []string
+}
+
+func (this *Listener) serves(route string) bool {
+	if len(this.Routes) == 0 {
+		return true
+	}
+	for _, r := range this.Routes {
+		if r == route {
+			return true
+		}
+	}
+	return false
+}
+
+// listeners 没有配置Listeners时，按Port和Tls设置使用单个监听
+func (this *Connector) listeners() []Listener {
+	if len(this.conf.Listeners) > 0 {
+		return this.conf.Listeners
+	}
+
+	return []Listener{{
+		Port:            this.conf.Port,
+		Tls:             this.conf.Tls,
+		TlsCertPath:     this.conf.TlsCertPath,
+		TlsKeyPath:      this.conf.TlsKeyPath,
+		TlsCertificates: this.conf.TlsCertificates,
+	}}
+}
+
+func (this *Connector) newApp(l *Listener) *fiber.App {
+	app := fiber.New(fiber.Config{
+		BodyLimit:         fiberBodyLimit(&this.conf),
+		StreamRequestBody: streamRequestBody(&this.conf),
+		ReadTimeout:       time.Duration(this.conf.ReadTimeout) * time.Second,
+		WriteTimeout:      time.Duration(this.conf.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(this.conf.IdleTimeout) * time.Second,
+		Concurrency:       this.conf.Concurrency,
+	})
+
+	ipFilter := this.handleIPFilter(l)
+	app.Use(cors.New(this.corsConfig()))
+	//健康检查和监控在访问日志和限流之前注册，不受其影响，监控仍按IP过滤
+	if !this.conf.DisableHealth && l.serves(RouteHealth) {
+		app.Get(this.conf.HealthPath, this.handleHealth)
+		app.Get(this.conf.ReadyPath, this.handleReady)
+	}
+	if this.conf.Metrics && l.serves(RouteMetrics) {
+		app.Get(this.conf.MetricsPath, ipFilter, this.handleMetrics)
+	}
+	if this.conf.AccessLog {
+		app.Use(this.handleAccessLog)
+	}
+	if this.conf.Compression > 0 {
+		app.Use(this.handleCompress)
+	}
+	//在压缩之后注册，记录压缩前的响应体
+	if this.conf.BodyLog {
+		app.Use(this.handleBodyLog)
+	}
+	app.Use(ipFilter)
+	if this.conf.RequestsPerSecond > 0 {
+		app.Use(this.handleRateLimit)
+	}
+	if l.serves(RouteError) {
+		app.Get("/error/query/:fingerprint", this.handleErrFingerprint)
+		app.Get("/error/statics", this.handleErrStatics)
+		app.Get("/error/stream", this.handleErrStream)
+	}
+	if l.serves(RouteAdmin) {
+		app.Get("/admin/services", this.handleAdminServices)
+		app.Get("/admin/services/:service", this.handleAdminService)
+		app.Get("/admin/openapi.json", this.handleOpenAPI)
+		app.Get("/admin/client.go", this.handleAdminClient)
+		app.Get("/admin/load", this.handleAdminLoad)
+		app.Get("/admin/health", this.handleAdminHealth)
+		app.Get("/admin/build", this.handleAdminBuild)
+		app.Get("/admin/logs", this.handleLogTail)
+		app.Get("/admin/breakers", this.handleAdminBreakers)
+		app.Post("/admin/breakers/:name/trip", this.handleAdminTripBreaker)
+		app.Post("/admin/breakers/:name/reset", this.handleAdminResetBreaker)
+	}
+	if l.serves(RouteAPI) {
+		if this.conf.Batch {
+			app.Post(this.conf.BatchPath, this.handleBatch)
+		}
+		if this.conf.SignedURLSecret != "" {
+			app.Get(this.conf.SignedURLPath+"/:version/:api", this.handleSignedAPI)
+		}
+		for _, m := range apiMethods {
+			app.Add(m, "/:version/:api", this.handleServiceAPI)
+		}
+	}
+
+	return app
+}
+
+func (this *Connector) listen(app *fiber.App, l *Listener, config *tls.Config) {
+	if config != nil {
+		ln, err := tls.Listen("tcp", fmt.Sprintf(":%d", l.Port), config)
+		if err != nil {
+			panic(herrors.ErrSysInternal.New(err.Error()).D("failed to listen tls"))
+		}
+
+		err = app.Listener(ln)
+		if err != nil {
+			panic(herrors.ErrSysInternal.New(err.Error()).D("failed to listen Fiber App"))
+		}
+	} else {
+		err := app.Listen(fmt.Sprintf(":%d", l.Port))
+		if err != nil {
+			panic(herrors.ErrSysInternal.New(err.Error()).D("failed to listen Fiber App"))
+		}
+	}
+}
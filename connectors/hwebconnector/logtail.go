@@ -0,0 +1,135 @@
+package hwebconnector
+
+import (
+	"strings"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+)
+
+const (
+	logTailBuffer       = 1024
+	logTailWriteTimeout = 10 * time.Second
+	logTailPingInterval = 30 * time.Second
+)
+
+type logTailRecord struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Msg     string `json:"msg"`
+	Dropped uint64 `json:"dropped,omitempty"` //自上一条以来因客户端处理不及时丢弃的条数
+}
+
+// logFilter level为最低级别(含)，q为日志内容需包含的字符串
+type logFilter struct {
+	level int
+	q     string
+}
+
+func (this *logFilter) match(r *hlogger.Record) bool {
+	if r.Level > this.level {
+		return false
+	}
+	return this.q == "" || strings.Contains(r.Msg, this.q)
+}
+
+func newLogFilter(c *fiber.Ctx) (*logFilter, *herrors.Error) {
+	f := &logFilter{level: hlogger.LevelDebug, q: c.Query("q")}
+	if l := c.Query("level"); l != "" {
+		level, err := hlogger.ParseLevel(l)
+		if err != nil {
+			return nil, herrors.ErrCallerInvalidRequest.New(err.Error()).D("invalid log level")
+		}
+		f.level = level
+	}
+	return f, nil
+}
+
+var logTailUpgrader = websocket.FastHTTPUpgrader{
+	CheckOrigin: func(ctx *fasthttp.RequestCtx) bool {
+		return true
+	},
+}
+
+// handleLogTail 以WebSocket推送实时日志，支持 level(最低级别) 和 q(包含的字符串) 过滤，仅在Debug模式下可用。
+// 浏览器无法为WebSocket设置header，AdminToken也可通过token查询参数携带
+func (this *Connector) handleLogTail(c *fiber.Ctx) error {
+	token := c.Get(adminTokenHeader)
+	if token == "" {
+		token = c.Query("token")
+	}
+	if !this.checkAdminToken(c, token) {
+		return nil
+	}
+	if !websocket.FastHTTPIsWebSocketUpgrade(c.Context()) {
+		return fiber.ErrUpgradeRequired
+	}
+
+	filter, err := newLogFilter(c)
+	if err != nil {
+		this.SendResponse(c, nil, err)
+		return nil
+	}
+
+	closing := this.closing
+	e := logTailUpgrader.Upgrade(c.Context(), func(conn *websocket.Conn) {
+		defer conn.Close()
+
+		sub := hlogger.Subscribe(logTailBuffer)
+		defer sub.Close()
+
+		//只读取控制帧，客户端断开时结束推送
+		gone := make(chan struct{})
+		go func() {
+			defer close(gone)
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+
+		ticker := time.NewTicker(logTailPingInterval)
+		defer ticker.Stop()
+		var dropped uint64
+		for {
+			select {
+			case r := <-sub.C:
+				if !filter.match(r) {
+					continue
+				}
+				rec := logTailRecord{
+					Time:  r.Time.Format(time.RFC3339Nano),
+					Level: hlogger.LevelName(r.Level),
+					Msg:   r.Msg,
+				}
+				if d := sub.Dropped(); d != dropped {
+					rec.Dropped = d - dropped
+					dropped = d
+				}
+				_ = conn.SetWriteDeadline(time.Now().Add(logTailWriteTimeout))
+				if err := conn.WriteJSON(&rec); err != nil {
+					return
+				}
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(logTailWriteTimeout)); err != nil {
+					return
+				}
+			case <-gone:
+				return
+			case <-closing:
+				_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(time.Second))
+				return
+			}
+		}
+	})
+	if e != nil {
+		hlogger.Warn("failed to upgrade log tail: %s", e.Error())
+	}
+	return nil
+}
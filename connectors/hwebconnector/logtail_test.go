@@ -0,0 +1,25 @@
+package hwebconnector
+
+import (
+	"testing"
+
+	"github.com/drharryhe/has/common/hlogger"
+)
+
+func TestLogFilter(t *testing.T) {
+	f := &logFilter{level: hlogger.LevelWarning, q: "order"}
+	cases := []struct {
+		r    hlogger.Record
+		want bool
+	}{
+		{hlogger.Record{Level: hlogger.LevelError, Msg: "[E] order 42 failed"}, true},
+		{hlogger.Record{Level: hlogger.LevelWarning, Msg: "[W] order 42 slow"}, true},
+		{hlogger.Record{Level: hlogger.LevelInfo, Msg: "[I] order 42 created"}, false},
+		{hlogger.Record{Level: hlogger.LevelError, Msg: "[E] user 7 failed"}, false},
+	}
+	for _, c := range cases {
+		if got := f.match(&c.r); got != c.want {
+			t.Errorf("match(%q) = %v, want %v", c.r.Msg, got, c.want)
+		}
+	}
+}
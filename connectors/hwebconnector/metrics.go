@@ -0,0 +1,76 @@
+package hwebconnector
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+
+	"github.com/drharryhe/has/common/herrors"
+)
+
+var (
+	metricsOnce    sync.Once
+	metricsHandler fasthttp.RequestHandler
+
+	requestsTotal    prometheus.Counter
+	apiRequestsTotal *prometheus.CounterVec
+	apiErrorsTotal   *prometheus.CounterVec
+	apiLatency       *prometheus.HistogramVec
+)
+
+// initMetrics 注册到prometheus缺省的Registry，多个connector共用同一组指标
+func initMetrics() {
+	metricsOnce.Do(func() {
+		requestsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "has",
+			Name:      "requests_total",
+			Help:      "Total number of API requests.",
+		})
+		apiRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "has",
+			Name:      "api_requests_total",
+			Help:      "Number of API requests by version and api.",
+		}, []string{"version", "api"})
+		apiErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "has",
+			Name:      "api_errors_total",
+			Help:      "Number of API errors by version, api and error code.",
+		}, []string{"version", "api", "code"})
+		apiLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "has",
+			Name:      "api_request_duration_seconds",
+			Help:      "API request latency by version and api.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"version", "api"})
+
+		prometheus.MustRegister(requestsTotal, apiRequestsTotal, apiErrorsTotal, apiLatency)
+		metricsHandler = fasthttpadaptor.NewFastHTTPHandler(promhttp.Handler())
+	})
+}
+
+func (this *Connector) handleMetrics(c *fiber.Ctx) error {
+	metricsHandler(c.Context())
+	return nil
+}
+
+func observeRequest(c *fiber.Ctx, version string, api string, start time.Time) {
+	code, _ := c.Locals(errorCodeKey).(int)
+	observeAPI(version, api, code, start)
+}
+
+// observeAPI 批量请求中的每次调用单独计数
+func observeAPI(version string, api string, code int, start time.Time) {
+	requestsTotal.Inc()
+	apiRequestsTotal.WithLabelValues(version, api).Inc()
+	apiLatency.WithLabelValues(version, api).Observe(time.Since(start).Seconds())
+
+	if code != herrors.ECodeOK {
+		apiErrorsTotal.WithLabelValues(version, api, strconv.Itoa(code)).Inc()
+	}
+}
@@ -0,0 +1,82 @@
+package hwebconnector
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+
+	"github.com/drharryhe/has/common/herrors"
+)
+
+const (
+	defaultMultipartMaxParts = 100
+)
+
+// multipartLimits 一个multipart请求体的part数、单个文件大小和文件总大小限制，size为0表示不限制
+type multipartLimits struct {
+	maxParts int
+	maxFile  int64
+	maxTotal int64
+	parts    int
+	total    int64
+}
+
+func (this *Connector) multipartLimits() *multipartLimits {
+	conf := this.config()
+	return &multipartLimits{
+		maxParts: conf.MultipartMaxParts,
+		maxFile:  int64(conf.MultipartMaxFileSize) * 1024,
+		maxTotal: int64(conf.MultipartMaxSize) * 1024,
+	}
+}
+
+// part 每读到一个part时调用
+func (this *multipartLimits) part() *herrors.Error {
+	this.parts++
+	if this.maxParts > 0 && this.parts > this.maxParts {
+		return herrors.ErrCallerInvalidRequest.New("too many parts, limit %d", this.maxParts).D("too many form parts")
+	}
+	return nil
+}
+
+// read 文件读入n字节后调用，size为该文件已读的字节数
+func (this *multipartLimits) read(name string, size int64, n int) *herrors.Error {
+	this.total += int64(n)
+	if this.maxFile > 0 && size > this.maxFile {
+		return herrors.ErrCallerInvalidRequest.New("file %s exceeds %d KB", name, this.maxFile/1024).D("file too large")
+	}
+	if this.maxTotal > 0 && this.total > this.maxTotal {
+		return herrors.ErrCallerInvalidRequest.New("form files exceed %d KB", this.maxTotal/1024).D("form files too large")
+	}
+	return nil
+}
+
+// checkMultipart 解析表单前扫描一遍请求体，超过限制时不再解析，避免为part和文件分配内存或临时文件
+func (this *Connector) checkMultipart(body []byte, boundary string) *herrors.Error {
+	limits := this.multipartLimits()
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to get data of form")
+		}
+		if e := limits.part(); e != nil {
+			return e
+		}
+		if part.FileName() == "" {
+			continue
+		}
+
+		file := &countReader{reader: part, name: part.FileName(), limits: limits}
+		if _, err = io.Copy(ioutil.Discard, file); file.err != nil {
+			return file.err
+		}
+		if err != nil {
+			return herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to get data of form")
+		}
+	}
+}
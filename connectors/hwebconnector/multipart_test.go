@@ -0,0 +1,99 @@
+package hwebconnector
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func multipartBody(fields int, files ...int) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	for i := 0; i < fields; i++ {
+		_ = w.WriteField("f", "v")
+	}
+	for _, size := range files {
+		fw, _ := w.CreateFormFile("file", "a.bin")
+		_, _ = fw.Write(bytes.Repeat([]byte("x"), size))
+	}
+	_ = w.Close()
+	return body, w.FormDataContentType()
+}
+
+func TestMultipartLimits(t *testing.T) {
+	c := New()
+	c.conf.MultipartMaxParts = 4
+	c.conf.MultipartMaxFileSize = 2
+	c.conf.MultipartMaxSize = 3
+	applyDefaults(&c.conf)
+	app := fiber.New()
+	app.Post("/", func(ctx *fiber.Ctx) error {
+		if err := c.ParseFormParams(ctx, htypes.Map{}); err != nil {
+			return ctx.SendString(err.Desc)
+		}
+		return ctx.SendString("ok")
+	})
+
+	cases := []struct {
+		fields int
+		files  []int
+		want   string
+	}{
+		{2, []int{1024, 1024}, "ok"},
+		{5, nil, "too many form parts"},
+		{0, []int{2049}, "file too large"},
+		{0, []int{2048, 2048}, "form files too large"},
+	}
+	for _, cs := range cases {
+		body, contentType := multipartBody(cs.fields, cs.files...)
+		req := httptest.NewRequest("POST", "/", body)
+		req.Header.Set("Content-Type", contentType)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		_, _ = buf.ReadFrom(resp.Body)
+		if buf.String() != cs.want {
+			t.Errorf("fields %d, files %v: got %q, want %q", cs.fields, cs.files, buf.String(), cs.want)
+		}
+	}
+}
+
+func TestUploadLimits(t *testing.T) {
+	store := &testObjectStore{objects: make(map[string]string)}
+	gw := htest.NewGateway().
+		Route("v1", "avatar", "demo", "Avatar").
+		Handle("demo", "Avatar", htest.Return(nil)).
+		SetPlugin("MinioPlugin", store)
+
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.UploadAPIs = []string{"v1/avatar"}
+	c.conf.UploadStore = "MinioPlugin"
+	c.conf.MultipartMaxSize = 3
+	applyDefaults(&c.conf)
+	if err := c.initUpload(); err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New(fiber.Config{StreamRequestBody: true, DisableStartupMessage: true})
+	app.Post("/:version/:api", c.handleServiceAPI)
+
+	//第二个文件超过总大小时，第一个文件也被删除
+	body, contentType := multipartBody(0, 2048, 2048)
+	req := httptest.NewRequest("POST", "/v1/avatar", body)
+	req.Header.Set("Content-Type", contentType)
+	if resp, _ := app.Test(req); resp.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", resp.StatusCode)
+	}
+	if len(store.objects) != 0 {
+		t.Errorf("uploaded objects not removed: %v", store.objects)
+	}
+}
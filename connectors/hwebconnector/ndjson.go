@@ -0,0 +1,147 @@
+package hwebconnector
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+const (
+	// NDJSONFlag slot返回 htypes.Map{NDJSONFlag: src} 时，src中的数据逐条以NDJSON发送，不需要将整个结果读入内存。
+	// src为<-chan htypes.Any或ItemIterator，只能通过本地路由返回
+	NDJSONFlag = "NDJSON-STREAM"
+
+	defaultNDJSONFlushItems = 100
+)
+
+// ItemIterator 逐条返回数据，没有更多数据时返回io.EOF。发送结束或客户端断开时调用Close
+type ItemIterator interface {
+	Next() (htypes.Any, error)
+	Close() error
+}
+
+// chanIterator 通道中的*herrors.Error或error作为错误结束发送，通道由服务在数据发送完后关闭
+type chanIterator struct {
+	ch <-chan htypes.Any
+}
+
+func (this *chanIterator) Next() (htypes.Any, error) {
+	v, ok := <-this.ch
+	if !ok {
+		return nil, io.EOF
+	}
+	if e, ok := v.(error); ok {
+		return nil, e
+	}
+	return v, nil
+}
+
+// ready 通道中是否有可以立即读取的数据，没有时先刷新已发送的数据再等待
+func (this *chanIterator) ready() bool {
+	return len(this.ch) > 0
+}
+
+// Close 客户端断开后丢弃剩余的数据，避免服务阻塞在发送上
+func (this *chanIterator) Close() error {
+	go func() {
+		for range this.ch {
+		}
+	}()
+	return nil
+}
+
+func ndjsonSource(data htypes.Any) (ItemIterator, bool) {
+	val, ok := data.(htypes.Map)
+	if !ok || val[NDJSONFlag] == nil {
+		return nil, false
+	}
+
+	switch src := val[NDJSONFlag].(type) {
+	case ItemIterator:
+		return src, true
+	case <-chan htypes.Any:
+		return &chanIterator{ch: src}, true
+	case chan htypes.Any:
+		return &chanIterator{ch: src}, true
+	}
+	return nil, true
+}
+
+// HandleNDJSONRequest 返回数据带有NDJSONFlag时以NDJSON流式发送，每NDJSONFlushItems条刷新一次。
+// 发送过程中的错误已无法改变响应状态，以错误响应作为最后一行发送
+func (this *Connector) HandleNDJSONRequest(c *fiber.Ctx, data htypes.Any) (bool, *herrors.Error) {
+	src, ok := ndjsonSource(data)
+	if !ok {
+		return false, nil
+	}
+	if src == nil {
+		return false, herrors.ErrSysInternal.New("parameter [%s] should be chan htypes.Any or ItemIterator", NDJSONFlag).D("bad return data")
+	}
+
+	c.Locals(errorCodeKey, herrors.ECodeOK)
+	c.Set(fiber.HeaderContentType, mimeNDJSON)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer src.Close()
+		this.writeNDJSON(w, src)
+	})
+	return true, nil
+}
+
+func (this *Connector) writeNDJSON(w *bufio.Writer, src ItemIterator) {
+	conf := this.config()
+	ch, _ := src.(*chanIterator)
+	enc := jsoniter.NewEncoder(w)
+	for n := 1; ; n++ {
+		//通道暂时没有数据时先发送已有的数据
+		if ch != nil && !ch.ready() {
+			if e := w.Flush(); e != nil {
+				return
+			}
+		}
+
+		item, err := src.Next()
+		if err == io.EOF {
+			_ = w.Flush()
+			return
+		}
+		if err != nil {
+			herr, ok := err.(*herrors.Error)
+			if !ok {
+				herr = herrors.ErrSysInternal.New(err.Error()).D("failed to read stream data")
+			}
+			hlogger.Error(herr)
+			_ = enc.Encode(this.envelope(NewResponseData(nil, herr)))
+			_ = w.Flush()
+			return
+		}
+
+		if conf.OmitEmpty {
+			item = omitEmpty(item)
+		}
+		if e := enc.Encode(item); e != nil {
+			hlogger.Error(herrors.ErrSysInternal.New(e.Error()).D("failed to encode stream data"))
+			return
+		}
+		//客户端断开时Flush会失败，此时停止发送
+		if n%conf.NDJSONFlushItems == 0 {
+			if e := w.Flush(); e != nil {
+				return
+			}
+		}
+	}
+}
+
+// closeNDJSON 不支持流式发送的调用(如批量调用)中释放数据源
+func closeNDJSON(data htypes.Any) bool {
+	src, ok := ndjsonSource(data)
+	if ok && src != nil {
+		_ = src.Close()
+	}
+	return ok
+}
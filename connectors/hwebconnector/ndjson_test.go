@@ -0,0 +1,78 @@
+package hwebconnector
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core/htest"
+)
+
+type sliceIterator struct {
+	items  []htypes.Any
+	closed bool
+}
+
+func (this *sliceIterator) Next() (htypes.Any, error) {
+	if len(this.items) == 0 {
+		return nil, io.EOF
+	}
+	item := this.items[0]
+	this.items = this.items[1:]
+	return item, nil
+}
+
+func (this *sliceIterator) Close() error {
+	this.closed = true
+	return nil
+}
+
+func TestHandleNDJSONRequest(t *testing.T) {
+	iter := &sliceIterator{items: []htypes.Any{htypes.Map{"id": 1}, htypes.Map{"id": 2}}}
+	gw := htest.NewGateway().
+		Route("v1", "list", "demo", "List").
+		Route("v1", "feed", "demo", "Feed").
+		Handle("demo", "List", func(ps htypes.Map) (htypes.Any, *herrors.Error) {
+			return htypes.Map{NDJSONFlag: iter}, nil
+		}).
+		Handle("demo", "Feed", func(ps htypes.Map) (htypes.Any, *herrors.Error) {
+			ch := make(chan htypes.Any)
+			go func() {
+				defer close(ch)
+				ch <- htypes.Map{"id": 1}
+				ch <- herrors.ErrSysInternal.New("db closed")
+			}()
+			return htypes.Map{NDJSONFlag: ch}, nil
+		})
+	app := newTestApp(gw)
+
+	get := func(url string) string {
+		resp, err := app.Test(httptest.NewRequest("GET", url, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusOK || resp.Header.Get(fiber.HeaderContentType) != mimeNDJSON {
+			t.Fatalf("%s: status = %d, content type = %s", url, resp.StatusCode, resp.Header.Get(fiber.HeaderContentType))
+		}
+		bs, _ := ioutil.ReadAll(resp.Body)
+		return string(bs)
+	}
+
+	if body := get("/v1/list"); body != "{\"id\":1}\n{\"id\":2}\n" {
+		t.Errorf("body = %q", body)
+	}
+	if !iter.closed {
+		t.Error("iterator not closed")
+	}
+
+	lines := strings.Split(strings.TrimSpace(get("/v1/feed")), "\n")
+	if len(lines) != 2 || lines[0] != `{"id":1}` || !strings.Contains(lines[1], `"code":101`) {
+		t.Errorf("lines = %q", lines)
+	}
+}
@@ -0,0 +1,63 @@
+package hwebconnector
+
+import (
+	"encoding/json"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drharryhe/has/common/htypes"
+)
+
+// numberJson 保留数值的原始文本，避免大整数转为float64后丢失精度
+var numberJson = jsoniter.Config{UseNumber: true}.Froze()
+
+// omitEmpty 去掉响应中值为null、空字符串、空数组和空对象的字段。0和false有实际含义，保留。
+// 数组元素只做内部处理，不删除，以免改变元素位置
+func omitEmpty(data htypes.Any) htypes.Any {
+	bs, err := numberJson.Marshal(data)
+	if err != nil {
+		return data
+	}
+
+	var val interface{}
+	if err = numberJson.Unmarshal(bs, &val); err != nil {
+		return data
+	}
+
+	val, _ = stripEmpty(val)
+	if val == nil {
+		return htypes.Map{}
+	}
+	return val
+}
+
+func stripEmpty(v interface{}) (interface{}, bool) {
+	switch val := v.(type) {
+	case nil:
+		return nil, false
+	case string:
+		return val, val != ""
+	case json.Number:
+		//转回数值，使非JSON的打包器也能正确编码
+		if i, err := val.Int64(); err == nil {
+			return i, true
+		}
+		f, _ := val.Float64()
+		return f, true
+	case []interface{}:
+		for i, item := range val {
+			val[i], _ = stripEmpty(item)
+		}
+		return val, len(val) > 0
+	case map[string]interface{}:
+		for k, item := range val {
+			if item, ok := stripEmpty(item); ok {
+				val[k] = item
+			} else {
+				delete(val, k)
+			}
+		}
+		return val, len(val) > 0
+	}
+	return v, true
+}
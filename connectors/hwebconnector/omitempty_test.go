@@ -0,0 +1,37 @@
+package hwebconnector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drharryhe/has/common/htypes"
+)
+
+func TestOmitEmpty(t *testing.T) {
+	data := htypes.Map{
+		"id":    int64(9007199254740993),
+		"name":  "",
+		"nick":  nil,
+		"age":   0,
+		"admin": false,
+		"tags":  []string{},
+		"list":  []interface{}{nil, htypes.Map{"a": nil, "b": 1}},
+		"extra": htypes.Map{"x": nil},
+	}
+
+	bs, _ := jsoniter.Marshal(omitEmpty(NewResponseData(data, nil)))
+	want := `{"data":{"admin":false,"age":0,"id":9007199254740993,"list":[null,{"b":1}]},"error":{"code":0}}`
+
+	var got, exp interface{}
+	_ = jsoniter.Unmarshal(bs, &got)
+	_ = jsoniter.Unmarshal([]byte(want), &exp)
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("omitEmpty = %s, want %s", bs, want)
+	}
+	if !strings.Contains(string(bs), "9007199254740993") {
+		t.Errorf("large integer lost precision: %s", bs)
+	}
+}
@@ -0,0 +1,291 @@
+package hwebconnector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drharryhe/has/common/hconf"
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+)
+
+const (
+	openAPIVersion           = "3.0.3"
+	openAPITitle             = "has"
+	defaultOpenAPIDocVersion = "1.0" //没有配置服务器版本时的文档版本
+)
+
+// schema OpenAPI的Schema对象，只包含生成文档需要的字段
+type schema struct {
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Default     string             `json:"default,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Maximum     *float64           `json:"maximum,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	MaxLength   *int               `json:"maxLength,omitempty"`
+	Pattern     string             `json:"pattern,omitempty"`
+	Enum        []htypes.Any       `json:"enum,omitempty"`
+	Items       *schema            `json:"items,omitempty"`
+	MinItems    int                `json:"minItems,omitempty"`
+	MaxItems    int                `json:"maxItems,omitempty"`
+	Properties  map[string]*schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Ref         string             `json:"$ref,omitempty"`
+}
+
+// handleOpenAPI 根据API定义和slot的参数、返回数据生成OpenAPI 3文档，仅在Debug模式下可用
+func (this *Connector) handleOpenAPI(c *fiber.Ctx) error {
+	if !this.checkAdmin(c) {
+		return nil
+	}
+
+	bs, err := jsoniter.Marshal(this.openAPIDocument())
+	if err != nil {
+		this.SendResponse(c, nil, herrors.ErrSysInternal.New(err.Error()).D("failed to generate openapi document"))
+		return nil
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Send(bs)
+}
+
+func (this *Connector) openAPIDocument() htypes.Map {
+	paths := make(htypes.Map)
+	for _, o := range this.Gateway.APIs() {
+		for _, a := range o.APIs {
+			if a.Disabled {
+				continue
+			}
+			paths[fmt.Sprintf("/%s/%s", o.Version, a.Name)] = htypes.Map{
+				"post": this.openAPIOperation(o.Version, &a),
+			}
+		}
+	}
+
+	version := hconf.Version()
+	if version == "" {
+		version = defaultOpenAPIDocVersion
+	}
+	doc := htypes.Map{
+		"openapi": openAPIVersion,
+		"info": htypes.Map{
+			"title":   openAPITitle,
+			"version": version,
+		},
+		"paths": paths,
+		"components": htypes.Map{
+			"schemas": htypes.Map{
+				"Error": this.errorSchema(),
+				"Page": &schema{
+					Type: "object",
+					Properties: map[string]*schema{
+						"total":     {Type: "integer"},
+						"page":      {Type: "integer"},
+						"page_size": {Type: "integer"},
+					},
+				},
+			},
+		},
+	}
+	if this.current().jwt != nil {
+		doc["components"].(htypes.Map)["securitySchemes"] = htypes.Map{
+			"jwt": htypes.Map{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+		}
+	}
+	return doc
+}
+
+// openAPIOperation 参数以JSON请求体描述，也可以作为查询参数或表单提交
+func (this *Connector) openAPIOperation(version string, a *core.API) htypes.Map {
+	body := &schema{Type: "object", Properties: map[string]*schema{}}
+	data := &schema{Type: "object"}
+	slot := this.Gateway.Server().Slot(a.EndPoint.Service, a.EndPoint.Slot)
+	if slot != nil {
+		for _, p := range slot.Params {
+			body.Properties[p.Name] = paramSchema(&p)
+			if p.Required {
+				body.Required = append(body.Required, p.Name)
+			}
+		}
+		for _, r := range slot.Rules {
+			body.Description = strings.TrimSpace(body.Description + "\n" + r.Expr)
+		}
+		if len(slot.Returns) > 0 {
+			data.Properties = make(map[string]*schema)
+			for _, p := range slot.Returns {
+				data.Properties[p.Name] = paramSchema(&p)
+				if p.Required {
+					data.Required = append(data.Required, p.Name)
+				}
+			}
+		}
+	}
+
+	op := htypes.Map{
+		"operationId": fmt.Sprintf("%s_%s", version, a.Name),
+		"summary":     a.Desc,
+		"tags":        []string{a.EndPoint.Service},
+		"requestBody": htypes.Map{
+			"content": htypes.Map{
+				fiber.MIMEApplicationJSON: htypes.Map{"schema": body},
+			},
+		},
+		"responses": htypes.Map{
+			"200": htypes.Map{
+				"description": "错误码不为0时表示失败",
+				"content": htypes.Map{
+					fiber.MIMEApplicationJSON: htypes.Map{
+						"schema": this.envelopeSchema(data),
+					},
+				},
+			},
+		},
+	}
+	if s := this.current(); s.jwt != nil && !s.jwtExcludes[fmt.Sprintf("%s/%s", version, a.Name)] {
+		op["security"] = []htypes.Map{{"jwt": []string{}}}
+	}
+	if slot != nil && slot.Deprecated {
+		op["deprecated"] = true
+	}
+	return op
+}
+
+// envelopeSchema 响应的字段名与ResponseFields和FlattenError设置一致
+func (this *Connector) envelopeSchema(data *schema) *schema {
+	conf := this.config()
+	name := func(field string) string {
+		return responseFieldName(conf, field)
+	}
+	ret := &schema{
+		Type: "object",
+		Properties: map[string]*schema{
+			name(ResponseFieldData): data,
+			name(ResponseFieldPage): {Ref: "#/components/schemas/Page"},
+		},
+	}
+	if conf.FlattenError {
+		for k, v := range this.errorSchema().Properties {
+			ret.Properties[k] = v
+		}
+	} else {
+		ret.Properties[name(ResponseFieldError)] = &schema{Ref: "#/components/schemas/Error"}
+	}
+	return ret
+}
+
+func (this *Connector) errorSchema() *schema {
+	name := func(field string) string {
+		return responseFieldName(this.config(), field)
+	}
+	return &schema{
+		Type: "object",
+		Properties: map[string]*schema{
+			name(ResponseFieldCode):        {Type: "integer", Description: "错误码，0表示成功"},
+			name(ResponseFieldDesc):        {Type: "string"},
+			name(ResponseFieldFingerprint): {Type: "string"},
+			name(ResponseFieldCause):       {Type: "string"},
+			name(ResponseFieldFields): {Type: "array", Description: "字段级的错误", Items: &schema{
+				Type: "object",
+				Properties: map[string]*schema{
+					"field":   {Type: "string"},
+					"id":      {Type: "string", Description: "消息ID"},
+					"message": {Type: "string"},
+					"params":  {Type: "object"},
+				},
+			}},
+		},
+	}
+}
+
+// paramSchema 将htypes类型转换为OpenAPI的Schema，Range类型为两个元素的数组
+func paramSchema(p *core.SlotParam) *schema {
+	s := typeSchema(p.Type)
+	if p.Format != "" {
+		s.Description = p.Format
+	}
+	if p.Desc != "" {
+		s.Description = strings.TrimSpace(p.Desc + " " + s.Description)
+	}
+	s.Default = p.Default
+	constrainSchema(s, p)
+	return s
+}
+
+// constrainSchema 将slot参数的Min、Max、Pattern和Enum转换为对应的Schema约束，数组的Pattern和Enum作用于元素
+func constrainSchema(s *schema, p *core.SlotParam) {
+	switch s.Type {
+	case "number", "integer":
+		s.Minimum, s.Maximum = p.Min, p.Max
+	case "string":
+		s.MinLength, s.MaxLength = intBound(p.Min), intBound(p.Max)
+	case "array":
+		if n := intBound(p.Min); n != nil {
+			s.MinItems = *n
+		}
+		if n := intBound(p.Max); n != nil {
+			s.MaxItems = *n
+		}
+		if s.Items != nil && (p.Pattern != "" || len(p.Enum) > 0) {
+			item := *s.Items
+			s.Items = &item
+			s = s.Items
+		}
+	}
+	s.Pattern = p.Pattern
+	s.Enum = p.Enum
+}
+
+func intBound(f *float64) *int {
+	if f == nil {
+		return nil
+	}
+	n := int(*f)
+	return &n
+}
+
+func typeSchema(t htypes.HType) *schema {
+	switch t {
+	case htypes.HTypeBool:
+		return &schema{Type: "boolean"}
+	case htypes.HTypeString:
+		return &schema{Type: "string"}
+	case htypes.HTypeNumber:
+		return &schema{Type: "number"}
+	case htypes.HTypeInteger:
+		return &schema{Type: "integer", Format: "int64"}
+	case htypes.HTypeTime:
+		return &schema{Type: "string", Format: "date-time"}
+	case htypes.HTypeBytes:
+		return &schema{Type: "string", Format: "byte"}
+	case htypes.HTypeDate:
+		return &schema{Type: "string", Format: "date"}
+	case htypes.HTypeDateTime:
+		return &schema{Type: "string", Description: "yyyy-mm-dd hh:MM:ss"}
+	case htypes.HTypeObject:
+		return &schema{Type: "object"}
+	case htypes.HTypeStringArray:
+		return &schema{Type: "array", Items: typeSchema(htypes.HTypeString)}
+	case htypes.HTypeNumberArray:
+		return &schema{Type: "array", Items: typeSchema(htypes.HTypeNumber)}
+	case htypes.HTypeBytesArray:
+		return &schema{Type: "array", Items: typeSchema(htypes.HTypeBytes)}
+	case htypes.HTypeDateArray:
+		return &schema{Type: "array", Items: typeSchema(htypes.HTypeDate)}
+	case htypes.HTypeDateTimeArray:
+		return &schema{Type: "array", Items: typeSchema(htypes.HTypeDateTime)}
+	case htypes.HTypeObjectArray:
+		return &schema{Type: "array", Items: typeSchema(htypes.HTypeObject)}
+	case htypes.HTypeNumberRange:
+		return &schema{Type: "array", Items: typeSchema(htypes.HTypeNumber), MinItems: 2, MaxItems: 2}
+	case htypes.HTypeDateRange:
+		return &schema{Type: "array", Items: typeSchema(htypes.HTypeDate), MinItems: 2, MaxItems: 2}
+	case htypes.HTypeDateTimeRange:
+		return &schema{Type: "array", Items: typeSchema(htypes.HTypeDateTime), MinItems: 2, MaxItems: 2}
+	}
+	return &schema{}
+}
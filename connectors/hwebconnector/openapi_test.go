@@ -0,0 +1,60 @@
+package hwebconnector
+
+import (
+	"testing"
+
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	maxName, maxTags := float64(20), float64(3)
+	gw := htest.NewGateway().
+		Route("v1", "user", "demo", "User").
+		Define("demo", core.Slot{
+			Name: "User",
+			Params: []core.SlotParam{
+				{Name: "id", Type: htypes.HTypeNumber, Required: true},
+				{Name: "range", Type: htypes.HTypeDateRange},
+				{Name: "name", Type: htypes.HTypeString, Max: &maxName, Pattern: "^[a-z]+$"},
+				{Name: "tags", Type: htypes.HTypeStringArray, Max: &maxTags, Enum: []htypes.Any{"a", "b"}},
+			},
+			Rules:   []core.SlotRule{{Expr: "id > 0"}},
+			Returns: []core.SlotParam{{Name: "name", Type: htypes.HTypeString}},
+		})
+
+	c := New()
+	c.Gateway = gw
+	doc := c.openAPIDocument()
+
+	op, _ := doc["paths"].(htypes.Map)["/v1/user"].(htypes.Map)["post"].(htypes.Map)
+	if op == nil {
+		t.Fatalf("paths = %v", doc["paths"])
+	}
+	if op["operationId"] != "v1_user" {
+		t.Errorf("operationId = %v", op["operationId"])
+	}
+
+	body := op["requestBody"].(htypes.Map)["content"].(htypes.Map)["application/json"].(htypes.Map)["schema"].(*schema)
+	if body.Properties["id"].Type != "number" || len(body.Required) != 1 || body.Required[0] != "id" {
+		t.Errorf("body = %+v", body)
+	}
+	if r := body.Properties["range"]; r.Type != "array" || r.Items.Format != "date" || r.MaxItems != 2 {
+		t.Errorf("range = %+v", r)
+	}
+	if n := body.Properties["name"]; n.MaxLength == nil || *n.MaxLength != 20 || n.Pattern != "^[a-z]+$" {
+		t.Errorf("name = %+v", n)
+	}
+	if tags := body.Properties["tags"]; tags.MaxItems != 3 || len(tags.Items.Enum) != 2 || tags.Enum != nil {
+		t.Errorf("tags = %+v", tags)
+	}
+	if body.Description != "id > 0" {
+		t.Errorf("rules = %q", body.Description)
+	}
+
+	res := op["responses"].(htypes.Map)["200"].(htypes.Map)["content"].(htypes.Map)["application/json"].(htypes.Map)["schema"].(*schema)
+	if res.Properties["data"].Properties["name"].Type != "string" {
+		t.Errorf("data = %+v", res.Properties["data"])
+	}
+}
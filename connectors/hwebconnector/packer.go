@@ -0,0 +1,80 @@
+package hwebconnector
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+)
+
+func (this *Connector) initPackers() *herrors.Error {
+	this.packers = make(map[string]core.IAPIDataPacker)
+	this.mimes = nil
+
+	for mime, name := range this.conf.ContentPackers {
+		p := this.Gateway.Packer(name)
+		if p == nil {
+			return herrors.ErrSysInternal.New("packer [%s] for %s not found", name, mime)
+		}
+		mime = strings.ToLower(mime)
+		this.packers[mime] = p
+		this.mimes = append(this.mimes, mime)
+	}
+	sort.Strings(this.mimes)
+
+	return nil
+}
+
+// responsePacker 根据Accept选择响应的打包器，未指定或优先接受JSON时使用缺省Packer
+func (this *Connector) responsePacker(c *fiber.Ctx) core.IAPIDataPacker {
+	if len(this.packers) == 0 {
+		return this.Packer
+	}
+
+	c.Vary(fiber.HeaderAccept)
+	if c.Get(fiber.HeaderAccept) == "" {
+		return this.Packer
+	}
+
+	mime := c.Accepts(append([]string{fiber.MIMEApplicationJSON}, this.mimes...)...)
+	if p := this.packers[mime]; p != nil {
+		c.Set(fiber.HeaderContentType, mime)
+		return p
+	}
+	return this.Packer
+}
+
+// requestPacker 返回请求Content-Type对应的打包器，没有单独配置时返回nil
+func (this *Connector) requestPacker(c *fiber.Ctx) core.IAPIDataPacker {
+	if len(this.packers) == 0 {
+		return nil
+	}
+
+	mime := strings.ToLower(strings.TrimSpace(strings.Split(string(c.Request().Header.ContentType()), ";")[0]))
+	return this.packers[mime]
+}
+
+func (this *Connector) unpackBody(c *fiber.Ctx, packer core.IAPIDataPacker, ps htypes.Map) *herrors.Error {
+	bs := c.Request().Body()
+	if len(bs) == 0 {
+		return nil
+	}
+
+	val, err := packer.Unmarshal(bs)
+	if err != nil {
+		return herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to parse body")
+	}
+
+	res, ok := val.(map[string]interface{})
+	if !ok {
+		return herrors.ErrCallerInvalidRequest.New("request body should be a map").D("failed to parse body")
+	}
+	for k, v := range res {
+		ps[k] = v
+	}
+	return nil
+}
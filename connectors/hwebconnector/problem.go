@@ -0,0 +1,91 @@
+package hwebconnector
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+)
+
+const (
+	ProblemDetailsAlways    = "always"    //错误总是以Problem Details输出
+	ProblemDetailsNegotiate = "negotiate" //请求的Accept包含application/problem+json时输出
+
+	mimeProblemJSON   = "application/problem+json"
+	problemAboutBlank = "about:blank"
+)
+
+// Problem RFC 7807 Problem Details，code、fingerprint、fields为扩展字段
+type Problem struct {
+	Type        string               `json:"type"`
+	Title       string               `json:"title"`
+	Status      int                  `json:"status"`
+	Detail      string               `json:"detail,omitempty"`
+	Instance    string               `json:"instance,omitempty"`
+	Code        int                  `json:"code"`
+	Fingerprint string               `json:"fingerprint,omitempty"`
+	Fields      []herrors.FieldError `json:"fields,omitempty"`
+}
+
+func checkProblemDetails(conf *WebConnector) *herrors.Error {
+	switch conf.ProblemDetails {
+	case "", ProblemDetailsAlways, ProblemDetailsNegotiate:
+		return nil
+	}
+	return herrors.ErrSysInternal.New("unknown problem details mode %s", conf.ProblemDetails).D("invalid config")
+}
+
+// wantsProblem 错误是否以Problem Details输出
+func (this *Connector) wantsProblem(c *fiber.Ctx) bool {
+	switch this.config().ProblemDetails {
+	case ProblemDetailsAlways:
+		return true
+	case ProblemDetailsNegotiate:
+		c.Vary(fiber.HeaderAccept)
+		for _, mime := range strings.Split(c.Get(fiber.HeaderAccept), ",") {
+			mime = strings.TrimSpace(strings.Split(mime, ";")[0])
+			if strings.EqualFold(mime, mimeProblemJSON) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// newProblem type为ProblemTypeBase加错误码，未配置时为about:blank。title为错误描述，detail为错误原因。
+// HTTP状态码按StatusCodes映射，不受AlwaysStatusOK影响
+func (this *Connector) newProblem(c *fiber.Ctx, err *herrors.Error) *Problem {
+	conf := this.config()
+	p := &Problem{
+		Type:        problemAboutBlank,
+		Title:       err.Desc,
+		Status:      this.errorStatus(err.Code),
+		Detail:      err.Cause,
+		Instance:    c.Path(),
+		Code:        err.Code,
+		Fingerprint: err.Fingerprint,
+		Fields:      err.Fields,
+	}
+	if conf.ProblemTypeBase != "" {
+		p.Type = conf.ProblemTypeBase + strconv.Itoa(err.Code)
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	return p
+}
+
+func (this *Connector) sendProblem(c *fiber.Ctx, err *herrors.Error) {
+	p := this.newProblem(c, err)
+	bs, _ := jsoniter.Marshal(p)
+	c.Status(p.Status)
+	c.Set(fiber.HeaderContentType, mimeProblemJSON)
+	if e := c.Send(bs); e != nil {
+		hlogger.Error(herrors.ErrSysInternal.New(e.Error()).D("failed to send data"))
+	}
+}
@@ -0,0 +1,81 @@
+package hwebconnector
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestProblemDetails(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "fail", "demo", "Fail").
+		Route("v1", "echo", "demo", "Echo").
+		Handle("demo", "Fail", htest.Fail(herrors.ErrCallerForbidden.New("ip 10.0.0.1 denied").D("forbidden"))).
+		Handle("demo", "Echo", htest.Return(nil))
+
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.ProblemDetails = ProblemDetailsNegotiate
+	c.conf.ProblemTypeBase = "https://example.com/errors/"
+	c.conf.AlwaysStatusOK = true
+	applyDefaults(&c.conf)
+	app := fiber.New()
+	app.Get("/:version/:api", c.handleServiceAPI)
+
+	get := func(path string, accept string) (int, string, map[string]interface{}) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept", accept)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs, _ := ioutil.ReadAll(resp.Body)
+		var body map[string]interface{}
+		if err := json.Unmarshal(bs, &body); err != nil {
+			t.Fatalf("%s: %v", bs, err)
+		}
+		return resp.StatusCode, resp.Header.Get("Content-Type"), body
+	}
+
+	status, ctype, body := get("/v1/fail", "application/problem+json, application/json;q=0.9")
+	if status != fiber.StatusForbidden || ctype != mimeProblemJSON {
+		t.Fatalf("status = %d, content type = %s", status, ctype)
+	}
+	want := map[string]interface{}{
+		"type":     "https://example.com/errors/204",
+		"title":    "forbidden",
+		"status":   float64(fiber.StatusForbidden),
+		"detail":   "ip 10.0.0.1 denied",
+		"instance": "/v1/fail",
+		"code":     float64(herrors.ECodeCallerForbidden),
+	}
+	for k, v := range want {
+		if body[k] != v {
+			t.Errorf("%s = %v, want %v", k, body[k], v)
+		}
+	}
+
+	//未协商时保持原来的响应
+	status, _, body = get("/v1/fail", "application/json")
+	if status != fiber.StatusOK || body["error"] == nil {
+		t.Fatalf("status = %d, body = %v", status, body)
+	}
+
+	//成功的响应不变
+	status, ctype, body = get("/v1/echo", mimeProblemJSON)
+	if status != fiber.StatusOK || ctype == mimeProblemJSON || body["error"] == nil {
+		t.Fatalf("status = %d, content type = %s, body = %v", status, ctype, body)
+	}
+
+	c.conf.ProblemDetails = "sometimes"
+	if err := checkResponseFields(&c.conf); err == nil {
+		t.Fatal("unknown mode should fail")
+	}
+}
@@ -0,0 +1,106 @@
+package hwebconnector
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/juju/ratelimit"
+
+	"github.com/drharryhe/has/common/herrors"
+)
+
+const (
+	rateLimiterIdleTimeout = 5 * time.Minute //超过该时长没有请求的IP，回收其令牌桶
+)
+
+// ipRateLimiter 按客户端IP限流，每个IP一个令牌桶
+type ipRateLimiter struct {
+	rate      float64
+	burst     int64
+	whitelist []*net.IPNet
+	buckets   map[string]*ipBucket
+	lock      sync.Mutex
+	stop      chan struct{}
+}
+
+type ipBucket struct {
+	bucket   *ratelimit.Bucket
+	lastSeen time.Time
+}
+
+func newIPRateLimiter(rate float64, burst int, whitelist []string) *ipRateLimiter {
+	if burst <= 0 {
+		burst = int(rate)
+		if burst <= 0 {
+			burst = 1
+		}
+	}
+
+	l := &ipRateLimiter{
+		rate:    rate,
+		burst:   int64(burst),
+		buckets: make(map[string]*ipBucket),
+		stop:    make(chan struct{}),
+	}
+
+	l.whitelist = parseCIDRs(whitelist, "rate limit whitelist")
+
+	go l.recycle()
+	return l
+}
+
+func (this *ipRateLimiter) allow(ip string) bool {
+	if this.whitelisted(ip) {
+		return true
+	}
+
+	this.lock.Lock()
+	b := this.buckets[ip]
+	if b == nil {
+		b = &ipBucket{bucket: ratelimit.NewBucketWithRate(this.rate, this.burst)}
+		this.buckets[ip] = b
+	}
+	b.lastSeen = time.Now()
+	this.lock.Unlock()
+
+	return b.bucket.TakeAvailable(1) > 0
+}
+
+func (this *ipRateLimiter) whitelisted(ip string) bool {
+	return ipInNets(ip, this.whitelist)
+}
+
+func (this *ipRateLimiter) recycle() {
+	ticker := time.NewTicker(rateLimiterIdleTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-this.stop:
+			return
+		case now := <-ticker.C:
+			this.lock.Lock()
+			for ip, b := range this.buckets {
+				if now.Sub(b.lastSeen) > rateLimiterIdleTimeout {
+					delete(this.buckets, ip)
+				}
+			}
+			this.lock.Unlock()
+		}
+	}
+}
+
+func (this *ipRateLimiter) close() {
+	close(this.stop)
+}
+
+func (this *Connector) handleRateLimit(c *fiber.Ctx) error {
+	ip := this.clientIP(c)
+	if !this.current().limiter.allow(ip) {
+		this.SendResponse(c, nil, herrors.ErrCallerTooManyRequests.New("too many requests from %s", ip).D("too many requests"))
+		return nil
+	}
+	return c.Next()
+}
@@ -0,0 +1,26 @@
+package hwebconnector
+
+import (
+	"testing"
+)
+
+func TestIPRateLimiter(t *testing.T) {
+	l := newIPRateLimiter(1, 2, []string{"127.0.0.1", "10.0.0.0/8"})
+	defer l.close()
+
+	for i := 0; i < 10; i++ {
+		if !l.allow("10.1.2.3") {
+			t.Fatal("whitelisted ip should not be limited")
+		}
+	}
+
+	if !l.allow("192.168.1.1") || !l.allow("192.168.1.1") {
+		t.Fatal("burst requests should be allowed")
+	}
+	if l.allow("192.168.1.1") {
+		t.Fatal("requests exceeding burst should be limited")
+	}
+	if !l.allow("192.168.1.2") {
+		t.Fatal("ip should be limited separately")
+	}
+}
@@ -0,0 +1,49 @@
+package hwebconnector
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+const (
+	// RawFlag slot返回 htypes.Map{RawFlag: []byte, RawContentTypeField: "application/json"} 时，
+	// 数据已经序列化(如来自缓存或上游服务)，直接作为响应体发送，不经过Packer和响应格式的处理。
+	// Content-Type缺省为application/json，批量请求中按普通数据返回
+	RawFlag             = "RAW-RESPONSE"
+	RawContentTypeField = "content_type"
+)
+
+// HandleRawRequest 返回数据带有RawFlag时直接发送其中的数据
+func (this *Connector) HandleRawRequest(c *fiber.Ctx, data htypes.Any) (bool, *herrors.Error) {
+	val, ok := data.(htypes.Map)
+	if !ok || val[RawFlag] == nil {
+		return false, nil
+	}
+
+	var bs []byte
+	switch v := val[RawFlag].(type) {
+	case []byte:
+		bs = v
+	case string:
+		bs = []byte(v)
+	default:
+		return false, herrors.ErrSysInternal.New("parameter [%s] should be []byte or string", RawFlag).D("bad return data")
+	}
+	if err := this.checkResponseSize(c, len(bs)); err != nil {
+		return false, err
+	}
+
+	ctype, _ := val[RawContentTypeField].(string)
+	if ctype == "" {
+		ctype = fiber.MIMEApplicationJSON
+	}
+	c.Locals(errorCodeKey, herrors.ECodeOK)
+	c.Set(fiber.HeaderContentType, ctype)
+	if e := c.Send(bs); e != nil {
+		hlogger.Error(herrors.ErrSysInternal.New(e.Error()).D("failed to send data"))
+	}
+	return true, nil
+}
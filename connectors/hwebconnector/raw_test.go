@@ -0,0 +1,46 @@
+package hwebconnector
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestHandleRawRequest(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "cached", "demo", "Cached").
+		Route("v1", "xml", "demo", "XML").
+		Route("v1", "bad", "demo", "Bad").
+		Handle("demo", "Cached", htest.Return(htypes.Map{RawFlag: []byte(`{"data":{"id":1},"error":{"code":0}}`)})).
+		Handle("demo", "XML", htest.Return(htypes.Map{RawFlag: "<a>1</a>", RawContentTypeField: "application/xml"})).
+		Handle("demo", "Bad", htest.Return(htypes.Map{RawFlag: 1}))
+	app := newTestApp(gw)
+
+	for _, cs := range []struct {
+		path   string
+		status int
+		ctype  string
+		body   string
+	}{
+		{"/v1/cached", fiber.StatusOK, fiber.MIMEApplicationJSON, `{"data":{"id":1},"error":{"code":0}}`},
+		{"/v1/xml", fiber.StatusOK, "application/xml", "<a>1</a>"},
+	} {
+		resp, err := app.Test(httptest.NewRequest("GET", cs.path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs, _ := ioutil.ReadAll(resp.Body)
+		if resp.StatusCode != cs.status || resp.Header.Get("Content-Type") != cs.ctype || string(bs) != cs.body {
+			t.Errorf("%s: status = %d, content type = %s, body = %s", cs.path, resp.StatusCode, resp.Header.Get("Content-Type"), bs)
+		}
+	}
+
+	if resp, _ := app.Test(httptest.NewRequest("GET", "/v1/bad", nil)); resp.StatusCode != fiber.StatusInternalServerError {
+		t.Errorf("bad raw data: status = %d, want 500", resp.StatusCode)
+	}
+}
@@ -0,0 +1,126 @@
+package hwebconnector
+
+import (
+	"reflect"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/drharryhe/has/common/hconf"
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+)
+
+// restartFields 需要重启才能生效的设置：监听端口、TLS，以及在Open中注册的路由和中间件
+var restartFields = []string{
+	"Port", "Tls", "TlsCertPath", "TlsKeyPath", "TlsCertificates", "TlsMinVersion", "TlsMaxVersion", "TlsCipherSuites", "Listeners",
+	"AccessLog", "BodyLog", "DisableHealth", "HealthPath", "ReadyPath", "Metrics", "MetricsPath", "Batch", "BatchPath", "SignedURLPath",
+	"CorsAllowOrigins", "CorsAllowMethods", "CorsAllowHeaders", "CorsExposeHeaders", "CorsAllowCredentials", "CorsMaxAge",
+	"ContentPackers", "UploadAPIs", "UploadStore", "ReadTimeout", "WriteTimeout", "IdleTimeout", "Concurrency",
+}
+
+// resetConfig 重新加载配置。需要重启才能生效的设置保持原值，其余设置立即生效，并返回错误说明未生效的设置
+func (this *Connector) resetConfig(ps htypes.Map) *herrors.Error {
+	this.confLock.Lock()
+	defer this.confLock.Unlock()
+
+	var conf WebConnector
+	if err := hconf.Decode(ps, &conf); err != nil {
+		return herrors.ErrCallerInvalidRequest.New(err.Error()).D("invalid config")
+	}
+	conf.EntityConfBase = this.conf.EntityConfBase
+	applyDefaults(&conf)
+	if err := checkResponseFields(&conf); err != nil {
+		return err
+	}
+
+	fields := append([]string{}, restartFields...)
+	//限流和压缩中间件只在开启时注册
+	if (conf.RequestsPerSecond > 0) != (this.conf.RequestsPerSecond > 0) {
+		fields = append(fields, "RequestsPerSecond")
+	}
+	if (conf.Compression > 0) != (this.conf.Compression > 0) {
+		fields = append(fields, "Compression")
+	}
+	//超过fiber的全局上限时，需要重新创建fiber App
+	if fiberBodyLimit(&conf) > this.App.Config().BodyLimit {
+		fields = append(fields, "BodyLimit", "APIBodyLimits")
+	}
+	//是否按流读取请求体在创建fiber App时确定
+	if streamRequestBody(&conf) != this.App.Config().StreamRequestBody {
+		fields = append(fields, "StreamBodyAPIs")
+	}
+	//签名URL的路由只在开启时注册
+	if (conf.SignedURLSecret != "") != (this.conf.SignedURLSecret != "") {
+		fields = append(fields, "SignedURLSecret")
+	}
+	restartErr := core.KeepRestartFields(&this.conf, &conf, fields...)
+
+	if err := this.publishSettings(conf); err != nil {
+		return err
+	}
+	return restartErr
+}
+
+// publishSettings 按conf创建新的设置并整体替换，创建失败时保持原设置。调用方持有confLock或在Open时调用
+func (this *Connector) publishSettings(conf WebConnector) *herrors.Error {
+	old, _ := this.settings.Load().(*settings)
+	s, err := this.newSettings(conf, old)
+	if err != nil {
+		return err
+	}
+	this.conf = s.conf
+	this.settings.Store(s)
+
+	//使用旧快照的请求仍可调用旧的限流器，关闭只停止回收空闲的令牌桶
+	if old != nil && old.limiter != nil && old.limiter != s.limiter {
+		old.limiter.close()
+	}
+	return nil
+}
+
+// newSettings 按conf创建设置，old不为空时沿用其中未变化的对象。
+// 限流和压缩中间件只在Open时按配置注册，开关需要重启才能生效，这里只更新其参数
+func (this *Connector) newSettings(conf WebConnector, old *settings) (*settings, *herrors.Error) {
+	s := &settings{conf: conf}
+	if old != nil {
+		//内存中的存储跨重新加载保留
+		s.idempotency, s.cache = old.idempotency, old.cache
+	}
+
+	if err := this.initJwt(s); err != nil {
+		return nil, err
+	}
+	if err := this.initIdempotency(s); err != nil {
+		return nil, err
+	}
+	if err := this.initResponseCache(s); err != nil {
+		return nil, err
+	}
+	if err := this.initSignedURL(s); err != nil {
+		return nil, err
+	}
+	if err := this.initTenant(s); err != nil {
+		return nil, err
+	}
+	this.initIPFilter(s)
+
+	if conf.RequestsPerSecond > 0 {
+		if old != nil && old.limiter != nil && conf.RequestsPerSecond == old.conf.RequestsPerSecond && conf.Burst == old.conf.Burst &&
+			reflect.DeepEqual(conf.RateLimitWhitelist, old.conf.RateLimitWhitelist) {
+			s.limiter = old.limiter
+		} else {
+			s.limiter = newIPRateLimiter(conf.RequestsPerSecond, conf.Burst, conf.RateLimitWhitelist)
+		}
+	}
+
+	if conf.Compression > 0 {
+		if old != nil && old.compressor != nil && conf.Compression == old.conf.Compression {
+			s.compressor = old.compressor
+		} else {
+			s.compressor = fasthttp.CompressHandlerLevel(func(ctx *fasthttp.RequestCtx) {}, conf.Compression)
+		}
+	}
+
+	return s, nil
+}
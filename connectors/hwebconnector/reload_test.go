@@ -0,0 +1,76 @@
+package hwebconnector
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestResetConfigJwt(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "echo", "demo", "Echo").
+		Handle("demo", "Echo", htest.Return(htypes.Map{"ok": true}))
+
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.JwtSecret = "secret"
+	c.conf.JwtRequired = true
+	applyDefaults(&c.conf)
+	if err := c.publishSettings(c.conf); err != nil {
+		t.Fatal(err)
+	}
+	c.App = fiber.New(fiber.Config{BodyLimit: fiberBodyLimit(&c.conf)})
+	c.App.Post("/:version/:api", c.handleServiceAPI)
+
+	//重新加载期间，未携带token的请求始终被拒绝
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			if err := c.resetConfig(htypes.Map{"JwtSecret": "secret", "JwtRequired": true}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		resp, err := c.App.Test(httptest.NewRequest("POST", "/v1/echo", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusUnauthorized {
+			t.Fatalf("request during reload: status = %d, want 401", resp.StatusCode)
+		}
+	}
+	<-done
+
+	//创建失败时保持原设置
+	if err := c.resetConfig(htypes.Map{"JwtSecret": "secret", "JwtRequired": true, "TenantSubdomain": "example.com"}); err == nil {
+		t.Fatal("invalid TenantSubdomain accepted")
+	}
+	if s := c.current(); s.jwt == nil || s.conf.TenantSubdomain != "" {
+		t.Error("settings changed after failed reload")
+	}
+}
+
+func TestVerifyJwtWithoutVerifier(t *testing.T) {
+	c := New()
+	c.conf.JwtSecret = "secret"
+	applyDefaults(&c.conf)
+
+	app := fiber.New()
+	app.Get("/", func(ctx *fiber.Ctx) error {
+		if err := c.verifyJwt(ctx, "v1", "echo", htypes.Map{}); err == nil {
+			t.Error("request accepted without jwt verifier")
+		}
+		return nil
+	})
+	if _, err := app.Test(httptest.NewRequest("GET", "/", nil)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,213 @@
+package hwebconnector
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/utils/hrandom"
+)
+
+const (
+	responseCachePrefix = "rcache:"
+	responseCacheTag    = "rcache-tag:"
+	responseCacheHeader = "X-Cache" //HIT或MISS
+	responseCacheTagTTL = 3600      //seconds, 标签版本比缓存的响应多保留的时长
+)
+
+var cacheTagParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// ResponseCacheStore 保存缓存的响应和标签版本，方法与hcacheplugin.Plugin一致，可直接使用缓存插件
+type ResponseCacheStore interface {
+	Get(key string) (htypes.Any, bool, *herrors.Error)
+	Set(key string, val htypes.Any, ttl time.Duration) *herrors.Error
+}
+
+// cachedResponse 缓存的响应，以JSON字符串保存，不依赖存储的序列化方式
+type cachedResponse struct {
+	ContentType string `json:"type"`
+	Body        []byte `json:"body"`
+	Time        int64  `json:"time"` //缓存时的unix时间，用于计算Age
+}
+
+// initResponseCache CacheStore为插件名，插件需实现ResponseCacheStore接口，不配置时保存在内存中
+func (this *Connector) initResponseCache(s *settings) *herrors.Error {
+	if len(s.conf.CacheAPIs) == 0 && len(s.conf.CacheInvalidates) == 0 {
+		return nil
+	}
+	if s.conf.CacheStore == "" {
+		if s.cache == nil {
+			//内存中的幂等存储同样实现了ResponseCacheStore
+			s.cache = newMemoryIdempotencyStore()
+		}
+		return nil
+	}
+
+	store, ok := this.Gateway.Server().Plugin(s.conf.CacheStore).(ResponseCacheStore)
+	if !ok {
+		return herrors.ErrSysInternal.New("plugin %s not found or not implement ResponseCacheStore", s.conf.CacheStore).D("failed to open web connector")
+	}
+	s.cache = store
+	return nil
+}
+
+// responseCacheKey 返回GET请求缓存的key，key包含API、参数和标签的当前版本，标签失效后key随之改变。
+// API未开启缓存时返回空字符串
+func (this *Connector) responseCacheKey(c *fiber.Ctx, version string, api string, ps htypes.Map) string {
+	s := this.current()
+	if s.cache == nil || c.Method() != fiber.MethodGet {
+		return ""
+	}
+	name := fmt.Sprintf("%s/%s", version, api)
+	if s.conf.CacheAPIs[name] <= 0 {
+		return ""
+	}
+
+	//参数包含JWT和会话的subject，不同用户的响应分别缓存
+	params := make(map[string]interface{}, len(ps))
+	for k, v := range ps {
+		if k != core.RequestIDField && k != core.TraceParentField && k != core.TraceStateField && k != s.conf.AddressField {
+			params[k] = v
+		}
+	}
+	h := sha1.New()
+	//fmt按key排序输出map
+	_, _ = fmt.Fprintf(h, "%v", params)
+	//同一API可按Accept返回JSON或CSV等不同格式
+	_, _ = fmt.Fprintf(h, "|%s", c.Get(fiber.HeaderAccept))
+	for _, tag := range cacheTags(s.conf.CacheTags[name], ps) {
+		_, _ = fmt.Fprintf(h, "|%s=%s", tag, tagVersion(s.cache, tag))
+	}
+	return responseCachePrefix + name + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// replayCache 有缓存的响应时直接返回，客户端要求 Cache-Control: no-cache 时不使用缓存
+func (this *Connector) replayCache(c *fiber.Ctx, key string) bool {
+	if strings.Contains(strings.ToLower(c.Get(fiber.HeaderCacheControl)), "no-cache") {
+		return false
+	}
+	val, ok, err := this.current().cache.Get(key)
+	if err != nil {
+		hlogger.Warn("failed to get cached response %s: %s", key, err.Error())
+		return false
+	}
+	if !ok {
+		return false
+	}
+	s, _ := val.(string)
+
+	var res cachedResponse
+	if e := jsoniter.UnmarshalFromString(s, &res); e != nil {
+		hlogger.Warn("invalid cached response of %s: %s", key, e.Error())
+		return false
+	}
+
+	age := time.Now().Unix() - res.Time
+	if age < 0 {
+		age = 0
+	}
+	maxAge := int64(this.config().CacheAPIs[c.Params("version")+"/"+c.Params("api")]) - age
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	this.cacheHeaders(c, maxAge)
+	c.Set(fiber.HeaderAge, strconv.FormatInt(age, 10))
+	c.Set(responseCacheHeader, "HIT")
+	if res.ContentType != "" {
+		c.Set(fiber.HeaderContentType, res.ContentType)
+	}
+	if e := c.Send(res.Body); e != nil {
+		hlogger.Error(herrors.ErrSysInternal.New(e.Error()).D("failed to send data"))
+	}
+	return true
+}
+
+// saveCache 只缓存成功的响应，文件流等按流发送的响应不缓存
+func (this *Connector) saveCache(c *fiber.Ctx, key string, ttl int) {
+	if code, ok := c.Locals(errorCodeKey).(int); ok && code != herrors.ECodeOK {
+		return
+	}
+	if c.Response().StatusCode() != fiber.StatusOK || c.Response().IsBodyStream() {
+		return
+	}
+
+	this.cacheHeaders(c, int64(ttl))
+	c.Set(fiber.HeaderAge, "0")
+	c.Set(responseCacheHeader, "MISS")
+	res := cachedResponse{
+		ContentType: string(c.Response().Header.ContentType()),
+		Body:        c.Response().Body(),
+		Time:        time.Now().Unix(),
+	}
+	s, _ := jsoniter.MarshalToString(res)
+	if err := this.current().cache.Set(key, s, time.Duration(ttl)*time.Second); err != nil {
+		hlogger.Warn("failed to save cached response %s: %s", key, err.Error())
+	}
+}
+
+func (this *Connector) cacheHeaders(c *fiber.Ctx, maxAge int64) {
+	scope := "private"
+	if this.config().CachePublic {
+		scope = "public"
+	}
+	c.Set(fiber.HeaderCacheControl, fmt.Sprintf("%s, max-age=%d", scope, maxAge))
+}
+
+// invalidateCache API调用成功后，使CacheInvalidates中该API的标签失效，带有这些标签的缓存不再被使用
+func (this *Connector) invalidateCache(version string, api string, ps htypes.Map) {
+	s := this.current()
+	if s.cache == nil {
+		return
+	}
+	tags := cacheTags(s.conf.CacheInvalidates[fmt.Sprintf("%s/%s", version, api)], ps)
+	if len(tags) == 0 {
+		return
+	}
+
+	ttl := 0
+	for _, t := range s.conf.CacheAPIs {
+		if t > ttl {
+			ttl = t
+		}
+	}
+	for _, tag := range tags {
+		if err := s.cache.Set(responseCacheTag+tag, hrandom.UuidWithoutDash(), time.Duration(ttl+responseCacheTagTTL)*time.Second); err != nil {
+			hlogger.Warn("failed to invalidate cache tag %s: %s", tag, err.Error())
+		}
+	}
+}
+
+func tagVersion(cache ResponseCacheStore, tag string) string {
+	val, ok, err := cache.Get(responseCacheTag + tag)
+	if err != nil || !ok {
+		return ""
+	}
+	s, _ := val.(string)
+	return s
+}
+
+// cacheTags 替换标签中引用的参数，如 product:{id}，参数不存在时替换为空字符串
+func cacheTags(tags []string, ps htypes.Map) []string {
+	ret := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		ret = append(ret, cacheTagParam.ReplaceAllStringFunc(tag, func(s string) string {
+			v, ok := ps[s[1:len(s)-1]]
+			if !ok || v == nil {
+				return ""
+			}
+			return fmt.Sprint(v)
+		}))
+	}
+	return ret
+}
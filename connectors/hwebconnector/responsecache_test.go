@@ -0,0 +1,70 @@
+package hwebconnector
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestResponseCache(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "product", "demo", "Product").
+		Route("v1", "updateProduct", "demo", "Update").
+		Handle("demo", "Product", htest.Return("p")).
+		Handle("demo", "Update", htest.Return(nil))
+
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.CacheAPIs = map[string]int{"v1/product": 60}
+	c.conf.CacheTags = map[string][]string{"v1/product": {"product:{id}"}}
+	c.conf.CacheInvalidates = map[string][]string{"v1/updateProduct": {"product:{id}"}}
+	applyDefaults(&c.conf)
+	if err := c.publishSettings(c.conf); err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Get("/:version/:api", c.handleServiceAPI)
+	app.Post("/:version/:api", c.handleServiceAPI)
+
+	router := gw.Router().(*htest.Router)
+	get := func(url string, want string) {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", url, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusOK || resp.Header.Get(responseCacheHeader) != want {
+			t.Errorf("GET %s: status = %d, %s = %s, want %s", url, resp.StatusCode, responseCacheHeader, resp.Header.Get(responseCacheHeader), want)
+		}
+		if cc := resp.Header.Get(fiber.HeaderCacheControl); cc == "" || resp.Header.Get(fiber.HeaderAge) == "" {
+			t.Errorf("GET %s: Cache-Control = %q, Age = %q", url, cc, resp.Header.Get(fiber.HeaderAge))
+		}
+	}
+
+	get("/v1/product?id=1", "MISS")
+	get("/v1/product?id=1", "HIT")
+	get("/v1/product?id=2", "MISS")
+	if n := len(router.Calls("demo", "Product")); n != 2 {
+		t.Fatalf("service called %d times, want 2", n)
+	}
+
+	//更新product 1后，只有它的缓存失效
+	if _, err := app.Test(httptest.NewRequest("POST", "/v1/updateProduct?id=1", nil)); err != nil {
+		t.Fatal(err)
+	}
+	get("/v1/product?id=1", "MISS")
+	get("/v1/product?id=2", "HIT")
+
+	//POST请求不使用缓存
+	resp, _ := app.Test(httptest.NewRequest("POST", "/v1/product?id=2", nil))
+	if resp.Header.Get(responseCacheHeader) != "" {
+		t.Error("POST response cached")
+	}
+	if n := len(router.Calls("demo", "Product")); n != 4 {
+		t.Errorf("service called %d times, want 4", n)
+	}
+}
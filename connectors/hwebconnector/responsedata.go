@@ -2,12 +2,14 @@ package hwebconnector
 
 import (
 	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hpaging"
 	"github.com/drharryhe/has/common/htypes"
 	"github.com/drharryhe/has/utils/hruntime"
 )
 
 type ResponseData struct {
 	Data  htypes.Any     `json:"data"`
+	Page  *hpaging.Page  `json:"page,omitempty"` //列表结果的分页信息
 	Error *herrors.Error `json:"error"`
 }
 
@@ -15,6 +17,9 @@ func NewResponseData(data htypes.Any, err *herrors.Error) *ResponseData {
 	var res ResponseData
 	if data == nil || hruntime.IsNil(data) {
 		res.Data = htypes.Map{}
+	} else if list, ok := data.(*hpaging.List); ok {
+		res.Data = list.Items
+		res.Page = &list.Page
 	} else {
 		res.Data = data
 	}
@@ -29,3 +34,97 @@ func NewResponseData(data htypes.Any, err *herrors.Error) *ResponseData {
 
 	return &res
 }
+
+// 响应中的标准字段，ResponseFields按这些名称设置输出的字段名
+const (
+	ResponseFieldData        = "data"
+	ResponseFieldPage        = "page"
+	ResponseFieldError       = "error"
+	ResponseFieldCode        = "code"
+	ResponseFieldDesc        = "desc"
+	ResponseFieldFingerprint = "fingerprint"
+	ResponseFieldCause       = "cause"
+	ResponseFieldFields      = "fields" //字段级的错误，没有时不输出
+)
+
+var responseFields = []string{
+	ResponseFieldData, ResponseFieldPage, ResponseFieldError,
+	ResponseFieldCode, ResponseFieldDesc, ResponseFieldFingerprint, ResponseFieldCause, ResponseFieldFields,
+}
+
+// checkResponseFields 只能重命名标准字段，同一层级输出的字段名不能重复
+func checkResponseFields(conf *WebConnector) *herrors.Error {
+	if err := checkProblemDetails(conf); err != nil {
+		return err
+	}
+
+	known := make(map[string]bool)
+	for _, f := range responseFields {
+		known[f] = true
+	}
+	for f := range conf.ResponseFields {
+		if !known[f] {
+			return herrors.ErrSysInternal.New("unknown response field %s", f).D("invalid response fields")
+		}
+	}
+
+	top := []string{ResponseFieldData, ResponseFieldPage}
+	inner := []string{ResponseFieldCode, ResponseFieldDesc, ResponseFieldFingerprint, ResponseFieldCause, ResponseFieldFields}
+	groups := [][]string{append(top, inner...)}
+	if !conf.FlattenError {
+		groups = [][]string{append(top, ResponseFieldError), inner}
+	}
+	for _, fields := range groups {
+		names := make(map[string]string)
+		for _, f := range fields {
+			n := responseFieldName(conf, f)
+			if n == "" {
+				return herrors.ErrSysInternal.New("name of response field %s is empty", f).D("invalid response fields")
+			}
+			if other, ok := names[n]; ok {
+				return herrors.ErrSysInternal.New("response field %s and %s have the same name %s", other, f, n).D("invalid response fields")
+			}
+			names[n] = f
+		}
+	}
+	return nil
+}
+
+func responseFieldName(conf *WebConnector, field string) string {
+	if n, ok := conf.ResponseFields[field]; ok {
+		return n
+	}
+	return field
+}
+
+// envelope 按ResponseFields和FlattenError构造响应，没有配置时使用ResponseData
+func (this *Connector) envelope(res *ResponseData) htypes.Any {
+	conf := this.config()
+	if len(conf.ResponseFields) == 0 && !conf.FlattenError {
+		return res
+	}
+
+	name := func(field string) string {
+		return responseFieldName(conf, field)
+	}
+	ret := htypes.Map{name(ResponseFieldData): res.Data}
+	if res.Page != nil {
+		ret[name(ResponseFieldPage)] = res.Page
+	}
+
+	e := ret
+	if !conf.FlattenError {
+		e = htypes.Map{}
+		ret[name(ResponseFieldError)] = e
+	}
+	e[name(ResponseFieldCode)] = res.Error.Code
+	e[name(ResponseFieldDesc)] = res.Error.Desc
+	if res.Error.Fingerprint != "" {
+		e[name(ResponseFieldFingerprint)] = res.Error.Fingerprint
+	}
+	e[name(ResponseFieldCause)] = res.Error.Cause
+	if len(res.Error.Fields) > 0 {
+		e[name(ResponseFieldFields)] = res.Error.Fields
+	}
+	return ret
+}
@@ -0,0 +1,58 @@
+package hwebconnector
+
+import (
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+func TestEnvelope(t *testing.T) {
+	c := New()
+	res := NewResponseData(htypes.Map{"id": 1}, herrors.ErrCallerInvalidRequest.New("bad").D("bad request"))
+
+	if ret := c.envelope(res); ret != res {
+		t.Errorf("default envelope = %v, want ResponseData", ret)
+	}
+
+	cases := []struct {
+		fields  map[string]string
+		flatten bool
+		want    string
+	}{
+		{map[string]string{"data": "result"}, false, `{"error":{"cause":"bad","code":201,"desc":"bad request"},"result":{"id":1}}`},
+		{map[string]string{"data": "result", "desc": "message"}, true, `{"cause":"bad","code":201,"message":"bad request","result":{"id":1}}`},
+	}
+	for _, cs := range cases {
+		c.conf.ResponseFields, c.conf.FlattenError = cs.fields, cs.flatten
+		if err := checkResponseFields(&c.conf); err != nil {
+			t.Fatal(err)
+		}
+		bs, _ := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(c.envelope(res))
+		if string(bs) != cs.want {
+			t.Errorf("envelope(%v, %t) = %s, want %s", cs.fields, cs.flatten, bs, cs.want)
+		}
+	}
+}
+
+func TestCheckResponseFields(t *testing.T) {
+	cases := []struct {
+		fields  map[string]string
+		flatten bool
+		ok      bool
+	}{
+		{map[string]string{"code": "data"}, false, true},
+		{map[string]string{"code": "data"}, true, false},
+		{map[string]string{"result": "data"}, false, false},
+		{map[string]string{"page": ""}, false, false},
+		{map[string]string{"error": "data"}, false, false},
+	}
+	for _, cs := range cases {
+		conf := WebConnector{ResponseFields: cs.fields, FlattenError: cs.flatten}
+		if err := checkResponseFields(&conf); (err == nil) != cs.ok {
+			t.Errorf("checkResponseFields(%v, %t) = %v, want ok %t", cs.fields, cs.flatten, err, cs.ok)
+		}
+	}
+}
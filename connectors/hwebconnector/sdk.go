@@ -0,0 +1,332 @@
+package hwebconnector
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+)
+
+const (
+	defaultClientPackage = "client"
+	clientPackageQuery   = "package"
+)
+
+type clientAPI struct {
+	Version string
+	API     string
+	Desc    string
+	Method  string //Go方法名，如 v1/user.list -> V1UserList
+	Params  []clientField
+	Returns []clientField
+}
+
+type clientField struct {
+	Name     string //Go字段名
+	JSON     string
+	Type     string
+	Optional bool
+	Desc     string
+}
+
+// handleAdminClient 根据API定义和slot的参数、返回数据生成Go客户端代码，package参数指定包名，仅在Debug模式下可用
+func (this *Connector) handleAdminClient(c *fiber.Ctx) error {
+	if !this.checkAdmin(c) {
+		return nil
+	}
+
+	pkg := c.Query(clientPackageQuery, defaultClientPackage)
+	bs, err := this.ClientSource(pkg)
+	if err != nil {
+		this.SendResponse(c, nil, err)
+		return nil
+	}
+	c.Set(fiber.HeaderContentType, "text/x-go; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.go"`, pkg))
+	return c.Send(bs)
+}
+
+// ClientSource 生成包名为pkg的Go客户端代码，每个API一个方法，参数和返回数据按slot的Params和Returns生成结构体，
+// 没有定义Returns的API返回interface{}。响应按ResponseFields和FlattenError解析，错误码不为0时返回*herrors.Error
+func (this *Connector) ClientSource(pkg string) ([]byte, *herrors.Error) {
+	conf := this.config()
+	if !token.IsIdentifier(pkg) || token.IsKeyword(pkg) {
+		return nil, herrors.ErrCallerInvalidRequest.New("invalid package name %s", pkg).D("failed to generate client")
+	}
+
+	var apis []clientAPI
+	methods := make(map[string]bool)
+	for _, o := range this.Gateway.APIs() {
+		for _, a := range o.APIs {
+			if a.Disabled {
+				continue
+			}
+			ca := clientAPI{
+				Version: o.Version,
+				API:     a.Name,
+				Desc:    strings.Join(strings.Fields(a.Desc), " "),
+				Method:  uniqueName(goName(o.Version+"_"+a.Name), methods),
+			}
+			if slot := this.Gateway.Server().Slot(a.EndPoint.Service, a.EndPoint.Slot); slot != nil {
+				ca.Params = clientFields(slot.Params, true)
+				ca.Returns = clientFields(slot.Returns, false)
+			}
+			apis = append(apis, ca)
+		}
+	}
+	sort.Slice(apis, func(i, j int) bool {
+		return apis[i].Method < apis[j].Method
+	})
+
+	name := func(field string) string {
+		return responseFieldName(conf, field)
+	}
+	var buf bytes.Buffer
+	err := clientTemplate.Execute(&buf, map[string]interface{}{
+		"Package":          pkg,
+		"APIs":             apis,
+		"Flatten":          conf.FlattenError,
+		"DataField":        name(ResponseFieldData),
+		"PageField":        name(ResponseFieldPage),
+		"ErrorField":       name(ResponseFieldError),
+		"CodeField":        name(ResponseFieldCode),
+		"DescField":        name(ResponseFieldDesc),
+		"CauseField":       name(ResponseFieldCause),
+		"FieldsField":      name(ResponseFieldFields),
+		"FingerprintField": name(ResponseFieldFingerprint),
+	})
+	if err != nil {
+		return nil, herrors.ErrSysInternal.New(err.Error()).D("failed to generate client")
+	}
+
+	bs, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, herrors.ErrSysInternal.New(err.Error()).D("failed to generate client")
+	}
+	return bs, nil
+}
+
+// clientFields 参数中非必需的数字和布尔值生成指针，未设置时不提交。Time以RFC3339字符串提交
+func clientFields(ps []core.SlotParam, params bool) []clientField {
+	var ret []clientField
+	names := make(map[string]bool)
+	for _, p := range ps {
+		f := clientField{
+			Name: uniqueName(goName(p.Name), names),
+			JSON: p.Name,
+			Type: goType(p.Type),
+			Desc: strings.Join(strings.Fields(p.Desc), " "),
+		}
+		if params && !p.Required {
+			f.Optional = true
+			if f.Type == "float64" || f.Type == "int64" || f.Type == "bool" {
+				f.Type = "*" + f.Type
+			}
+		}
+		ret = append(ret, f)
+	}
+	return ret
+}
+
+// goName 以非字母数字分隔单词并首字母大写，如 user.list -> UserList，page_size -> PageSize
+func goName(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
+
+func uniqueName(name string, used map[string]bool) string {
+	ret := name
+	for i := 2; used[ret]; i++ {
+		ret = fmt.Sprintf("%s%d", name, i)
+	}
+	used[ret] = true
+	return ret
+}
+
+func goType(t htypes.HType) string {
+	switch t {
+	case htypes.HTypeBool:
+		return "bool"
+	case htypes.HTypeString, htypes.HTypeDate, htypes.HTypeDateTime, htypes.HTypeTime:
+		return "string"
+	case htypes.HTypeNumber:
+		return "float64"
+	case htypes.HTypeInteger:
+		return "int64"
+	case htypes.HTypeBytes:
+		return "[]byte"
+	case htypes.HTypeObject:
+		return "map[string]interface{}"
+	case htypes.HTypeStringArray, htypes.HTypeDateArray, htypes.HTypeDateTimeArray, htypes.HTypeDateRange, htypes.HTypeDateTimeRange:
+		return "[]string"
+	case htypes.HTypeNumberArray, htypes.HTypeNumberRange:
+		return "[]float64"
+	case htypes.HTypeBytesArray:
+		return "[][]byte"
+	case htypes.HTypeObjectArray:
+		return "[]map[string]interface{}"
+	}
+	return "interface{}"
+}
+
+var clientTemplate = template.Must(template.New("client").Parse(`// Code generated by hwebconnector. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hpaging"
+)
+
+// Client 以JSON请求体POST到 BaseURL/version/api，解析响应中的数据和错误
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	Header     http.Header //每个请求携带的header，如 Authorization
+}
+
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+		Header:     make(http.Header),
+	}
+}
+
+// Call 调用API，响应数据解析到out，返回分页信息，响应中没有分页信息时为nil。错误码不为0时返回服务的错误
+func (this *Client) Call(ctx context.Context, version string, api string, params interface{}, out interface{}) (*hpaging.Page, *herrors.Error) {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return nil, herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to marshal params")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/%s/%s", this.BaseURL, version, api), bytes.NewReader(body))
+	if err != nil {
+		return nil, herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to create request")
+	}
+	for k, vs := range this.Header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	client := this.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, herrors.ErrSysUnavailable.New(err.Error()).D("request failed")
+	}
+	defer resp.Body.Close()
+	bs, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, herrors.ErrSysUnavailable.New(err.Error()).D("failed to read response")
+	}
+
+	var res map[string]json.RawMessage
+	if err := json.Unmarshal(bs, &res); err != nil {
+		return nil, herrors.ErrSysInternal.New("%s: %s", resp.Status, err.Error()).D("invalid response")
+	}
+{{if .Flatten}}	e := res
+{{else}}	var e map[string]json.RawMessage
+	if err := json.Unmarshal(res[{{printf "%q" .ErrorField}}], &e); err != nil {
+		return nil, herrors.ErrSysInternal.New("%s: %s", resp.Status, err.Error()).D("invalid response")
+	}
+{{end}}	var ret herrors.Error
+	for field, v := range map[string]interface{}{
+		{{printf "%q" .CodeField}}:        &ret.Code,
+		{{printf "%q" .DescField}}:        &ret.Desc,
+		{{printf "%q" .CauseField}}:       &ret.Cause,
+		{{printf "%q" .FingerprintField}}: &ret.Fingerprint,
+		{{printf "%q" .FieldsField}}:      &ret.Fields,
+	} {
+		if len(e[field]) > 0 {
+			_ = json.Unmarshal(e[field], v)
+		}
+	}
+	if ret.Code != herrors.ECodeOK {
+		return nil, &ret
+	}
+
+	var page *hpaging.Page
+	if len(res[{{printf "%q" .PageField}}]) > 0 {
+		if err := json.Unmarshal(res[{{printf "%q" .PageField}}], &page); err != nil {
+			return nil, herrors.ErrSysInternal.New(err.Error()).D("invalid response page")
+		}
+	}
+	if out != nil && len(res[{{printf "%q" .DataField}}]) > 0 {
+		if err := json.Unmarshal(res[{{printf "%q" .DataField}}], out); err != nil {
+			return nil, herrors.ErrSysInternal.New(err.Error()).D("invalid response data")
+		}
+	}
+	return page, nil
+}
+{{range .APIs}}{{$api := .}}
+// {{.Method}}Params {{.Version}}/{{.API}} 的参数
+type {{.Method}}Params struct {
+{{- range .Params}}
+	{{.Name}} {{.Type}} ` + "`" + `json:"{{.JSON}}{{if .Optional}},omitempty{{end}}"` + "`" + `{{if .Desc}} //{{.Desc}}{{end}}
+{{- end}}
+}
+{{if .Returns}}
+// {{.Method}}Result {{.Version}}/{{.API}} 的返回数据
+type {{.Method}}Result struct {
+{{- range .Returns}}
+	{{.Name}} {{.Type}} ` + "`" + `json:"{{.JSON}}"` + "`" + `{{if .Desc}} //{{.Desc}}{{end}}
+{{- end}}
+}
+{{end}}
+// {{.Method}} 调用 {{.Version}}/{{.API}}{{if .Desc}}，{{.Desc}}{{end}}
+func (this *Client) {{.Method}}(ctx context.Context, params *{{.Method}}Params) ({{if .Returns}}*{{.Method}}Result{{else}}interface{}{{end}}, *herrors.Error) {
+	if params == nil {
+		params = &{{.Method}}Params{}
+	}
+{{- if .Returns}}
+	var ret {{.Method}}Result
+	if _, err := this.Call(ctx, {{printf "%q" .Version}}, {{printf "%q" .API}}, params, &ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+{{- else}}
+	var ret interface{}
+	if _, err := this.Call(ctx, {{printf "%q" .Version}}, {{printf "%q" .API}}, params, &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+{{- end}}
+}
+{{end}}`))
@@ -0,0 +1,54 @@
+package hwebconnector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestClientSource(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "user.list", "demo", "Users").
+		Route("v1", "ping", "demo", "Ping").
+		Define("demo", core.Slot{
+			Name: "Users",
+			Params: []core.SlotParam{
+				{Name: "page_size", Type: htypes.HTypeNumber},
+				{Name: "name", Type: htypes.HTypeString, Required: true},
+				{Name: "range", Type: htypes.HTypeDateRange},
+			},
+			Returns: []core.SlotParam{{Name: "total", Type: htypes.HTypeNumber}},
+		})
+
+	c := New()
+	c.Gateway = gw
+	c.conf.ResponseFields = map[string]string{"data": "result"}
+	bs, err := c.ClientSource("demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := string(bs)
+	for _, want := range []string{
+		"package demo",
+		"func (this *Client) V1UserList(ctx context.Context, params *V1UserListParams) (*V1UserListResult, *herrors.Error)",
+		"func (this *Client) V1Ping(ctx context.Context, params *V1PingParams) (interface{}, *herrors.Error)",
+		"PageSize *float64 `json:\"page_size,omitempty\"`",
+		"Name     string   `json:\"name\"`",
+		"Range    []string `json:\"range,omitempty\"`",
+		"Total float64 `json:\"total\"`",
+		`res["result"]`,
+		`this.Call(ctx, "v1", "user.list", params, &ret)`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("client source missing %q", want)
+		}
+	}
+
+	if _, err := c.ClientSource("func"); err == nil {
+		t.Error("keyword package name should fail")
+	}
+}
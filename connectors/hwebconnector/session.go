@@ -0,0 +1,77 @@
+package hwebconnector
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+)
+
+const (
+	defaultSessionCookie       = "session_id"
+	defaultSessionHeader       = "X-Session-Id"
+	defaultSessionIDField      = "SessionID"
+	defaultSessionSubjectField = "SessionSubject"
+)
+
+// SessionProvider 按会话ID读取subject并顺延会话，会话不存在或已销毁时返回错误，hsessionplugin.Plugin实现了该接口
+type SessionProvider interface {
+	Subject(id string) (string, *herrors.Error)
+}
+
+// initSession SessionPlugin为插件名，插件需实现SessionProvider接口
+func (this *Connector) initSession() *herrors.Error {
+	if this.conf.SessionPlugin == "" {
+		return nil
+	}
+
+	if this.conf.SessionCookie == "" {
+		this.conf.SessionCookie = defaultSessionCookie
+	}
+	if this.conf.SessionHeader == "" {
+		this.conf.SessionHeader = defaultSessionHeader
+	}
+	if this.conf.SessionIDField == "" {
+		this.conf.SessionIDField = defaultSessionIDField
+	}
+	if this.conf.SessionSubjectField == "" {
+		this.conf.SessionSubjectField = defaultSessionSubjectField
+	}
+
+	sessions, ok := this.Gateway.Server().Plugin(this.conf.SessionPlugin).(SessionProvider)
+	if !ok {
+		return herrors.ErrSysInternal.New("plugin %s not found or not implement SessionProvider", this.conf.SessionPlugin).D("failed to open web connector")
+	}
+	this.sessions = sessions
+	return nil
+}
+
+// loadSession 按SessionHeader或SessionCookie读取会话，会话ID和subject写入参数，客户端提交的同名参数被丢弃。
+// 未携带会话时不处理，由服务决定是否需要登录；携带了无效或已销毁的会话时拒绝请求
+func (this *Connector) loadSession(c *fiber.Ctx, ps htypes.Map) *herrors.Error {
+	conf := this.config()
+	if this.sessions == nil {
+		return nil
+	}
+	delete(ps, conf.SessionIDField)
+	delete(ps, conf.SessionSubjectField)
+
+	id := c.Get(conf.SessionHeader)
+	if id == "" {
+		id = c.Cookies(conf.SessionCookie)
+	}
+	if id == "" {
+		return nil
+	}
+
+	subject, err := this.sessions.Subject(id)
+	if err != nil {
+		return err
+	}
+	ps[conf.SessionIDField] = id
+	ps[conf.SessionSubjectField] = subject
+	core.SetScoped(ps, core.ScopeSession, id)
+	core.SetScoped(ps, core.ScopeSubject, subject)
+	return nil
+}
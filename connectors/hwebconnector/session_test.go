@@ -0,0 +1,67 @@
+package hwebconnector
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/core/htest"
+)
+
+type testSessions struct {
+	core.BasePlugin
+	subjects map[string]string
+}
+
+func (this *testSessions) Subject(id string) (string, *herrors.Error) {
+	if s, ok := this.subjects[id]; ok {
+		return s, nil
+	}
+	return "", herrors.ErrCallerUnauthorizedAccess.New("session not found or expired").D("invalid session")
+}
+
+func TestSession(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "me", "demo", "Me").
+		Handle("demo", "Me", htest.Return(nil)).
+		SetPlugin("SessionPlugin", &testSessions{subjects: map[string]string{"s1": "alice"}})
+
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.SessionPlugin = "SessionPlugin"
+	applyDefaults(&c.conf)
+	if err := c.initSession(); err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Get("/:version/:api", c.handleServiceAPI)
+
+	req := httptest.NewRequest("GET", "/v1/me", nil)
+	req.Header.Set("Cookie", defaultSessionCookie+"=s1")
+	if resp, _ := app.Test(req); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	ps := gw.Router().(*htest.Router).LastParams("demo", "Me")
+	htest.AssertParams(t, ps, htypes.Map{defaultSessionIDField: "s1", defaultSessionSubjectField: "alice"})
+
+	//会话已销毁
+	req = httptest.NewRequest("GET", "/v1/me", nil)
+	req.Header.Set(defaultSessionHeader, "s2")
+	if resp, _ := app.Test(req); resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", resp.StatusCode)
+	}
+
+	//未携带会话时丢弃客户端提交的会话ID和subject
+	req = httptest.NewRequest("GET", "/v1/me?"+defaultSessionIDField+"=victim&"+defaultSessionSubjectField+"=root", nil)
+	if resp, _ := app.Test(req); resp.StatusCode != fiber.StatusOK {
+		t.Errorf("request without session: status = %d, want 200", resp.StatusCode)
+	}
+	ps = gw.Router().(*htest.Router).LastParams("demo", "Me")
+	htest.AssertNoParam(t, ps, defaultSessionIDField)
+	htest.AssertNoParam(t, ps, defaultSessionSubjectField)
+}
@@ -0,0 +1,122 @@
+package hwebconnector
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/utils/hsignurl"
+)
+
+const (
+	defaultSignedURLPath = "/signed"
+	defaultSignedURLTTL  = 300 //seconds
+)
+
+func (this *Connector) initSignedURL(s *settings) *herrors.Error {
+	if s.conf.SignedURLSecret == "" {
+		return nil
+	}
+
+	signer, err := hsignurl.New(s.conf.SignedURLSecret, time.Duration(s.conf.SignedURLTTL)*time.Second)
+	if err != nil {
+		return herrors.ErrSysInternal.New(err.Error()).D("failed to init signed url")
+	}
+	s.signer = signer
+	return nil
+}
+
+// SignURL 生成调用API的签名URL，如文件下载链接，在有效期内不需要JWT即可访问。ttl为0时使用SignedURLTTL。
+// 服务中可使用hsignurl和相同的SignedURLSecret生成，路径为 SignedURLPath/version/api
+func (this *Connector) SignURL(version string, api string, params htypes.Map, ttl time.Duration) (string, *herrors.Error) {
+	s := this.current()
+	if s.signer == nil {
+		return "", herrors.ErrSysInternal.New("SignedURLSecret not configured").D("failed to sign url")
+	}
+
+	vals := make(url.Values, len(params))
+	for k, v := range params {
+		vals.Set(k, fmt.Sprintf("%v", v))
+	}
+	return s.signer.Sign(fmt.Sprintf("%s/%s/%s", s.conf.SignedURLPath, version, api), vals, ttl), nil
+}
+
+// handleSignedAPI 签名有效且未过期时直接调用API，不校验JWT。参数只能来自签名覆盖的查询参数
+func (this *Connector) handleSignedAPI(c *fiber.Ctx) error {
+	api := c.Params("api")
+	version := c.Params("version")
+	s := this.current()
+
+	if s.conf.Metrics {
+		defer observeRequest(c, version, api, time.Now())
+	}
+	this.load.Begin()
+	defer this.load.End()
+	requestID := this.requestID(c)
+	this.deprecationHeaders(c, version, api)
+
+	query, e := url.ParseQuery(string(c.Request().URI().QueryString()))
+	if e != nil {
+		this.SendResponse(c, nil, herrors.ErrCallerInvalidRequest.New(e.Error()).D("failed to parse URL"))
+		return nil
+	}
+	if e = s.signer.Verify(c.Path(), query); e != nil {
+		this.SendResponse(c, nil, herrors.ErrCallerForbidden.New(e.Error()).D("invalid signed url"))
+		return nil
+	}
+
+	ps := make(htypes.Map, len(query)+2)
+	for k, v := range query {
+		if k != hsignurl.ParamExpires && k != hsignurl.ParamSignature {
+			ps[k] = this.paramValue(v)
+		}
+	}
+	core.StripScope(ps)
+	if err := this.resolveTenant(c, ps); err != nil {
+		this.SendResponse(c, nil, err)
+		return nil
+	}
+	ps[s.conf.AddressField] = this.clientIP(c)
+	ps[core.RequestIDField] = requestID
+	core.SetScoped(ps, core.ScopeAddress, ps[s.conf.AddressField])
+	core.SetScoped(ps, core.ScopeRequestID, requestID)
+	ctx, cancel := this.requestContext(c, requestID)
+	defer cancel()
+	ret, err := this.Gateway.RequestAPIContext(ctx, version, api, ps)
+	if err != nil {
+		this.SendResponse(c, nil, err)
+		return nil
+	}
+
+	if ok, err := this.HandleNDJSONRequest(c, ret); ok || err != nil {
+		if err != nil {
+			this.SendResponse(c, nil, err)
+		}
+		return nil
+	}
+	if ok, err := this.HandleRawRequest(c, ret); ok || err != nil {
+		if err != nil {
+			this.SendResponse(c, nil, err)
+		}
+		return nil
+	}
+	if ok, err := this.HandleCSVRequest(c, api, ret); ok || err != nil {
+		if err != nil {
+			this.SendResponse(c, nil, err)
+		}
+		return nil
+	}
+	if ok, err := this.HandleFileRequest(c, ret); ok {
+		if err != nil {
+			this.SendResponse(c, nil, err)
+		}
+	} else {
+		this.SendResponse(c, ret, err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package hwebconnector
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestHandleSignedAPI(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "download", "demo", "Download").
+		Handle("demo", "Download", htest.Return(htypes.Map{DownloadFlag: true, "name": "a.txt", "data": []byte("abc")}))
+
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.SignedURLSecret = "secret"
+	applyDefaults(&c.conf)
+	if err := c.publishSettings(c.conf); err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Get(c.conf.SignedURLPath+"/:version/:api", c.handleSignedAPI)
+
+	link, err := c.SignURL("v1", "download", htypes.Map{"id": 1}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, _ := app.Test(httptest.NewRequest("GET", link, nil))
+	if resp.StatusCode != fiber.StatusOK || resp.Header.Get("Content-Type") != "application/octet-stream" {
+		t.Errorf("status = %d, content type = %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	htest.AssertParams(t, gw.Router().(*htest.Router).LastParams("demo", "Download"), htypes.Map{"id": "1"})
+
+	resp, _ = app.Test(httptest.NewRequest("GET", link+"&id=2", nil))
+	if resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("tampered url: status = %d, want 403", resp.StatusCode)
+	}
+}
@@ -0,0 +1,51 @@
+package hwebconnector
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+)
+
+var defaultStatusCodes = map[int]int{
+	herrors.ECodeOK:                       fiber.StatusOK,
+	herrors.ECodeSysBusy:                  fiber.StatusServiceUnavailable,
+	herrors.ECodeSysTimeout:               fiber.StatusGatewayTimeout,
+	herrors.ECodeSysUnavailable:           fiber.StatusServiceUnavailable,
+	herrors.ECodeCallerUnauthorizedAccess: fiber.StatusUnauthorized,
+	herrors.ECodeCallerTooManyRequests:    fiber.StatusTooManyRequests,
+	herrors.ECodeCallerForbidden:          fiber.StatusForbidden,
+	herrors.ECodeCallerConflict:           fiber.StatusConflict,
+	herrors.ECodeCallerGone:               fiber.StatusGone,
+	herrors.ECodeUserUnauthorizedAct:      fiber.StatusForbidden,
+}
+
+// httpStatus 将herrors错误码映射为HTTP状态码：配置优先，其次是预定义映射，
+// 最后按错误码区间，服务器错误为5xx，调用方和用户错误为4xx
+func (this *Connector) httpStatus(err *herrors.Error) int {
+	if this.config().AlwaysStatusOK {
+		return fiber.StatusOK
+	}
+
+	code := herrors.ECodeOK
+	if err != nil {
+		code = err.Code
+	}
+	return this.errorStatus(code)
+}
+
+// errorStatus 错误码对应的HTTP状态码
+func (this *Connector) errorStatus(code int) int {
+	if status, ok := this.config().StatusCodes[strconv.Itoa(code)]; ok {
+		return status
+	}
+	if status, ok := defaultStatusCodes[code]; ok {
+		return status
+	}
+
+	if code < 0 || (code >= 100 && code < 200) {
+		return fiber.StatusInternalServerError
+	}
+	return fiber.StatusBadRequest
+}
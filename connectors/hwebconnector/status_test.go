@@ -0,0 +1,37 @@
+package hwebconnector
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+)
+
+func TestHttpStatus(t *testing.T) {
+	c := &Connector{}
+	c.conf.StatusCodes = map[string]int{"1001": fiber.StatusNotFound}
+	for _, tc := range []struct {
+		err    *herrors.Error
+		status int
+	}{
+		{nil, fiber.StatusOK},
+		{herrors.ErrSysInternal.New("x"), fiber.StatusInternalServerError},
+		{herrors.ErrSysTimeout.New("x"), fiber.StatusGatewayTimeout},
+		{herrors.ErrSysUnavailable.New("x"), fiber.StatusServiceUnavailable},
+		{herrors.ErrCallerInvalidRequest.New("x"), fiber.StatusBadRequest},
+		{herrors.ErrCallerTooManyRequests.New("x"), fiber.StatusTooManyRequests},
+		{herrors.ErrUserUnauthorizedAct.New("x"), fiber.StatusForbidden},
+		{herrors.New(1001).New("x"), fiber.StatusNotFound},
+		{herrors.New(1002).New("x"), fiber.StatusBadRequest},
+	} {
+		if status := c.httpStatus(tc.err); status != tc.status {
+			t.Errorf("%v: status = %d, want %d", tc.err, status, tc.status)
+		}
+	}
+
+	c.conf.AlwaysStatusOK = true
+	if status := c.httpStatus(herrors.ErrSysTimeout.New("x")); status != fiber.StatusOK {
+		t.Errorf("AlwaysStatusOK: status = %d", status)
+	}
+}
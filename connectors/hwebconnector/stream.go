@@ -0,0 +1,130 @@
+package hwebconnector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/valyala/fasthttp"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+// sendFileStream 以流的方式发送文件，src 可以是 io.Reader 或文件路径。
+// 当数据源可定位（文件或 io.ReadSeeker）时，支持单区间的 Range 请求。
+func (this *Connector) sendFileStream(c *fiber.Ctx, name string, preview bool, src htypes.Any) *herrors.Error {
+	var (
+		reader io.Reader
+		size   = -1
+	)
+
+	switch v := src.(type) {
+	case string:
+		f, err := os.Open(v)
+		if err != nil {
+			return herrors.ErrSysInternal.New(err.Error()).D("failed to open file")
+		}
+		info, err := f.Stat()
+		if err != nil {
+			_ = f.Close()
+			return herrors.ErrSysInternal.New(err.Error()).D("failed to open file")
+		}
+		reader = f
+		size = int(info.Size())
+	case io.Reader:
+		reader = v
+		if seeker, ok := v.(io.Seeker); ok {
+			if end, err := seeker.Seek(0, io.SeekEnd); err == nil {
+				if _, err = seeker.Seek(0, io.SeekStart); err == nil {
+					size = int(end)
+				}
+			}
+		}
+	default:
+		return herrors.ErrCallerInvalidRequest.New("parameter [%s] should be io.Reader or file path", DownloadStreamFlag).D("bad parameter")
+	}
+
+	if preview {
+		c.Set(fiber.HeaderContentType, utils.GetMIME(filepath.Ext(name)))
+		c.Set(fiber.HeaderContentDisposition, "inline; filename=\""+name+"\"")
+	} else {
+		c.Set(fiber.HeaderContentType, "application/octet-stream")
+		c.Set(fiber.HeaderContentDisposition, "attachment; filename=\""+name+"\"")
+	}
+
+	seeker, seekable := reader.(io.Seeker)
+	if seekable && size >= 0 {
+		c.Set(fiber.HeaderAcceptRanges, "bytes")
+
+		if rng := c.Get(fiber.HeaderRange); rng != "" {
+			start, end, err := fasthttp.ParseByteRange([]byte(rng), size)
+			if err != nil {
+				closeStream(reader)
+				c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", size))
+				c.Status(fiber.StatusRequestedRangeNotSatisfiable)
+				return nil
+			}
+			if _, err = seeker.Seek(int64(start), io.SeekStart); err != nil {
+				closeStream(reader)
+				return herrors.ErrSysInternal.New(err.Error()).D("failed to seek file")
+			}
+
+			c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+			c.Status(fiber.StatusPartialContent)
+			reader = &streamLimitReader{Reader: io.LimitReader(reader, int64(end-start+1)), source: reader}
+			size = end - start + 1
+		}
+	}
+
+	bufSize := this.config().StreamBufferSize * 1024
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer closeStream(reader)
+
+		buf := make([]byte, bufSize)
+		for {
+			n, err := reader.Read(buf)
+			if n > 0 {
+				if _, e := w.Write(buf[:n]); e != nil {
+					return
+				}
+				//客户端断开时Flush会失败，此时停止发送
+				if e := w.Flush(); e != nil {
+					return
+				}
+			}
+			if err != nil {
+				if err != io.EOF {
+					hlogger.Error(herrors.ErrSysInternal.New(err.Error()).D("failed to send file stream"))
+				}
+				return
+			}
+		}
+	})
+
+	if size >= 0 {
+		c.Response().Header.SetContentLength(size)
+	}
+
+	return nil
+}
+
+// streamLimitReader 保留原始数据源，以便发送完成后关闭
+type streamLimitReader struct {
+	io.Reader
+	source io.Reader
+}
+
+func closeStream(r io.Reader) {
+	if lr, ok := r.(*streamLimitReader); ok {
+		r = lr.source
+	}
+	if closer, ok := r.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
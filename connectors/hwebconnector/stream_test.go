@@ -0,0 +1,113 @@
+package hwebconnector
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core/htest"
+)
+
+const streamContent = "0123456789abcdef"
+
+// closeReader 可定位的数据源，记录是否被关闭
+type closeReader struct {
+	*bytes.Reader
+	closed chan struct{}
+}
+
+func (this *closeReader) Close() error {
+	close(this.closed)
+	return nil
+}
+
+// plainReader 不可定位的数据源
+type plainReader struct {
+	io.Reader
+}
+
+func TestSendFileStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte(streamContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var src *closeReader
+	gw := htest.NewGateway().
+		Route("v1", "file", "demo", "File").
+		Route("v1", "reader", "demo", "Reader").
+		Route("v1", "plain", "demo", "Plain").
+		Handle("demo", "File", htest.Return(htypes.Map{DownloadFlag: true, "name": "a.txt", DownloadStreamFlag: path})).
+		Handle("demo", "Reader", func(ps htypes.Map) (htypes.Any, *herrors.Error) {
+			src = &closeReader{Reader: bytes.NewReader([]byte(streamContent)), closed: make(chan struct{})}
+			return htypes.Map{DownloadFlag: true, "name": "a.txt", DownloadStreamFlag: src}, nil
+		}).
+		Handle("demo", "Plain", func(ps htypes.Map) (htypes.Any, *herrors.Error) {
+			return htypes.Map{DownloadFlag: true, "name": "a.txt", DownloadStreamFlag: &plainReader{bytes.NewReader([]byte(streamContent))}}, nil
+		})
+	app := newTestApp(gw)
+
+	get := func(api string, rng string) (int, string, string) {
+		req := httptest.NewRequest("GET", "/v1/"+api, nil)
+		if rng != "" {
+			req.Header.Set(fiber.HeaderRange, rng)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, resp.Header.Get(fiber.HeaderContentRange), string(bs)
+	}
+	closed := func(api string) {
+		t.Helper()
+		select {
+		case <-src.closed:
+		case <-time.After(time.Second):
+			t.Errorf("%s: source not closed", api)
+		}
+	}
+
+	for _, api := range []string{"file", "reader"} {
+		//完整下载
+		status, _, body := get(api, "")
+		if status != fiber.StatusOK || body != streamContent {
+			t.Errorf("%s: status = %d, body = %q", api, status, body)
+		}
+		if api == "reader" {
+			closed(api)
+		}
+
+		//单区间的Range请求
+		status, contentRange, body := get(api, "bytes=2-5")
+		if status != fiber.StatusPartialContent || contentRange != "bytes 2-5/16" || body != "2345" {
+			t.Errorf("%s range: status = %d, Content-Range = %q, body = %q", api, status, contentRange, body)
+		}
+		if api == "reader" {
+			closed(api)
+		}
+
+		//无法满足的区间
+		status, contentRange, body = get(api, "bytes=100-200")
+		if status != fiber.StatusRequestedRangeNotSatisfiable || contentRange != "bytes */16" || body != "" {
+			t.Errorf("%s unsatisfiable range: status = %d, Content-Range = %q, body = %q", api, status, contentRange, body)
+		}
+		if api == "reader" {
+			closed(api)
+		}
+	}
+
+	//不可定位的数据源忽略Range，发送完整内容
+	status, contentRange, body := get("plain", "bytes=2-5")
+	if status != fiber.StatusOK || contentRange != "" || body != streamContent {
+		t.Errorf("plain: status = %d, Content-Range = %q, body = %q", status, contentRange, body)
+	}
+}
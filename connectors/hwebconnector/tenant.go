@@ -0,0 +1,78 @@
+package hwebconnector
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+)
+
+const (
+	defaultTenantField = "tenant"
+
+	tenantPlaceholder = "{tenant}"
+)
+
+var tenantID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// initTenant TenantSubdomain如 {tenant}.example.com，转换为匹配Host的正则表达式
+func (this *Connector) initTenant(s *settings) *herrors.Error {
+	if s.conf.TenantSubdomain == "" {
+		return nil
+	}
+	if strings.Count(s.conf.TenantSubdomain, tenantPlaceholder) != 1 {
+		return herrors.ErrSysInternal.New("TenantSubdomain %s should contain %s once", s.conf.TenantSubdomain, tenantPlaceholder).D("failed to open web connector")
+	}
+
+	pattern := regexp.QuoteMeta(strings.ToLower(s.conf.TenantSubdomain))
+	pattern = strings.Replace(pattern, regexp.QuoteMeta(tenantPlaceholder), `([a-z0-9_-]+)`, 1)
+	s.tenantHost = regexp.MustCompile("^" + pattern + "$")
+	return nil
+}
+
+// resolveTenant 按TenantHeader或TenantSubdomain确定租户，写入参数的TenantField并覆盖调用方传入的同名参数，
+// 没有配置租户解析时不处理
+func (this *Connector) resolveTenant(c *fiber.Ctx, ps htypes.Map) *herrors.Error {
+	s := this.current()
+	if s.conf.TenantHeader == "" && s.tenantHost == nil {
+		return nil
+	}
+
+	tenant := s.tenant(c)
+	if tenant == "" {
+		delete(ps, s.conf.TenantField)
+		if s.conf.TenantRequired {
+			return herrors.ErrCallerInvalidRequest.New("tenant not specified").D("tenant not specified")
+		}
+		return nil
+	}
+	if !tenantID.MatchString(tenant) {
+		return herrors.ErrCallerInvalidRequest.New("invalid tenant %s", tenant).D("invalid tenant")
+	}
+	ps[s.conf.TenantField] = tenant
+	core.SetScoped(ps, core.ScopeTenant, tenant)
+	return nil
+}
+
+// tenant header优先，其次是Host中的子域名
+func (this *settings) tenant(c *fiber.Ctx) string {
+	if this.conf.TenantHeader != "" {
+		if t := strings.TrimSpace(c.Get(this.conf.TenantHeader)); t != "" {
+			return t
+		}
+	}
+	if this.tenantHost != nil {
+		host := strings.ToLower(c.Hostname())
+		if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.HasSuffix(host, "]") {
+			host = host[:i]
+		}
+		if m := this.tenantHost.FindStringSubmatch(host); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
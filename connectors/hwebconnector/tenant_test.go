@@ -0,0 +1,104 @@
+package hwebconnector
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestResolveTenant(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "echo", "demo", "Echo").
+		Handle("demo", "Echo", htest.Return(nil))
+
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.TenantHeader = "X-Tenant-Id"
+	c.conf.TenantSubdomain = "{tenant}.example.com"
+	c.conf.TenantRequired = true
+	applyDefaults(&c.conf)
+	if err := c.publishSettings(c.conf); err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Post("/:version/:api", c.handleServiceAPI)
+
+	cases := []struct {
+		host   string
+		header string
+		status int
+		tenant htypes.Any
+	}{
+		{"acme.example.com", "", fiber.StatusOK, "acme"},
+		{"Globex.Example.com:8080", "", fiber.StatusOK, "globex"},
+		{"acme.example.com", "initech", fiber.StatusOK, "initech"},
+		{"example.com", "", fiber.StatusBadRequest, nil},
+		{"a.b.example.com", "", fiber.StatusBadRequest, nil},
+		{"example.com", "bad/tenant", fiber.StatusBadRequest, nil},
+	}
+	for _, cs := range cases {
+		//调用方传入的tenant参数被覆盖
+		req := httptest.NewRequest("POST", "/v1/echo", strings.NewReader(`{"tenant":"spoofed"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Host = cs.host
+		if cs.header != "" {
+			req.Header.Set("X-Tenant-Id", cs.header)
+		}
+		gw.Router().(*htest.Router).Reset()
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != cs.status {
+			t.Errorf("%s %s: status = %d, want %d", cs.host, cs.header, resp.StatusCode, cs.status)
+			continue
+		}
+		if cs.tenant != nil {
+			htest.AssertParam(t, gw.Router().(*htest.Router).LastParams("demo", "Echo"), "tenant", cs.tenant)
+		}
+	}
+
+	c.conf.TenantSubdomain = "example.com"
+	if err := c.publishSettings(c.conf); err == nil {
+		t.Error("TenantSubdomain without {tenant} accepted")
+	}
+}
+
+func TestResolveTenantScope(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "echo", "demo", "Echo").
+		Handle("demo", "Echo", htest.Return(nil))
+
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.TenantHeader = "X-Tenant-Id"
+	applyDefaults(&c.conf)
+	if err := c.publishSettings(c.conf); err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Post("/:version/:api", c.handleServiceAPI)
+
+	//调用方传入的作用域被丢弃
+	req := httptest.NewRequest("POST", "/v1/echo", strings.NewReader(`{"__ctx__":{"subject":"admin","tenant":"spoofed"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant-Id", "acme")
+	if _, err := app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+	ps := gw.LastCall().Params
+	if core.ScopedTenant(ps) != "acme" || core.ScopedSubject(ps) != "" {
+		t.Fatalf("scope = %v", core.ScopedValues(ps))
+	}
+	if core.ScopedRequestID(ps) == "" || core.ScopedRequestID(ps) != ps[core.RequestIDField] {
+		t.Fatalf("scoped request id = %s", core.ScopedRequestID(ps))
+	}
+}
@@ -0,0 +1,51 @@
+package hwebconnector
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/drharryhe/has/core/htest"
+)
+
+func TestReadTimeout(t *testing.T) {
+	c := New()
+	c.Gateway = htest.NewGateway()
+	c.conf.ReadTimeout = 1
+	applyDefaults(&c.conf)
+	if c.conf.WriteTimeout != defaultWriteTimeout || c.conf.IdleTimeout != defaultIdleTimeout || c.conf.Concurrency != defaultConcurrency {
+		t.Fatalf("defaults not applied: %+v", c.conf)
+	}
+
+	app := c.newApp(&Listener{Routes: []string{RouteHealth}})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = app.Listener(ln)
+	}()
+	defer app.Shutdown()
+
+	//只发送部分header的慢速连接在ReadTimeout后被关闭
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, _ = conn.Write([]byte("GET /healthz HTTP/1.1\r\nHost: x\r\n"))
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	start := time.Now()
+	buf := make([]byte, 1024)
+	for {
+		if _, err := conn.Read(buf); err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				t.Fatal("connection not closed by server")
+			}
+			break
+		}
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("connection closed after %s, want about 1s", d)
+	}
+}
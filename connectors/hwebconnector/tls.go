@@ -0,0 +1,91 @@
+package hwebconnector
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultTlsMinVersion = "1.2"
+)
+
+// TlsCertificate 证书和私钥，一个监听可以配置多个证书，按客户端SNI请求的域名选择
+type TlsCertificate struct {
+	CertPath string
+	KeyPath  string
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsConfig 第一个证书为没有SNI或域名都不匹配时的缺省证书，证书文件变化或收到SIGHUP时重新加载，见watchCerts。
+// fasthttp不支持HTTP/2，ALPN只声明http/1.1，需要HTTP/2时由前端代理终止TLS
+func (this *Connector) tlsConfig(l *Listener) (*tls.Config, error) {
+	pairs := l.TlsCertificates
+	if l.TlsCertPath != "" || l.TlsKeyPath != "" {
+		pairs = append([]TlsCertificate{{CertPath: l.TlsCertPath, KeyPath: l.TlsKeyPath}}, pairs...)
+	}
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("tls certificate of listener on port %d not configured", l.Port)
+	}
+
+	holder := &certHolder{pairs: pairs}
+	if err := holder.load(); err != nil {
+		return nil, err
+	}
+	this.certs = append(this.certs, holder)
+	config := &tls.Config{NextProtos: []string{"http/1.1"}, GetCertificate: holder.getCertificate}
+
+	var err error
+	if config.MinVersion, err = tlsVersion(this.conf.TlsMinVersion); err != nil {
+		return nil, err
+	}
+	if this.conf.TlsMaxVersion != "" {
+		if config.MaxVersion, err = tlsVersion(this.conf.TlsMaxVersion); err != nil {
+			return nil, err
+		}
+		if config.MaxVersion < config.MinVersion {
+			return nil, fmt.Errorf("TlsMaxVersion %s lower than TlsMinVersion %s", this.conf.TlsMaxVersion, this.conf.TlsMinVersion)
+		}
+	}
+	if config.CipherSuites, err = cipherSuites(this.conf.TlsCipherSuites); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func tlsVersion(name string) (uint16, error) {
+	v, ok := tlsVersions[strings.TrimPrefix(strings.TrimSpace(name), "TLS")]
+	if !ok {
+		return 0, fmt.Errorf("tls version %s not supported", name)
+	}
+	return v, nil
+}
+
+// cipherSuites 只允许Go认为安全的套件，如 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256。
+// 不配置时使用Go的缺省套件，TLS 1.3的套件不可配置
+func cipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	secure := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		secure[s.Name] = s.ID
+	}
+
+	ret := make([]uint16, 0, len(names))
+	for _, n := range names {
+		id, ok := secure[n]
+		if !ok {
+			return nil, fmt.Errorf("cipher suite %s unknown or insecure", n)
+		}
+		ret = append(ret, id)
+	}
+	return ret, nil
+}
@@ -0,0 +1,33 @@
+package hwebconnector
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestTlsConfig(t *testing.T) {
+	c := New()
+	applyDefaults(&c.conf)
+
+	if _, err := c.tlsConfig(&Listener{Port: 443, Tls: true}); err == nil {
+		t.Error("listener without certificate should fail")
+	}
+
+	if v, err := tlsVersion(c.conf.TlsMinVersion); err != nil || v != tls.VersionTLS12 {
+		t.Errorf("default min version = %x, %v", v, err)
+	}
+	if v, err := tlsVersion("TLS1.3"); err != nil || v != tls.VersionTLS13 {
+		t.Errorf("version TLS1.3 = %x, %v", v, err)
+	}
+	if _, err := tlsVersion("2.0"); err == nil {
+		t.Error("version 2.0 should be unsupported")
+	}
+
+	ids, err := cipherSuites([]string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"})
+	if err != nil || len(ids) != 1 || ids[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("cipher suites = %v, %v", ids, err)
+	}
+	if _, err = cipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA"}); err == nil {
+		t.Error("insecure cipher suite should be rejected")
+	}
+}
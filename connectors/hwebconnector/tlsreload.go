@@ -0,0 +1,165 @@
+package hwebconnector
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/atomic"
+
+	"github.com/drharryhe/has/common/hlogger"
+)
+
+const (
+	certReloadDelay = 500 * time.Millisecond //证书和私钥通常先后写入，等待写完再加载
+)
+
+// certHolder 一个监听的证书，重新加载后对新连接生效，已建立的连接不受影响
+type certHolder struct {
+	pairs []TlsCertificate
+	certs atomic.Value //[]tls.Certificate
+}
+
+// load 加载全部证书，任一证书加载失败时返回错误并保留原证书
+func (this *certHolder) load() error {
+	certs := make([]tls.Certificate, 0, len(this.pairs))
+	for _, p := range this.pairs {
+		cer, err := tls.LoadX509KeyPair(p.CertPath, p.KeyPath)
+		if err != nil {
+			return fmt.Errorf("failed to load certificate %s: %s", p.CertPath, err.Error())
+		}
+		certs = append(certs, cer)
+	}
+	this.certs.Store(certs)
+	return nil
+}
+
+// getCertificate 按SNI选择证书，都不匹配时使用第一个证书
+func (this *certHolder) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certs := this.certs.Load().([]tls.Certificate)
+	if len(certs) > 1 && hello.ServerName != "" {
+		for i := range certs {
+			if hello.SupportsCertificate(&certs[i]) == nil {
+				return &certs[i], nil
+			}
+		}
+	}
+	return &certs[0], nil
+}
+
+// certWatcher 监听证书所在目录和SIGHUP，证书续期(如Let's Encrypt)后不需要重启
+type certWatcher struct {
+	watcher *fsnotify.Watcher
+	signals chan os.Signal
+	done    chan struct{}
+	once    sync.Once
+}
+
+// watchCerts 证书文件变化或收到SIGHUP时重新加载所有监听的证书，加载失败时记录日志并继续使用原证书
+func (this *Connector) watchCerts() {
+	if len(this.certs) == 0 {
+		return
+	}
+
+	w := &certWatcher{signals: make(chan os.Signal, 1), done: make(chan struct{})}
+	signal.Notify(w.signals, syscall.SIGHUP)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		hlogger.Warn("tls certificate watch disabled, reload by SIGHUP: %s", err.Error())
+	} else {
+		//续期工具可能以替换文件或符号链接的方式更新证书，因此监听所在目录
+		dirs := make(map[string]bool)
+		for _, h := range this.certs {
+			for _, p := range h.pairs {
+				dirs[filepath.Dir(p.CertPath)] = true
+				dirs[filepath.Dir(p.KeyPath)] = true
+			}
+		}
+		for dir := range dirs {
+			if err := watcher.Add(dir); err != nil {
+				hlogger.Warn("failed to watch tls certificate directory %s: %s", dir, err.Error())
+			}
+		}
+		w.watcher = watcher
+	}
+	this.certWatcher = w
+
+	go func() {
+		var events <-chan fsnotify.Event
+		if w.watcher != nil {
+			events = w.watcher.Events
+		}
+		var timer *time.Timer
+		reload := make(chan struct{}, 1)
+		for {
+			select {
+			case <-w.done:
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case <-w.signals:
+				this.reloadCerts()
+			case e, ok := <-events:
+				if !ok {
+					events = nil
+					continue
+				}
+				if e.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 || !this.isCertFile(e.Name) {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(certReloadDelay, func() {
+					select {
+					case reload <- struct{}{}:
+					default:
+					}
+				})
+			case <-reload:
+				this.reloadCerts()
+			}
+		}
+	}()
+}
+
+func (this *Connector) isCertFile(name string) bool {
+	name = filepath.Clean(name)
+	for _, h := range this.certs {
+		for _, p := range h.pairs {
+			if name == filepath.Clean(p.CertPath) || name == filepath.Clean(p.KeyPath) {
+				return true
+			}
+		}
+	}
+	//Kubernetes挂载的Secret通过替换 ..data 符号链接更新
+	return filepath.Base(name) == "..data"
+}
+
+func (this *Connector) reloadCerts() {
+	for _, h := range this.certs {
+		if err := h.load(); err != nil {
+			hlogger.Error("failed to reload tls certificates, keep using the old ones: %s", err.Error())
+			continue
+		}
+		hlogger.Info("tls certificates reloaded: %s", h.pairs[0].CertPath)
+	}
+}
+
+func (this *certWatcher) close() {
+	this.once.Do(func() {
+		signal.Stop(this.signals)
+		close(this.done)
+		if this.watcher != nil {
+			_ = this.watcher.Close()
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package hwebconnector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htrace"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+)
+
+// startSpan 以请求header中的W3C trace上下文为上级创建span，并写入响应header。未开启trace时返回nil
+func (this *Connector) startSpan(c *fiber.Ctx, version string, api string) trace.Span {
+	if !htrace.Enabled() {
+		return nil
+	}
+
+	ctx := htrace.Extract(context.Background(), c.Get(htrace.HeaderTraceParent), c.Get(htrace.HeaderTraceState))
+	ctx, span := htrace.Tracer().Start(ctx, version+"/"+api,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("has.version", version),
+			attribute.String("has.api", api),
+			attribute.String("http.method", c.Method()),
+			attribute.String("net.peer.ip", this.clientIP(c)),
+		))
+	if parent, _ := htrace.Inject(ctx); parent != "" {
+		c.Set(htrace.HeaderTraceParent, parent)
+	}
+	return span
+}
+
+// traceParams 将span的trace上下文写入服务参数，覆盖调用方提交的同名参数
+func traceParams(span trace.Span, ps htypes.Map) {
+	if span == nil {
+		return
+	}
+	ctx := trace.ContextWithSpan(context.Background(), span)
+	parent, state := htrace.Inject(ctx)
+	ps[core.TraceParentField] = parent
+	if state != "" {
+		ps[core.TraceStateField] = state
+	} else {
+		delete(ps, core.TraceStateField)
+	}
+}
+
+// endSpan 记录SendResponse写入的错误码
+func endSpan(c *fiber.Ctx, span trace.Span) {
+	if span == nil {
+		return
+	}
+	code, _ := c.Locals(errorCodeKey).(int)
+	span.SetAttributes(
+		attribute.Int("has.error_code", code),
+		attribute.Int("http.status_code", c.Response().StatusCode()),
+	)
+	if code != herrors.ECodeOK {
+		span.SetStatus(codes.Error, fmt.Sprintf("error code %d", code))
+	}
+	span.End()
+}
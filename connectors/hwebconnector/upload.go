@@ -0,0 +1,172 @@
+package hwebconnector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"path"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/utils/hrandom"
+)
+
+const (
+	UploadKeyField = "key" //UploadAPIs中的API，上传文件的对象key
+	UploadURLField = "url" //UploadAPIs中的API，上传文件的URL
+
+	maxUploadFieldSize  = 1024 * 1024 //bytes, 上传请求中非文件字段的最大长度
+	uploadRemoveTimeout = 10          //seconds
+)
+
+// ObjectStore 按流保存上传的文件，size未知时为-1，返回对象的URL，hminioplugin.Plugin实现了该接口
+type ObjectStore interface {
+	PutObject(ctx context.Context, key string, r io.Reader, size int64, contentType string) (string, *herrors.Error)
+	RemoveObject(ctx context.Context, key string) *herrors.Error
+}
+
+// initUpload UploadStore为插件名，插件需实现ObjectStore接口
+func (this *Connector) initUpload() *herrors.Error {
+	if len(this.conf.UploadAPIs) == 0 {
+		return nil
+	}
+
+	store, ok := this.Gateway.Server().Plugin(this.conf.UploadStore).(ObjectStore)
+	if !ok {
+		return herrors.ErrSysInternal.New("plugin %s not found or not implement ObjectStore", this.conf.UploadStore).D("failed to open web connector")
+	}
+	this.uploads = store
+	return nil
+}
+
+// uploadAPI 是否将该API上传的文件直接写入对象存储
+func (this *Connector) uploadAPI(version string, api string) bool {
+	name := fmt.Sprintf("%s/%s", version, api)
+	for _, a := range this.config().UploadAPIs {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// countReader 记录上传文件的大小，超过multipart限制时返回错误
+type countReader struct {
+	reader io.Reader
+	name   string
+	limits *multipartLimits
+	n      int64
+	err    *herrors.Error
+}
+
+func (this *countReader) Read(p []byte) (int, error) {
+	if this.err != nil {
+		return 0, this.err
+	}
+	n, err := this.reader.Read(p)
+	this.n += int64(n)
+	if this.err = this.limits.read(this.name, this.n, n); this.err != nil {
+		return n, this.err
+	}
+	return n, err
+}
+
+// parseUpload 逐个读取multipart请求体中的文件并写入对象存储，文件以 {name, size, type, key, url} 传给服务，不读入内存。
+// 某个文件写入失败时删除已写入的文件
+func (this *Connector) parseUpload(c *fiber.Ctx, ctx context.Context, ps htypes.Map) *herrors.Error {
+	boundary := string(c.Request().Header.MultipartFormBoundary())
+	if boundary == "" {
+		return nil
+	}
+
+	var r io.Reader = bytes.NewReader(c.Body())
+	if stream := requestBodyStream(c); stream != nil {
+		body := &bodyStream{reader: stream}
+		c.Locals(bodyStreamKey, body)
+		r = body
+	}
+
+	values := make(map[string][]string)
+	files := make(map[string][]htypes.Any)
+	var keys []string
+	limits := this.multipartLimits()
+	mr := multipart.NewReader(r, boundary)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			this.removeUploads(keys)
+			return herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to get data of form")
+		}
+
+		if e := limits.part(); e != nil {
+			this.removeUploads(keys)
+			return e
+		}
+
+		name := part.FormName()
+		if part.FileName() == "" {
+			bs, err := ioutil.ReadAll(io.LimitReader(part, maxUploadFieldSize+1))
+			if err != nil || len(bs) > maxUploadFieldSize {
+				this.removeUploads(keys)
+				return herrors.ErrCallerInvalidRequest.New("invalid form field %s", name).D("failed to get data of form")
+			}
+			values[name] = append(values[name], string(bs))
+			continue
+		}
+
+		key := this.config().UploadPrefix + hrandom.UuidWithoutDash() + "/" + path.Base(part.FileName())
+		body := &countReader{reader: part, name: part.FileName(), limits: limits}
+		contentType := part.Header.Get(fiber.HeaderContentType)
+		url, e := this.uploads.PutObject(ctx, key, body, -1, contentType)
+		if e == nil {
+			keys = append(keys, key)
+		}
+		//超过限制时，ObjectStore可能没有返回读取请求体的错误
+		if body.err != nil {
+			e = body.err
+		}
+		if e != nil {
+			this.removeUploads(keys)
+			return e
+		}
+		files[name] = append(files[name], htypes.Map{
+			"name":         part.FileName(),
+			"size":         body.n,
+			"type":         contentType,
+			UploadKeyField: key,
+			UploadURLField: url,
+		})
+	}
+
+	for k, v := range values {
+		ps[k] = this.paramValue(v)
+	}
+	for k, v := range files {
+		ps[k] = v
+	}
+	return nil
+}
+
+// removeUploads 请求被取消时ctx已失效，使用单独的ctx删除
+func (this *Connector) removeUploads(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), uploadRemoveTimeout*time.Second)
+	defer cancel()
+	for _, key := range keys {
+		if err := this.uploads.RemoveObject(ctx, key); err != nil {
+			hlogger.Warn("failed to remove upload %s: %s", key, err.Error())
+		}
+	}
+}
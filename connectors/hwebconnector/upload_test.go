@@ -0,0 +1,104 @@
+package hwebconnector
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/core/htest"
+)
+
+type testObjectStore struct {
+	core.BasePlugin
+	objects map[string]string
+	failAt  int
+}
+
+func (this *testObjectStore) PutObject(ctx context.Context, key string, r io.Reader, size int64, contentType string) (string, *herrors.Error) {
+	bs, _ := ioutil.ReadAll(r)
+	if this.failAt > 0 && len(this.objects)+1 == this.failAt {
+		return "", herrors.ErrSysInternal.New("put failed")
+	}
+	this.objects[key] = string(bs)
+	return "http://store/" + key, nil
+}
+
+func (this *testObjectStore) RemoveObject(ctx context.Context, key string) *herrors.Error {
+	delete(this.objects, key)
+	return nil
+}
+
+func TestUpload(t *testing.T) {
+	store := &testObjectStore{objects: make(map[string]string)}
+	gw := htest.NewGateway().
+		Route("v1", "avatar", "demo", "Avatar").
+		Handle("demo", "Avatar", htest.Return(nil)).
+		SetPlugin("MinioPlugin", store)
+
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.UploadAPIs = []string{"v1/avatar"}
+	c.conf.UploadStore = "MinioPlugin"
+	c.conf.UploadPrefix = "up/"
+	applyDefaults(&c.conf)
+	if err := c.initUpload(); err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New(fiber.Config{StreamRequestBody: true, DisableStartupMessage: true})
+	app.Post("/:version/:api", c.handleServiceAPI)
+
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	_ = w.WriteField("title", "me")
+	fw, _ := w.CreateFormFile("file", "../a.png")
+	_, _ = fw.Write(bytes.Repeat([]byte("x"), 100000))
+	fw, _ = w.CreateFormFile("file", "b.txt")
+	_, _ = fw.Write([]byte("hello"))
+	_ = w.Close()
+
+	req := httptest.NewRequest("POST", "/v1/avatar", bytes.NewReader(body.Bytes()))
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	if resp, _ := app.Test(req); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	ps := gw.Router().(*htest.Router).LastParams("demo", "Avatar")
+	if ps["title"] != "me" {
+		t.Errorf("title = %v", ps["title"])
+	}
+	files, _ := ps["file"].([]htypes.Any)
+	if len(files) != 2 {
+		t.Fatalf("files = %v", ps["file"])
+	}
+	f := files[0].(htypes.Map)
+	key, _ := f[UploadKeyField].(string)
+	if !strings.HasPrefix(key, "up/") || !strings.HasSuffix(key, "/a.png") || f["size"] != int64(100000) || f["data"] != nil {
+		t.Errorf("file = %v", f)
+	}
+	if f[UploadURLField] != "http://store/"+key || len(store.objects[key]) != 100000 {
+		t.Errorf("object %s not stored", key)
+	}
+
+	//第二个文件失败时删除已上传的文件
+	store.objects = make(map[string]string)
+	store.failAt = 2
+	req = httptest.NewRequest("POST", "/v1/avatar", bytes.NewReader(body.Bytes()))
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	if resp, _ := app.Test(req); resp.StatusCode == fiber.StatusOK {
+		t.Error("failed upload should not succeed")
+	}
+	if len(store.objects) != 0 {
+		t.Errorf("uploaded objects not removed: %v", store.objects)
+	}
+}
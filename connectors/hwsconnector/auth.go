@@ -0,0 +1,218 @@
+package hwsconnector
+
+import (
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/utils/hjwt"
+)
+
+const (
+	defaultAuthTimeout         = 10 //seconds
+	defaultJwtSubjectField     = "JwtSubject"
+	defaultJwtClaimsField      = "JwtClaims"
+	defaultSessionIDField      = "SessionID"
+	defaultSessionSubjectField = "SessionSubject"
+
+	// 认证失败时关闭连接的close code，与HTTP状态码对应
+	CloseUnauthorized = 4401 //token无效、已过期或会话已销毁
+	CloseAuthTimeout  = 4408 //AuthTimeout内未收到认证帧
+)
+
+// SessionProvider 按会话ID读取subject并顺延会话，会话不存在或已销毁时返回错误，hsessionplugin.Plugin实现了该接口
+type SessionProvider interface {
+	Subject(id string) (string, *herrors.Error)
+}
+
+// clientAuth 连接认证得到的参数
+type clientAuth struct {
+	params  htypes.Map
+	subject htypes.Any
+	expire  time.Time //JWT的过期时间，为零表示不过期
+	session string    //会话ID，每个请求帧重新检查会话是否有效
+}
+
+// initAuth 配置了JwtSecret或SessionPlugin时，连接需先认证才能调用API
+func (this *Connector) initAuth() *herrors.Error {
+	if this.conf.AuthTimeout <= 0 {
+		this.conf.AuthTimeout = defaultAuthTimeout
+	}
+	if this.conf.JwtSubjectField == "" {
+		this.conf.JwtSubjectField = defaultJwtSubjectField
+	}
+	if this.conf.JwtClaimsField == "" {
+		this.conf.JwtClaimsField = defaultJwtClaimsField
+	}
+	if this.conf.SessionIDField == "" {
+		this.conf.SessionIDField = defaultSessionIDField
+	}
+	if this.conf.SessionSubjectField == "" {
+		this.conf.SessionSubjectField = defaultSessionSubjectField
+	}
+
+	if this.conf.JwtSecret != "" {
+		signer, err := hjwt.New(this.conf.JwtSecret, this.conf.JwtAlgorithm, 0, this.conf.JwtIssuer)
+		if err != nil {
+			return herrors.ErrSysInternal.New(err.Error()).D("failed to init jwt")
+		}
+		this.jwt = signer
+	}
+	if this.conf.SessionPlugin != "" {
+		sessions, ok := this.Gateway.Server().Plugin(this.conf.SessionPlugin).(SessionProvider)
+		if !ok {
+			return herrors.ErrSysInternal.New("plugin %s not found or not implement SessionProvider", this.conf.SessionPlugin).D("failed to open websocket connector")
+		}
+		this.sessions = sessions
+	}
+	return nil
+}
+
+func (this *Connector) authRequired() bool {
+	return this.jwt != nil || this.sessions != nil
+}
+
+// queryAuth 升级请求在AuthQuery参数中携带了token时，在升级前认证，token无效时拒绝升级。
+// 未携带时返回nil，由连接的第一帧认证
+func (this *Connector) queryAuth(c *fiber.Ctx) (*clientAuth, *herrors.Error) {
+	if !this.authRequired() || this.conf.AuthQuery == "" {
+		return nil, nil
+	}
+	token := c.Query(this.conf.AuthQuery)
+	if token == "" {
+		return nil, nil
+	}
+	return this.authenticate(token)
+}
+
+// handshake 等待第一帧 {"id": "1", "auth": "<token>"}，认证成功时返回与请求帧相同的响应帧，
+// 超时或认证失败时以CloseAuthTimeout或CloseUnauthorized关闭连接
+func (this *Connector) handshake(cl *client) bool {
+	_ = cl.conn.SetReadDeadline(time.Now().Add(time.Duration(this.conf.AuthTimeout) * time.Second))
+	_, bs, err := cl.conn.ReadMessage()
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			this.closeClient(cl, CloseAuthTimeout, "authentication timeout")
+		}
+		return false
+	}
+
+	var id, token string
+	if val, e := this.Packer.Unmarshal(bs); e == nil {
+		m, _ := val.(map[string]interface{})
+		id, _ = m["id"].(string)
+		token, _ = m["auth"].(string)
+	}
+	if token == "" {
+		this.closeClient(cl, CloseUnauthorized, "authentication required")
+		return false
+	}
+
+	auth, e := this.authenticate(token)
+	if e != nil {
+		_ = this.send(cl, NewResponseData(id, nil, e))
+		this.closeClient(cl, CloseUnauthorized, e.Desc)
+		return false
+	}
+	cl.auth = auth
+	return this.send(cl, NewResponseData(id, htypes.Map{"client": cl.id}, nil)) == nil
+}
+
+// authenticate 配置了JwtSecret时先按JWT校验，失败且配置了SessionPlugin时再按会话ID校验
+func (this *Connector) authenticate(token string) (*clientAuth, *herrors.Error) {
+	var err *herrors.Error
+	if this.jwt != nil {
+		claims, e := this.jwt.Verify(token)
+		if e == nil {
+			return &clientAuth{
+				params: htypes.Map{
+					this.conf.JwtSubjectField: claims[hjwt.ClaimSubject],
+					this.conf.JwtClaimsField:  htypes.Map(claims),
+				},
+				subject: claims[hjwt.ClaimSubject],
+				expire:  claimTime(claims[hjwt.ClaimExpire]),
+			}, nil
+		}
+		err = herrors.ErrCallerUnauthorizedAccess.New(e.Error()).D("invalid token")
+	}
+	if this.sessions != nil {
+		subject, e := this.sessions.Subject(token)
+		if e == nil {
+			return &clientAuth{
+				params: htypes.Map{
+					this.conf.SessionIDField:      token,
+					this.conf.SessionSubjectField: subject,
+				},
+				subject: subject,
+				session: token,
+			}, nil
+		}
+		err = e
+	}
+	return nil, err
+}
+
+// authorize 每个请求帧调用前检查认证是否仍然有效，并写入认证得到的参数，客户端提交的同名参数被覆盖。
+// JWT过期或会话已销毁时返回错误
+func (this *Connector) authorize(cl *client, ps htypes.Map) *herrors.Error {
+	if !this.authRequired() {
+		return nil
+	}
+	for _, f := range []string{this.conf.JwtSubjectField, this.conf.JwtClaimsField, this.conf.SessionIDField, this.conf.SessionSubjectField} {
+		delete(ps, f)
+	}
+
+	auth := cl.auth
+	if !auth.expire.IsZero() && time.Now().After(auth.expire) {
+		return herrors.ErrCallerUnauthorizedAccess.New("jwt expired").D("invalid token")
+	}
+	if auth.session != "" {
+		if _, err := this.sessions.Subject(auth.session); err != nil {
+			return err
+		}
+	}
+	for k, v := range auth.params {
+		ps[k] = v
+	}
+	core.SetScoped(ps, core.ScopeSubject, auth.subject)
+	if auth.session != "" {
+		core.SetScoped(ps, core.ScopeSession, auth.session)
+	}
+	if claims, ok := auth.params[this.conf.JwtClaimsField]; ok {
+		core.SetScoped(ps, core.ScopeClaims, claims)
+	}
+	return nil
+}
+
+func (this *Connector) closeClient(cl *client, code int, reason string) {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+
+	msg := websocket.FormatCloseMessage(code, reason)
+	if err := cl.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Duration(this.conf.WriteTimeout)*time.Second)); err != nil {
+		hlogger.Info("failed to close websocket client %s: %s", cl.id, err.Error())
+	}
+	_ = cl.conn.Close()
+}
+
+func claimTime(v interface{}) time.Time {
+	var sec int64
+	switch t := v.(type) {
+	case float64:
+		sec = int64(t)
+	case int64:
+		sec = t
+	case json.Number:
+		sec, _ = t.Int64()
+	default:
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
@@ -0,0 +1,109 @@
+package hwsconnector
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/drharryhe/has/core/htest"
+	"github.com/drharryhe/has/utils/hjwt"
+)
+
+func TestAuthHandshake(t *testing.T) {
+	gw := htest.NewGateway().
+		Route("v1", "echo", "demo", "Echo").
+		Handle("demo", "Echo", htest.Return("ok"))
+
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.ClientField = defaultClientField
+	c.conf.ReadTimeout = defaultReadTimeout
+	c.conf.WriteTimeout = defaultWriteTimeout
+	c.conf.MaxMessageSize = defaultMaxMessageSize
+	c.conf.MaxInflight = defaultMaxInflight
+	c.conf.JwtSecret = "secret"
+	c.conf.AuthQuery = "token"
+	c.conf.AuthTimeout = 1
+	if err := c.initAuth(); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Get("/ws", c.handleUpgrade)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = app.Listener(ln)
+	}()
+	defer app.Shutdown()
+	url := fmt.Sprintf("ws://%s/ws", ln.Addr().String())
+
+	signer, _ := hjwt.New("secret", "", time.Minute, "")
+	token, _ := signer.Issue("u1", nil)
+
+	dial := func(url string) *websocket.Conn {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	closeCode := func(conn *websocket.Conn) int {
+		t.Helper()
+		_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				if ce, ok := err.(*websocket.CloseError); ok {
+					return ce.Code
+				}
+				t.Fatalf("read: %v", err)
+			}
+		}
+	}
+
+	//未认证的API帧
+	conn := dial(url)
+	_ = conn.WriteJSON(map[string]interface{}{"id": "1", "version": "v1", "api": "echo"})
+	if code := closeCode(conn); code != CloseUnauthorized {
+		t.Errorf("close code = %d, want %d", code, CloseUnauthorized)
+	}
+
+	//认证超时
+	conn = dial(url)
+	if code := closeCode(conn); code != CloseAuthTimeout {
+		t.Errorf("close code = %d, want %d", code, CloseAuthTimeout)
+	}
+
+	//第一帧认证后调用API，客户端提交的subject被覆盖
+	conn = dial(url)
+	_ = conn.WriteJSON(map[string]interface{}{"id": "1", "auth": token})
+	var res map[string]interface{}
+	if err := conn.ReadJSON(&res); err != nil || res["id"] != "1" || res["error"].(map[string]interface{})["code"].(float64) != 0 {
+		t.Fatalf("auth response = %v, %v", res, err)
+	}
+	_ = conn.WriteJSON(map[string]interface{}{"id": "2", "version": "v1", "api": "echo", "params": map[string]interface{}{"JwtSubject": "admin"}})
+	if err := conn.ReadJSON(&res); err != nil || res["data"] != "ok" {
+		t.Fatalf("api response = %v, %v", res, err)
+	}
+	htest.AssertParam(t, gw.LastCall().Params, "JwtSubject", "u1")
+	_ = conn.Close()
+
+	//查询参数认证
+	conn = dial(url + "?token=" + token)
+	_ = conn.WriteJSON(map[string]interface{}{"id": "3", "version": "v1", "api": "echo"})
+	if err := conn.ReadJSON(&res); err != nil || res["data"] != "ok" {
+		t.Fatalf("api response = %v, %v", res, err)
+	}
+	_ = conn.Close()
+	if _, resp, err := websocket.DefaultDialer.Dial(url+"?token=bad", nil); err == nil || resp == nil || resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("invalid query token: err = %v", err)
+	}
+}
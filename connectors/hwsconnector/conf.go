@@ -0,0 +1,32 @@
+package hwsconnector
+
+import "github.com/drharryhe/has/core"
+
+type WsConnector struct {
+	core.ConnectorConf
+
+	Port           int
+	Path           string //升级为WebSocket的路径
+	Tls            bool
+	TlsCertPath    string
+	TlsKeyPath     string
+	AddressField   string
+	ClientField    string //连接ID写入请求参数的字段名，服务可据此向客户端推送消息
+	ReadTimeout    int    // seconds, 读超时，超时未收到任何帧则断开
+	WriteTimeout   int    // seconds
+	MaxMessageSize int    // KB
+
+	JwtSecret           string // 配置后连接需先以JWT认证才能调用API
+	JwtAlgorithm        string // HS256, HS384, HS512
+	JwtIssuer           string
+	JwtSubjectField     string // JWT subject写入参数的字段名，缺省为 JwtSubject
+	JwtClaimsField      string // JWT claims写入参数的字段名，缺省为 JwtClaims
+	SessionPlugin       string // 会话插件，如 SessionPlugin，需实现SessionProvider，配置后连接可以会话ID认证
+	SessionIDField      string // 会话ID写入参数的字段名，缺省为 SessionID
+	SessionSubjectField string // 会话subject写入参数的字段名，缺省为 SessionSubject
+	AuthQuery           string // 升级请求中携带token的查询参数名，如 token，不配置则只能以第一帧认证
+	AuthTimeout         int    // seconds, 连接后等待认证帧的时长，缺省为 10
+
+	AllowOrigins []string // 允许建立连接的来源，如 https://example.com，"*"允许所有来源，不配置则只允许与请求Host相同的来源
+	MaxInflight  int      // 每个连接同时处理的帧数，达到时暂停读取，缺省为 16
+}
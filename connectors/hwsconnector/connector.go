@@ -0,0 +1,335 @@
+package hwsconnector
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/utils/hjwt"
+	"github.com/drharryhe/has/utils/hrandom"
+)
+
+const (
+	defaultPort           = 1977
+	defaultPath           = "/ws"
+	defaultClientField    = "WsClient"
+	defaultReadTimeout    = 60  //seconds
+	defaultWriteTimeout   = 10  //seconds
+	defaultMaxMessageSize = 512 //KB
+	defaultMaxInflight    = 16
+
+	originAny = "*"
+)
+
+func New() *Connector {
+	return new(Connector)
+}
+
+type Connector struct {
+	core.BaseConnector
+
+	conf     WsConnector
+	App      *fiber.App
+	upgrader websocket.FastHTTPUpgrader
+	clients  sync.Map // id -> *client
+	jwt      *hjwt.Signer
+	sessions SessionProvider
+}
+
+type client struct {
+	id   string
+	ip   string
+	conn *websocket.Conn
+	lock sync.Mutex      //websocket连接不支持并发写
+	ctx  context.Context //连接断开时取消，处理中的请求随之取消
+	auth *clientAuth     //开启认证时，认证通过后设置
+}
+
+func (this *Connector) Open(gw core.IAPIGateway, ins core.IAPIConnector) *herrors.Error {
+	if err := this.BaseConnector.Open(gw, ins); err != nil {
+		return err
+	}
+
+	if this.conf.Port == 0 {
+		this.conf.Port = defaultPort
+	}
+	if this.conf.Path == "" {
+		this.conf.Path = defaultPath
+	}
+	if this.conf.ClientField == "" {
+		this.conf.ClientField = defaultClientField
+	}
+	if this.conf.ReadTimeout <= 0 {
+		this.conf.ReadTimeout = defaultReadTimeout
+	}
+	if this.conf.WriteTimeout <= 0 {
+		this.conf.WriteTimeout = defaultWriteTimeout
+	}
+	if this.conf.MaxMessageSize <= 0 {
+		this.conf.MaxMessageSize = defaultMaxMessageSize
+	}
+	if err := this.initAuth(); err != nil {
+		return err
+	}
+
+	if this.conf.MaxInflight <= 0 {
+		this.conf.MaxInflight = defaultMaxInflight
+	}
+	this.upgrader = websocket.FastHTTPUpgrader{CheckOrigin: this.checkOrigin}
+
+	this.App = fiber.New()
+	this.App.Get(this.conf.Path, this.handleUpgrade)
+
+	go func() {
+		if this.conf.Tls {
+			cer, err := tls.LoadX509KeyPair(this.conf.TlsCertPath, this.conf.TlsKeyPath)
+			if err != nil {
+				panic(herrors.ErrSysInternal.New(err.Error()).D("failed to load tls certificate"))
+			}
+
+			ln, err := tls.Listen("tcp", fmt.Sprintf(":%d", this.conf.Port), &tls.Config{Certificates: []tls.Certificate{cer}})
+			if err != nil {
+				panic(herrors.ErrSysInternal.New(err.Error()).D("failed to listen tls"))
+			}
+
+			if err = this.App.Listener(ln); err != nil {
+				panic(herrors.ErrSysInternal.New(err.Error()).D("failed to listen Fiber App"))
+			}
+		} else {
+			if err := this.App.Listen(fmt.Sprintf(":%d", this.conf.Port)); err != nil {
+				panic(herrors.ErrSysInternal.New(err.Error()).D("failed to listen Fiber App"))
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (this *Connector) Close() {
+	this.clients.Range(func(key, value interface{}) bool {
+		_ = value.(*client).conn.Close()
+		return true
+	})
+
+	if this.App != nil {
+		if err := this.App.Shutdown(); err != nil {
+			hlogger.Warn("websocket connector shutdown: %s", err.Error())
+		}
+	}
+
+	this.BaseConnector.Close()
+}
+
+// Push 向指定连接推送事件
+func (this *Connector) Push(clientID string, event string, data htypes.Any) *herrors.Error {
+	v, ok := this.clients.Load(clientID)
+	if !ok {
+		return herrors.ErrCallerInvalidRequest.New("websocket client %s not found", clientID)
+	}
+
+	res := NewResponseData("", data, nil)
+	res.Event = event
+	return this.send(v.(*client), res)
+}
+
+// Broadcast 向所有连接推送事件
+func (this *Connector) Broadcast(event string, data htypes.Any) {
+	this.clients.Range(func(key, value interface{}) bool {
+		res := NewResponseData("", data, nil)
+		res.Event = event
+		if err := this.send(value.(*client), res); err != nil {
+			hlogger.Error(err)
+		}
+		return true
+	})
+}
+
+func (this *Connector) handleUpgrade(c *fiber.Ctx) error {
+	if !websocket.FastHTTPIsWebSocketUpgrade(c.Context()) {
+		return fiber.ErrUpgradeRequired
+	}
+
+	//浏览器不能为WebSocket升级请求设置header，token在查询参数或第一帧中携带
+	auth, herr := this.queryAuth(c)
+	if herr != nil {
+		return c.Status(fiber.StatusUnauthorized).SendString(herr.Desc)
+	}
+
+	ip := c.IP()
+	err := this.upgrader.Upgrade(c.Context(), func(conn *websocket.Conn) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cl := &client{
+			id:   hrandom.UuidWithoutDash(),
+			ip:   ip,
+			conn: conn,
+			ctx:  ctx,
+			auth: auth,
+		}
+		this.clients.Store(cl.id, cl)
+		defer func() {
+			cancel()
+			this.clients.Delete(cl.id)
+			_ = conn.Close()
+		}()
+
+		this.serve(cl)
+	})
+	if err != nil {
+		hlogger.Error(herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to upgrade websocket"))
+	}
+
+	return nil
+}
+
+func (this *Connector) serve(cl *client) {
+	readTimeout := time.Duration(this.conf.ReadTimeout) * time.Second
+
+	cl.conn.SetReadLimit(int64(this.conf.MaxMessageSize) * 1024)
+	if this.authRequired() && cl.auth == nil && !this.handshake(cl) {
+		return
+	}
+	cl.conn.SetPongHandler(func(string) error {
+		return cl.conn.SetReadDeadline(time.Now().Add(readTimeout))
+	})
+
+	//处理中的帧达到MaxInflight时暂停读取，直到有帧处理完成
+	sem := make(chan struct{}, this.conf.MaxInflight)
+	for {
+		sem <- struct{}{}
+		_ = cl.conn.SetReadDeadline(time.Now().Add(readTimeout))
+		typ, bs, err := cl.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				hlogger.Info("websocket client %s closed: %s", cl.id, err.Error())
+			}
+			return
+		}
+
+		if typ != websocket.TextMessage && typ != websocket.BinaryMessage {
+			<-sem
+			continue
+		}
+
+		go func() {
+			defer func() {
+				<-sem
+			}()
+			this.handleFrame(cl, bs)
+		}()
+	}
+}
+
+// checkOrigin 没有Origin的请求(非浏览器客户端)允许。配置AllowOrigins时只允许其中的来源，"*"允许所有来源，
+// 否则只允许与请求Host相同的来源，避免其他网站以用户浏览器中的凭证建立连接
+func (this *Connector) checkOrigin(ctx *fasthttp.RequestCtx) bool {
+	origin := string(ctx.Request.Header.Peek(fiber.HeaderOrigin))
+	if origin == "" {
+		return true
+	}
+	if len(this.conf.AllowOrigins) > 0 {
+		for _, o := range this.conf.AllowOrigins {
+			if o == originAny || strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+				return true
+			}
+		}
+		hlogger.Warn("websocket origin %s not allowed", origin)
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || !strings.EqualFold(u.Host, string(ctx.Host())) {
+		hlogger.Warn("websocket origin %s not allowed", origin)
+		return false
+	}
+	return true
+}
+
+func (this *Connector) handleFrame(cl *client, bs []byte) {
+	val, err := this.Packer.Unmarshal(bs)
+	if err != nil {
+		_ = this.send(cl, NewResponseData("", nil, herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to parse frame")))
+		return
+	}
+
+	m, _ := val.(map[string]interface{})
+	var frame RequestFrame
+	frame.ID, _ = m["id"].(string)
+	frame.Version, _ = m["version"].(string)
+	frame.API, _ = m["api"].(string)
+	if ps, ok := m["params"].(map[string]interface{}); ok {
+		frame.Params = ps
+	} else {
+		frame.Params = make(htypes.Map)
+	}
+
+	if frame.Version == "" || frame.API == "" {
+		_ = this.send(cl, NewResponseData(frame.ID, nil, herrors.ErrCallerInvalidRequest.New("version or api not found in frame").D("bad request")))
+		return
+	}
+
+	core.StripScope(frame.Params)
+	if err := this.authorize(cl, frame.Params); err != nil {
+		_ = this.send(cl, NewResponseData(frame.ID, nil, err))
+		this.closeClient(cl, CloseUnauthorized, err.Desc)
+		return
+	}
+	if this.conf.AddressField != "" {
+		frame.Params[this.conf.AddressField] = cl.ip
+	}
+	frame.Params[this.conf.ClientField] = cl.id
+	core.SetScoped(frame.Params, core.ScopeAddress, cl.ip)
+
+	ret, err := this.Gateway.RequestAPIContext(cl.ctx, frame.Version, frame.API, frame.Params)
+	if err != nil && err.Code != herrors.ECodeOK && this.conf.Lang != "" {
+		if trans := this.Gateway.I18n(); trans != nil {
+			err = core.TranslateFields(trans, err, this.conf.Lang)
+			err = err.D(trans.Translate(this.conf.Lang, err.Desc))
+		}
+	}
+
+	if e := this.send(cl, NewResponseData(frame.ID, ret, err)); e != nil {
+		hlogger.Error(e)
+	}
+}
+
+func (this *Connector) send(cl *client, res *ResponseData) *herrors.Error {
+	bs, err := this.Packer.Marshal(res)
+	if err != nil {
+		return err
+	}
+
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+
+	_ = cl.conn.SetWriteDeadline(time.Now().Add(time.Duration(this.conf.WriteTimeout) * time.Second))
+	if e := cl.conn.WriteMessage(websocket.TextMessage, bs); e != nil {
+		return herrors.ErrSysInternal.New(e.Error()).D("failed to send data")
+	}
+	return nil
+}
+
+func (this *Connector) EntityStub() *core.EntityStub {
+	return core.NewEntityStub(
+		&core.EntityStubOptions{
+			Owner:       this,
+			Ping:        nil,
+			GetLoad:     nil,
+			ResetConfig: nil,
+		})
+}
+
+func (this *Connector) Config() core.IEntityConf {
+	return &this.conf
+}
@@ -0,0 +1,126 @@
+package hwsconnector
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/atomic"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core/htest"
+)
+
+func newTestConnector(gw *htest.Gateway) (*Connector, string, func()) {
+	c := New()
+	c.Gateway = gw
+	c.Packer = gw.Packer(htest.DefaultPacker)
+	c.conf.ClientField = defaultClientField
+	c.conf.ReadTimeout = defaultReadTimeout
+	c.conf.WriteTimeout = defaultWriteTimeout
+	c.conf.MaxMessageSize = defaultMaxMessageSize
+	c.conf.MaxInflight = defaultMaxInflight
+	c.upgrader = websocket.FastHTTPUpgrader{CheckOrigin: c.checkOrigin}
+
+	app := fiber.New()
+	app.Get("/ws", c.handleUpgrade)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	go func() {
+		_ = app.Listener(ln)
+	}()
+	return c, ln.Addr().String(), func() {
+		_ = app.Shutdown()
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	c, addr, stop := newTestConnector(htest.NewGateway())
+	defer stop()
+
+	dial := func(origin string) bool {
+		h := http.Header{}
+		if origin != "" {
+			h.Set("Origin", origin)
+		}
+		conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://%s/ws", addr), h)
+		if err != nil {
+			return false
+		}
+		_ = conn.Close()
+		return true
+	}
+
+	cases := []struct {
+		allow  []string
+		origin string
+		ok     bool
+	}{
+		{nil, "", true},
+		{nil, "http://" + addr, true},
+		{nil, "https://evil.example.com", false},
+		{[]string{"https://app.example.com/"}, "https://app.example.com", true},
+		{[]string{"https://app.example.com"}, "http://" + addr, false},
+		{[]string{"*"}, "https://evil.example.com", true},
+	}
+	for _, tc := range cases {
+		c.conf.AllowOrigins = tc.allow
+		if ok := dial(tc.origin); ok != tc.ok {
+			t.Errorf("allow %v origin %q: connected = %v, want %v", tc.allow, tc.origin, ok, tc.ok)
+		}
+	}
+}
+
+func TestMaxInflight(t *testing.T) {
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	gw := htest.NewGateway().
+		Route("v1", "slow", "demo", "Slow").
+		Handle("demo", "Slow", func(params htypes.Map) (htypes.Any, *herrors.Error) {
+			n := running.Inc()
+			for {
+				p := peak.Load()
+				if n <= p || peak.CAS(p, n) {
+					break
+				}
+			}
+			<-release
+			running.Dec()
+			return "ok", nil
+		})
+	c, addr, stop := newTestConnector(gw)
+	defer stop()
+	c.conf.MaxInflight = 2
+
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://%s/ws", addr), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for i := 0; i < 5; i++ {
+		_ = conn.WriteJSON(map[string]interface{}{"id": fmt.Sprint(i), "version": "v1", "api": "slow"})
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if n := running.Load(); n != 2 {
+		t.Errorf("running = %d, want 2", n)
+	}
+	close(release)
+
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for i := 0; i < 5; i++ {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p := peak.Load(); p != 2 {
+		t.Errorf("peak = %d, want 2", p)
+	}
+}
@@ -0,0 +1,47 @@
+package hwsconnector
+
+import (
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hpaging"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/utils/hruntime"
+)
+
+// RequestFrame 客户端请求帧
+type RequestFrame struct {
+	ID      string     `json:"id"`
+	Version string     `json:"version"`
+	API     string     `json:"api"`
+	Params  htypes.Map `json:"params"`
+}
+
+// ResponseData 请求的响应帧，ID与请求帧对应；服务端推送的事件帧ID为空，Event为事件名
+type ResponseData struct {
+	ID    string         `json:"id,omitempty"`
+	Event string         `json:"event,omitempty"`
+	Data  htypes.Any     `json:"data"`
+	Page  *hpaging.Page  `json:"page,omitempty"` //列表结果的分页信息
+	Error *herrors.Error `json:"error"`
+}
+
+func NewResponseData(id string, data htypes.Any, err *herrors.Error) *ResponseData {
+	res := ResponseData{ID: id}
+	if data == nil || hruntime.IsNil(data) {
+		res.Data = htypes.Map{}
+	} else if list, ok := data.(*hpaging.List); ok {
+		res.Data = list.Items
+		res.Page = &list.Page
+	} else {
+		res.Data = data
+	}
+
+	if err == nil || hruntime.IsNil(err) {
+		res.Error = &herrors.Error{
+			Code: herrors.ECodeOK,
+		}
+	} else {
+		res.Error = err
+	}
+
+	return &res
+}
@@ -8,8 +8,10 @@ type APIDefine struct {
 }
 
 type OpenAPI struct {
-	Version string `json:"version"`
-	APIs    []API  `json:"apis"`
+	Version     string `json:"version"`
+	APIs        []API  `json:"apis"`
+	Removed     bool   `json:"removed"`     //已下线的版本，调用返回ErrCallerGone
+	Replacement string `json:"replacement"` //替代的版本，如 v2，写入下线版本的错误信息
 }
 
 type API struct {
@@ -1,7 +1,10 @@
 package core
 
 import (
+	"context"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/afex/hystrix-go/hystrix"
 	jsoniter "github.com/json-iterator/go"
@@ -36,8 +39,10 @@ type APIGateway struct {
 	BreakerSleepWindow            int
 	BreakerErrorPercentThreshold  int
 	BreakerDashboard              bool
+	Breakers                      map[string]Breaker //按 service 或 service/slot 覆盖熔断设置
 	UserField                     string
 	AddressField                  string
+	DeprecationLogInterval        int //seconds, 废弃API调用次数的日志间隔，缺省为 60
 }
 
 type APIGateWayImplement struct {
@@ -50,10 +55,15 @@ type APIGateWayImplement struct {
 	i18n        IAPIi18n
 	packers     map[string]IAPIDataPacker
 
-	apiSet map[string]map[string]*API
+	apiSet     map[string]map[string]*API
+	removed    map[string]string //已下线的版本 -> 替代的版本
+	deprecated sync.Map          //version/api -> *deprecatedUsage
 
-	conf           APIGateway             //Gateway配置
-	breakCmdConfig *hystrix.CommandConfig //熔断器设置
+	conf             APIGateway             //Gateway配置
+	breakCmdConfig   *hystrix.CommandConfig //熔断器设置
+	breakerCmds      sync.Map               //熔断命令 -> 配置键
+	breakerLock      sync.RWMutex           //保护conf和熔断设置，resetConfig时写，请求处理时读
+	breakerDashboard *hystrix.StreamHandler
 }
 
 func (this *APIGateWayImplement) init(opt *APIGatewayOptions, args ...htypes.Any) {
@@ -68,6 +78,7 @@ func (this *APIGateWayImplement) init(opt *APIGatewayOptions, args ...htypes.Any
 		Router:        opt.Router,
 		Plugins:       opt.Plugins,
 		AssetsManager: opt.AssetsManager,
+		Authorizer:    opt.Authorizer,
 	}, args)
 
 	this.class = hruntime.GetObjectName(this)
@@ -113,6 +124,15 @@ func (this *APIGateWayImplement) init(opt *APIGatewayOptions, args ...htypes.Any
 
 	this.loadAPIs()
 	hconf.Load(&this.conf)
+	if this.conf.DeprecationLogInterval <= 0 {
+		this.conf.DeprecationLogInterval = defaultDeprecationLogInterval
+	}
+	if this.conf.UseBreaker {
+		this.initBreaker()
+	}
+
+	//退出时先关闭connector，等待处理中的请求完成后再关闭router和plugins
+	this.server.addCloseHook(this.close)
 
 	if err := this.router.RegisterEntity(this); err != nil {
 		panic(err.D("failed to init APIGateWayImplement"))
@@ -124,16 +144,6 @@ func (this *APIGateWayImplement) Start() {
 }
 
 func (this *APIGateWayImplement) Shutdown() {
-	for _, c := range this.connectors {
-		c.Close()
-	}
-	for _, m := range this.middlewares {
-		m.Close()
-	}
-	for _, p := range this.packers {
-		p.Close()
-	}
-
 	this.server.Shutdown()
 }
 
@@ -149,7 +159,35 @@ func (this *APIGateWayImplement) I18n() IAPIi18n {
 	return this.i18n
 }
 
-func (this *APIGateWayImplement) RequestAPI(version string, api string, params htypes.Map) (ret htypes.Any, err *herrors.Error) {
+// APIs 返回已加载的API定义，按版本和名称排序
+func (this *APIGateWayImplement) APIs() []OpenAPI {
+	versions := make([]string, 0, len(this.apiSet))
+	for v := range this.apiSet {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+
+	ret := make([]OpenAPI, 0, len(versions))
+	for _, v := range versions {
+		o := OpenAPI{Version: v}
+		for _, a := range this.apiSet[v] {
+			o.APIs = append(o.APIs, *a)
+		}
+		sort.Slice(o.APIs, func(i, j int) bool { return o.APIs[i].Name < o.APIs[j].Name })
+		ret = append(ret, o)
+	}
+	return ret
+}
+
+func (this *APIGateWayImplement) RequestAPI(version string, api string, params htypes.Map) (htypes.Any, *herrors.Error) {
+	return this.RequestAPIContext(context.Background(), version, api, params)
+}
+
+// RequestAPIContext ctx取消时不再等待服务返回，服务可通过RequestContext(params)感知取消并中止处理
+func (this *APIGateWayImplement) RequestAPIContext(ctx context.Context, version string, api string, params htypes.Map) (ret htypes.Any, err *herrors.Error) {
+	if err := this.checkRemoved(version); err != nil {
+		return nil, err
+	}
 	a := this.apiSet[version]
 	if a == nil {
 		return nil, herrors.ErrCallerInvalidRequest.New("api version %s not supported", version)
@@ -162,6 +200,7 @@ func (this *APIGateWayImplement) RequestAPI(version string, api string, params h
 	if v.Disabled {
 		return nil, herrors.ErrCallerInvalidRequest.New("api %s disabled", api)
 	}
+	this.trackDeprecated(version, v)
 
 	for _, m := range this.middlewares {
 		if m.Type() == MiddlewareTypeIn || m.Type() == MiddlewareTypeInOut {
@@ -176,25 +215,10 @@ func (this *APIGateWayImplement) RequestAPI(version string, api string, params h
 	}
 
 	//加入熔断控制
-	if this.conf.UseBreaker {
-		cmd := this.cmdName(v.Name, params)
-
-		if hystrix.GetCircuitSettings()[cmd] == nil {
-			hystrix.ConfigureCommand(cmd, *this.breakCmdConfig)
-		}
-
-		breakerErr := hystrix.Do(cmd, func() error {
-			ret, err = this.server.RequestService(v.EndPoint.Service, v.EndPoint.Slot, params)
-			return nil
-		}, func(e error) error {
-			return herrors.ErrSysBusy.New(e.Error())
-		})
-
-		if breakerErr != nil {
-			return nil, herrors.ErrSysInternal.New(breakerErr.Error())
-		}
+	if this.breakerEnabled() {
+		ret, err = this.requestServiceWithBreaker(ctx, v, params)
 	} else {
-		ret, err = this.server.RequestService(v.EndPoint.Service, v.EndPoint.Slot, params)
+		ret, err = this.server.RequestServiceContext(ctx, v.EndPoint.Service, v.EndPoint.Slot, params)
 	}
 
 	for _, m := range this.middlewares {
@@ -244,12 +268,62 @@ func (this *APIGateWayImplement) EntityStub() *EntityStub {
 			Owner:       this,
 			Ping:        nil,
 			GetLoad:     nil,
-			ResetConfig: nil,
+			ResetConfig: this.resetConfig,
+		})
+}
+
+// resetConfig 重新加载配置，熔断设置变化时所有熔断器按新设置重建，统计数据清零
+func (this *APIGateWayImplement) resetConfig(ps htypes.Map) *herrors.Error {
+	var conf APIGateway
+	if err := hconf.Decode(ps, &conf); err != nil {
+		return herrors.ErrCallerInvalidRequest.New(err.Error()).D("invalid config")
+	}
+	if conf.DeprecationLogInterval <= 0 {
+		conf.DeprecationLogInterval = defaultDeprecationLogInterval
+	}
+
+	//请求处理中读取配置时持有breakerLock的读锁
+	this.breakerLock.Lock()
+	conf.EntityConfBase = this.conf.EntityConfBase
+	err := KeepRestartFields(&this.conf, &conf, "BreakerDashboard")
+	this.conf = conf
+	if this.conf.UseBreaker {
+		this.initBreaker()
+		this.breakerCmds.Range(func(cmd, key interface{}) bool {
+			hystrix.ConfigureCommand(cmd.(string), this.breakerConfig(key.(string)))
+			return true
 		})
+		//熔断器的并发池在创建时确定，需要重建
+		hystrix.Flush()
+	}
+	this.breakerLock.Unlock()
+
+	return err
+}
+
+// breakerEnabled 是否开启熔断，配置可能被resetConfig替换，需持有breakerLock读取
+func (this *APIGateWayImplement) breakerEnabled() bool {
+	this.breakerLock.RLock()
+	defer this.breakerLock.RUnlock()
+
+	return this.conf.UseBreaker
+}
+
+func (this *APIGateWayImplement) close() {
+	for _, c := range this.connectors {
+		c.Close()
+	}
+	for _, m := range this.middlewares {
+		m.Close()
+	}
+	for _, p := range this.packers {
+		p.Close()
+	}
 }
 
 func (this *APIGateWayImplement) loadAPIs() {
 	this.apiSet = make(map[string]map[string]*API)
+	this.removed = make(map[string]string)
 
 	file, err := this.server.Assets().File(apiFileName)
 	if err != nil {
@@ -262,6 +336,10 @@ func (this *APIGateWayImplement) loadAPIs() {
 	}
 
 	for _, openAPI := range apiDef.APIVersions {
+		if openAPI.Removed {
+			this.removed[openAPI.Version] = openAPI.Replacement
+			continue
+		}
 		if this.apiSet[openAPI.Version] == nil {
 			this.apiSet[openAPI.Version] = make(map[string]*API)
 		}
@@ -296,14 +374,18 @@ func (this *APIGateWayImplement) initBreaker() {
 		ErrorPercentThreshold:  this.conf.BreakerErrorPercentThreshold,
 	}
 
-	if this.conf.BreakerDashboard {
-		hystrixStreamHandler := hystrix.NewStreamHandler()
-		hystrixStreamHandler.Start()
+	hystrix.SetLogger(breakerLogger{})
+	registerBreakerCollector()
+
+	if this.conf.BreakerDashboard && this.breakerDashboard == nil {
+		this.breakerDashboard = hystrix.NewStreamHandler()
+		this.breakerDashboard.Start()
 	}
 
 	return
 }
 
+// cmdName 调用方需持有breakerLock
 func (this *APIGateWayImplement) cmdName(api string, data htypes.Map) string {
 	var ps []string
 	if this.conf.BreakerLimitAPI {
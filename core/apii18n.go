@@ -1,15 +1,18 @@
 package core
 
 import (
-	"fmt"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 
+	"github.com/fsnotify/fsnotify"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/pelletier/go-toml/v2"
 
 	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
 	"github.com/drharryhe/has/utils/hio"
 	"github.com/drharryhe/has/utils/hruntime"
 )
@@ -30,10 +33,16 @@ func (this *BaseAPIi18n) Open() bool {
 func (this *BaseAPIi18n) Close() {
 }
 
+// DefaultAPIi18n 从Dir目录加载翻译文件，文件名为语言名，如 cn-zh.json、en-us.toml，
+// 内容为 原文 -> 译文 的映射。目录中的文件变化时自动重新加载。
 type DefaultAPIi18n struct {
 	BaseAPIi18n
 
-	dirs map[string]map[string]string
+	Dir string //翻译文件目录，缺省为 LangDir
+
+	dirs    map[string]map[string]string
+	lock    sync.RWMutex
+	watcher *fsnotify.Watcher
 }
 
 func (this *DefaultAPIi18n) Class() string {
@@ -41,30 +50,107 @@ func (this *DefaultAPIi18n) Class() string {
 }
 
 func (this *DefaultAPIi18n) Open() *herrors.Error {
-	this.dirs = make(map[string]map[string]string)
+	if this.Dir == "" {
+		this.Dir = LangDir
+	}
+	this.class = hruntime.GetObjectName(this)
+
+	if err := this.load(); err != nil {
+		return err
+	}
+
+	this.watch()
+	return nil
+}
 
-	err := filepath.Walk(LangDir, func(p string, info os.FileInfo, err error) error {
+func (this *DefaultAPIi18n) Close() {
+	if this.watcher != nil {
+		_ = this.watcher.Close()
+	}
+}
+
+func (this *DefaultAPIi18n) Translate(lang string, text string) string {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	if this.dirs[lang] != nil {
+		t := this.dirs[lang][text]
+		if t == "" {
+			return text
+		} else {
+			return t
+		}
+	}
+	return text
+}
+
+// TranslateFields 按langs的顺序查找每个字段错误的消息ID的译文，代入参数后作为字段的Message，
+// 找不到译文时保留原Message。err带字段错误时返回副本，不修改err
+func TranslateFields(trans IAPIi18n, err *herrors.Error, langs ...string) *herrors.Error {
+	if trans == nil || err == nil || len(err.Fields) == 0 {
+		return err
+	}
+
+	ret := *err
+	ret.Fields = make([]herrors.FieldError, len(err.Fields))
+	for i, f := range err.Fields {
+		for _, lang := range langs {
+			if t := trans.Translate(lang, f.ID); t != f.ID {
+				f.Message = f.Format(t)
+				break
+			}
+		}
+		ret.Fields[i] = f
+	}
+	return &ret
+}
+
+func (this *DefaultAPIi18n) load() *herrors.Error {
+	dirs := make(map[string]map[string]string)
+
+	if _, err := os.Stat(this.Dir); os.IsNotExist(err) {
+		this.lock.Lock()
+		this.dirs = dirs
+		this.lock.Unlock()
+		return nil
+	}
+
+	err := filepath.Walk(this.Dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
 		if info.IsDir() {
 			return nil
 		}
-		ext := path.Ext(info.Name())
-		if strings.ToLower(ext) != ".json" {
+
+		ext := strings.ToLower(path.Ext(info.Name()))
+		if ext != ".json" && ext != ".toml" {
 			return nil
 		}
 
-		ss := strings.Split(info.Name(), ".")
-		v := make(map[string]string)
-		bs, err := hio.ReadFile(fmt.Sprintf("%s%c%s", LangDir, os.PathSeparator, info.Name()))
+		bs, err := hio.ReadFile(p)
 		if err != nil {
 			return herrors.ErrSysInternal.New(err.Error())
 		}
 
-		err = jsoniter.Unmarshal(bs, &v)
+		v := make(map[string]string)
+		if ext == ".json" {
+			err = jsoniter.Unmarshal(bs, &v)
+		} else {
+			err = toml.Unmarshal(bs, &v)
+		}
 		if err != nil {
-			return herrors.ErrSysInternal.New(err.Error())
+			return herrors.ErrSysInternal.New("failed to parse %s: %s", p, err.Error())
 		}
 
-		this.dirs[ss[0]] = v
+		lang := strings.TrimSuffix(info.Name(), path.Ext(info.Name()))
+		if dirs[lang] == nil {
+			dirs[lang] = v
+		} else {
+			for k, t := range v {
+				dirs[lang][k] = t
+			}
+		}
 
 		return nil
 	})
@@ -73,18 +159,44 @@ func (this *DefaultAPIi18n) Open() *herrors.Error {
 		return herrors.ErrSysInternal.New(err.Error())
 	}
 
-	this.class = hruntime.GetObjectName(this)
+	this.lock.Lock()
+	this.dirs = dirs
+	this.lock.Unlock()
 	return nil
 }
 
-func (this *DefaultAPIi18n) Translate(lang string, text string) string {
-	if this.dirs[lang] != nil {
-		t := this.dirs[lang][text]
-		if t == "" {
-			return text
-		} else {
-			return t
-		}
+func (this *DefaultAPIi18n) watch() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		hlogger.Warn("i18n hot reload disabled: %s", err.Error())
+		return
 	}
-	return text
+	if err = watcher.Add(this.Dir); err != nil {
+		_ = watcher.Close()
+		hlogger.Warn("i18n hot reload disabled: %s", err.Error())
+		return
+	}
+	this.watcher = watcher
+
+	go func() {
+		for {
+			select {
+			case e, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if e.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+					continue
+				}
+				if err := this.load(); err != nil {
+					hlogger.Error(err.D("failed to reload i18n files"))
+				}
+			case e, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				hlogger.Warn("i18n watcher: %s", e.Error())
+			}
+		}
+	}()
 }
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"strings"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+// IAuthorizer 授权策略，在router分发服务请求前调用，返回错误时拒绝请求
+type IAuthorizer interface {
+	Authorize(service string, slot *Slot, params htypes.Map) *herrors.Error
+}
+
+// RolesResolver 返回调用主体的角色和权限，未认证时返回 nil, nil, nil。
+// 可以从参数中的认证信息解析，也可以请求认证服务或外部存储
+type RolesResolver func(params htypes.Map) (roles []string, perms []string, err *herrors.Error)
+
+func NewRoleAuthorizer(resolver RolesResolver) *RoleAuthorizer {
+	return &RoleAuthorizer{resolver: resolver}
+}
+
+// RoleAuthorizer 按slot的Roles和Permissions授权：具有Roles中的任一角色，并且具有Permissions中的所有权限
+type RoleAuthorizer struct {
+	resolver RolesResolver
+}
+
+func (this *RoleAuthorizer) Authorize(service string, slot *Slot, params htypes.Map) *herrors.Error {
+	if slot == nil || (len(slot.Roles) == 0 && len(slot.Permissions) == 0) {
+		return nil
+	}
+
+	roles, perms, err := this.resolver(params)
+	if err != nil {
+		return err
+	}
+	if roles == nil && perms == nil {
+		return herrors.ErrCallerUnauthorizedAccess.New("slot %s/%s requires authentication", service, slot.Name).D("unauthorized access")
+	}
+
+	if len(slot.Roles) > 0 && !containsAny(roles, slot.Roles) {
+		return herrors.ErrCallerUnauthorizedAccess.New("slot %s/%s requires one of roles %s", service, slot.Name, strings.Join(slot.Roles, ",")).D("unauthorized access")
+	}
+	for _, p := range slot.Permissions {
+		if !containsAny(perms, []string{p}) {
+			return herrors.ErrCallerUnauthorizedAccess.New("slot %s/%s requires permission %s", service, slot.Name, p).D("unauthorized access")
+		}
+	}
+	return nil
+}
+
+// ClaimsRolesResolver 从请求作用域中的JWT claims读取角色和权限，claims由connector校验JWT后写入，
+// 调用方在参数中提交的claims不被使用。claim的值可以是字符串数组或逗号分隔的字符串
+func ClaimsRolesResolver(rolesClaim string, permsClaim string) RolesResolver {
+	return func(params htypes.Map) ([]string, []string, *herrors.Error) {
+		var claims map[string]interface{}
+		val, _ := Scoped(params, ScopeClaims)
+		switch v := val.(type) {
+		case htypes.Map:
+			claims = v
+		case map[string]interface{}:
+			//经过打包传输的作用域中claims的类型
+			claims = v
+		default:
+			return nil, nil, nil
+		}
+
+		roles := claimStrings(claims[rolesClaim])
+		perms := claimStrings(claims[permsClaim])
+		if roles == nil {
+			roles = []string{}
+		}
+		return roles, perms, nil
+	}
+}
+
+func claimStrings(v interface{}) []string {
+	var ret []string
+	switch val := v.(type) {
+	case string:
+		for _, s := range strings.Split(val, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				ret = append(ret, s)
+			}
+		}
+	case []string:
+		ret = val
+	case []interface{}:
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				ret = append(ret, s)
+			}
+		}
+	}
+	return ret
+}
+
+func containsAny(have []string, want []string) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+func TestRoleAuthorizer(t *testing.T) {
+	authz := NewRoleAuthorizer(ClaimsRolesResolver("roles", "perms"))
+	slot := &Slot{Name: "DelUser", Roles: []string{"admin", "ops"}, Permissions: []string{"user:delete"}}
+
+	cases := []struct {
+		params htypes.Map
+		ok     bool
+	}{
+		{htypes.Map{}, false},
+		{scopedClaims(htypes.Map{"roles": []interface{}{"guest"}, "perms": "user:delete"}), false},
+		{scopedClaims(htypes.Map{"roles": []interface{}{"ops"}}), false},
+		{scopedClaims(htypes.Map{"roles": "guest, ops", "perms": []interface{}{"user:delete"}}), true},
+		{scopedClaims(map[string]interface{}{"roles": []interface{}{"ops"}, "perms": "user:delete"}), true},
+		//调用方在参数中提交的claims不授予角色
+		{htypes.Map{"JwtClaims": htypes.Map{"roles": []interface{}{"admin"}, "perms": "user:delete"}}, false},
+		{htypes.Map{"JwtClaims": map[string]interface{}{"roles": []interface{}{"admin"}, "perms": "user:delete"}}, false},
+	}
+	for i, c := range cases {
+		err := authz.Authorize("ap", slot, c.params)
+		if (err == nil) != c.ok {
+			t.Errorf("case %d: Authorize = %v, want ok %v", i, err, c.ok)
+		}
+		if err != nil && err.Code != herrors.ECodeCallerUnauthorizedAccess {
+			t.Errorf("case %d: error code = %d", i, err.Code)
+		}
+	}
+
+	if err := authz.Authorize("ap", &Slot{Name: "Login"}, htypes.Map{}); err != nil {
+		t.Errorf("slot without roles should not be restricted: %v", err)
+	}
+}
+
+func scopedClaims(claims htypes.Any) htypes.Map {
+	ps := htypes.Map{}
+	SetScoped(ps, ScopeClaims, claims)
+	return ps
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"context"
+
+	"github.com/afex/hystrix-go/hystrix"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+// Breaker 按 service 或 service/slot 覆盖的熔断设置，未设置(<=0)的项沿用APIGateway的全局设置
+type Breaker struct {
+	RequestTimeout         int //ms
+	MaxConcurrentRequest   int
+	RequestVolumeThreshold int
+	SleepWindow            int //ms
+	ErrorPercentThreshold  int
+}
+
+// breakerLogger 将熔断器状态变化写入hlogger：
+// 打开时输出 "opening circuit <cmd>"，SleepWindow 过后放行一个试探请求(half-open)时输出
+// "allowing single test to possibly close circuit <cmd>"，试探成功关闭时输出 "closing circuit <cmd>"
+type breakerLogger struct{}
+
+func (breakerLogger) Printf(format string, items ...interface{}) {
+	hlogger.Warn(format, items...)
+	trackBreakerLog(format, items)
+}
+
+// breakerKey 按 service/slot、service 的顺序查找单独的熔断设置，返回配置键，为空表示使用全局设置
+func (this *APIGateWayImplement) breakerKey(service string, slot string) string {
+	if _, ok := this.conf.Breakers[service+"/"+slot]; ok {
+		return service + "/" + slot
+	}
+	if _, ok := this.conf.Breakers[service]; ok {
+		return service
+	}
+	return ""
+}
+
+// breakerConfig 返回配置键对应的熔断设置，未设置的项沿用全局设置
+func (this *APIGateWayImplement) breakerConfig(key string) hystrix.CommandConfig {
+	cfg := *this.breakCmdConfig
+
+	b, ok := this.conf.Breakers[key]
+	if !ok {
+		return cfg
+	}
+	if b.RequestTimeout > 0 {
+		cfg.Timeout = b.RequestTimeout
+	}
+	if b.MaxConcurrentRequest > 0 {
+		cfg.MaxConcurrentRequests = b.MaxConcurrentRequest
+	}
+	if b.RequestVolumeThreshold > 0 {
+		cfg.RequestVolumeThreshold = b.RequestVolumeThreshold
+	}
+	if b.SleepWindow > 0 {
+		cfg.SleepWindow = b.SleepWindow
+	}
+	if b.ErrorPercentThreshold > 0 {
+		cfg.ErrorPercentThreshold = b.ErrorPercentThreshold
+	}
+	return cfg
+}
+
+// requestServiceWithBreaker 在熔断控制下调用服务。服务返回的系统错误计入熔断统计；
+// 熔断器打开时直接返回ErrSysUnavailable
+func (this *APIGateWayImplement) requestServiceWithBreaker(ctx context.Context, api *API, params htypes.Map) (htypes.Any, *herrors.Error) {
+	this.breakerLock.RLock()
+	key := this.breakerKey(api.EndPoint.Service, api.EndPoint.Slot)
+	cmd := this.cmdName(api.Name, params)
+	if key != "" {
+		//不同设置的service/slot使用各自的熔断命令
+		cmd = key + "_" + cmd
+	}
+
+	if _, ok := this.breakerCmds.Load(cmd); !ok {
+		hystrix.ConfigureCommand(cmd, this.breakerConfig(key))
+		this.breakerCmds.Store(cmd, key)
+	}
+	this.breakerLock.RUnlock()
+
+	if breakerForced(cmd) {
+		return nil, herrors.ErrSysUnavailable.New("circuit %s tripped manually", cmd).D("service unavailable")
+	}
+
+	//超时后服务调用仍在进行，结果只在run返回后读取
+	var (
+		ret htypes.Any
+		err *herrors.Error
+	)
+	breakerErr := hystrix.Do(cmd, func() error {
+		ret, err = this.server.RequestServiceContext(ctx, api.EndPoint.Service, api.EndPoint.Slot, params)
+		if err != nil && err.Code > 100 && err.Code < 200 {
+			return err
+		}
+		return nil
+	}, nil)
+
+	switch breakerErr {
+	case nil:
+		return ret, err
+	case hystrix.ErrCircuitOpen:
+		return nil, herrors.ErrSysUnavailable.New("circuit %s open", cmd).D("service unavailable")
+	case hystrix.ErrMaxConcurrency:
+		return nil, herrors.ErrSysBusy.New("circuit %s max concurrency", cmd).D("server busy")
+	case hystrix.ErrTimeout:
+		return nil, herrors.ErrSysTimeout.New("circuit %s timeout", cmd).D("request timeout")
+	}
+
+	if e, ok := breakerErr.(*herrors.Error); ok {
+		return ret, e
+	}
+	return nil, herrors.ErrSysInternal.New(breakerErr.Error())
+}
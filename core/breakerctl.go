@@ -0,0 +1,233 @@
+package core
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/afex/hystrix-go/hystrix"
+	metricCollector "github.com/afex/hystrix-go/hystrix/metric_collector"
+	"github.com/afex/hystrix-go/hystrix/rolling"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+)
+
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open" //SleepWindow过后放行试探请求
+)
+
+// BreakerState 熔断器的状态和最近10秒的调用统计
+type BreakerState struct {
+	Name          string    `json:"name"`                 //熔断命令
+	Config        string    `json:"config"`               //熔断设置的配置键 service 或 service/slot，为空表示全局设置
+	State         string    `json:"state"`                //closed, open, half-open
+	Forced        bool      `json:"forced"`               //手动打开，重置前不会自动关闭
+	Operator      string    `json:"operator,omitempty"`   //最近一次手动操作者
+	ChangedAt     time.Time `json:"changed_at,omitempty"` //最近一次状态变化的时间
+	Requests      int       `json:"requests"`
+	Errors        int       `json:"errors"` //失败、超时、并发超限和熔断拒绝的请求
+	ErrorPercent  int       `json:"error_percent"`
+	ShortCircuits int       `json:"short_circuits"` //熔断器打开时被拒绝的请求
+	Timeouts      int       `json:"timeouts"`
+	Rejects       int       `json:"rejects"` //并发超限被拒绝的请求
+}
+
+// breakerStatus 熔断器的状态，由breakerLogger按hystrix的状态变化日志更新，手动控制时直接更新
+type breakerStatus struct {
+	state    string
+	forced   bool
+	operator string
+	changed  time.Time
+}
+
+var (
+	breakerStatusLock sync.Mutex
+	breakerStatuses   = make(map[string]*breakerStatus)
+
+	breakerCollectors   sync.Map //熔断命令 -> *breakerCollector
+	breakerCollectorReg sync.Once
+)
+
+// setBreakerStatus 更新熔断器状态，operator为空表示自动变化
+func setBreakerStatus(name string, state string, forced bool, operator string) {
+	breakerStatusLock.Lock()
+	defer breakerStatusLock.Unlock()
+
+	s := breakerStatuses[name]
+	if s == nil {
+		s = &breakerStatus{}
+		breakerStatuses[name] = s
+	}
+	s.state, s.forced, s.changed = state, forced, time.Now()
+	if operator != "" {
+		s.operator = operator
+	}
+}
+
+func getBreakerStatus(name string) breakerStatus {
+	breakerStatusLock.Lock()
+	defer breakerStatusLock.Unlock()
+
+	if s := breakerStatuses[name]; s != nil {
+		return *s
+	}
+	return breakerStatus{state: BreakerClosed}
+}
+
+// trackBreakerLog 根据hystrix的日志跟踪状态变化，手动打开的熔断器保持打开
+func trackBreakerLog(format string, items []interface{}) {
+	if len(items) == 0 {
+		return
+	}
+	name, ok := items[0].(string)
+	if !ok {
+		return
+	}
+
+	state := ""
+	switch {
+	case strings.Contains(format, "opening circuit"):
+		state = BreakerOpen
+	case strings.Contains(format, "allowing single test"):
+		state = BreakerHalfOpen
+	case strings.Contains(format, "closing circuit"):
+		state = BreakerClosed
+	default:
+		return
+	}
+	if s := getBreakerStatus(name); !s.forced && s.state != state {
+		setBreakerStatus(name, state, false, "")
+	}
+}
+
+// breakerCollector 记录熔断器最近10秒的调用统计，hystrix关闭熔断器时清零
+type breakerCollector struct {
+	lock          sync.RWMutex
+	requests      *rolling.Number
+	errors        *rolling.Number
+	shortCircuits *rolling.Number
+	timeouts      *rolling.Number
+	rejects       *rolling.Number
+}
+
+func newBreakerCollector(name string) metricCollector.MetricCollector {
+	c := &breakerCollector{}
+	c.Reset()
+	breakerCollectors.Store(name, c)
+	return c
+}
+
+func (this *breakerCollector) Update(r metricCollector.MetricResult) {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	this.requests.Increment(r.Attempts)
+	this.errors.Increment(r.Errors)
+	this.shortCircuits.Increment(r.ShortCircuits)
+	this.timeouts.Increment(r.Timeouts)
+	this.rejects.Increment(r.Rejects)
+}
+
+func (this *breakerCollector) Reset() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.requests = rolling.NewNumber()
+	this.errors = rolling.NewNumber()
+	this.shortCircuits = rolling.NewNumber()
+	this.timeouts = rolling.NewNumber()
+	this.rejects = rolling.NewNumber()
+}
+
+func (this *breakerCollector) fill(s *BreakerState) {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	now := time.Now()
+	s.Requests = int(this.requests.Sum(now))
+	s.Errors = int(this.errors.Sum(now))
+	s.ShortCircuits = int(this.shortCircuits.Sum(now))
+	s.Timeouts = int(this.timeouts.Sum(now))
+	s.Rejects = int(this.rejects.Sum(now))
+	if s.Requests > 0 {
+		s.ErrorPercent = s.Errors * 100 / s.Requests
+	}
+}
+
+func registerBreakerCollector() {
+	breakerCollectorReg.Do(func() {
+		metricCollector.Registry.Register(newBreakerCollector)
+	})
+}
+
+// Breakers 已创建的熔断器的状态，按名称排序，未开启熔断时为空
+func (this *APIGateWayImplement) Breakers() []BreakerState {
+	ret := []BreakerState{}
+	this.breakerCmds.Range(func(k, v interface{}) bool {
+		name := k.(string)
+		status := getBreakerStatus(name)
+		s := BreakerState{
+			Name:      name,
+			Config:    v.(string),
+			State:     status.state,
+			Forced:    status.forced,
+			Operator:  status.operator,
+			ChangedAt: status.changed,
+		}
+		if c, ok := breakerCollectors.Load(name); ok {
+			c.(*breakerCollector).fill(&s)
+		}
+		ret = append(ret, s)
+		return true
+	})
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+// TripBreaker 手动打开熔断器，重置前该熔断器的请求直接返回ErrSysUnavailable
+func (this *APIGateWayImplement) TripBreaker(name string, operator string) *herrors.Error {
+	if err := this.checkBreaker(name); err != nil {
+		return err
+	}
+
+	setBreakerStatus(name, BreakerOpen, true, operator)
+	hlogger.Warn("circuit %s tripped manually by %s", name, operator)
+	return nil
+}
+
+// ResetBreaker 取消手动打开，并关闭自动打开的熔断器，统计随之清零
+func (this *APIGateWayImplement) ResetBreaker(name string, operator string) *herrors.Error {
+	if err := this.checkBreaker(name); err != nil {
+		return err
+	}
+
+	status := getBreakerStatus(name)
+	setBreakerStatus(name, BreakerClosed, false, operator)
+	if status.state != BreakerClosed {
+		//hystrix没有公开关闭熔断器的方法，打开的熔断器收到成功事件时关闭并清零统计
+		if c, _, err := hystrix.GetCircuit(name); err == nil {
+			_ = c.ReportEvent([]string{"success"}, time.Now(), 0)
+		}
+	}
+	hlogger.Warn("circuit %s reset manually by %s", name, operator)
+	return nil
+}
+
+func (this *APIGateWayImplement) checkBreaker(name string) *herrors.Error {
+	if !this.breakerEnabled() {
+		return herrors.ErrCallerInvalidRequest.New("breaker not enabled").D("breaker not enabled")
+	}
+	if _, ok := this.breakerCmds.Load(name); !ok {
+		return herrors.ErrCallerInvalidRequest.New("breaker %s not found", name).D("breaker not found")
+	}
+	return nil
+}
+
+// breakerForced 熔断器是否被手动打开
+func breakerForced(name string) bool {
+	return getBreakerStatus(name).forced
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+func TestTripBreaker(t *testing.T) {
+	gw := &APIGateWayImplement{}
+	gw.conf.UseBreaker = true
+	gw.breakerCmds.Store("demo/Echo", "demo")
+
+	if err := gw.TripBreaker("demo/List", "admin"); err == nil || err.Code != herrors.ECodeCallerInvalidRequest {
+		t.Fatalf("unknown breaker: err = %v", err)
+	}
+
+	if err := gw.TripBreaker("demo/Echo", "admin@127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if !breakerForced("demo/Echo") {
+		t.Fatal("breaker should be forced open")
+	}
+	bs := gw.Breakers()
+	if len(bs) != 1 || bs[0].State != BreakerOpen || !bs[0].Forced || bs[0].Operator != "admin@127.0.0.1" || bs[0].Config != "demo" {
+		t.Fatalf("breakers = %+v", bs)
+	}
+
+	//手动打开的熔断器不随hystrix的状态变化关闭
+	trackBreakerLog("hystrix-go: closing circuit %v", []interface{}{"demo/Echo"})
+	if s := gw.Breakers()[0]; s.State != BreakerOpen {
+		t.Fatalf("state = %s, want open", s.State)
+	}
+
+	if err := gw.ResetBreaker("demo/Echo", "ops"); err != nil {
+		t.Fatal(err)
+	}
+	if s := gw.Breakers()[0]; s.State != BreakerClosed || s.Forced || s.Operator != "ops" {
+		t.Fatalf("breaker = %+v", s)
+	}
+
+	trackBreakerLog("hystrix-go: opening circuit %v", []interface{}{"demo/Echo"})
+	if s := gw.Breakers()[0]; s.State != BreakerOpen || s.Forced {
+		t.Fatalf("breaker = %+v", s)
+	}
+	_ = gw.ResetBreaker("demo/Echo", "ops")
+
+	gw.conf.UseBreaker = false
+	if err := gw.TripBreaker("demo/Echo", "ops"); err == nil {
+		t.Fatal("trip should fail when breaker disabled")
+	}
+}
+
+func TestResetConfigWhileChecking(t *testing.T) {
+	gw := &APIGateWayImplement{}
+	gw.conf.UseBreaker = false
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = gw.resetConfig(htypes.Map{"UseBreaker": false, "DeprecationLogInterval": i})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if err := gw.checkBreaker("demo/Echo"); err == nil {
+			t.Fatal("breaker should be disabled")
+		}
+	}
+	<-done
+
+	if gw.conf.DeprecationLogInterval != 99 {
+		t.Errorf("DeprecationLogInterval = %d", gw.conf.DeprecationLogInterval)
+	}
+	_ = gw.resetConfig(htypes.Map{"UseBreaker": false})
+	if gw.conf.DeprecationLogInterval != defaultDeprecationLogInterval {
+		t.Errorf("DeprecationLogInterval = %d, want default", gw.conf.DeprecationLogInterval)
+	}
+}
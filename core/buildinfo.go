@@ -0,0 +1,42 @@
+package core
+
+import (
+	"runtime"
+	"time"
+)
+
+// 构建信息，在链接时注入，如
+//
+//	go build -ldflags "-X github.com/drharryhe/has/core.Version=v1.2.0 -X github.com/drharryhe/has/core.GitCommit=$(git rev-parse --short HEAD) -X github.com/drharryhe/has/core.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version   string //版本号或构建tag
+	GitCommit string
+	BuildTime string
+)
+
+// BuildInfo 服务器的构建信息和运行时长，用于确认运行中的版本
+type BuildInfo struct {
+	Version    string    `json:"version"`
+	GitCommit  string    `json:"git_commit"`
+	BuildTime  string    `json:"build_time"`
+	GoVersion  string    `json:"go_version"`
+	GOMAXPROCS int       `json:"gomaxprocs"`
+	StartTime  time.Time `json:"start_time"`
+	Uptime     int64     `json:"uptime"` //seconds, 未启动时为0
+}
+
+// NewBuildInfo 以链接时注入的变量和当前运行时构造BuildInfo，start为零表示服务器未启动
+func NewBuildInfo(start time.Time) *BuildInfo {
+	ret := &BuildInfo{
+		Version:    Version,
+		GitCommit:  GitCommit,
+		BuildTime:  BuildTime,
+		GoVersion:  runtime.Version(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		StartTime:  start,
+	}
+	if !start.IsZero() {
+		ret.Uptime = int64(time.Since(start).Seconds())
+	}
+	return ret
+}
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"github.com/drharryhe/has/common/herrors"
+)
+
+// Bulkhead 限制service或slot同时处理的请求数，避免耗时的slot(如生成报表)占用全部资源，与熔断互补
+type Bulkhead struct {
+	MaxConcurrent int //同时处理的请求数上限，<=0表示不限制
+	MaxWait       int //ms, 达到上限时等待空位的时长，0表示直接拒绝
+	MaxQueue      int //最多同时等待的请求数，超过时直接拒绝，0表示不限制
+}
+
+// BulkheadLoad 隔离舱的使用情况，用于调整MaxConcurrent和MaxQueue
+type BulkheadLoad struct {
+	Limit    int    `json:"limit"`
+	Active   int    `json:"active"`   //处理中的请求数
+	Waiting  int64  `json:"waiting"`  //等待空位的请求数
+	Rejected uint64 `json:"rejected"` //配置生效以来拒绝的请求数
+}
+
+type bulkhead struct {
+	conf     Bulkhead
+	sem      chan struct{}
+	waiting  atomic.Int64
+	rejected atomic.Uint64
+}
+
+// bulkhead 按 service/slot、service 的顺序查找隔离舱，按service设置时该服务的所有slot共用一个隔离舱。
+// 配置变化后使用新的隔离舱，处理中的请求在原隔离舱中释放
+func (this *ServerImplement) bulkhead(settings *Server, service string, slot string) *bulkhead {
+	name := service + "/" + slot
+	conf, ok := settings.Bulkheads[name]
+	if !ok {
+		name = service
+		conf, ok = settings.Bulkheads[service]
+	}
+	if !ok || conf.MaxConcurrent <= 0 {
+		return nil
+	}
+
+	this.bulkheadLock.Lock()
+	defer this.bulkheadLock.Unlock()
+	b := this.bulkheads[name]
+	if b == nil || b.conf != conf {
+		if this.bulkheads == nil {
+			this.bulkheads = make(map[string]*bulkhead)
+		}
+		b = &bulkhead{conf: conf, sem: make(chan struct{}, conf.MaxConcurrent)}
+		this.bulkheads[name] = b
+	}
+	return b
+}
+
+// acquire 取得一个空位，达到上限时最多等待MaxWait，无法取得时返回ErrSysUnavailable
+func (this *bulkhead) acquire(ctx context.Context, service string, slot string) *herrors.Error {
+	select {
+	case this.sem <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if this.conf.MaxWait <= 0 {
+		return this.reject(service, slot)
+	}
+	if n := this.waiting.Inc(); this.conf.MaxQueue > 0 && n > int64(this.conf.MaxQueue) {
+		this.waiting.Dec()
+		return this.reject(service, slot)
+	}
+	defer this.waiting.Dec()
+
+	timer := time.NewTimer(time.Duration(this.conf.MaxWait) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case this.sem <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return this.reject(service, slot)
+	case <-ctx.Done():
+		return contextError(ctx, service, slot)
+	}
+}
+
+func (this *bulkhead) release() {
+	<-this.sem
+}
+
+func (this *bulkhead) reject(service string, slot string) *herrors.Error {
+	this.rejected.Inc()
+	return herrors.ErrSysUnavailable.New("service %s slot %s: %d requests in progress, bulkhead full", service, slot, len(this.sem)).D("service busy")
+}
+
+// bulkheadLoads 当前配置的隔离舱的使用情况
+func (this *ServerImplement) bulkheadLoads() map[string]BulkheadLoad {
+	this.bulkheadLock.Lock()
+	defer this.bulkheadLock.Unlock()
+
+	conf := this.currentConfig()
+	var ret map[string]BulkheadLoad
+	for name, b := range this.bulkheads {
+		if conf.Bulkheads[name] != b.conf {
+			continue
+		}
+		if ret == nil {
+			ret = make(map[string]BulkheadLoad)
+		}
+		ret[name] = BulkheadLoad{
+			Limit:    b.conf.MaxConcurrent,
+			Active:   len(b.sem),
+			Waiting:  b.waiting.Load(),
+			Rejected: b.rejected.Load(),
+		}
+	}
+	return ret
+}
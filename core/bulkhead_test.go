@@ -0,0 +1,77 @@
+package core
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+func TestBulkhead(t *testing.T) {
+	router := &flightRouter{release: make(chan struct{})}
+	s := &ServerImplement{router: router}
+	s.conf.Bulkheads = map[string]Bulkhead{
+		"report":          {MaxConcurrent: 1, MaxWait: 1000, MaxQueue: 1},
+		"report/Generate": {MaxConcurrent: 2},
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]*herrors.Error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = s.RequestService("report", "Generate", htypes.Map{})
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	//slot的设置优先于service，不等待直接拒绝超过上限的请求
+	l := s.bulkheadLoads()["report/Generate"]
+	if l.Active != 2 || l.Rejected != 2 {
+		t.Errorf("load = %+v, want 2 active and 2 rejected", l)
+	}
+
+	//service的其他slot共用一个空位，等待队列满时拒绝
+	var queued *herrors.Error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _ = s.RequestService("report", "A", htypes.Map{})
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, queued = s.RequestService("report", "B", htypes.Map{})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if _, err := s.RequestService("report", "C", htypes.Map{}); err == nil || err.Code != herrors.ECodeSysUnavailable {
+		t.Errorf("queue full: err = %v, want ErrSysUnavailable", err)
+	}
+	if l := s.bulkheadLoads()["report"]; l.Active != 1 || l.Waiting != 1 {
+		t.Errorf("load = %+v, want 1 active and 1 waiting", l)
+	}
+
+	close(router.release)
+	wg.Wait()
+	if queued != nil {
+		t.Errorf("queued request failed: %v", queued)
+	}
+	n := 0
+	for _, err := range errs {
+		if err != nil {
+			n++
+			if err.Code != herrors.ECodeSysUnavailable {
+				t.Errorf("err = %v, want ErrSysUnavailable", err)
+			}
+		}
+	}
+	if n != 2 {
+		t.Errorf("%d requests rejected, want 2", n)
+	}
+	if l := s.bulkheadLoads()["report"]; l.Active != 0 || l.Waiting != 0 {
+		t.Errorf("load after release = %+v", l)
+	}
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"context"
+	"sync"
+
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/utils/hrandom"
+)
+
+type boundContext struct {
+	ctx  context.Context
+	refs int
+}
+
+var (
+	boundLock     sync.Mutex
+	boundContexts = make(map[string]*boundContext) //登记标识 -> 本地调用中的ctx
+)
+
+// bindContext 服务执行期间登记调用方的ctx，服务通过RequestContext(params)取得，调用方取消时随之取消。
+// 登记标识为随机生成的内部值，写入参数的请求作用域，不使用调用方可以指定的请求ID，避免不同请求共用ctx。
+// 沿用同一参数的嵌套调用(服务内再调用服务)共用最先登记的ctx，返回的函数在调用结束时解除登记
+func bindContext(params htypes.Map, ctx context.Context) func() {
+	if params == nil || ctx.Done() == nil {
+		return func() {}
+	}
+
+	boundLock.Lock()
+	token := ScopedString(params, ScopeContext)
+	b := boundContexts[token]
+	if token == "" || b == nil {
+		token = hrandom.UuidWithoutDash()
+		b = &boundContext{ctx: ctx}
+		boundContexts[token] = b
+		SetScoped(params, ScopeContext, token)
+	}
+	b.refs++
+	boundLock.Unlock()
+
+	return func() {
+		boundLock.Lock()
+		if b.refs--; b.refs == 0 {
+			delete(boundContexts, token)
+		}
+		boundLock.Unlock()
+	}
+}
+
+// lookupContext 返回参数登记的ctx，没有时返回nil
+func lookupContext(params htypes.Map) context.Context {
+	token := ScopedString(params, ScopeContext)
+	if token == "" {
+		return nil
+	}
+
+	boundLock.Lock()
+	defer boundLock.Unlock()
+	if b := boundContexts[token]; b != nil {
+		return b.ctx
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"github.com/drharryhe/has/common/herrors"
+	hlogger "github.com/drharryhe/has/common/hlogger"
+)
+
+const (
+	defaultDeprecationLogInterval = 60 //seconds
+)
+
+// deprecatedUsage 废弃API自上次记录日志以来的调用次数
+type deprecatedUsage struct {
+	count   atomic.Int64
+	logTime atomic.Int64 //unix seconds
+}
+
+// API 返回版本中的API定义，不存在时返回nil
+func (this *APIGateWayImplement) API(version string, api string) *API {
+	return this.apiSet[version][api]
+}
+
+// checkRemoved 版本已下线时返回ErrCallerGone，错误信息中给出替代的版本
+func (this *APIGateWayImplement) checkRemoved(version string) *herrors.Error {
+	replacement, ok := this.removed[version]
+	if !ok {
+		return nil
+	}
+	if replacement == "" {
+		return herrors.ErrCallerGone.New("api version %s removed", version).D("api version removed")
+	}
+	return herrors.ErrCallerGone.New("api version %s removed, use %s instead", version, replacement).D("api version removed")
+}
+
+// trackDeprecated 统计废弃slot的调用次数，每个API每DeprecationLogInterval最多记录一条日志，用于跟踪调用方的迁移
+func (this *APIGateWayImplement) trackDeprecated(version string, a *API) {
+	slot := this.server.Slot(a.EndPoint.Service, a.EndPoint.Slot)
+	if slot == nil || !slot.Deprecated {
+		return
+	}
+
+	name := fmt.Sprintf("%s/%s", version, a.Name)
+	val, _ := this.deprecated.LoadOrStore(name, new(deprecatedUsage))
+	usage := val.(*deprecatedUsage)
+	usage.count.Inc()
+
+	now := time.Now().Unix()
+	last := usage.logTime.Load()
+	this.breakerLock.RLock()
+	interval := int64(this.conf.DeprecationLogInterval)
+	this.breakerLock.RUnlock()
+	if now-last < interval || !usage.logTime.CAS(last, now) {
+		return
+	}
+	msg := fmt.Sprintf("deprecated api %s called %d times", name, usage.count.Swap(0))
+	if slot.Sunset != "" {
+		msg += ", sunset " + slot.Sunset
+	}
+	if slot.Replacement != "" {
+		msg += ", replacement " + slot.Replacement
+	}
+	hlogger.Warn(msg)
+}
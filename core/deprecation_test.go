@@ -0,0 +1,46 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drharryhe/has/common/herrors"
+)
+
+func TestSlotSunset(t *testing.T) {
+	s := &Slot{Name: "list", Sunset: "2027-01-31"}
+	if err := compileSlot(s); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2027, 1, 31, 0, 0, 0, 0, time.UTC); !s.SunsetTime().Equal(want) {
+		t.Fatalf("sunset = %v, want %v", s.SunsetTime(), want)
+	}
+
+	s.Sunset = "2027-01-31T08:00:00+08:00"
+	if want := time.Date(2027, 1, 31, 0, 0, 0, 0, time.UTC); !s.SunsetTime().Equal(want) {
+		t.Fatalf("sunset = %v, want %v", s.SunsetTime(), want)
+	}
+
+	s.Sunset = "next year"
+	if err := compileSlot(s); err == nil {
+		t.Fatal("invalid sunset should fail")
+	}
+	if !s.SunsetTime().IsZero() {
+		t.Fatal("invalid sunset should be zero")
+	}
+}
+
+func TestCheckRemoved(t *testing.T) {
+	gw := &APIGateWayImplement{removed: map[string]string{"v0": "", "v1": "v2"}}
+	if err := gw.checkRemoved("v2"); err != nil {
+		t.Fatalf("v2: %v", err)
+	}
+	if err := gw.checkRemoved("v0"); err == nil || err.Code != herrors.ECodeCallerGone {
+		t.Fatalf("v0: err = %v", err)
+	}
+	err := gw.checkRemoved("v1")
+	if err == nil || err.Code != herrors.ECodeCallerGone || !strings.Contains(err.Error(), "use v2 instead") {
+		t.Fatalf("v1: err = %v", err)
+	}
+}
@@ -1,6 +1,9 @@
 package core
 
 import (
+	"reflect"
+	"strings"
+
 	"github.com/drharryhe/has/common/herrors"
 	"github.com/drharryhe/has/common/htypes"
 	"github.com/drharryhe/has/utils/hruntime"
@@ -125,6 +128,30 @@ func (this *EntityStub) ResetConfig(params htypes.Map) *herrors.Error {
 	return this.options.ResetConfig(params)
 }
 
+// KeepRestartFields 将conf中需要重启才能生效的字段恢复为old中的值，有变化时返回错误说明这些字段
+func KeepRestartFields(old IEntityConf, conf IEntityConf, fields ...string) *herrors.Error {
+	ov := reflect.ValueOf(old).Elem()
+	nv := reflect.ValueOf(conf).Elem()
+
+	var changed []string
+	for _, f := range fields {
+		o := ov.FieldByName(f)
+		n := nv.FieldByName(f)
+		if !o.IsValid() || !n.IsValid() {
+			continue
+		}
+		if !reflect.DeepEqual(o.Interface(), n.Interface()) {
+			changed = append(changed, f)
+			n.Set(o)
+		}
+	}
+
+	if len(changed) > 0 {
+		return herrors.ErrSysUnhandled.New("%s changed, restart required", strings.Join(changed, ", ")).D("restart required")
+	}
+	return nil
+}
+
 func CheckAndRegisterEntity(ins htypes.Any, router IRouter) *herrors.Error {
 	entity, ok := ins.(IEntity)
 	if !ok {
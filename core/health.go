@@ -0,0 +1,78 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EntityHealth 实体Ping的结果，Ping为nil的实体总是健康
+type EntityHealth struct {
+	EntityMeta
+
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+	Latency int64  `json:"latency"` //milliseconds
+}
+
+// Health 所有实体健康时Healthy为true
+type Health struct {
+	Healthy  bool            `json:"healthy"`
+	Entities []*EntityHealth `json:"entities"`
+}
+
+// CheckHealth 并发调用实体的Ping，超过timeout未返回的实体视为不健康，不等待其返回
+func CheckHealth(entities []IEntity, timeout time.Duration) *Health {
+	ret := &Health{Healthy: true, Entities: make([]*EntityHealth, len(entities))}
+	var wg sync.WaitGroup
+	for i, m := range entities {
+		wg.Add(1)
+		go func(i int, m IEntity) {
+			defer wg.Done()
+			ret.Entities[i] = pingEntity(m, timeout)
+		}(i, m)
+	}
+	wg.Wait()
+
+	for _, h := range ret.Entities {
+		if !h.Healthy {
+			ret.Healthy = false
+		}
+	}
+	sort.Slice(ret.Entities, func(i, j int) bool {
+		a, b := ret.Entities[i], ret.Entities[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Class < b.Class
+	})
+	return ret
+}
+
+func pingEntity(m IEntity, timeout time.Duration) *EntityHealth {
+	h := &EntityHealth{EntityMeta: *m.EntityMeta()}
+	start := time.Now()
+	done := make(chan *SlotResponse, 1)
+	go func() {
+		var res SlotResponse
+		m.EntityStub().Manage("Ping", nil, &res)
+		done <- &res
+	}()
+
+	select {
+	case res := <-done:
+		h.Latency = time.Since(start).Milliseconds()
+		if res.Error != nil {
+			h.Error = res.Error.Error()
+		} else if ok, isBool := res.Data.(bool); isBool && !ok {
+			h.Error = "ping failed"
+		} else {
+			h.Healthy = true
+		}
+	case <-time.After(timeout):
+		h.Latency = timeout.Milliseconds()
+		h.Error = fmt.Sprintf("ping timeout after %s", timeout)
+	}
+	return h
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+type testEntity struct {
+	class string
+	ping  EntityGetter
+}
+
+func (this *testEntity) Class() string {
+	return this.class
+}
+
+func (this *testEntity) Server() IServer {
+	return nil
+}
+
+func (this *testEntity) Config() IEntityConf {
+	return &EntityConfBase{}
+}
+
+func (this *testEntity) EntityMeta() *EntityMeta {
+	return &EntityMeta{EID: this.class, Type: EntityTypePlugin, Class: this.class}
+}
+
+func (this *testEntity) EntityStub() *EntityStub {
+	return NewEntityStub(&EntityStubOptions{Owner: this, Ping: this.ping})
+}
+
+func TestCheckHealth(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	entities := []IEntity{
+		&testEntity{class: "a"},
+		&testEntity{class: "b", ping: func(htypes.Map) (htypes.Any, *herrors.Error) {
+			return nil, herrors.ErrSysInternal.New("db down")
+		}},
+		&testEntity{class: "c", ping: func(htypes.Map) (htypes.Any, *herrors.Error) {
+			return false, nil
+		}},
+		&testEntity{class: "d", ping: func(htypes.Map) (htypes.Any, *herrors.Error) {
+			<-block
+			return true, nil
+		}},
+	}
+
+	start := time.Now()
+	h := CheckHealth(entities, 50*time.Millisecond)
+	if time.Since(start) > time.Second {
+		t.Error("stuck ping blocked the check")
+	}
+	if h.Healthy || len(h.Entities) != 4 {
+		t.Fatalf("health = %+v", h)
+	}
+	want := map[string]string{"a": "", "b": "db down", "c": "ping failed", "d": "ping timeout after 50ms"}
+	for _, e := range h.Entities {
+		if e.Healthy != (want[e.Class] == "") || e.Error != want[e.Class] {
+			t.Errorf("%s: %+v", e.Class, e)
+		}
+	}
+
+	if h = CheckHealth(entities[:1], time.Second); !h.Healthy {
+		t.Errorf("health = %+v, want healthy", h)
+	}
+}
@@ -0,0 +1,78 @@
+package htest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+// AssertParam 检查参数值，数值按float64比较，因此int字面量可以与JSON解析得到的float64相等
+func AssertParam(t testing.TB, ps htypes.Map, key string, want htypes.Any) {
+	t.Helper()
+
+	got, ok := ps[key]
+	if !ok {
+		t.Errorf("param [%s] not found, want %#v", key, want)
+		return
+	}
+	if !equal(got, want) {
+		t.Errorf("param [%s] = %#v, want %#v", key, got, want)
+	}
+}
+
+// AssertParams 检查want中的每个参数，ps中的其他参数不检查
+func AssertParams(t testing.TB, ps htypes.Map, want htypes.Map) {
+	t.Helper()
+
+	for k, v := range want {
+		AssertParam(t, ps, k, v)
+	}
+}
+
+// AssertNoParam 检查参数不存在
+func AssertNoParam(t testing.TB, ps htypes.Map, key string) {
+	t.Helper()
+
+	if v, ok := ps[key]; ok {
+		t.Errorf("param [%s] = %#v, want not present", key, v)
+	}
+}
+
+// AssertErrCode 检查错误码，code为herrors.ECodeOK时要求没有错误
+func AssertErrCode(t testing.TB, err *herrors.Error, code int) {
+	t.Helper()
+
+	if err == nil || err.Code == herrors.ECodeOK {
+		if code != herrors.ECodeOK {
+			t.Errorf("error = nil, want code %d", code)
+		}
+		return
+	}
+	if err.Code != code {
+		t.Errorf("error code = %d (%s), want %d", err.Code, err.Error(), code)
+	}
+}
+
+func equal(got htypes.Any, want htypes.Any) bool {
+	if g, ok := toFloat(got); ok {
+		if w, ok := toFloat(want); ok {
+			return g == w
+		}
+	}
+	return reflect.DeepEqual(got, want)
+}
+
+func toFloat(v htypes.Any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
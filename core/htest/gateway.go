@@ -0,0 +1,508 @@
+// Package htest 提供内存中的网关、服务器和路由，测试服务和连接器时不需要启动完整的服务器
+package htest
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/datapackers/hjsonpacker"
+)
+
+const (
+	DefaultPacker = "JsonPacker" //缺省注册的打包器名称，与hjsonpacker的配置节一致
+)
+
+// Handler 模拟slot的处理函数
+type Handler func(params htypes.Map) (htypes.Any, *herrors.Error)
+
+// Return 返回固定数据的Handler
+func Return(data htypes.Any) Handler {
+	return func(params htypes.Map) (htypes.Any, *herrors.Error) {
+		return data, nil
+	}
+}
+
+// Fail 返回固定错误的Handler
+func Fail(err *herrors.Error) Handler {
+	return func(params htypes.Map) (htypes.Any, *herrors.Error) {
+		return nil, err
+	}
+}
+
+// Call 一次API或服务调用的记录，通过RequestService直接调用时Version和API为空
+type Call struct {
+	Version string
+	API     string
+	Service string
+	Slot    string
+	Params  htypes.Map
+	Ctx     context.Context //RequestAPIContext传入的ctx，可用于检查连接器是否传递了取消
+}
+
+func NewGateway() *Gateway {
+	gw := &Gateway{
+		routes:  make(map[string]core.EndPoint),
+		packers: make(map[string]core.IAPIDataPacker),
+	}
+	gw.server = newServer()
+	gw.packers[DefaultPacker] = hjsonpacker.New()
+	return gw
+}
+
+// Gateway 实现core.IAPIGateway，API按Route设置映射到slot，由Handle注册的Handler处理
+type Gateway struct {
+	server  *Server
+	i18n    core.IAPIi18n
+	lock    sync.Mutex
+	routes  map[string]core.EndPoint //version/api -> slot
+	packers map[string]core.IAPIDataPacker
+	calls   []*Call
+}
+
+// Route 将API映射到service的slot
+func (this *Gateway) Route(version string, api string, service string, slot string) *Gateway {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.routes[version+"/"+api] = core.EndPoint{Service: service, Slot: slot}
+	return this
+}
+
+// Handle 注册slot的处理函数
+func (this *Gateway) Handle(service string, slot string, h Handler) *Gateway {
+	this.server.router.Handle(service, slot, h)
+	return this
+}
+
+// Define 设置slot的定义，如参数和返回数据的字段，供Server.Slot返回
+func (this *Gateway) Define(service string, slot core.Slot) *Gateway {
+	this.server.lock.Lock()
+	defer this.server.lock.Unlock()
+
+	this.server.slots[service+"/"+slot.Name] = &slot
+	return this
+}
+
+// SetPacker 注册打包器，连接器按配置的Packer名称获取
+func (this *Gateway) SetPacker(name string, packer core.IAPIDataPacker) *Gateway {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.packers[name] = packer
+	return this
+}
+
+// SetPlugin 注册插件，Server.Plugin按名称返回，插件不会被打开
+func (this *Gateway) SetPlugin(cls string, plugin core.IPlugin) *Gateway {
+	this.server.lock.Lock()
+	defer this.server.lock.Unlock()
+
+	this.server.plugins[cls] = plugin
+	return this
+}
+
+// SetHealth 设置Server.Health和Router.Health的结果，缺省为健康，Ready返回其中的Healthy
+func (this *Gateway) SetHealth(h *core.Health) *Gateway {
+	this.server.router.lock.Lock()
+	defer this.server.router.lock.Unlock()
+
+	this.server.router.health = h
+	return this
+}
+
+func (this *Gateway) SetI18n(i18n core.IAPIi18n) *Gateway {
+	this.i18n = i18n
+	return this
+}
+
+// Calls 返回所有RequestAPI调用的记录
+func (this *Gateway) Calls() []*Call {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return append([]*Call(nil), this.calls...)
+}
+
+// LastCall 返回最后一次RequestAPI调用的记录，没有调用时返回nil
+func (this *Gateway) LastCall() *Call {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if len(this.calls) == 0 {
+		return nil
+	}
+	return this.calls[len(this.calls)-1]
+}
+
+// Reset 清除调用记录，已注册的路由和Handler保留
+func (this *Gateway) Reset() {
+	this.lock.Lock()
+	this.calls = nil
+	this.lock.Unlock()
+
+	this.server.router.Reset()
+}
+
+func (this *Gateway) Start() {}
+
+func (this *Gateway) Shutdown() {}
+
+func (this *Gateway) Server() core.IServer {
+	return this.server
+}
+
+func (this *Gateway) Router() core.IRouter {
+	return this.server.router
+}
+
+func (this *Gateway) Packer(name string) core.IAPIDataPacker {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return this.packers[name]
+}
+
+func (this *Gateway) I18n() core.IAPIi18n {
+	return this.i18n
+}
+
+// APIs 返回Route设置的API，按版本和名称排序
+func (this *Gateway) APIs() []core.OpenAPI {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	keys := make([]string, 0, len(this.routes))
+	for k := range this.routes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var ret []core.OpenAPI
+	for _, k := range keys {
+		i := strings.Index(k, "/")
+		version, api := k[:i], k[i+1:]
+		if len(ret) == 0 || ret[len(ret)-1].Version != version {
+			ret = append(ret, core.OpenAPI{Version: version})
+		}
+		ret[len(ret)-1].APIs = append(ret[len(ret)-1].APIs, core.API{Name: api, EndPoint: this.routes[k]})
+	}
+	return ret
+}
+
+// API 返回Route设置的API
+func (this *Gateway) API(version string, api string) *core.API {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	ep, ok := this.routes[version+"/"+api]
+	if !ok {
+		return nil
+	}
+	return &core.API{Name: api, EndPoint: ep}
+}
+
+func (this *Gateway) RequestAPI(version string, api string, params htypes.Map) (htypes.Any, *herrors.Error) {
+	return this.RequestAPIContext(context.Background(), version, api, params)
+}
+
+func (this *Gateway) RequestAPIContext(ctx context.Context, version string, api string, params htypes.Map) (htypes.Any, *herrors.Error) {
+	this.lock.Lock()
+	ep, ok := this.routes[version+"/"+api]
+	this.calls = append(this.calls, &Call{Version: version, API: api, Service: ep.Service, Slot: ep.Slot, Params: params, Ctx: ctx})
+	this.lock.Unlock()
+
+	if !ok {
+		return nil, herrors.ErrCallerInvalidRequest.New("api %s/%s not supported", version, api)
+	}
+	return this.server.RequestServiceContext(ctx, ep.Service, ep.Slot, params)
+}
+
+func newServer() *Server {
+	s := &Server{
+		services: make(map[string]core.IService),
+		slots:    make(map[string]*core.Slot),
+		plugins:  make(map[string]core.IPlugin),
+		started:  time.Now(),
+	}
+	s.router = newRouter(s)
+	return s
+}
+
+// Server 实现core.IServer，服务请求直接交给内存中的Router
+type Server struct {
+	router   *Router
+	services map[string]core.IService
+	lock     sync.Mutex
+	slots    map[string]*core.Slot //Gateway.Define设置的slot，service/slot -> slot
+	plugins  map[string]core.IPlugin
+	started  time.Time
+}
+
+func (this *Server) Start() {}
+
+func (this *Server) Shutdown() {}
+
+func (this *Server) Ready() bool {
+	return this.Health().Healthy
+}
+
+func (this *Server) Health() *core.Health {
+	return this.router.Health(0)
+}
+
+func (this *Server) BuildInfo() *core.BuildInfo {
+	return core.NewBuildInfo(this.started)
+}
+
+func (this *Server) Router() core.IRouter {
+	return this.router
+}
+
+func (this *Server) Plugin(cls string) core.IPlugin {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return this.plugins[cls]
+}
+
+func (this *Server) Services() map[string]core.IService {
+	return this.services
+}
+
+func (this *Server) Slot(service string, slot string) *core.Slot {
+	this.lock.Lock()
+	s := this.slots[service+"/"+slot]
+	this.lock.Unlock()
+	if s != nil {
+		return s
+	}
+
+	if s := this.services[service]; s != nil {
+		return s.Slot(slot)
+	}
+	return nil
+}
+
+func (this *Server) Assets() core.IAssetManager {
+	return nil
+}
+
+// RegisterService 打开并注册真实的服务，未注册Handler的slot由服务处理
+func (this *Server) RegisterService(service core.IService, args ...htypes.Any) {
+	if err := this.AddService(service, args...); err != nil {
+		panic(err.D("failed to register service %s", service.Name()))
+	}
+}
+
+func (this *Server) AddService(service core.IService, args ...htypes.Any) *herrors.Error {
+	if err := service.Open(this, service, args...); err != nil {
+		return err
+	}
+	if err := this.router.RegisterService(service); err != nil {
+		service.Close()
+		return err
+	}
+	this.services[service.Name()] = service
+	return nil
+}
+
+func (this *Server) RemoveService(name string) *herrors.Error {
+	s := this.services[name]
+	if s == nil {
+		return herrors.ErrCallerInvalidRequest.New("service %s not found", name)
+	}
+	this.router.UnRegisterService(s)
+	delete(this.services, name)
+	s.Close()
+	return nil
+}
+
+func (this *Server) RequestService(service string, slot string, params htypes.Map) (htypes.Any, *herrors.Error) {
+	return this.router.RequestService(service, slot, params)
+}
+
+// RequestServiceContext ctx已取消时不调用服务，直接返回错误
+func (this *Server) RequestServiceContext(ctx context.Context, service string, slot string, params htypes.Map) (htypes.Any, *herrors.Error) {
+	if ctx.Err() != nil {
+		return nil, herrors.ErrCallerInvalidRequest.New("service %s slot %s canceled", service, slot).D("request canceled")
+	}
+	return this.router.RequestService(service, slot, params)
+}
+
+func newRouter(s *Server) *Router {
+	return &Router{
+		server:   s,
+		handlers: make(map[string]Handler),
+		services: make(map[string]core.IService),
+	}
+}
+
+// Router 实现core.IRouter，记录每次服务调用，优先使用注册的Handler
+type Router struct {
+	server   *Server
+	lock     sync.Mutex
+	handlers map[string]Handler //service/slot -> handler
+	services map[string]core.IService
+	calls    []*Call
+	authz    core.IAuthorizer
+	draining map[string]bool
+	health   *core.Health
+
+	middlewares core.SlotMiddlewares
+}
+
+func (this *Router) Handle(service string, slot string, h Handler) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.handlers[service+"/"+slot] = h
+}
+
+// Calls 返回指定slot的服务调用记录
+func (this *Router) Calls(service string, slot string) []*Call {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	var ret []*Call
+	for _, c := range this.calls {
+		if c.Service == service && c.Slot == slot {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// LastParams 返回指定slot最后一次调用的参数，没有调用时返回nil
+func (this *Router) LastParams(service string, slot string) htypes.Map {
+	calls := this.Calls(service, slot)
+	if len(calls) == 0 {
+		return nil
+	}
+	return calls[len(calls)-1].Params
+}
+
+func (this *Router) Reset() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.calls = nil
+}
+
+func (this *Router) Open(s core.IServer, ins core.IRouter) *herrors.Error {
+	return nil
+}
+
+func (this *Router) Close() {}
+
+func (this *Router) RegisterService(s core.IService) *herrors.Error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.services[s.Name()] != nil {
+		return herrors.ErrSysInternal.New("service name %s duplicated", s.Name())
+	}
+	this.services[s.Name()] = s
+	return nil
+}
+
+func (this *Router) UnRegisterService(s core.IService) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	delete(this.services, s.Name())
+}
+
+func (this *Router) DrainService(name string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.draining == nil {
+		this.draining = make(map[string]bool)
+	}
+	this.draining[name] = true
+}
+
+func (this *Router) RequestService(service string, slot string, params htypes.Map) (htypes.Any, *herrors.Error) {
+	this.lock.Lock()
+	this.calls = append(this.calls, &Call{Service: service, Slot: slot, Params: params})
+	h := this.handlers[service+"/"+slot]
+	s := this.services[service]
+	authz := this.authz
+	draining := this.draining[service]
+	this.lock.Unlock()
+
+	if draining {
+		return nil, herrors.ErrSysUnavailable.New("service %s draining", service)
+	}
+
+	if authz != nil {
+		var sl *core.Slot
+		if s != nil {
+			sl = s.Slot(slot)
+		}
+		if err := authz.Authorize(service, sl, params); err != nil {
+			return nil, err
+		}
+	}
+
+	if h == nil && s == nil {
+		return nil, herrors.ErrCallerInvalidRequest.New("service %s slot %s not found", service, slot)
+	}
+	sl := &core.Slot{Name: slot}
+	if s != nil && s.Slot(slot) != nil {
+		sl = s.Slot(slot)
+	}
+	return this.middlewares.Dispatch(service, sl, params, func(ps htypes.Map) (htypes.Any, *herrors.Error) {
+		if h != nil {
+			return h(ps)
+		}
+		return s.Request(slot, ps)
+	})
+}
+
+func (this *Router) Use(scope string, mws ...core.SlotMiddleware) {
+	this.middlewares.Use(scope, mws...)
+}
+
+func (this *Router) SetAuthorizer(a core.IAuthorizer) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.authz = a
+}
+
+func (this *Router) AllEntities() []*core.EntityMeta {
+	return nil
+}
+
+func (this *Router) RegisterEntity(m core.IEntity) *herrors.Error {
+	return nil
+}
+
+func (this *Router) UnRegisterEntity(m core.IEntity) {}
+
+func (this *Router) ManageEntity(mm *core.EntityMeta, slot string, params htypes.Map) (htypes.Any, *herrors.Error) {
+	return nil, herrors.ErrSysUnhandled.New("entity management not supported by htest router")
+}
+
+func (this *Router) ReloadEntityConfig(section string, params htypes.Map) {}
+
+func (this *Router) Loads() []*core.Load {
+	return nil
+}
+
+func (this *Router) Health(timeout time.Duration) *core.Health {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.health == nil {
+		return &core.Health{Healthy: true, Entities: []*core.EntityHealth{}}
+	}
+	return this.health
+}
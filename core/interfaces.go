@@ -1,6 +1,9 @@
 package core
 
 import (
+	"context"
+	"time"
+
 	"github.com/drharryhe/has/common/herrors"
 	"github.com/drharryhe/has/common/htypes"
 )
@@ -24,15 +27,22 @@ type IEntityConf interface {
 type IServer interface {
 	Start()
 	Shutdown()
+	Ready() bool     //启动完成、未开始关闭且所有实体健康
+	Health() *Health //本地实体的健康状况
 
 	Router() IRouter
 	Plugin(cls string) IPlugin
 	Services() map[string]IService
 	Slot(service string, slot string) *Slot
 	Assets() IAssetManager
+	BuildInfo() *BuildInfo
 
 	RegisterService(service IService, args ...htypes.Any)
+	AddService(service IService, args ...htypes.Any) *herrors.Error //运行时添加服务，失败时返回错误
+	RemoveService(name string) *herrors.Error                       //运行时移除并关闭服务
 	RequestService(service string, slot string, params htypes.Map) (htypes.Any, *herrors.Error)
+	//ctx取消时放弃等待，服务通过RequestContext(params)感知取消
+	RequestServiceContext(ctx context.Context, service string, slot string, params htypes.Map) (htypes.Any, *herrors.Error)
 }
 
 type IService interface {
@@ -59,11 +69,18 @@ type IRouter interface {
 	RegisterService(s IService) *herrors.Error                                                  //注册服务
 	UnRegisterService(s IService)                                                               //注销服务
 	RequestService(service string, slot string, params htypes.Map) (htypes.Any, *herrors.Error) //同步请求服务
+	SetAuthorizer(a IAuthorizer)                                                                //设置分发请求前的授权策略
+	Use(scope string, mws ...SlotMiddleware)                                                    //注册slot中间件，scope为空表示所有slot，或为 service、service/slot
+	DrainService(name string)                                                                   //停止向服务分发新请求，之后的请求返回ErrSysUnavailable
 
 	// 实体治理相关方法
 	AllEntities() []*EntityMeta
 	RegisterEntity(m IEntity) *herrors.Error
+	UnRegisterEntity(m IEntity)
 	ManageEntity(mm *EntityMeta, slot string, params htypes.Map) (htypes.Any, *herrors.Error)
+	ReloadEntityConfig(section string, params htypes.Map) //配置文件变化时重新加载实体配置
+	Loads() []*Load                                       //本地实体的负载
+	Health(timeout time.Duration) *Health                 //并发调用本地实体的Ping，每个实体最多等待timeout
 }
 
 type IPlugin interface {
@@ -72,6 +89,17 @@ type IPlugin interface {
 	Capability() htypes.Any
 }
 
+// IServiceDrainer 需要在关闭前处理完进行中工作的服务实现。server关闭或移除服务时先停止分发新请求，
+// 再调用Drain，ctx在DrainTimeout后取消，之后调用Close
+type IServiceDrainer interface {
+	Drain(ctx context.Context) *herrors.Error
+}
+
+// IPluginStarter 需要在server启动后才开始工作的插件(如定时任务)实现，在Server.Start中调用
+type IPluginStarter interface {
+	Start()
+}
+
 type IAPIConnector interface {
 	Open(gw IAPIGateway, ins IAPIConnector) *herrors.Error
 	Close()
@@ -100,6 +128,10 @@ type IAPIGateway interface {
 	Packer(name string) IAPIDataPacker
 	I18n() IAPIi18n
 	RequestAPI(version string, api string, params htypes.Map) (htypes.Any, *herrors.Error)
+	//ctx通常随客户端连接断开而取消
+	RequestAPIContext(ctx context.Context, version string, api string, params htypes.Map) (htypes.Any, *herrors.Error)
+	APIs() []OpenAPI //已加载的API定义
+	API(version string, api string) *API
 }
 
 type IAPIi18n interface {
@@ -112,3 +144,10 @@ type IAssetManager interface {
 	Init() *herrors.Error
 	File(path string) ([]byte, *herrors.Error)
 }
+
+// IBreakerManager 查看和手动控制熔断器，APIGateWayImplement实现
+type IBreakerManager interface {
+	Breakers() []BreakerState
+	TripBreaker(name string, operator string) *herrors.Error
+	ResetBreaker(name string, operator string) *herrors.Error
+}
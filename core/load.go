@@ -0,0 +1,95 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+const (
+	loadWindow = 10 //seconds, 计算请求速率的时间窗口
+)
+
+// Load 实体的负载情况，由EntityStub的GetLoad返回，没有统计的项为0
+type Load struct {
+	EntityMeta
+
+	InFlight          int64             `json:"in_flight"`           //处理中的请求数
+	Requests          uint64            `json:"requests"`            //启动以来的请求数
+	RequestsPerSecond float64           `json:"requests_per_second"` //最近loadWindow秒的平均请求速率
+	Goroutines        int               `json:"goroutines,omitempty"`
+	MemoryAlloc       uint64            `json:"memory_alloc,omitempty"` //bytes, 堆上已分配的内存
+	MemorySys         uint64            `json:"memory_sys,omitempty"`   //bytes, 从系统获得的内存
+	Services          map[string]uint64 `json:"services,omitempty"`     //按服务统计的请求数
+
+	Bulkheads map[string]BulkheadLoad `json:"bulkheads,omitempty"` //按 service 或 service/slot 统计的隔离舱使用情况
+}
+
+// LoadCounter 统计处理中的请求数和请求速率，Begin和End需成对调用
+type LoadCounter struct {
+	inFlight atomic.Int64
+	total    atomic.Uint64
+	buckets  [loadWindow + 1]loadBucket //按秒计数，多出的一个是当前秒
+}
+
+type loadBucket struct {
+	sec atomic.Int64
+	n   atomic.Uint64
+}
+
+func (this *LoadCounter) Begin() {
+	this.inFlight.Inc()
+	this.total.Inc()
+
+	now := time.Now().Unix()
+	b := &this.buckets[now%int64(len(this.buckets))]
+	if old := b.sec.Load(); old != now && b.sec.CAS(old, now) {
+		b.n.Store(0)
+	}
+	b.n.Inc()
+}
+
+func (this *LoadCounter) End() {
+	this.inFlight.Dec()
+}
+
+// Load 返回处理中的请求数、请求总数和最近loadWindow秒(不含当前秒)的平均请求速率
+func (this *LoadCounter) Load() *Load {
+	now := time.Now().Unix()
+	var n uint64
+	for i := range this.buckets {
+		b := &this.buckets[i]
+		if sec := b.sec.Load(); sec < now && sec >= now-loadWindow {
+			n += b.n.Load()
+		}
+	}
+
+	return &Load{
+		InFlight:          this.inFlight.Load(),
+		Requests:          this.total.Load(),
+		RequestsPerSecond: float64(n) / loadWindow,
+	}
+}
+
+// serviceCounter 按服务统计请求数
+type serviceCounter struct {
+	counts sync.Map //service -> *atomic.Uint64
+}
+
+func (this *serviceCounter) inc(service string) {
+	c, ok := this.counts.Load(service)
+	if !ok {
+		c, _ = this.counts.LoadOrStore(service, atomic.NewUint64(0))
+	}
+	c.(*atomic.Uint64).Inc()
+}
+
+func (this *serviceCounter) snapshot() map[string]uint64 {
+	ret := make(map[string]uint64)
+	this.counts.Range(func(k, v interface{}) bool {
+		ret[k.(string)] = v.(*atomic.Uint64).Load()
+		return true
+	})
+	return ret
+}
@@ -0,0 +1,27 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadCounter(t *testing.T) {
+	var c LoadCounter
+	c.Begin()
+	c.Begin()
+	c.End()
+
+	l := c.Load()
+	if l.InFlight != 1 || l.Requests != 2 {
+		t.Errorf("load = %+v, want 1 in flight and 2 requests", l)
+	}
+	//模拟上一秒的请求
+	now := time.Now().Unix()
+	b := &c.buckets[(now-1)%int64(len(c.buckets))]
+	b.sec.Store(now - 1)
+	b.n.Store(20)
+	//Begin可能刚好跨过一秒，计入速率
+	if l = c.Load(); l.RequestsPerSecond < 2 || l.RequestsPerSecond > 2.2 {
+		t.Errorf("requests per second = %v, want 2", l.RequestsPerSecond)
+	}
+}
@@ -1,9 +1,15 @@
 package core
 
+import "github.com/drharryhe/has/common/hconf"
+
 type ServerOptions struct {
 	Router        IRouter
 	Plugins       []IPlugin
 	AssetsManager IAssetManager
+	Authorizer    IAuthorizer //slot授权策略，为空表示不检查
+
+	SlotMiddlewares []SlotMiddleware  //应用于所有slot的中间件，按顺序执行，限定服务或slot的中间件通过Router().Use注册
+	ConfigProviders []hconf.IProvider //配置文件之外的配置来源，如远程配置和密钥，按顺序覆盖配置文件
 }
 
 type APIGatewayOptions struct {
@@ -10,6 +10,14 @@ import (
 
 type PluginConf struct {
 	EntityConfBase
+
+	Degraded          bool //使用Reconnector的插件Open时连接失败不终止启动，以降级状态运行并在后台重连
+	ReconnectDelay    int  //ms, 首次重连前等待时间，之后每次加倍，缺省为 1000
+	ReconnectMaxDelay int  //ms, 重连等待时间上限，缺省为 30000
+}
+
+func (this *PluginConf) pluginConf() *PluginConf {
+	return this
 }
 
 type BasePlugin struct {
@@ -31,6 +39,24 @@ func (this *BasePlugin) Open(s IServer, ins IPlugin) *herrors.Error {
 func (this *BasePlugin) Close() {
 }
 
+// NewReconnector 按插件配置的Degraded、ReconnectDelay和ReconnectMaxDelay创建Reconnector并首次连接，
+// 需在BasePlugin.Open之后调用。返回的Reconnector可在插件关闭时Close，其Ping可用作插件的Ping
+func (this *BasePlugin) NewReconnector(connect func() *herrors.Error) (*Reconnector, *herrors.Error) {
+	conf := &PluginConf{}
+	if c, ok := this.instance.(IEntity).Config().(interface{ pluginConf() *PluginConf }); ok {
+		conf = c.pluginConf()
+	}
+
+	r := NewReconnector(this.class, RetryPolicy{
+		BaseDelay: conf.ReconnectDelay,
+		MaxDelay:  conf.ReconnectMaxDelay,
+	}, connect)
+	if err := r.Open(conf.Degraded); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 func (this *BasePlugin) Server() IServer {
 	return this.server
 }
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"github.com/drharryhe/has/common/herrors"
+	hlogger "github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+const (
+	defaultReconnectDelay    = 1000  //ms
+	defaultReconnectMaxDelay = 30000 //ms
+	defaultReconnectJitter   = 500   //ms
+)
+
+// Reconnector 有状态插件(如数据库、redis)的后端连接管理，连接失败或断开后在后台按指数退避加随机抖动重连
+type Reconnector struct {
+	name    string
+	policy  RetryPolicy
+	connect func() *herrors.Error
+
+	connected atomic.Bool
+	lock      sync.Mutex
+	err       *herrors.Error //最近一次连接失败的错误
+	trigger   chan struct{}
+	done      chan struct{}
+	loopOnce  sync.Once
+	closeOnce sync.Once
+}
+
+// NewReconnector connect建立或检查后端连接，policy的MaxAttempts被忽略，重连直到成功或Close
+func NewReconnector(name string, policy RetryPolicy, connect func() *herrors.Error) *Reconnector {
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = defaultReconnectDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = defaultReconnectMaxDelay
+	}
+	if policy.Jitter <= 0 {
+		policy.Jitter = defaultReconnectJitter
+	}
+	return &Reconnector{
+		name:    name,
+		policy:  policy,
+		connect: connect,
+		trigger: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// Open 首次连接，失败时degraded为true则以降级状态返回nil并在后台重连，否则返回连接错误
+func (this *Reconnector) Open(degraded bool) *herrors.Error {
+	err := this.connect()
+	if err == nil {
+		this.connected.Store(true)
+		return nil
+	}
+	if !degraded {
+		return err
+	}
+
+	hlogger.Warn("%s started degraded: %s", this.name, err.Error())
+	this.Disconnected(err)
+	return nil
+}
+
+// Disconnected 插件发现连接不可用时调用，在后台重连，重连期间再次调用不重复启动
+func (this *Reconnector) Disconnected(err *herrors.Error) {
+	this.lock.Lock()
+	this.err = err
+	this.lock.Unlock()
+	this.connected.Store(false)
+
+	this.loopOnce.Do(func() {
+		go this.loop()
+	})
+	select {
+	case this.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Connected 后端当前是否已连接
+func (this *Reconnector) Connected() bool {
+	return this.connected.Load()
+}
+
+// Err 未连接时返回最近一次连接失败的错误，已连接时返回nil
+func (this *Reconnector) Err() *herrors.Error {
+	if this.Connected() {
+		return nil
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.err == nil {
+		return herrors.ErrSysUnavailable.New("%s not connected", this.name).D("service unavailable")
+	}
+	return herrors.ErrSysUnavailable.New("%s not connected: %s", this.name, this.err.Error()).D("service unavailable")
+}
+
+// Ping 可直接用作EntityStubOptions.Ping，未连接时不健康，服务器的Ready随之为false
+func (this *Reconnector) Ping(params htypes.Map) (htypes.Any, *herrors.Error) {
+	if err := this.Err(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Close 停止后台重连
+func (this *Reconnector) Close() {
+	this.closeOnce.Do(func() {
+		close(this.done)
+	})
+}
+
+func (this *Reconnector) loop() {
+	for {
+		select {
+		case <-this.done:
+			return
+		case <-this.trigger:
+		}
+
+		for attempt := 1; !this.Connected(); attempt++ {
+			select {
+			case <-this.done:
+				return
+			case <-time.After(this.policy.delay(attempt)):
+			}
+
+			if err := this.connect(); err != nil {
+				this.lock.Lock()
+				this.err = err
+				this.lock.Unlock()
+				hlogger.Warn("%s reconnect attempt %d failed: %s", this.name, attempt, err.Error())
+				continue
+			}
+			this.connected.Store(true)
+			hlogger.Info("%s reconnected after %d attempts", this.name, attempt)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"github.com/drharryhe/has/common/herrors"
+)
+
+func TestReconnector(t *testing.T) {
+	var calls atomic.Int64
+	var fails atomic.Int64
+	connect := func() *herrors.Error {
+		calls.Inc()
+		if fails.Load() > 0 {
+			fails.Dec()
+			return herrors.ErrSysUnavailable.New("backend down")
+		}
+		return nil
+	}
+	policy := RetryPolicy{BaseDelay: 1, MaxDelay: 5, Jitter: 1}
+	waitConnected := func(r *Reconnector) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !r.Connected() {
+			if time.Now().After(deadline) {
+				t.Fatalf("not reconnected, last error: %v", r.Err())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	fails.Store(1)
+	r := NewReconnector("test", policy, connect)
+	if err := r.Open(false); err == nil || err.Code != herrors.ECodeSysUnavailable {
+		t.Fatalf("open without degraded: err = %v", err)
+	}
+	r.Close()
+
+	fails.Store(3)
+	r = NewReconnector("test", policy, connect)
+	defer r.Close()
+	if err := r.Open(true); err != nil {
+		t.Fatalf("open degraded: err = %v", err)
+	}
+	if _, err := r.Ping(nil); err == nil {
+		t.Fatal("ping should fail while degraded")
+	}
+	waitConnected(r)
+	if _, err := r.Ping(nil); err != nil {
+		t.Fatalf("ping after reconnect: %v", err)
+	}
+
+	calls.Store(0)
+	fails.Store(2)
+	r.Disconnected(herrors.ErrSysUnavailable.New("connection reset"))
+	waitConnected(r)
+	if calls.Load() != 3 {
+		t.Fatalf("reconnect attempts = %d, want 3", calls.Load())
+	}
+}
+
+func TestReconnectorClose(t *testing.T) {
+	var calls atomic.Int64
+	r := NewReconnector("test", RetryPolicy{BaseDelay: 1, MaxDelay: 1, Jitter: 1}, func() *herrors.Error {
+		calls.Inc()
+		return herrors.ErrSysUnavailable.New("backend down")
+	})
+	_ = r.Open(true)
+	time.Sleep(20 * time.Millisecond)
+	r.Close()
+	time.Sleep(10 * time.Millisecond)
+	n := calls.Load()
+	time.Sleep(20 * time.Millisecond)
+	if calls.Load() != n {
+		t.Fatal("reconnect continued after close")
+	}
+}
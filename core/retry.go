@@ -0,0 +1,52 @@
+package core
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/drharryhe/has/common/herrors"
+)
+
+// RetryPolicy 服务调用的重试策略，只对暂时性错误(ErrSysUnavailable、ErrSysBusy、ErrSysTimeout)重试
+type RetryPolicy struct {
+	MaxAttempts int //包括首次调用在内的最多调用次数，<=1表示不重试
+	BaseDelay   int //ms, 第n次重试前等待 BaseDelay*2^(n-1)
+	MaxDelay    int //ms, 等待时间上限，0表示不限制
+	Jitter      int //ms, 每次等待随机增加 0~Jitter
+}
+
+// retryPolicy 按 service/slot、service 的顺序查找重试策略，slot定义了no_retry时不重试
+func (this *ServerImplement) retryPolicy(conf *Server, service string, slot string) RetryPolicy {
+	if s := this.Slot(service, slot); s != nil && s.NoRetry {
+		return RetryPolicy{}
+	}
+	if p, ok := conf.Retries[service+"/"+slot]; ok {
+		return p
+	}
+	return conf.Retries[service]
+}
+
+func (this RetryPolicy) delay(attempt int) time.Duration {
+	d := time.Duration(this.BaseDelay) * time.Millisecond
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if this.MaxDelay > 0 && d >= time.Duration(this.MaxDelay)*time.Millisecond {
+			break
+		}
+	}
+	if this.MaxDelay > 0 && d > time.Duration(this.MaxDelay)*time.Millisecond {
+		d = time.Duration(this.MaxDelay) * time.Millisecond
+	}
+	if this.Jitter > 0 {
+		d += time.Duration(rand.Intn(this.Jitter)) * time.Millisecond
+	}
+	return d
+}
+
+func isTransient(err *herrors.Error) bool {
+	switch err.Code {
+	case herrors.ECodeSysUnavailable, herrors.ECodeSysBusy, herrors.ECodeSysTimeout:
+		return true
+	}
+	return false
+}
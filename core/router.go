@@ -1,10 +1,14 @@
 package core
 
 import (
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/drharryhe/has/common/hconf"
 	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
 	"github.com/drharryhe/has/common/htypes"
 	"github.com/drharryhe/has/utils/hrandom"
 	"github.com/drharryhe/has/utils/hruntime"
@@ -26,6 +30,12 @@ type BaseRouter struct {
 	class    string
 	Services map[string]IService
 	Entities map[string]IEntity
+
+	authorizer IAuthorizer
+	draining   map[string]bool //正在排空的服务，不再分发新请求
+	lock       sync.RWMutex    //服务可以在运行时添加和移除
+
+	middlewares SlotMiddlewares
 }
 
 /**
@@ -60,6 +70,7 @@ func (this *BaseRouter) Open(s IServer, ins IRouter) *herrors.Error {
 	this.class = hruntime.GetObjectName(ins.(IEntity).Config())
 	this.Services = make(map[string]IService)
 	this.Entities = make(map[string]IEntity)
+	this.draining = make(map[string]bool)
 	hconf.Load(ins.(IEntity).Config())
 
 	return nil
@@ -68,37 +79,120 @@ func (this *BaseRouter) Open(s IServer, ins IRouter) *herrors.Error {
 func (this *BaseRouter) Close() {}
 
 func (this *BaseRouter) RegisterService(s IService) *herrors.Error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
 	if this.Services[s.Name()] != nil {
 		return herrors.ErrSysInternal.New("service name %s duplicated", s.Name())
 	}
 
 	this.Services[s.Name()] = s
+	delete(this.draining, s.Name())
 	return nil
 }
 
+func (this *BaseRouter) SetAuthorizer(a IAuthorizer) {
+	this.authorizer = a
+}
+
+// Authorize 分发服务请求前调用授权策略，没有设置授权策略时直接通过
+func (this *BaseRouter) Authorize(service string, slot *Slot, params htypes.Map) *herrors.Error {
+	if this.authorizer == nil {
+		return nil
+	}
+	return this.authorizer.Authorize(service, slot, params)
+}
+
+// Use 注册slot中间件，scope为空表示所有slot，或为 service、service/slot
+func (this *BaseRouter) Use(scope string, mws ...SlotMiddleware) {
+	this.middlewares.Use(scope, mws...)
+}
+
+// Dispatch 执行slot中间件后调用服务，具体的router在授权后调用
+func (this *BaseRouter) Dispatch(s IService, slot *Slot, params htypes.Map) (htypes.Any, *herrors.Error) {
+	return this.middlewares.Dispatch(s.Name(), slot, params, func(ps htypes.Map) (htypes.Any, *herrors.Error) {
+		return s.Request(slot.Name, ps)
+	})
+}
+
 func (this *BaseRouter) UnRegisterService(s IService) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
 	delete(this.Services, s.Name())
 }
 
+// DrainService 标记服务正在排空，具体的router在分发前检查Draining
+func (this *BaseRouter) DrainService(name string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.draining[name] = true
+}
+
+func (this *BaseRouter) Draining(name string) bool {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	return this.draining[name]
+}
+
+// Service 返回本地注册的服务，没有时返回nil
+func (this *BaseRouter) Service(name string) IService {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	return this.Services[name]
+}
+
 func (this *BaseRouter) AllEntities() []*EntityMeta {
 	var ret []*EntityMeta
-	for _, m := range this.Entities {
+	for _, m := range this.entities() {
 		ret = append(ret, m.EntityMeta())
 	}
 	return ret
 }
 
 func (this *BaseRouter) RegisterEntity(m IEntity) *herrors.Error {
-	if m.EntityMeta() == nil {
+	meta := m.EntityMeta()
+	if meta == nil {
 		return herrors.ErrSysInternal.New("Entity %s EntityMeta is null", hruntime.GetObjectName(m))
 	}
 
-	this.Entities[m.EntityMeta().EID] = m
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.Entities[meta.EID] = m
 	return nil
 }
 
+func (this *BaseRouter) UnRegisterEntity(m IEntity) {
+	meta := m.EntityMeta()
+	if meta == nil {
+		return
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	delete(this.Entities, meta.EID)
+}
+
+func (this *BaseRouter) entities() []IEntity {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	ret := make([]IEntity, 0, len(this.Entities))
+	for _, m := range this.Entities {
+		ret = append(ret, m)
+	}
+	return ret
+}
+
 func (this *BaseRouter) ManageEntity(mm *EntityMeta, slot string, params htypes.Map) (htypes.Any, *herrors.Error) {
+	this.lock.RLock()
 	m := this.Entities[mm.EID]
+	this.lock.RUnlock()
 	if m == nil {
 		return nil, herrors.ErrSysInternal.New("Entity entity [" + mm.EID + "] not found")
 	}
@@ -109,6 +203,50 @@ func (this *BaseRouter) ManageEntity(mm *EntityMeta, slot string, params htypes.
 	return res.Data, res.Error
 }
 
+// Loads 汇总本地实体的负载，GetLoad未实现或失败的实体不包含在内
+func (this *BaseRouter) Loads() []*Load {
+	var ret []*Load
+	for _, m := range this.entities() {
+		var res SlotResponse
+		m.EntityStub().Manage("GetLoad", nil, &res)
+		l, ok := res.Data.(*Load)
+		if res.Error != nil || !ok || l == nil {
+			continue
+		}
+		if l.EID == "" {
+			l.EntityMeta = *m.EntityMeta()
+		}
+		ret = append(ret, l)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Type != ret[j].Type {
+			return ret[i].Type < ret[j].Type
+		}
+		return ret[i].Class < ret[j].Class
+	})
+	return ret
+}
+
+// ReloadEntityConfig 调用配置节为section的实体的ResetConfig，无法在线生效的设置由实体返回错误并记录日志
+func (this *BaseRouter) ReloadEntityConfig(section string, params htypes.Map) {
+	for _, m := range this.entities() {
+		if hruntime.GetObjectName(m.Config()) != section {
+			continue
+		}
+		if err := m.EntityStub().ResetConfig(params); err != nil {
+			hlogger.Error("failed to reload config [%s]: %s", section, err.Error())
+		} else {
+			hlogger.Info("config [%s] reloaded", section)
+		}
+	}
+}
+
+// Health 汇总本地实体(路由、插件、服务等)的Ping结果
+func (this *BaseRouter) Health(timeout time.Duration) *Health {
+	return CheckHealth(this.entities(), timeout)
+}
+
 /**
 utilities methods for concrete router implements
 */
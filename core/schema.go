@@ -0,0 +1,288 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+// 参数校验错误的消息ID，在翻译文件中以消息ID为键配置译文，译文中可使用FieldError.Params中的参数，
+// 所有消息都可使用 {field}
+const (
+	MsgParamRequired  = "param.required"   //缺少必需参数
+	MsgParamType      = "param.type"       //{type}
+	MsgParamValidator = "param.validator"  //{validator}
+	MsgParamMinValue  = "param.min.value"  //{min}
+	MsgParamMinLength = "param.min.length" //{min}
+	MsgParamMinSize   = "param.min.size"   //{min}
+	MsgParamMaxValue  = "param.max.value"  //{max}
+	MsgParamMaxLength = "param.max.length" //{max}
+	MsgParamMaxSize   = "param.max.size"   //{max}
+	MsgParamPattern   = "param.pattern"    //{value} {pattern}
+	MsgParamEnum      = "param.enum"       //{value} {enum}
+	MsgParamRule      = "param.rule"       //{expr}，设置了SlotRule.Message时以Message为消息ID
+	MsgParamRuleTypes = "param.rule.types" //{expr} {left} {right}
+)
+
+// SlotRule 参数间的约束，如 endDate >= startDate。Expr为 参数 运算符 参数或常量，
+// 运算符支持 == != > >= < <=，常量为数字、true/false或带双引号的字符串。任一参数未提交时不检查
+type SlotRule struct {
+	Expr    string `json:"expr"`
+	Message string `json:"message"` //不满足时的错误信息，缺省为 rule [Expr] not satisfied
+}
+
+var (
+	ruleExpr  = regexp.MustCompile(`^\s*([A-Za-z_]\w*)\s*(==|!=|>=|<=|>|<)\s*(.+?)\s*$`)
+	ruleParam = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+)
+
+// slotRule 解析后的SlotRule，right为参数名时literal为nil
+type slotRule struct {
+	SlotRule
+	left    string
+	op      string
+	right   string
+	literal htypes.Any
+}
+
+// compileSlot 加载slot时解析参数的Pattern和Rules并检查Sunset，定义无效时返回错误
+func compileSlot(s *Slot) *herrors.Error {
+	for i := range s.Params {
+		p := &s.Params[i]
+		if p.Pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return herrors.ErrSysInternal.New("slot %s param %s: invalid pattern: %s", s.Name, p.Name, err.Error())
+		}
+		p.pattern = re
+	}
+
+	if _, err := parseSunset(s.Sunset); err != nil {
+		return herrors.ErrSysInternal.New("slot %s: invalid sunset %s", s.Name, s.Sunset)
+	}
+
+	s.rules = nil
+	for _, r := range s.Rules {
+		rule, err := parseRule(r)
+		if err != nil {
+			return herrors.ErrSysInternal.New("slot %s: %s", s.Name, err.Error())
+		}
+		s.rules = append(s.rules, rule)
+	}
+	return nil
+}
+
+func parseRule(r SlotRule) (*slotRule, error) {
+	m := ruleExpr.FindStringSubmatch(r.Expr)
+	if m == nil {
+		return nil, fmt.Errorf("invalid rule [%s]", r.Expr)
+	}
+
+	rule := &slotRule{SlotRule: r, left: m[1], op: m[2], right: m[3]}
+	switch {
+	case strings.HasPrefix(rule.right, `"`):
+		s, err := strconv.Unquote(rule.right)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string in rule [%s]", r.Expr)
+		}
+		rule.literal = s
+	case rule.right == "true" || rule.right == "false":
+		rule.literal = rule.right == "true"
+	default:
+		if f, err := strconv.ParseFloat(rule.right, 64); err == nil {
+			rule.literal = f
+		} else if !ruleParam.MatchString(rule.right) {
+			return nil, fmt.Errorf("invalid operand in rule [%s]", r.Expr)
+		}
+	}
+	return rule, nil
+}
+
+// check 不满足时返回以规则左侧参数为字段的错误
+func (this *slotRule) check(ps htypes.Map) *herrors.FieldError {
+	left, ok := ps[this.left]
+	if !ok || left == nil {
+		return nil
+	}
+	right := this.literal
+	if right == nil {
+		if right, ok = ps[this.right]; !ok || right == nil {
+			return nil
+		}
+	}
+
+	c, ok := compareValues(left, right)
+	if !ok || (isBool(left) && this.op != "==" && this.op != "!=") {
+		return &herrors.FieldError{
+			Field:   this.left,
+			ID:      MsgParamRuleTypes,
+			Message: fmt.Sprintf("rule [%s]: cannot compare %v and %v", this.Expr, left, right),
+			Params:  map[string]interface{}{"expr": this.Expr, "left": left, "right": right},
+		}
+	}
+
+	var pass bool
+	switch this.op {
+	case "==":
+		pass = c == 0
+	case "!=":
+		pass = c != 0
+	case ">":
+		pass = c > 0
+	case ">=":
+		pass = c >= 0
+	case "<":
+		pass = c < 0
+	case "<=":
+		pass = c <= 0
+	}
+	if pass {
+		return nil
+	}
+	if this.Message != "" {
+		return &herrors.FieldError{Field: this.left, ID: this.Message, Message: this.Message, Params: map[string]interface{}{"expr": this.Expr}}
+	}
+	return &herrors.FieldError{
+		Field:   this.left,
+		ID:      MsgParamRule,
+		Message: fmt.Sprintf("rule [%s] not satisfied", this.Expr),
+		Params:  map[string]interface{}{"expr": this.Expr},
+	}
+}
+
+func isBool(v htypes.Any) bool {
+	_, ok := v.(bool)
+	return ok
+}
+
+// compareValues 数字按数值比较，字符串按字典序比较(日期和时间参数格式固定，可直接比较)，布尔值只比较是否相等
+func compareValues(a htypes.Any, b htypes.Any) (int, bool) {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+	if sa, ok := a.(string); ok {
+		sb, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(sa, sb), true
+	}
+	if ba, ok := a.(bool); ok {
+		bb, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		if ba == bb {
+			return 0, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+func toFloat(v htypes.Any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	}
+	return 0, false
+}
+
+// checkSchema 检查Min、Max、Pattern和Enum。Min和Max对数字是取值范围，对字符串是字符数，对数组是元素个数；
+// Pattern和Enum对数组检查每个元素
+func (this *SlotParam) checkSchema(v htypes.Any) []herrors.FieldError {
+	var errs []herrors.FieldError
+	if n, ok := this.measure(v); ok {
+		m := this.measureName(v)
+		if this.Min != nil && n < *this.Min {
+			errs = append(errs, this.fieldError("param.min."+m, map[string]interface{}{"min": *this.Min}, "%s less than %v", m, *this.Min))
+		}
+		if this.Max != nil && n > *this.Max {
+			errs = append(errs, this.fieldError("param.max."+m, map[string]interface{}{"max": *this.Max}, "%s greater than %v", m, *this.Max))
+		}
+	}
+
+	items := []htypes.Any{v}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+		items = items[:0]
+		for i := 0; i < rv.Len(); i++ {
+			items = append(items, rv.Index(i).Interface())
+		}
+	}
+	for _, item := range items {
+		if this.pattern != nil {
+			if s, ok := item.(string); ok && !this.pattern.MatchString(s) {
+				errs = append(errs, this.fieldError(MsgParamPattern, map[string]interface{}{"value": s, "pattern": this.Pattern}, "%q does not match %s", s, this.Pattern))
+			}
+		}
+		if len(this.Enum) > 0 && !this.inEnum(item) {
+			errs = append(errs, this.fieldError(MsgParamEnum, map[string]interface{}{"value": item, "enum": this.Enum}, "%v not in %v", item, this.Enum))
+		}
+	}
+	return errs
+}
+
+// fieldError 错误信息为 [参数名] 具体描述
+func (this *SlotParam) fieldError(id string, params map[string]interface{}, format string, v ...interface{}) herrors.FieldError {
+	return herrors.FieldError{
+		Field:   this.Name,
+		ID:      id,
+		Message: fmt.Sprintf("[%s] %s", this.Name, fmt.Sprintf(format, v...)),
+		Params:  params,
+	}
+}
+
+func (this *SlotParam) measure(v htypes.Any) (float64, bool) {
+	if f, ok := toFloat(v); ok {
+		return f, true
+	}
+	if s, ok := v.(string); ok {
+		return float64(utf8.RuneCountInString(s)), true
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice {
+		return float64(rv.Len()), true
+	}
+	return 0, false
+}
+
+func (this *SlotParam) measureName(v htypes.Any) string {
+	if _, ok := toFloat(v); ok {
+		return "value"
+	}
+	if _, ok := v.(string); ok {
+		return "length"
+	}
+	return "size"
+}
+
+func (this *SlotParam) inEnum(v htypes.Any) bool {
+	for _, e := range this.Enum {
+		if c, ok := compareValues(v, e); ok && c == 0 {
+			return true
+		}
+	}
+	return false
+}
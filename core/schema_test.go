@@ -0,0 +1,143 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+const testSchemaSlot = `{
+	"name": "search",
+	"params": [
+		{"name": "page", "type": "Number", "min": 1, "max": 100},
+		{"name": "user", "type": "String", "required": true, "min": 2, "pattern": "^[a-z]+$"},
+		{"name": "status", "type": "String", "enum": ["open", "closed"]},
+		{"name": "tags", "type": "StringArray", "max": 2, "enum": ["a", "b"]},
+		{"name": "startDate", "type": "String"},
+		{"name": "endDate", "type": "String"}
+	],
+	"rules": [
+		{"expr": "endDate >= startDate", "message": "endDate should not be earlier than startDate"},
+		{"expr": "status != \"closed\""}
+	]
+}`
+
+func newSchemaSlot(t *testing.T) *Slot {
+	var s Slot
+	if err := jsoniter.UnmarshalFromString(testSchemaSlot, &s); err != nil {
+		t.Fatal(err)
+	}
+	if err := compileSlot(&s); err != nil {
+		t.Fatal(err)
+	}
+	return &s
+}
+
+func TestCheckParams(t *testing.T) {
+	svc := &Service{}
+	slot := newSchemaSlot(t)
+
+	ok := htypes.Map{"page": float64(2), "user": "bob", "status": "open", "tags": []interface{}{"a"}, "startDate": "2022-01-01", "endDate": "2022-01-02"}
+	if err := svc.checkParams(ok, slot); err != nil {
+		t.Fatalf("valid params: %v", err)
+	}
+
+	bad := htypes.Map{"page": float64(0), "user": "B", "status": "closed", "tags": []interface{}{"a", "c", "b"}, "startDate": "2022-01-02", "endDate": "2022-01-01"}
+	err := svc.checkParams(bad, slot)
+	if err == nil || err.Code != herrors.ECodeCallerInvalidRequest {
+		t.Fatalf("err = %v, want invalid request", err)
+	}
+	for _, want := range []string{
+		"[page] value less than 1",
+		"[user] length less than 2",
+		`[user] "B" does not match`,
+		"[tags] size greater than 2",
+		"[tags] c not in",
+		"endDate should not be earlier than startDate",
+		`rule [status != "closed"] not satisfied`,
+	} {
+		if !strings.Contains(err.Cause, want) {
+			t.Errorf("violations %q missing %q", err.Cause, want)
+		}
+	}
+
+	if err := svc.checkParams(htypes.Map{"page": "x"}, slot); err == nil || !strings.Contains(err.Cause, "required parameter [user]") || !strings.Contains(err.Cause, `[page] cannot convert "x" to Number`) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestCompileSlot(t *testing.T) {
+	for _, expr := range []string{"a >= ", "a => b", "a == b c", `a == "x`} {
+		s := &Slot{Name: "s", Rules: []SlotRule{{Expr: expr}}}
+		if err := compileSlot(s); err == nil {
+			t.Errorf("rule %q should be invalid", expr)
+		}
+	}
+	s := &Slot{Name: "s", Params: []SlotParam{{Name: "p", Pattern: "("}}}
+	if err := compileSlot(s); err == nil {
+		t.Error("invalid pattern should fail")
+	}
+}
+
+type mapI18n map[string]map[string]string
+
+func (this mapI18n) Open() *herrors.Error { return nil }
+func (this mapI18n) Close()               {}
+func (this mapI18n) Translate(lang string, text string) string {
+	if t, ok := this[lang][text]; ok {
+		return t
+	}
+	return text
+}
+
+func TestTranslateFields(t *testing.T) {
+	svc := &Service{}
+	slot := newSchemaSlot(t)
+	err := svc.checkParams(htypes.Map{"page": float64(0), "user": "b"}, slot)
+	if err == nil || len(err.Fields) != 2 {
+		t.Fatalf("err = %v, want 2 field errors", err)
+	}
+	if f := err.Fields[0]; f.Field != "page" || f.ID != MsgParamMinValue || f.Params["min"] != float64(1) {
+		t.Errorf("field error = %+v", f)
+	}
+
+	trans := mapI18n{"cn-zh": {MsgParamMinLength: "{field}至少{min}个字符"}}
+	ret := TranslateFields(trans, err, "en-us", "cn-zh")
+	if ret.Fields[1].Message != "user至少2个字符" {
+		t.Errorf("translated message = %q", ret.Fields[1].Message)
+	}
+	if ret.Fields[0].Message != "[page] value less than 1" || err.Fields[1].Message != "[user] length less than 2" {
+		t.Errorf("untranslated messages = %q, %q", ret.Fields[0].Message, err.Fields[1].Message)
+	}
+}
+
+func TestCheckParamsCoerce(t *testing.T) {
+	svc := &Service{}
+	slot := &Slot{Name: "list", Params: []SlotParam{
+		{Name: "page", Type: htypes.HTypeInteger},
+		{Name: "active", Type: htypes.HTypeBool},
+		{Name: "since", Type: htypes.HTypeTime},
+		{Name: "Size", Type: htypes.HTypeNumber, CaseInSensitive: true},
+	}}
+
+	ps := htypes.Map{"page": "2", "active": "true", "since": "2022-01-02T00:00:00Z", "size": "10"}
+	if err := svc.checkParams(ps, slot); err != nil {
+		t.Fatal(err)
+	}
+	if ps["page"] != int64(2) || ps["active"] != true || ps["Size"] != float64(10) {
+		t.Fatalf("params = %v", ps)
+	}
+	if since, ok := ps["since"].(time.Time); !ok || !since.Equal(time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("since = %v", ps["since"])
+	}
+
+	err := svc.checkParams(htypes.Map{"active": "maybe"}, slot)
+	if err == nil || len(err.Fields) != 1 || err.Fields[0].Field != "active" || err.Fields[0].ID != MsgParamType {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/drharryhe/has/common/htypes"
+)
+
+const (
+	ScopeField = "__ctx__" //参数中保存请求作用域值的子map，由connector和中间件写入，调用方传入的同名参数被丢弃
+
+	ScopeSubject   = "subject"    //JWT或会话的subject
+	ScopeClaims    = "claims"     //JWT的claims
+	ScopeSession   = "session"    //会话ID
+	ScopeTenant    = "tenant"     //租户ID
+	ScopeRequestID = "request_id" //请求ID
+	ScopeAddress   = "address"    //客户端地址
+	ScopeContext   = "ctx_token"  //本地调用登记ctx的内部标识，由server写入
+)
+
+// SetScoped 在参数的请求作用域中写入值，val为nil时删除
+func SetScoped(ps htypes.Map, key string, val htypes.Any) {
+	if ps == nil {
+		return
+	}
+	scope := ScopedValues(ps)
+	if val == nil {
+		if scope != nil {
+			delete(scope, key)
+		}
+		return
+	}
+	if scope == nil {
+		scope = make(htypes.Map)
+		ps[ScopeField] = scope
+	}
+	scope[key] = val
+}
+
+// Scoped 返回参数的请求作用域中的值
+func Scoped(ps htypes.Map, key string) (htypes.Any, bool) {
+	val, ok := ScopedValues(ps)[key]
+	return val, ok
+}
+
+// ScopedString 返回请求作用域中的字符串值，不存在时返回空字符串，非字符串值按%v格式化
+func ScopedString(ps htypes.Map, key string) string {
+	val, ok := Scoped(ps, key)
+	if !ok || val == nil {
+		return ""
+	}
+	if s, ok := val.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", val)
+}
+
+// ScopedValues 返回参数的请求作用域，没有时返回nil。经过打包传输的参数中子map的类型为map[string]interface{}
+func ScopedValues(ps htypes.Map) htypes.Map {
+	switch scope := ps[ScopeField].(type) {
+	case htypes.Map:
+		return scope
+	case map[string]interface{}:
+		return scope
+	}
+	return nil
+}
+
+// StripScope 删除调用方传入的请求作用域，connector解析完调用方参数后、写入作用域值之前调用
+func StripScope(ps htypes.Map) {
+	delete(ps, ScopeField)
+}
+
+// ScopedSubject 返回请求的JWT或会话subject
+func ScopedSubject(ps htypes.Map) string {
+	return ScopedString(ps, ScopeSubject)
+}
+
+// ScopedTenant 返回请求的租户ID
+func ScopedTenant(ps htypes.Map) string {
+	return ScopedString(ps, ScopeTenant)
+}
+
+// ScopedRequestID 返回请求ID，作用域中没有时返回参数中的RequestID
+func ScopedRequestID(ps htypes.Map) string {
+	if id := ScopedString(ps, ScopeRequestID); id != "" {
+		return id
+	}
+	id, _ := ps[RequestIDField].(string)
+	return id
+}
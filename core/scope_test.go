@@ -0,0 +1,45 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/drharryhe/has/common/htypes"
+)
+
+func TestScope(t *testing.T) {
+	ps := htypes.Map{RequestIDField: "r1", "subject": "param"}
+	if ScopedSubject(ps) != "" || ScopedValues(ps) != nil {
+		t.Fatal("empty scope should have no values")
+	}
+	if ScopedRequestID(ps) != "r1" {
+		t.Fatalf("request id = %s, want fallback r1", ScopedRequestID(ps))
+	}
+
+	SetScoped(ps, ScopeSubject, "u1")
+	SetScoped(ps, ScopeTenant, "acme")
+	SetScoped(ps, ScopeRequestID, "r2")
+	SetScoped(ps, "uid", 42)
+	if ScopedSubject(ps) != "u1" || ScopedTenant(ps) != "acme" || ScopedRequestID(ps) != "r2" || ScopedString(ps, "uid") != "42" {
+		t.Fatalf("scope = %v", ScopedValues(ps))
+	}
+	if ps["subject"] != "param" {
+		t.Fatal("scoped values should not overwrite params")
+	}
+
+	SetScoped(ps, ScopeTenant, nil)
+	if _, ok := Scoped(ps, ScopeTenant); ok {
+		t.Fatal("nil value should delete key")
+	}
+
+	//经过打包传输后子map为map[string]interface{}
+	ps = htypes.Map{ScopeField: map[string]interface{}{ScopeSubject: "u2"}}
+	SetScoped(ps, ScopeTenant, "t2")
+	if ScopedSubject(ps) != "u2" || ScopedTenant(ps) != "t2" {
+		t.Fatalf("scope = %v", ScopedValues(ps))
+	}
+
+	StripScope(ps)
+	if ScopedValues(ps) != nil {
+		t.Fatal("scope not stripped")
+	}
+}
@@ -1,17 +1,25 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
 	"runtime"
+	"sync"
 	"syscall"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/atomic"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/drharryhe/has/common/hconf"
 	"github.com/drharryhe/has/common/herrors"
 	hlogger "github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/htrace"
 	"github.com/drharryhe/has/common/htypes"
 	"github.com/drharryhe/has/utils/hio"
 	"github.com/drharryhe/has/utils/hrandom"
@@ -19,7 +27,13 @@ import (
 )
 
 const (
-	defaultMaxProcs = 1
+	RequestIDField   = "RequestID"   //请求ID参数名，由connector写入，用于关联同一请求的日志
+	TraceParentField = "TraceParent" //W3C traceparent参数名，跨服务传递trace上下文
+	TraceStateField  = "TraceState"
+
+	defaultMaxProcs      = 1
+	defaultDrainTimeout  = 10   //seconds
+	defaultHealthTimeout = 3000 //ms
 
 	//熔断器缺省设置
 	defaultRequestTimeout         = 1000
@@ -32,7 +46,17 @@ const (
 type Server struct {
 	EntityConfBase
 
-	MaxProcs int
+	MaxProcs        int
+	RequestTimeout  int                    //ms, 服务调用超时，0表示不限制
+	RequestTimeouts map[string]int         //ms, 按 service 或 service/slot 覆盖RequestTimeout
+	Retries         map[string]RetryPolicy //按 service 或 service/slot 设置失败重试策略
+	DrainTimeout    int                    //seconds, 关闭或移除服务时等待服务Drain的时长，缺省为 10
+	HealthTimeout   int                    //ms, 健康检查中每个实体Ping的超时时长，缺省为 3000
+	Bulkheads       map[string]Bulkhead    //按 service 或 service/slot 限制同时处理的请求数
+
+	TraceEndpoint    string  //OTLP/HTTP地址，如 http://127.0.0.1:4318/v1/traces，为空时不记录trace
+	TraceSampleRate  float64 //根span的采样率(0,1]，缺省为1
+	TraceServiceName string  //trace中的服务名，缺省为has
 }
 
 func NewServer(opt *ServerOptions, args ...htypes.Any) *ServerImplement {
@@ -45,12 +69,23 @@ type ServerImplement struct {
 	Instance      IServer
 	class         string
 	conf          Server
+	settings      atomic.Value //*Server, 请求处理时读取的配置快照，resetConfig时整体替换，快照发布后不再修改
+	confLock      sync.Mutex
 	quitSignal    chan os.Signal //退出信号
 	router        IRouter
 	plugins       map[string]IPlugin
 	services      map[string]IService
+	servicesLock  sync.RWMutex
 	assetsManager IAssetManager
-	requestNo     atomic.Uint64
+	load          LoadCounter    //请求数、处理中的请求数和请求速率
+	serviceLoad   serviceCounter //按服务统计的请求数
+	ready         atomic.Bool
+	authorizer    IAuthorizer
+	flights       singleflight.Group //slot定义了singleflight时合并相同的并发请求
+	closeHooks    []func()           //在关闭router和plugins之前调用
+	bulkheads     map[string]*bulkhead
+	bulkheadLock  sync.Mutex
+	startTime     time.Time
 }
 
 func (this *ServerImplement) Class() string {
@@ -84,7 +119,7 @@ func (this *ServerImplement) EntityStub() *EntityStub {
 		&EntityStubOptions{
 			Owner:       this,
 			Ping:        nil,
-			GetLoad:     nil,
+			GetLoad:     this.getLoad,
 			ResetConfig: this.resetConfig,
 		})
 }
@@ -97,8 +132,16 @@ func (this *ServerImplement) Router() IRouter {
 	return this.router
 }
 
+// Services 返回当前服务的副本，服务可以在运行时添加和移除
 func (this *ServerImplement) Services() map[string]IService {
-	return this.services
+	this.servicesLock.RLock()
+	defer this.servicesLock.RUnlock()
+
+	ret := make(map[string]IService, len(this.services))
+	for k, v := range this.services {
+		ret[k] = v
+	}
+	return ret
 }
 
 func (this *ServerImplement) init(opt *ServerOptions, args ...htypes.Any) {
@@ -106,9 +149,15 @@ func (this *ServerImplement) init(opt *ServerOptions, args ...htypes.Any) {
 		panic("ServerOptions cannot be nil")
 	}
 
+	hconf.SetProviders(opt.ConfigProviders...)
 	hconf.Init()
 	hconf.Load(&this.conf)
-	hlogger.Init(hconf.LogOutputs(), hconf.LogFileName())
+	this.publishConfig()
+	hlogger.Init(hconf.LogOutputs(), hconf.LogFileName(), hconf.LogLevel(), hconf.LogFormat())
+	if err := htrace.Init(this.conf.TraceEndpoint, this.conf.TraceSampleRate, this.conf.TraceServiceName); err != nil {
+		hlogger.Critical(err)
+		panic("failed to init trace")
+	}
 
 	this.class = hruntime.GetObjectName(&this.conf)
 	this.Instance = this
@@ -123,6 +172,11 @@ func (this *ServerImplement) init(opt *ServerOptions, args ...htypes.Any) {
 		hlogger.Critical(err)
 		panic("failed to init server")
 	}
+	if opt.Authorizer != nil {
+		opt.Router.SetAuthorizer(opt.Authorizer)
+		this.authorizer = opt.Authorizer
+	}
+	opt.Router.Use("", opt.SlotMiddlewares...)
 	if err := CheckAndRegisterEntity(opt.Router, opt.Router); err != nil {
 		hlogger.Critical(err)
 		panic("failed to init server")
@@ -155,6 +209,7 @@ func (this *ServerImplement) Plugin(cls string) IPlugin {
 }
 
 func (this *ServerImplement) Start() {
+	this.startTime = time.Now()
 	//根据配置决定是否支持多核，缺省是单核,  如果是docker容器，需要做特殊处理
 	if this.conf.MaxProcs > 0 {
 		runtime.GOMAXPROCS(this.conf.MaxProcs)
@@ -165,45 +220,159 @@ func (this *ServerImplement) Start() {
 		hlogger.Error(err)
 	}
 	hlogger.Info("server started...")
+	this.ready.Store(true)
+	for _, p := range this.plugins {
+		if starter, ok := p.(IPluginStarter); ok {
+			starter.Start()
+		}
+	}
+
+	if err := hconf.Watch(this.reloadConfig); err != nil {
+		hlogger.Warn("config hot reload disabled: %s", err.Error())
+	}
 
 	this.waitForQuit()
 }
 
+// BuildInfo 构建信息、启动时间和运行时长
+func (this *ServerImplement) BuildInfo() *BuildInfo {
+	return NewBuildInfo(this.startTime)
+}
+
+// Ready 启动完成、未开始关闭，且所有实体的Ping成功，如数据库插件的连接可用
+func (this *ServerImplement) Ready() bool {
+	if !this.ready.Load() {
+		return false
+	}
+	health := this.Health()
+	for _, h := range health.Entities {
+		if !h.Healthy {
+			hlogger.Warn("%s %s not ready: %s", h.Type, h.Class, h.Error)
+		}
+	}
+	return health.Healthy
+}
+
+// Health 本地实体的健康状况，每个实体的Ping最多等待HealthTimeout
+func (this *ServerImplement) Health() *Health {
+	timeout := this.currentConfig().HealthTimeout
+	if timeout <= 0 {
+		timeout = defaultHealthTimeout
+	}
+	return this.router.Health(time.Duration(timeout) * time.Millisecond)
+}
+
 func (this *ServerImplement) Shutdown() {
 	this.quitSignal <- syscall.SIGQUIT
 }
 
+// RegisterService 启动时注册服务，失败时panic
 func (this *ServerImplement) RegisterService(service IService, args ...htypes.Any) {
-	var herr *herrors.Error
+	if err := this.AddService(service, args...); err != nil {
+		panic(err.D("failed to register service [%s] ", hruntime.GetObjectName(service)))
+	}
+}
 
-	if entity, ok := service.(IEntity); !ok {
-		herr = herrors.ErrSysInternal.New("Plugin %s not implement IEntity interface", hruntime.GetObjectName(service))
-		goto panic
-	} else {
-		hconf.Load(entity.Config())
+// AddService 加载配置、打开并注册服务，可在server启动后调用。失败时关闭已打开的服务并返回错误
+func (this *ServerImplement) AddService(service IService, args ...htypes.Any) (herr *herrors.Error) {
+	entity, ok := service.(IEntity)
+	if !ok {
+		return herrors.ErrSysInternal.New("Service %s not implement IEntity interface", hruntime.GetObjectName(service))
+	}
 
-		if herr = service.Open(this, service, args); herr != nil {
-			goto panic
+	//配置节不存在等情况下hconf和服务会panic，运行时添加服务不能影响整个server
+	opened := false
+	defer func() {
+		if e := recover(); e != nil {
+			if opened {
+				service.Close()
+			}
+			herr = herrors.ErrSysInternal.New("failed to add service %s: %v", hruntime.GetObjectName(service), e)
 		}
+	}()
 
-		if herr = this.router.RegisterService(service); herr != nil {
-			goto panic
-		}
+	hconf.Load(entity.Config())
+	if herr = service.Open(this, service, args); herr != nil {
+		return herr
+	}
+	opened = true
 
-		if herr = this.router.RegisterEntity(entity); herr != nil {
-			goto panic
-		}
+	if herr = this.router.RegisterService(service); herr != nil {
+		service.Close()
+		return herr
+	}
+	if herr = this.router.RegisterEntity(entity); herr != nil {
+		this.router.UnRegisterService(service)
+		service.Close()
+		return herr
+	}
+
+	this.servicesLock.Lock()
+	this.services[service.Name()] = service
+	this.servicesLock.Unlock()
+	return nil
+}
+
+// RemoveService 注销并关闭服务，之后对该服务的请求返回服务不可用
+func (this *ServerImplement) RemoveService(name string) *herrors.Error {
+	this.servicesLock.Lock()
+	service := this.services[name]
+	delete(this.services, name)
+	this.servicesLock.Unlock()
+
+	if service == nil {
+		return herrors.ErrCallerInvalidRequest.New("service %s not found", name)
+	}
+
+	this.router.UnRegisterService(service)
+	this.router.UnRegisterEntity(service.(IEntity))
+	this.drainServices([]IService{service})
+	service.Close()
+	hlogger.Info("service %s removed", name)
+	return nil
+}
 
-		this.services[entity.(IService).Name()] = service
+// drainServices 先停止分发新请求，再并发调用实现了IServiceDrainer的服务的Drain，最多等待DrainTimeout
+func (this *ServerImplement) drainServices(services []IService) {
+	timeout := this.currentConfig().DrainTimeout
+	if timeout <= 0 {
+		timeout = defaultDrainTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, s := range services {
+		this.router.DrainService(s.Name())
+		d, ok := s.(IServiceDrainer)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(s IService, d IServiceDrainer) {
+			defer wg.Done()
+			if err := d.Drain(ctx); err != nil {
+				hlogger.Warn("service %s drain: %s", s.Name(), err.Error())
+			}
+		}(s, d)
 	}
-	return
 
-panic:
-	panic(herr.D("failed to register service [%s] ", hruntime.GetObjectName(service)))
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		hlogger.Warn("services drain timeout after %d seconds", timeout)
+	}
 }
 
 func (this *ServerImplement) Slot(service string, slot string) *Slot {
+	this.servicesLock.RLock()
 	s := this.services[service]
+	this.servicesLock.RUnlock()
 	if s == nil {
 		return nil
 	}
@@ -211,19 +380,220 @@ func (this *ServerImplement) Slot(service string, slot string) *Slot {
 	return s.Slot(slot)
 }
 
-func (this *ServerImplement) RequestService(service string, slot string, params htypes.Map) (ret htypes.Any, err *herrors.Error) {
+func (this *ServerImplement) RequestService(service string, slot string, params htypes.Map) (htypes.Any, *herrors.Error) {
+	return this.RequestServiceContext(context.Background(), service, slot, params)
+}
+
+// RequestServiceContext 在ctx或配置的超时时间内等待服务返回，超时返回ErrSysTimeout。
+// 配置了重试策略时，暂时性错误按策略重试，所有重试都在同一个超时时间内完成。
+// ctx和参数中的请求ID互相补全，服务可通过RequestContext(params)取得带请求ID的ctx记录日志，
+// 该ctx在调用方取消(如客户端断开)或超时时取消，服务可据此中止耗时操作。
+// 服务内以RequestService发起的嵌套调用沿用同一请求的ctx。
+// 开启trace时为每次调用创建span，参数中的TraceParent更新为该span，服务以RequestContext(params)创建子span
+func (this *ServerImplement) RequestServiceContext(ctx context.Context, service string, slot string, params htypes.Map) (ret htypes.Any, err *herrors.Error) {
+	this.load.Begin()
+	defer this.load.End()
+	this.serviceLoad.inc(service)
+
+	if id := hlogger.RequestID(ctx); id != "" {
+		if params != nil && params[RequestIDField] == nil {
+			params[RequestIDField] = id
+		}
+	} else if id, ok := params[RequestIDField].(string); ok && id != "" {
+		ctx = hlogger.NewContext(ctx, id)
+	}
+	if ctx.Done() == nil {
+		if parent := lookupContext(params); parent != nil {
+			ctx = parent
+		}
+	}
+
+	if htrace.Enabled() {
+		var span trace.Span
+		ctx, span = this.startSpan(ctx, service, slot, params)
+		defer func() {
+			endSpan(span, err)
+		}()
+	}
+
+	if s := this.Slot(service, slot); s != nil && s.Singleflight {
+		return this.requestShared(ctx, s, service, slot, params)
+	}
+	return this.requestWithPolicy(ctx, service, slot, params)
+}
+
+// requestWithPolicy 按配置的超时时间和重试策略调用服务
+func (this *ServerImplement) requestWithPolicy(ctx context.Context, service string, slot string, params htypes.Map) (htypes.Any, *herrors.Error) {
+	//整个请求(包括重试)使用同一份配置
+	conf := this.currentConfig()
+	if timeout := conf.requestTimeout(service, slot); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	policy := this.retryPolicy(conf, service, slot)
+	for attempt := 1; ; attempt++ {
+		data, err := this.waitService(ctx, conf, service, slot, params)
+		if err == nil || attempt >= policy.MaxAttempts || !isTransient(err) || ctx.Err() != nil {
+			return data, err
+		}
+
+		//重试不能超过整个请求的超时时间
+		delay := policy.delay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return data, err
+		}
+
+		hlogger.WarnCtx(ctx, "service %s slot %s attempt %d failed: %s, retry after %s", service, slot, attempt, err.Error(), delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return data, err
+		}
+	}
+}
+
+// waitService 配置了隔离舱时先取得空位，空位在服务返回后释放，调用方不再等待时仍占用到服务返回
+func (this *ServerImplement) waitService(ctx context.Context, conf *Server, service string, slot string, params htypes.Map) (htypes.Any, *herrors.Error) {
+	//调用方已取消(如客户端已断开)时不再调用服务
+	if ctx.Err() != nil {
+		return nil, contextError(ctx, service, slot)
+	}
+	b := this.bulkhead(conf, service, slot)
+	if b != nil {
+		if err := b.acquire(ctx, service, slot); err != nil {
+			return nil, err
+		}
+	}
+	if ctx.Done() == nil {
+		if b != nil {
+			defer b.release()
+		}
+		return this.requestService(ctx, service, slot, params)
+	}
+
+	type result struct {
+		data htypes.Any
+		err  *herrors.Error
+	}
+	done := make(chan result, 1)
+	go func() {
+		if b != nil {
+			defer b.release()
+		}
+		data, err := this.requestService(ctx, service, slot, params)
+		done <- result{data: data, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-ctx.Done():
+		return nil, contextError(ctx, service, slot)
+	}
+}
+
+// contextError ctx超时返回ErrSysTimeout，被调用方取消返回ErrCallerInvalidRequest
+func contextError(ctx context.Context, service string, slot string) *herrors.Error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return herrors.ErrSysTimeout.New("service %s slot %s timeout", service, slot).D("request timeout")
+	}
+	return herrors.ErrCallerInvalidRequest.New("service %s slot %s canceled", service, slot).D("request canceled")
+}
+
+func (this *ServerImplement) requestService(ctx context.Context, service string, slot string, params htypes.Map) (ret htypes.Any, err *herrors.Error) {
+	//panic作为系统错误返回给调用方，避免被当作空的成功结果
 	if !hconf.IsDebug() {
 		defer func() {
 			e := recover()
 			if e != nil {
-				hlogger.Error(herrors.ErrSysInternal.New(e.(error).Error()))
+				ret = nil
+				err = herrors.ErrSysInternal.New("service %s slot %s panic: %v", service, slot, e).Trace().D("internal error")
+				hlogger.ErrorCtx(ctx, "%s, fingerprint %s", err.Error(), err.Fingerprint)
 			}
 		}()
 	}
 
+	defer bindContext(params, ctx)()
 	return this.router.RequestService(service, slot, params)
 }
 
+// RequestContext 返回携带参数中请求ID和trace上下文的ctx，用于hlogger的XxxCtx系列函数和创建子span。
+// 在本服务器内调用时，ctx随调用方取消或超时而取消
+func RequestContext(params htypes.Map) context.Context {
+	id, _ := params[RequestIDField].(string)
+	ctx := lookupContext(params)
+	if ctx == nil {
+		ctx = context.Background()
+		if id != "" {
+			ctx = hlogger.NewContext(ctx, id)
+		}
+	}
+	parent, _ := params[TraceParentField].(string)
+	state, _ := params[TraceStateField].(string)
+	return htrace.Extract(ctx, parent, state)
+}
+
+// startSpan ctx中没有span时以参数中的TraceParent为上级
+func (this *ServerImplement) startSpan(ctx context.Context, service string, slot string, params htypes.Map) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		parent, _ := params[TraceParentField].(string)
+		state, _ := params[TraceStateField].(string)
+		ctx = htrace.Extract(ctx, parent, state)
+	}
+
+	ctx, span := htrace.Tracer().Start(ctx, service+"/"+slot,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String("has.service", service),
+			attribute.String("has.slot", slot),
+		))
+	if params != nil {
+		if parent, state := htrace.Inject(ctx); parent != "" {
+			params[TraceParentField] = parent
+			if state != "" {
+				params[TraceStateField] = state
+			}
+		}
+	}
+	return ctx, span
+}
+
+func endSpan(span trace.Span, err *herrors.Error) {
+	if err != nil {
+		span.SetAttributes(attribute.Int("has.error_code", err.Code))
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(attribute.Int("has.error_code", herrors.ECodeOK))
+	}
+	span.End()
+}
+
+func (this *Server) requestTimeout(service string, slot string) time.Duration {
+	if t, ok := this.RequestTimeouts[service+"/"+slot]; ok {
+		return time.Duration(t) * time.Millisecond
+	}
+	if t, ok := this.RequestTimeouts[service]; ok {
+		return time.Duration(t) * time.Millisecond
+	}
+	return time.Duration(this.RequestTimeout) * time.Millisecond
+}
+
+// currentConfig 当前的配置快照，调用方不能修改。未发布快照时(如测试中直接构造)使用conf
+func (this *ServerImplement) currentConfig() *Server {
+	if conf, ok := this.settings.Load().(*Server); ok {
+		return conf
+	}
+	return &this.conf
+}
+
+// publishConfig 以conf的副本作为新的配置快照，调用方持有confLock或在启动时调用
+func (this *ServerImplement) publishConfig() {
+	conf := this.conf
+	this.settings.Store(&conf)
+}
+
 func (this *ServerImplement) waitForQuit() {
 	this.quitSignal = make(chan os.Signal)
 	signal.Notify(this.quitSignal,
@@ -240,21 +610,82 @@ func (this *ServerImplement) waitForQuit() {
 }
 
 func (this *ServerImplement) close() {
+	this.ready.Store(false)
+	hconf.Unwatch()
+
+	for _, h := range this.closeHooks {
+		h()
+	}
+
+	//connector关闭后排空并关闭服务，之后关闭router和plugins
+	if this.router != nil {
+		services := this.Services()
+		list := make([]IService, 0, len(services))
+		for _, s := range services {
+			list = append(list, s)
+		}
+		this.drainServices(list)
+		for _, s := range list {
+			s.Close()
+		}
+	}
+
 	if this.router != nil {
 		this.router.Close()
 	}
 	for _, p := range this.plugins {
 		p.Close()
 	}
+	htrace.Shutdown()
+}
+
+func (this *ServerImplement) addCloseHook(h func()) {
+	this.closeHooks = append(this.closeHooks, h)
+}
+
+// getLoad 服务请求的负载，以及goroutine数量和内存使用
+func (this *ServerImplement) getLoad(params htypes.Map) (htypes.Any, *herrors.Error) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	l := this.load.Load()
+	l.EntityMeta = *this.EntityMeta()
+	l.Goroutines = runtime.NumGoroutine()
+	l.MemoryAlloc = ms.Alloc
+	l.MemorySys = ms.Sys
+	l.Services = this.serviceLoad.snapshot()
+	l.Bulkheads = this.bulkheadLoads()
+	return l, nil
 }
 
-func (this *ServerImplement) newRequestNo() uint64 {
-	return this.requestNo.Add(1)
+// reloadConfig 配置文件中的配置节变化时，由对应实体的ResetConfig重新加载
+func (this *ServerImplement) reloadConfig(name string, section map[string]interface{}) {
+	this.router.ReloadEntityConfig(name, section)
 }
 
+// resetConfig 无参数时恢复缺省设置，否则按参数重新加载配置
 func (this *ServerImplement) resetConfig(ps htypes.Map) *herrors.Error {
-	this.conf.MaxProcs = 1
+	this.confLock.Lock()
+	defer this.confLock.Unlock()
+
+	if len(ps) == 0 {
+		this.conf.MaxProcs = 1
+		this.publishConfig()
 
-	hconf.Save()
+		hconf.Save()
+		return nil
+	}
+
+	var conf Server
+	if err := hconf.Decode(ps, &conf); err != nil {
+		return herrors.ErrCallerInvalidRequest.New(err.Error()).D("invalid config")
+	}
+	conf.EntityConfBase = this.conf.EntityConfBase
+
+	if conf.MaxProcs > 0 && conf.MaxProcs != this.conf.MaxProcs {
+		runtime.GOMAXPROCS(conf.MaxProcs)
+	}
+	this.conf = conf
+	this.publishConfig()
 	return nil
 }
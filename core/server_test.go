@@ -0,0 +1,170 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+type drainService struct {
+	Service
+	name    string
+	drained chan struct{}
+	block   bool
+}
+
+func (this *drainService) Name() string {
+	return this.name
+}
+
+func (this *drainService) Drain(ctx context.Context) *herrors.Error {
+	if this.block {
+		<-ctx.Done()
+		return herrors.ErrSysTimeout.New(ctx.Err().Error())
+	}
+	close(this.drained)
+	return nil
+}
+
+type plainService struct {
+	Service
+}
+
+func (this *plainService) Name() string {
+	return "plain"
+}
+
+func TestDrainServices(t *testing.T) {
+	router := &flightRouter{}
+	router.draining = make(map[string]bool)
+	s := &ServerImplement{router: router}
+	s.conf.DrainTimeout = 1
+
+	a := &drainService{name: "a", drained: make(chan struct{})}
+	s.drainServices([]IService{a, &plainService{}})
+	select {
+	case <-a.drained:
+	default:
+		t.Error("Drain not called")
+	}
+	if !router.Draining("a") || !router.Draining("plain") {
+		t.Error("services should be marked draining")
+	}
+
+	start := time.Now()
+	s.drainServices([]IService{&drainService{name: "b", block: true}})
+	if d := time.Since(start); d < time.Second || d > 2*time.Second {
+		t.Errorf("drain took %s, want about DrainTimeout", d)
+	}
+}
+
+type cancelRouter struct {
+	BaseRouter
+	server   *ServerImplement
+	canceled chan struct{}
+}
+
+func (this *cancelRouter) RequestService(service string, slot string, params htypes.Map) (htypes.Any, *herrors.Error) {
+	if service == "outer" {
+		return this.server.RequestService("inner", slot, params)
+	}
+	select {
+	case <-RequestContext(params).Done():
+		close(this.canceled)
+	case <-time.After(time.Second):
+	}
+	return nil, nil
+}
+
+func TestRequestContextCanceled(t *testing.T) {
+	router := &cancelRouter{canceled: make(chan struct{})}
+	s := &ServerImplement{router: router}
+	router.server = s
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	params := htypes.Map{RequestIDField: "r1"}
+	_, err := s.RequestServiceContext(ctx, "outer", "Slow", params)
+	if err == nil || err.Code != herrors.ECodeCallerInvalidRequest {
+		t.Errorf("err = %v, want canceled", err)
+	}
+	select {
+	case <-router.canceled:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("nested service not canceled")
+	}
+	token := ScopedString(params, ScopeContext)
+	for i := 0; lookupContext(htypes.Map{ScopeField: htypes.Map{ScopeContext: token}}) != nil; i++ {
+		if i == 50 {
+			t.Fatal("context still bound after request")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRequestContextSameRequestID(t *testing.T) {
+	router := &cancelRouter{canceled: make(chan struct{})}
+	s := &ServerImplement{router: router}
+	router.server = s
+
+	//另一个请求使用相同的请求ID，取消时不影响本请求
+	other, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	unbind := bindContext(htypes.Map{RequestIDField: "r2"}, other)
+	defer unbind()
+	cancel()
+
+	ctx, stop := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer stop()
+	start := time.Now()
+	_, _ = s.RequestServiceContext(ctx, "outer", "Slow", htypes.Map{RequestIDField: "r2"})
+	if d := time.Since(start); d < 150*time.Millisecond {
+		t.Errorf("request canceled by another request with the same id after %s", d)
+	}
+}
+
+func TestBuildInfo(t *testing.T) {
+	GitCommit = "abc123"
+	defer func() { GitCommit = "" }()
+
+	s := &ServerImplement{}
+	if info := s.BuildInfo(); info.GitCommit != "abc123" || info.GoVersion == "" || info.GOMAXPROCS <= 0 || info.Uptime != 0 {
+		t.Errorf("before start = %+v", info)
+	}
+	s.startTime = time.Now().Add(-time.Minute)
+	if info := s.BuildInfo(); info.Uptime < 60 {
+		t.Errorf("uptime = %d, want >= 60", info.Uptime)
+	}
+}
+
+func TestResetConfigSnapshot(t *testing.T) {
+	router := &flightRouter{release: make(chan struct{})}
+	close(router.release)
+	s := &ServerImplement{router: router}
+	s.conf.RequestTimeout = 1000
+	s.publishConfig()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = s.resetConfig(htypes.Map{"RequestTimeout": 1000 + i, "Retries": map[string]interface{}{"demo": map[string]interface{}{"MaxAttempts": 2}}})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if _, err := s.RequestService("demo", "List", htypes.Map{"q": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+
+	if conf := s.currentConfig(); conf.RequestTimeout != 1099 || conf.Retries["demo"].MaxAttempts != 2 {
+		t.Errorf("config = %+v", conf)
+	}
+}
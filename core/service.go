@@ -28,6 +28,9 @@ const (
 
 var validate *validator.Validate
 
+// SlotHandler 以函数实现的slot，通过AddSlot注册
+type SlotHandler func(ps htypes.Map, res *SlotResponse)
+
 type ServiceConf struct {
 	EntityConfBase
 
@@ -44,6 +47,7 @@ type Service struct {
 	slots            map[string]*Slot
 	slotHandlers     map[string]*MethodCaller
 	slotHandlerNames map[string]string
+	slotFuncs        map[string]SlotHandler       //AddSlot注册的slot
 	limiter          ratelimit.Limiter            //服务整体显示器
 	requestLimiters  map[string]ratelimit.Limiter //api限制器
 }
@@ -158,19 +162,53 @@ func (this *Service) Request(slot string, params htypes.Map) (htypes.Any, *herro
 	}
 
 	//处理传入参数
-	if err := this.checkParams(params, s.Params); err != nil {
+	if err := this.checkParams(params, s); err != nil {
 		return nil, err
 	}
 
 	//正式调用服务
 	switch s.Lang {
 	case NativeLang:
+		if f := this.slotFuncs[s.Impl]; f != nil {
+			var res SlotResponse
+			f(params, &res)
+			if res.Error != nil {
+				return nil, res.Error
+			}
+			return res.Data, nil
+		}
 		return this.callSlotHandler(string(s.Impl), params)
 	default:
 		return nil, herrors.ErrSysInternal.New("slot language %s not implemented", s.Lang)
 	}
 }
 
+// AddSlot 注册以函数实现的slot，如通用的增删改查slot，需在服务的Open中、Service.Open之后调用。
+// slot定义文件中已有同名slot时保留定义文件中的slot，服务以此覆盖单个slot，返回false
+func (this *Service) AddSlot(s Slot, handler SlotHandler) (bool, *herrors.Error) {
+	if s.Name == "" || handler == nil {
+		return false, herrors.ErrSysInternal.New("service [%s] slot name or handler not specified", this.class).D("failed to add slot")
+	}
+	if this.slots[s.Name] != nil {
+		return false, nil
+	}
+
+	s.Lang = NativeLang
+	s.Impl = s.Name
+	if err := compileSlot(&s); err != nil {
+		return false, err.D("failed to add service [%s] slot", this.class)
+	}
+	if this.slotFuncs == nil {
+		this.slotFuncs = make(map[string]SlotHandler)
+	}
+	if this.slots == nil {
+		this.slots = make(map[string]*Slot)
+	}
+	this.slotFuncs[s.Impl] = handler
+	this.slots[s.Name] = &s
+	return true, nil
+}
+
 func (this *Service) Response(res *SlotResponse, data htypes.Any, err *herrors.Error) {
 	res.Error = err
 	res.Data = data
@@ -208,6 +246,9 @@ func (this *Service) loadSlots() *herrors.Error {
 
 	this.slots = make(map[string]*Slot)
 	for i, s := range slots {
+		if err := compileSlot(&slots[i]); err != nil {
+			return err.D("failed to load service [%s] slot", this.class)
+		}
 		this.slots[s.Name] = &slots[i]
 	}
 
@@ -281,11 +322,15 @@ func (this *Service) callSlotHandler(slot string, data htypes.Any) (htypes.Any,
 	}
 }
 
-func (this *Service) checkParams(ps htypes.Map, def []SlotParam) *herrors.Error {
-	var v htypes.Any
+// checkParams 检查参数类型、Validator、Min/Max/Pattern/Enum和slot的Rules，所有不满足的条件汇总在一个ErrCallerInvalidRequest中返回，
+// 每个条件同时作为带消息ID的FieldError返回，用于逐个字段翻译
+func (this *Service) checkParams(ps htypes.Map, slot *Slot) *herrors.Error {
+	var errs []herrors.FieldError
 	var toDelNames []string
 	replaceParams := make(map[string]htypes.Any)
-	for _, p := range def {
+	for i := range slot.Params {
+		p := &slot.Params[i]
+		var v htypes.Any
 		if !p.CaseInSensitive {
 			v = ps[p.Name]
 		} else {
@@ -300,38 +345,62 @@ func (this *Service) checkParams(ps htypes.Map, def []SlotParam) *herrors.Error
 			}
 		}
 
+		if v == nil && p.Default != "" && p.Default[0] == '$' {
+			if v = ps[p.Default[1:]]; v != nil {
+				ps[p.Name] = v
+			}
+		}
 		if v == nil {
-			if p.Default != "" {
-				if p.Default[0] == '$' {
-					if v = ps[p.Default[1:]]; v != nil {
-						ps[p.Name] = v
-					} else {
-						continue
-					}
-				}
-			} else if p.Required == true {
-				return herrors.ErrCallerInvalidRequest.New("required parameter [%s] not found", p.Name)
-			} else {
-				continue
+			if p.Required && p.Default == "" {
+				errs = append(errs, herrors.FieldError{Field: p.Name, ID: MsgParamRequired, Message: fmt.Sprintf("required parameter [%s] not found", p.Name)})
 			}
+			continue
+		}
+
+		//查询参数、表单和header的值为字符串，按参数类型转换后交给服务
+		if cv, err := htypes.Coerce(v, p.Type); err != nil {
+			errs = append(errs, p.fieldError(MsgParamType, map[string]interface{}{"type": p.Type}, "%s", err.Error()))
+			continue
+		} else if _, ok := replaceParams[p.Name]; ok {
+			v, replaceParams[p.Name] = cv, cv
+		} else {
+			v, ps[p.Name] = cv, cv
 		}
 
 		if err := htypes.Validate(v, p.Type); err != nil {
-			return herrors.ErrCallerInvalidRequest.New(err.Error())
+			errs = append(errs, p.fieldError(MsgParamType, map[string]interface{}{"type": p.Type}, "%s", err.Error()))
+			continue
 		}
 
 		if p.Validator != "" {
 			if err := this.validateVar(v, p.Validator); err != nil {
-				return err
+				errs = append(errs, p.fieldError(MsgParamValidator, map[string]interface{}{"validator": p.Validator}, "%s", err.Cause))
+				continue
 			}
 		}
 
-		for _, k := range toDelNames {
-			delete(ps, k)
+		errs = append(errs, p.checkSchema(v)...)
+	}
+
+	for _, k := range toDelNames {
+		delete(ps, k)
+	}
+	for k, v := range replaceParams {
+		ps[k] = v
+	}
+
+	for _, r := range slot.rules {
+		if e := r.check(ps); e != nil {
+			errs = append(errs, *e)
 		}
-		for k, v := range replaceParams {
-			ps[k] = v
+	}
+
+	if len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, e := range errs {
+			msgs[i] = e.Message
 		}
+		return herrors.ErrCallerInvalidRequest.New(strings.Join(msgs, "; ")).D("invalid parameters").WithFields(errs...)
 	}
 	return nil
 }
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+type flightResult struct {
+	data htypes.Any
+	err  *herrors.Error
+}
+
+// requestShared slot定义了singleflight时，参数相同的并发请求只调用一次服务，共享同一结果。
+// 共享的结果不能被调用方修改；每个调用方在加入前单独授权，等待时受各自ctx控制
+func (this *ServerImplement) requestShared(ctx context.Context, s *Slot, service string, slot string, params htypes.Map) (htypes.Any, *herrors.Error) {
+	if this.authorizer != nil {
+		if err := this.authorizer.Authorize(service, s, params); err != nil {
+			return nil, err
+		}
+	}
+
+	key := singleflightKey(service, slot, s.SingleflightKeys, params)
+	ch := this.flights.DoChan(key, func() (interface{}, error) {
+		//首个调用方取消请求不影响其他等待的调用方
+		data, err := this.requestWithPolicy(detachedContext{ctx}, service, slot, params)
+		return flightResult{data: data, err: err}, nil
+	})
+
+	select {
+	case r := <-ch:
+		res := r.Val.(flightResult)
+		return res.data, res.err
+	case <-ctx.Done():
+		return nil, contextError(ctx, service, slot)
+	}
+}
+
+// singleflightKey keys为空时使用除请求ID和trace上下文以外的所有参数
+func singleflightKey(service string, slot string, keys []string, params htypes.Map) string {
+	ps := make(map[string]interface{})
+	if len(keys) == 0 {
+		for k, v := range params {
+			if k != RequestIDField && k != TraceParentField && k != TraceStateField {
+				ps[k] = v
+			}
+		}
+	} else {
+		for _, k := range keys {
+			ps[k] = params[k]
+		}
+	}
+	//fmt按key排序输出map
+	return fmt.Sprintf("%s/%s:%v", service, slot, ps)
+}
+
+// detachedContext 保留ctx中的值(如请求ID和trace)，但不随ctx取消
+type detachedContext struct {
+	parent context.Context
+}
+
+func (this detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (this detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (this detachedContext) Err() error {
+	return nil
+}
+
+func (this detachedContext) Value(key interface{}) interface{} {
+	return this.parent.Value(key)
+}
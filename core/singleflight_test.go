@@ -0,0 +1,85 @@
+package core
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+type flightService struct {
+	Service
+	slot Slot
+}
+
+func (this *flightService) Slot(slot string) *Slot {
+	return &this.slot
+}
+
+type flightRouter struct {
+	BaseRouter
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (this *flightRouter) RequestService(service string, slot string, params htypes.Map) (htypes.Any, *herrors.Error) {
+	this.calls.Inc()
+	<-this.release
+	return params["q"], nil
+}
+
+func TestRequestShared(t *testing.T) {
+	router := &flightRouter{release: make(chan struct{})}
+	s := &ServerImplement{
+		router:   router,
+		services: map[string]IService{"demo": &flightService{slot: Slot{Name: "List", Singleflight: true, SingleflightKeys: []string{"q"}}}},
+	}
+
+	var wg sync.WaitGroup
+	results := make([]htypes.Any, 6)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			q := "a"
+			if i == 0 {
+				q = "b"
+			}
+			results[i], _ = s.RequestService("demo", "List", htypes.Map{"q": q, "page": i})
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(router.release)
+	wg.Wait()
+
+	if n := router.calls.Load(); n != 2 {
+		t.Errorf("calls = %d, want 2", n)
+	}
+	for i, r := range results {
+		want := "a"
+		if i == 0 {
+			want = "b"
+		}
+		if r != want {
+			t.Errorf("result %d = %v, want %s", i, r, want)
+		}
+	}
+}
+
+func TestSingleflightKey(t *testing.T) {
+	a := singleflightKey("demo", "List", nil, htypes.Map{"q": "a", "n": 1, RequestIDField: "r1"})
+	b := singleflightKey("demo", "List", nil, htypes.Map{"n": 1, "q": "a", RequestIDField: "r2", TraceParentField: "00-x"})
+	if a != b {
+		t.Errorf("keys differ: %s, %s", a, b)
+	}
+	if a == singleflightKey("demo", "List", nil, htypes.Map{"q": "a", "n": 2}) {
+		t.Error("different params should not share key")
+	}
+	if singleflightKey("demo", "List", []string{"q"}, htypes.Map{"q": "a", "n": 1}) != singleflightKey("demo", "List", []string{"q"}, htypes.Map{"q": "a", "n": 2}) {
+		t.Error("params outside keys should be ignored")
+	}
+}
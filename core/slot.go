@@ -1,17 +1,32 @@
 package core
 
 import (
+	"regexp"
+	"time"
+
 	"github.com/drharryhe/has/common/herrors"
 	"github.com/drharryhe/has/common/htypes"
 )
 
 type Slot struct {
-	Name     string      `json:"name"`
-	Desc     string      `json:"-"`
-	Disabled bool        `json:"disabled"`
-	Params   []SlotParam `json:"params"`
-	Lang     string      `json:"lang"`
-	Impl     string      `json:"impl"`
+	Name             string      `json:"name"`
+	Desc             string      `json:"-"`
+	Disabled         bool        `json:"disabled"`
+	Params           []SlotParam `json:"params"`
+	Lang             string      `json:"lang"`
+	Impl             string      `json:"impl"`
+	NoRetry          bool        `json:"no_retry"`          //非幂等的slot设置为true，不论如何配置都不重试
+	Roles            []string    `json:"roles"`             //调用需要具有其中任一角色，为空表示不限制
+	Permissions      []string    `json:"permissions"`       //调用需要具有其中所有权限
+	Returns          []SlotParam `json:"returns"`           //返回数据的字段，只用于生成API文档，可不配置
+	Singleflight     bool        `json:"singleflight"`      //只读的slot可设置为true，参数相同的并发请求只调用一次，共享同一结果
+	SingleflightKeys []string    `json:"singleflight_keys"` //判断请求相同的参数，为空时使用除请求ID外的所有参数。只列出部分参数时应包含用户等区分数据范围的参数
+	Rules            []SlotRule  `json:"rules"`             //参数间的约束，如 endDate >= startDate，调用前检查
+	Deprecated       bool        `json:"deprecated"`        //已废弃，web connector的响应带 Deprecation header，网关定期记录调用次数
+	Sunset           string      `json:"sunset"`            //计划下线时间，格式为2006-01-02或RFC3339，响应带 Sunset header
+	Replacement      string      `json:"replacement"`       //替代的API，如 v2/user.list，响应带 Link header
+
+	rules []*slotRule
 }
 
 type SlotParam struct {
@@ -23,9 +38,31 @@ type SlotParam struct {
 	CaseInSensitive bool         `json:"case_insensitive"` //参数名是否大小写敏感
 	Validator       string       `json:"validator"`        //具体设置见：https://godoc.org/gopkg.in/go-playground/validator.v9，注意：required验证不需要，已经在Required中字段验证
 	Default         string       `json:"default"`          //缺省值，通常是从其他参数中提取值
+	Min             *float64     `json:"min"`              //数字的最小值，字符串的最少字符数，数组的最少元素数
+	Max             *float64     `json:"max"`              //数字的最大值，字符串的最多字符数，数组的最多元素数
+	Pattern         string       `json:"pattern"`          //字符串需匹配的正则表达式，数组检查每个元素
+	Enum            []htypes.Any `json:"enum"`             //允许的取值，数组检查每个元素
+
+	pattern *regexp.Regexp
 }
 
 type SlotResponse struct {
 	Error *herrors.Error
 	Data  interface{}
 }
+
+// SunsetTime 返回Sunset的时间，未设置或格式无效时返回零值
+func (this *Slot) SunsetTime() time.Time {
+	t, _ := parseSunset(this.Sunset)
+	return t
+}
+
+func parseSunset(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.UTC); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+// SlotNext 继续处理slot请求，依次调用后续的中间件和服务
+type SlotNext func(params htypes.Map) (htypes.Any, *herrors.Error)
+
+// SlotMiddleware slot中间件，在router授权后、服务处理前执行。
+// 调用next继续处理，可以在调用前修改参数、调用后处理结果，不调用next直接返回错误即中止请求
+type SlotMiddleware func(service string, slot *Slot, params htypes.Map, next SlotNext) (htypes.Any, *herrors.Error)
+
+type scopedSlotMiddleware struct {
+	scope      string //空表示全局，service 或 service/slot
+	middleware SlotMiddleware
+}
+
+// SlotMiddlewares 按注册顺序执行的slot中间件，全局和限定服务、slot的中间件按同一顺序排列
+type SlotMiddlewares struct {
+	lock  sync.RWMutex
+	items []scopedSlotMiddleware
+}
+
+// Use 注册中间件，scope为空或 * 时应用于所有slot，为 service 时应用于该服务的所有slot，为 service/slot 时只应用于该slot
+func (this *SlotMiddlewares) Use(scope string, mws ...SlotMiddleware) {
+	if scope == "*" {
+		scope = ""
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	for _, mw := range mws {
+		if mw != nil {
+			this.items = append(this.items, scopedSlotMiddleware{scope: scope, middleware: mw})
+		}
+	}
+}
+
+// Dispatch 依次执行应用于service/slot的中间件，最后调用h
+func (this *SlotMiddlewares) Dispatch(service string, slot *Slot, params htypes.Map, h SlotNext) (htypes.Any, *herrors.Error) {
+	var name string
+	if slot != nil {
+		name = slot.Name
+	}
+
+	this.lock.RLock()
+	var mws []SlotMiddleware
+	for _, item := range this.items {
+		if item.matches(service, name) {
+			mws = append(mws, item.middleware)
+		}
+	}
+	this.lock.RUnlock()
+
+	for i := len(mws) - 1; i >= 0; i-- {
+		mw, next := mws[i], h
+		h = func(ps htypes.Map) (htypes.Any, *herrors.Error) {
+			return mw(service, slot, ps, next)
+		}
+	}
+	return h(params)
+}
+
+func (this scopedSlotMiddleware) matches(service string, slot string) bool {
+	if this.scope == "" || this.scope == service {
+		return true
+	}
+	s, sl := this.scope, ""
+	if i := strings.Index(this.scope, "/"); i >= 0 {
+		s, sl = this.scope[:i], this.scope[i+1:]
+	}
+	return s == service && sl == slot
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+func TestSlotMiddlewares(t *testing.T) {
+	var trace []string
+	mark := func(name string) SlotMiddleware {
+		return func(service string, slot *Slot, params htypes.Map, next SlotNext) (htypes.Any, *herrors.Error) {
+			trace = append(trace, name)
+			return next(params)
+		}
+	}
+
+	var mws SlotMiddlewares
+	mws.Use("", mark("log"))
+	mws.Use("user", mark("user"))
+	mws.Use("user/Delete", func(service string, slot *Slot, params htypes.Map, next SlotNext) (htypes.Any, *herrors.Error) {
+		if params["admin"] != true {
+			return nil, herrors.ErrCallerForbidden.New("%s/%s requires admin", service, slot.Name)
+		}
+		return next(params)
+	})
+	mws.Use("*", func(service string, slot *Slot, params htypes.Map, next SlotNext) (htypes.Any, *herrors.Error) {
+		params["checked"] = true
+		ret, err := next(params)
+		if err == nil {
+			ret = htypes.Map{"wrapped": ret}
+		}
+		return ret, err
+	})
+
+	handler := func(ps htypes.Map) (htypes.Any, *herrors.Error) {
+		trace = append(trace, "handler")
+		return ps["checked"], nil
+	}
+
+	ret, err := mws.Dispatch("user", &Slot{Name: "List"}, htypes.Map{}, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := ret.(htypes.Map)["wrapped"]; v != true {
+		t.Fatalf("ret = %v", ret)
+	}
+	if s := strings.Join(trace, ","); s != "log,user,handler" {
+		t.Fatalf("trace = %s", s)
+	}
+
+	trace = nil
+	if _, err = mws.Dispatch("user", &Slot{Name: "Delete"}, htypes.Map{}, handler); err == nil || err.Code != herrors.ECodeCallerForbidden {
+		t.Fatalf("err = %v", err)
+	}
+	if s := strings.Join(trace, ","); s != "log,user" {
+		t.Fatalf("trace = %s", s)
+	}
+
+	trace = nil
+	if _, err = mws.Dispatch("order", &Slot{Name: "Delete"}, htypes.Map{}, handler); err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(trace, ","); s != "log,handler" {
+		t.Fatalf("trace = %s", s)
+	}
+}
@@ -0,0 +1,7 @@
+package hmsgpackpacker
+
+import "github.com/drharryhe/has/core"
+
+type MsgpackPacker struct {
+	core.EntityConfBase
+}
@@ -0,0 +1,102 @@
+package hmsgpackpacker
+
+import (
+	"bytes"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/utils/hruntime"
+)
+
+const (
+	MIME = "application/msgpack"
+)
+
+func New() *DataPacker {
+	return new(DataPacker)
+}
+
+type DataPacker struct {
+	core.BasePacker
+
+	conf MsgpackPacker
+}
+
+func (this *DataPacker) Config() core.IEntityConf {
+	return &this.conf
+}
+
+func (this *DataPacker) Marshal(data htypes.Any) ([]byte, *herrors.Error) {
+	if hruntime.IsNil(data) {
+		data = map[string]interface{}{}
+	}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	//与JSON打包器使用相同的字段名
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(data); err != nil {
+		return nil, herrors.ErrSysInternal.New(err.Error()).D("failed to marshal data")
+	}
+	return buf.Bytes(), nil
+}
+
+func (this *DataPacker) Unmarshal(data []byte) (htypes.Any, *herrors.Error) {
+	ret := make(map[string]interface{})
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	if err := dec.Decode(&ret); err != nil {
+		return nil, herrors.ErrSysInternal.New(err.Error()).D("failed to unmarshal data")
+	}
+
+	for k, v := range ret {
+		ret[k] = normalize(v)
+	}
+	return ret, nil
+}
+
+// normalize 将数值统一为float64，嵌套的map统一为map[string]interface{}，与JSON解析结果保持一致，服务无需区分数据格式
+func normalize(v interface{}) interface{} {
+	switch val := v.(type) {
+	case int8:
+		return float64(val)
+	case int16:
+		return float64(val)
+	case int32:
+		return float64(val)
+	case int64:
+		return float64(val)
+	case uint8:
+		return float64(val)
+	case uint16:
+		return float64(val)
+	case uint32:
+		return float64(val)
+	case uint64:
+		return float64(val)
+	case float32:
+		return float64(val)
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = normalize(item)
+		}
+		return val
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			if s, ok := k.(string); ok {
+				m[s] = normalize(item)
+			}
+		}
+		return m
+	case []interface{}:
+		for i, item := range val {
+			val[i] = normalize(item)
+		}
+		return val
+	}
+	return v
+}
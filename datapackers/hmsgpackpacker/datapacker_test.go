@@ -0,0 +1,35 @@
+package hmsgpackpacker
+
+import (
+	"testing"
+)
+
+func TestPacker(t *testing.T) {
+	p := New()
+
+	bs, err := p.Marshal(map[string]interface{}{
+		"name": "has",
+		"age":  18,
+		"tags": []interface{}{1, "a"},
+		"sub":  map[string]interface{}{"size": 1.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := p.Unmarshal(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := val.(map[string]interface{})
+	if m["name"] != "has" || m["age"] != float64(18) {
+		t.Fatalf("unexpected result %v", m)
+	}
+	if m["tags"].([]interface{})[0] != float64(1) {
+		t.Fatalf("unexpected tags %v", m["tags"])
+	}
+	if m["sub"].(map[string]interface{})["size"] != 1.5 {
+		t.Fatalf("unexpected sub %v", m["sub"])
+	}
+}
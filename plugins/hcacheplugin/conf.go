@@ -0,0 +1,19 @@
+package hcacheplugin
+
+import "github.com/drharryhe/has/core"
+
+type CachePlugin struct {
+	core.PluginConf
+
+	Backend      string // redis 或 memory，memory只用于本地开发，不能在多个进程间共享
+	Addr         string
+	Password     string
+	DB           int
+	PoolSize     int    // 连接池大小，0表示使用redis客户端的缺省值
+	MinIdleConns int    // 最少空闲连接数
+	DialTimeout  int    // ms
+	MaxRetries   int    // 连接断开等网络错误时的重试次数
+	Prefix       string // key前缀，多个应用共用redis时避免冲突
+	DefaultTTL   int    // seconds, Set未指定过期时间时使用，0表示不过期
+	Packer       string // 值的序列化方式，JsonPacker 或 MsgpackPacker
+}
@@ -0,0 +1,206 @@
+package hcacheplugin
+
+/// 分布式缓存plugin，后端为redis，本地开发时可使用内存
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/patrickmn/go-cache"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/datapackers/hjsonpacker"
+	"github.com/drharryhe/has/datapackers/hmsgpackpacker"
+)
+
+const (
+	BackendRedis  = "redis"
+	BackendMemory = "memory"
+
+	defaultAddr            = "localhost:6379"
+	defaultPacker          = "JsonPacker"
+	defaultCleanupDuration = 10 * 60 //seconds
+	valueField             = "v"     //打包器只支持map，值包装为 {"v": val} 后序列化
+)
+
+var plugin = &Plugin{}
+
+func New() *Plugin {
+	return plugin
+}
+
+type Plugin struct {
+	core.BasePlugin
+
+	conf   CachePlugin
+	store  store
+	packer core.IAPIDataPacker
+}
+
+func (this *Plugin) Open(s core.IServer, ins core.IPlugin) *herrors.Error {
+	if err := this.BasePlugin.Open(s, ins); err != nil {
+		return err
+	}
+
+	return this.open()
+}
+
+func (this *Plugin) open() *herrors.Error {
+	if this.conf.Backend == "" {
+		this.conf.Backend = BackendRedis
+	}
+	if this.conf.Addr == "" {
+		this.conf.Addr = defaultAddr
+	}
+	if this.conf.Packer == "" {
+		this.conf.Packer = defaultPacker
+	}
+
+	switch this.conf.Packer {
+	case "JsonPacker":
+		this.packer = hjsonpacker.New()
+	case "MsgpackPacker":
+		this.packer = hmsgpackpacker.New()
+	default:
+		return herrors.ErrSysInternal.New("packer [%s] not supported", this.conf.Packer).D("failed to open cache plugin")
+	}
+
+	switch this.conf.Backend {
+	case BackendRedis:
+		client := redis.NewClient(&redis.Options{
+			Addr:         this.conf.Addr,
+			Password:     this.conf.Password,
+			DB:           this.conf.DB,
+			PoolSize:     this.conf.PoolSize,
+			MinIdleConns: this.conf.MinIdleConns,
+			DialTimeout:  time.Duration(this.conf.DialTimeout) * time.Millisecond,
+			MaxRetries:   this.conf.MaxRetries,
+		})
+		//启动时redis不可用不影响服务启动，连接恢复后自动重连
+		if err := client.Ping(context.Background()).Err(); err != nil {
+			hlogger.Warn("cache plugin failed to connect redis %s: %s", this.conf.Addr, err.Error())
+		}
+		this.store = &redisStore{client: client}
+	case BackendMemory:
+		this.store = &memoryStore{cache: cache.New(cache.NoExpiration, defaultCleanupDuration*time.Second)}
+	default:
+		return herrors.ErrSysInternal.New("cache backend [%s] not supported", this.conf.Backend).D("failed to open cache plugin")
+	}
+
+	return nil
+}
+
+func (this *Plugin) Close() {
+	if this.store != nil {
+		if err := this.store.close(); err != nil {
+			hlogger.Warn("cache plugin close: %s", err.Error())
+		}
+	}
+}
+
+func (this *Plugin) Capability() htypes.Any {
+	return this
+}
+
+func (this *Plugin) Config() core.IEntityConf {
+	return &this.conf
+}
+
+func (this *Plugin) EntityStub() *core.EntityStub {
+	return core.NewEntityStub(
+		&core.EntityStubOptions{
+			Owner:       this,
+			Ping:        nil,
+			GetLoad:     nil,
+			ResetConfig: nil,
+		})
+}
+
+// Get 读取缓存，不存在时ok为false
+func (this *Plugin) Get(key string) (val htypes.Any, ok bool, err *herrors.Error) {
+	bs, ok, e := this.store.get(this.conf.Prefix + key)
+	if e != nil {
+		return nil, false, herrors.ErrSysInternal.New(e.Error()).D("failed to get cache")
+	}
+	if !ok {
+		return nil, false, nil
+	}
+
+	data, err := this.packer.Unmarshal(bs)
+	if err != nil {
+		return nil, false, err
+	}
+	m, _ := data.(map[string]interface{})
+	return m[valueField], true, nil
+}
+
+// Set 写入缓存，ttl为0时使用DefaultTTL
+func (this *Plugin) Set(key string, val htypes.Any, ttl time.Duration) *herrors.Error {
+	bs, err := this.packer.Marshal(htypes.Map{valueField: val})
+	if err != nil {
+		return err
+	}
+
+	if ttl <= 0 {
+		ttl = time.Duration(this.conf.DefaultTTL) * time.Second
+	}
+	if e := this.store.set(this.conf.Prefix+key, bs, ttl); e != nil {
+		return herrors.ErrSysInternal.New(e.Error()).D("failed to set cache")
+	}
+	return nil
+}
+
+// SetNX key不存在时写入缓存并返回true，可用作简单的分布式锁
+func (this *Plugin) SetNX(key string, val htypes.Any, ttl time.Duration) (bool, *herrors.Error) {
+	bs, err := this.packer.Marshal(htypes.Map{valueField: val})
+	if err != nil {
+		return false, err
+	}
+
+	if ttl <= 0 {
+		ttl = time.Duration(this.conf.DefaultTTL) * time.Second
+	}
+	ok, e := this.store.setNX(this.conf.Prefix+key, bs, ttl)
+	if e != nil {
+		return false, herrors.ErrSysInternal.New(e.Error()).D("failed to set cache")
+	}
+	return ok, nil
+}
+
+func (this *Plugin) Del(keys ...string) *herrors.Error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	ks := make([]string, len(keys))
+	for i, k := range keys {
+		ks[i] = this.conf.Prefix + k
+	}
+	if e := this.store.del(ks...); e != nil {
+		return herrors.ErrSysInternal.New(e.Error()).D("failed to delete cache")
+	}
+	return nil
+}
+
+// GetOrSet 缓存不存在时调用fn计算并写入缓存。缓存读写失败只记录日志，不影响返回fn的结果
+func (this *Plugin) GetOrSet(key string, ttl time.Duration, fn func() (htypes.Any, *herrors.Error)) (htypes.Any, *herrors.Error) {
+	val, ok, err := this.Get(key)
+	if err != nil {
+		hlogger.Warn("cache get %s: %s", key, err.Error())
+	} else if ok {
+		return val, nil
+	}
+
+	val, err = fn()
+	if err != nil {
+		return nil, err
+	}
+	if err = this.Set(key, val, ttl); err != nil {
+		hlogger.Warn("cache set %s: %s", key, err.Error())
+	}
+	return val, nil
+}
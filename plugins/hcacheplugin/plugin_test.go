@@ -0,0 +1,54 @@
+package hcacheplugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+func TestMemoryCache(t *testing.T) {
+	p := &Plugin{conf: CachePlugin{Backend: BackendMemory, Prefix: "t:"}}
+	if err := p.open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if err := p.Set("a", htypes.Map{"n": 1}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	val, ok, err := p.Get("a")
+	if err != nil || !ok {
+		t.Fatalf("get a: %v %v", ok, err)
+	}
+	if m, _ := val.(map[string]interface{}); m["n"] != float64(1) {
+		t.Errorf("get a = %v", val)
+	}
+
+	calls := 0
+	fn := func() (htypes.Any, *herrors.Error) {
+		calls++
+		return "v", nil
+	}
+	for i := 0; i < 2; i++ {
+		if v, err := p.GetOrSet("b", 0, fn); err != nil || v != "v" {
+			t.Fatalf("GetOrSet = %v, %v", v, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("fn called %d times, want 1", calls)
+	}
+
+	_ = p.Del("a", "b")
+	if _, ok, _ = p.Get("a"); ok {
+		t.Error("a should be deleted")
+	}
+
+	if ok, err = p.SetNX("lock", 1, time.Minute); err != nil || !ok {
+		t.Fatalf("SetNX = %v, %v", ok, err)
+	}
+	if ok, _ = p.SetNX("lock", 1, time.Minute); ok {
+		t.Error("SetNX succeeded on existing key")
+	}
+}
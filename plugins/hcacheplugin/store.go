@@ -0,0 +1,90 @@
+package hcacheplugin
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/patrickmn/go-cache"
+)
+
+// store 缓存后端，值为序列化后的数据
+type store interface {
+	get(key string) ([]byte, bool, error)
+	set(key string, val []byte, ttl time.Duration) error
+	setNX(key string, val []byte, ttl time.Duration) (bool, error)
+	del(keys ...string) error
+	close() error
+}
+
+// redisStore 连接池和断线重连由redis客户端处理
+type redisStore struct {
+	client *redis.Client
+}
+
+func (this *redisStore) get(key string) ([]byte, bool, error) {
+	bs, err := this.client.Get(context.Background(), key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return bs, true, nil
+}
+
+func (this *redisStore) set(key string, val []byte, ttl time.Duration) error {
+	return this.client.Set(context.Background(), key, val, ttl).Err()
+}
+
+func (this *redisStore) setNX(key string, val []byte, ttl time.Duration) (bool, error) {
+	return this.client.SetNX(context.Background(), key, val, ttl).Result()
+}
+
+func (this *redisStore) del(keys ...string) error {
+	return this.client.Del(context.Background(), keys...).Err()
+}
+
+func (this *redisStore) close() error {
+	return this.client.Close()
+}
+
+type memoryStore struct {
+	cache *cache.Cache
+}
+
+func (this *memoryStore) get(key string) ([]byte, bool, error) {
+	val, ok := this.cache.Get(key)
+	if !ok {
+		return nil, false, nil
+	}
+	return val.([]byte), true, nil
+}
+
+func (this *memoryStore) set(key string, val []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = cache.NoExpiration
+	}
+	this.cache.Set(key, val, ttl)
+	return nil
+}
+
+func (this *memoryStore) setNX(key string, val []byte, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		ttl = cache.NoExpiration
+	}
+	//go-cache的Add在key存在且未过期时返回错误
+	return this.cache.Add(key, val, ttl) == nil, nil
+}
+
+func (this *memoryStore) del(keys ...string) error {
+	for _, k := range keys {
+		this.cache.Delete(k)
+	}
+	return nil
+}
+
+func (this *memoryStore) close() error {
+	this.cache.Flush()
+	return nil
+}
@@ -0,0 +1,20 @@
+package hcronplugin
+
+import "github.com/drharryhe/has/core"
+
+type CronPlugin struct {
+	core.PluginConf
+
+	Jobs            []CronJob // 调用服务slot的定时任务，也可以在服务中通过AddFunc添加
+	ShutdownTimeout int       // seconds, 关闭时等待执行中任务的时间，缺省为 10
+}
+
+type CronJob struct {
+	Name         string
+	Schedule     string                 // cron表达式(分 时 日 月 周)，或 @daily、@every 5m 等
+	Service      string                 // 调用的服务
+	Slot         string                 // 调用的slot
+	Params       map[string]interface{} // 调用参数
+	AllowOverlap bool                   // 上一次未执行完时是否仍然执行，缺省跳过本次
+	Disabled     bool
+}
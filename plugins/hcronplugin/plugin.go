@@ -0,0 +1,242 @@
+package hcronplugin
+
+/// 定时任务plugin，按cron表达式或固定间隔执行函数或调用服务
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/utils/hcron"
+	"github.com/drharryhe/has/utils/hrandom"
+)
+
+const (
+	defaultShutdownTimeout = 10 //seconds
+)
+
+var plugin = &Plugin{}
+
+func New() *Plugin {
+	return plugin
+}
+
+// JobFunc 定时执行的函数，ctx携带本次执行的ID，插件关闭时取消
+type JobFunc func(ctx context.Context) *herrors.Error
+
+type job struct {
+	name         string
+	schedule     hcron.Schedule
+	allowOverlap bool
+	fn           JobFunc
+	running      atomic.Int32
+}
+
+type Plugin struct {
+	core.BasePlugin
+
+	conf    CronPlugin
+	lock    sync.Mutex
+	jobs    map[string]*job
+	started bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func (this *Plugin) Open(s core.IServer, ins core.IPlugin) *herrors.Error {
+	if err := this.BasePlugin.Open(s, ins); err != nil {
+		return err
+	}
+
+	if this.conf.ShutdownTimeout <= 0 {
+		this.conf.ShutdownTimeout = defaultShutdownTimeout
+	}
+	this.jobs = make(map[string]*job)
+	this.ctx, this.cancel = context.WithCancel(context.Background())
+
+	for _, j := range this.conf.Jobs {
+		if j.Disabled {
+			continue
+		}
+		if err := this.add(j.Name, j.Schedule, j.AllowOverlap, this.serviceJob(j)); err != nil {
+			return err.D("failed to open cron plugin")
+		}
+	}
+	return nil
+}
+
+// Start 由server启动后调用，开始调度已添加的任务
+func (this *Plugin) Start() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.started {
+		return
+	}
+	this.started = true
+	for _, j := range this.jobs {
+		this.schedule(j)
+	}
+}
+
+// Close 停止调度，取消执行中任务的ctx并等待其结束，最多等待ShutdownTimeout
+func (this *Plugin) Close() {
+	if this.cancel == nil {
+		return
+	}
+	this.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		this.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Duration(this.conf.ShutdownTimeout) * time.Second):
+		hlogger.Warn("cron plugin closed with jobs still running")
+	}
+}
+
+func (this *Plugin) Capability() htypes.Any {
+	return this
+}
+
+func (this *Plugin) Config() core.IEntityConf {
+	return &this.conf
+}
+
+func (this *Plugin) EntityStub() *core.EntityStub {
+	return core.NewEntityStub(
+		&core.EntityStubOptions{
+			Owner:       this,
+			Ping:        nil,
+			GetLoad:     nil,
+			ResetConfig: nil,
+		})
+}
+
+// AddFunc 添加定时执行的函数，名称不能重复。server启动前添加的任务在启动后开始调度。
+// allowOverlap为false时，上一次未执行完则跳过本次
+func (this *Plugin) AddFunc(name string, schedule string, allowOverlap bool, fn JobFunc) *herrors.Error {
+	return this.add(name, schedule, allowOverlap, fn)
+}
+
+// Remove 停止调度任务，执行中的任务不受影响
+func (this *Plugin) Remove(name string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	delete(this.jobs, name)
+}
+
+func (this *Plugin) add(name string, schedule string, allowOverlap bool, fn JobFunc) *herrors.Error {
+	if name == "" || fn == nil {
+		return herrors.ErrSysInternal.New("job name and func required")
+	}
+	sched, err := hcron.Parse(schedule)
+	if err != nil {
+		return herrors.ErrSysInternal.New("job %s: %s", name, err.Error())
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.jobs[name] != nil {
+		return herrors.ErrSysInternal.New("job name %s duplicated", name)
+	}
+	j := &job{name: name, schedule: sched, allowOverlap: allowOverlap, fn: fn}
+	this.jobs[name] = j
+	if this.started {
+		this.schedule(j)
+	}
+	return nil
+}
+
+// schedule 每个任务一个调度goroutine，任务被移除或插件关闭时退出
+func (this *Plugin) schedule(j *job) {
+	this.wg.Add(1)
+	go func() {
+		defer this.wg.Done()
+
+		for {
+			next := j.schedule.Next(time.Now())
+			if next.IsZero() {
+				hlogger.Warn("cron job %s has no next run time", j.name)
+				return
+			}
+
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-this.ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if !this.scheduled(j) {
+				return
+			}
+			if !j.allowOverlap && j.running.Load() > 0 {
+				hlogger.Warn("cron job %s skipped, previous run still running", j.name)
+				continue
+			}
+			this.wg.Add(1)
+			go this.run(j)
+		}
+	}()
+}
+
+func (this *Plugin) scheduled(j *job) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return this.jobs[j.name] == j
+}
+
+func (this *Plugin) run(j *job) {
+	defer this.wg.Done()
+	j.running.Inc()
+	defer j.running.Dec()
+
+	ctx := hlogger.NewContext(this.ctx, "cron-"+hrandom.UuidWithoutDash())
+	start := time.Now()
+	hlogger.InfoCtx(ctx, "cron job %s started", j.name)
+
+	var err *herrors.Error
+	func() {
+		defer func() {
+			if e := recover(); e != nil {
+				err = herrors.ErrSysInternal.New("cron job %s panic: %v", j.name, e).Trace()
+			}
+		}()
+		err = j.fn(ctx)
+	}()
+
+	if err != nil {
+		hlogger.ErrorCtx(ctx, "cron job %s failed after %s: %s", j.name, time.Since(start), err.Error())
+	} else {
+		hlogger.InfoCtx(ctx, "cron job %s finished in %s", j.name, time.Since(start))
+	}
+}
+
+// serviceJob 调用服务的任务，请求ID为本次执行的ID
+func (this *Plugin) serviceJob(j CronJob) JobFunc {
+	return func(ctx context.Context) *herrors.Error {
+		ps := make(htypes.Map, len(j.Params)+1)
+		for k, v := range j.Params {
+			ps[k] = v
+		}
+		ps[core.RequestIDField] = hlogger.RequestID(ctx)
+
+		_, err := this.Server().RequestServiceContext(ctx, j.Service, j.Slot, ps)
+		return err
+	}
+}
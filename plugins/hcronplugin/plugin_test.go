@@ -0,0 +1,85 @@
+package hcronplugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"github.com/drharryhe/has/common/herrors"
+)
+
+type tickSchedule time.Duration
+
+func (this tickSchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(this))
+}
+
+func newTestPlugin() *Plugin {
+	p := &Plugin{jobs: make(map[string]*job)}
+	p.conf.ShutdownTimeout = 1
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	return p
+}
+
+func TestSchedule(t *testing.T) {
+	p := newTestPlugin()
+
+	var slow, overlap atomic.Int32
+	release := make(chan struct{})
+	wait := func(ctx context.Context) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+	}
+	p.jobs["slow"] = &job{name: "slow", schedule: tickSchedule(10 * time.Millisecond), fn: func(ctx context.Context) *herrors.Error {
+		slow.Inc()
+		wait(ctx)
+		return nil
+	}}
+	p.jobs["overlap"] = &job{name: "overlap", schedule: tickSchedule(10 * time.Millisecond), allowOverlap: true, fn: func(ctx context.Context) *herrors.Error {
+		overlap.Inc()
+		wait(ctx)
+		return nil
+	}}
+
+	p.Start()
+	time.Sleep(100 * time.Millisecond)
+	if n := slow.Load(); n != 1 {
+		t.Errorf("slow job ran %d times while running, want 1", n)
+	}
+	if n := overlap.Load(); n < 2 {
+		t.Errorf("overlap job ran %d times, want >= 2", n)
+	}
+
+	p.Remove("overlap")
+	close(release)
+	time.Sleep(30 * time.Millisecond)
+	n := overlap.Load()
+	time.Sleep(30 * time.Millisecond)
+	if overlap.Load() != n {
+		t.Error("removed job still scheduled")
+	}
+
+	p.Close()
+	if n := slow.Load(); n < 2 {
+		t.Errorf("slow job ran %d times after release, want >= 2", n)
+	}
+}
+
+func TestAddFunc(t *testing.T) {
+	p := newTestPlugin()
+	fn := func(ctx context.Context) *herrors.Error { return nil }
+
+	if err := p.AddFunc("cleanup", "0 3 * * *", false, fn); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddFunc("cleanup", "@hourly", false, fn); err == nil {
+		t.Error("duplicated name should fail")
+	}
+	if err := p.AddFunc("warm", "every 5m", false, fn); err == nil {
+		t.Error("invalid schedule should fail")
+	}
+}
@@ -0,0 +1,10 @@
+package heventplugin
+
+import "github.com/drharryhe/has/core"
+
+type EventPlugin struct {
+	core.PluginConf
+
+	QueueSize       int // 每个订阅等待处理的事件数上限，超过时丢弃新事件，缺省为 1024
+	ShutdownTimeout int // seconds, 关闭时等待已发布事件处理完的时间，缺省为 10
+}
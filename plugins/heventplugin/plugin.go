@@ -0,0 +1,217 @@
+package heventplugin
+
+/// 进程内事件总线plugin，服务之间通过主题发布和订阅事件，不依赖消息中间件
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/utils/hrandom"
+)
+
+const (
+	defaultQueueSize       = 1024
+	defaultShutdownTimeout = 10 //seconds
+)
+
+var plugin = &Plugin{}
+
+func New() *Plugin {
+	return plugin
+}
+
+// Event 发布的事件，ID同时作为处理时ctx的请求ID
+type Event struct {
+	ID      string
+	Topic   string
+	Payload htypes.Any
+	Time    time.Time
+}
+
+// Handler 事件处理函数，ctx携带事件ID，插件关闭超时时取消。panic被恢复并记录日志，不影响其他订阅
+type Handler func(ctx context.Context, e *Event)
+
+// Subscription 一个订阅，每个订阅按发布顺序在独立的goroutine中处理事件，处理慢的订阅不影响其他订阅
+type Subscription struct {
+	topic   string
+	handler Handler
+	queue   chan *Event
+	owner   *Plugin
+	dropped atomic.Uint64
+}
+
+type Plugin struct {
+	core.BasePlugin
+
+	conf   EventPlugin
+	lock   sync.RWMutex
+	subs   map[string][]*Subscription
+	closed bool
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func (this *Plugin) Open(s core.IServer, ins core.IPlugin) *herrors.Error {
+	if err := this.BasePlugin.Open(s, ins); err != nil {
+		return err
+	}
+
+	this.init()
+	return nil
+}
+
+func (this *Plugin) init() {
+	if this.conf.QueueSize <= 0 {
+		this.conf.QueueSize = defaultQueueSize
+	}
+	if this.conf.ShutdownTimeout <= 0 {
+		this.conf.ShutdownTimeout = defaultShutdownTimeout
+	}
+	this.subs = make(map[string][]*Subscription)
+	this.closed = false
+	this.ctx, this.cancel = context.WithCancel(context.Background())
+}
+
+// Close 不再接受发布，等待已发布的事件处理完，最多等待ShutdownTimeout，之后取消处理中事件的ctx
+func (this *Plugin) Close() {
+	this.lock.Lock()
+	if this.closed || this.cancel == nil {
+		this.lock.Unlock()
+		return
+	}
+	this.closed = true
+	for _, subs := range this.subs {
+		for _, s := range subs {
+			close(s.queue)
+		}
+	}
+	this.subs = nil
+	this.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		this.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Duration(this.conf.ShutdownTimeout) * time.Second):
+		hlogger.Warn("event plugin closed with events still being handled")
+	}
+	this.cancel()
+}
+
+func (this *Plugin) Capability() htypes.Any {
+	return this
+}
+
+func (this *Plugin) Config() core.IEntityConf {
+	return &this.conf
+}
+
+func (this *Plugin) EntityStub() *core.EntityStub {
+	return core.NewEntityStub(
+		&core.EntityStubOptions{
+			Owner:       this,
+			Ping:        nil,
+			GetLoad:     nil,
+			ResetConfig: nil,
+		})
+}
+
+// Subscribe 订阅主题，同一主题可以有多个订阅，每个订阅都会收到该主题的所有事件。插件关闭后返回错误
+func (this *Plugin) Subscribe(topic string, handler Handler) (*Subscription, *herrors.Error) {
+	if topic == "" || handler == nil {
+		return nil, herrors.ErrSysInternal.New("event topic and handler required")
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.closed {
+		return nil, herrors.ErrSysUnavailable.New("event plugin closed").D("failed to subscribe %s", topic)
+	}
+	s := &Subscription{
+		topic:   topic,
+		handler: handler,
+		queue:   make(chan *Event, this.conf.QueueSize),
+		owner:   this,
+	}
+	this.subs[topic] = append(this.subs[topic], s)
+
+	this.wg.Add(1)
+	go s.run(this.ctx)
+	return s, nil
+}
+
+// Publish 异步发布事件，不等待订阅处理，返回事件ID。订阅的队列已满时该订阅丢弃此事件并记录日志
+func (this *Plugin) Publish(topic string, payload htypes.Any) string {
+	e := &Event{ID: hrandom.UuidWithoutDash(), Topic: topic, Payload: payload, Time: time.Now()}
+
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	if this.closed {
+		hlogger.Warn("event %s %s dropped, event plugin closed", topic, e.ID)
+		return e.ID
+	}
+	for _, s := range this.subs[topic] {
+		select {
+		case s.queue <- e:
+		default:
+			s.dropped.Inc()
+			hlogger.Warn("event %s %s dropped, subscription queue full", topic, e.ID)
+		}
+	}
+	return e.ID
+}
+
+// Unsubscribe 取消订阅，已在队列中的事件仍会处理
+func (this *Subscription) Unsubscribe() {
+	p := this.owner
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	subs := p.subs[this.topic]
+	for i, s := range subs {
+		if s == this {
+			p.subs[this.topic] = append(subs[:i:i], subs[i+1:]...)
+			close(this.queue)
+			break
+		}
+	}
+	if len(p.subs[this.topic]) == 0 {
+		delete(p.subs, this.topic)
+	}
+}
+
+// Dropped 因队列已满丢弃的事件数
+func (this *Subscription) Dropped() uint64 {
+	return this.dropped.Load()
+}
+
+func (this *Subscription) run(ctx context.Context) {
+	defer this.owner.wg.Done()
+
+	for e := range this.queue {
+		this.handle(ctx, e)
+	}
+}
+
+func (this *Subscription) handle(ctx context.Context, e *Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			err := herrors.ErrSysInternal.New("event %s %s handler panic: %v", e.Topic, e.ID, r).Trace()
+			hlogger.Error("%s, fingerprint %s", err.Error(), err.Fingerprint)
+		}
+	}()
+	this.handler(hlogger.NewContext(ctx, e.ID), e)
+}
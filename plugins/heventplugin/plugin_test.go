@@ -0,0 +1,116 @@
+package heventplugin
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drharryhe/has/common/hlogger"
+)
+
+func newTestPlugin(queueSize int) *Plugin {
+	p := &Plugin{}
+	p.conf.QueueSize = queueSize
+	p.conf.ShutdownTimeout = 1
+	p.init()
+	return p
+}
+
+func TestPublish(t *testing.T) {
+	p := newTestPlugin(0)
+
+	var lock sync.Mutex
+	var got []interface{}
+	var ids []string
+	if _, err := p.Subscribe("user.locked", func(ctx context.Context, e *Event) {
+		lock.Lock()
+		defer lock.Unlock()
+		got = append(got, e.Payload)
+		ids = append(ids, hlogger.RequestID(ctx))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	//panic的订阅不影响其他订阅和后续事件
+	_, _ = p.Subscribe("user.locked", func(ctx context.Context, e *Event) {
+		panic("boom")
+	})
+
+	id := p.Publish("user.locked", "u1")
+	p.Publish("user.locked", "u2")
+	p.Publish("user.created", "u3")
+	p.Close()
+
+	if len(got) != 2 || got[0] != "u1" || got[1] != "u2" {
+		t.Errorf("received %v, want [u1 u2]", got)
+	}
+	if ids[0] != id {
+		t.Errorf("ctx request id = %s, want event id %s", ids[0], id)
+	}
+	if _, err := p.Subscribe("user.locked", func(ctx context.Context, e *Event) {}); err == nil {
+		t.Error("subscribe after close should fail")
+	}
+}
+
+func TestSubscription(t *testing.T) {
+	p := newTestPlugin(1)
+	defer p.Close()
+
+	release := make(chan struct{})
+	received := make(chan string, 10)
+	s, _ := p.Subscribe("report", func(ctx context.Context, e *Event) {
+		<-release
+		received <- e.Payload.(string)
+	})
+
+	//第一个事件处理中，第二个在队列中，第三个被丢弃
+	p.Publish("report", "a")
+	time.Sleep(20 * time.Millisecond)
+	p.Publish("report", "b")
+	p.Publish("report", "c")
+	if n := s.Dropped(); n != 1 {
+		t.Errorf("dropped = %d, want 1", n)
+	}
+
+	s.Unsubscribe()
+	p.Publish("report", "d")
+	close(release)
+	var got []string
+	for len(got) < 2 {
+		select {
+		case v := <-received:
+			got = append(got, v)
+		case <-time.After(time.Second):
+			t.Fatalf("received %v, want [a b]", got)
+		}
+	}
+	if got[0] != "a" || got[1] != "b" {
+		t.Errorf("received %v, want [a b]", got)
+	}
+	select {
+	case v := <-received:
+		t.Errorf("received %s after unsubscribe", v)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestCloseTimeout(t *testing.T) {
+	p := newTestPlugin(0)
+	canceled := make(chan struct{})
+	_, _ = p.Subscribe("slow", func(ctx context.Context, e *Event) {
+		<-ctx.Done()
+		close(canceled)
+	})
+	p.Publish("slow", nil)
+
+	start := time.Now()
+	p.Close()
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("handler ctx not canceled after shutdown timeout")
+	}
+	if d := time.Since(start); d < time.Second {
+		t.Errorf("closed after %s, want to wait ShutdownTimeout", d)
+	}
+}
@@ -0,0 +1,24 @@
+package hflagplugin
+
+import "github.com/drharryhe/has/core"
+
+type FlagPlugin struct {
+	core.PluginConf
+
+	Flags      []Flag   // 修改后随配置文件重新加载，无需重启
+	RolloutKey []string // 按比例开启时用于分桶的上下文字段，依次取第一个有值的字段，缺省为 ["user", "tenant"]
+}
+
+type Flag struct {
+	Name       string
+	Enabled    bool       // 总开关，关闭时忽略Rules和Percentage
+	Rules      []FlagRule // 按顺序匹配，第一个匹配的规则决定结果
+	Percentage int        // 1-99时按RolloutKey分桶，只对该比例开启，同一上下文的结果固定；其他值表示全部开启
+}
+
+// FlagRule 上下文中Attribute的值为Values之一时，结果为Enabled，如 tenant 为 acme 时开启
+type FlagRule struct {
+	Attribute string
+	Values    []string
+	Enabled   bool
+}
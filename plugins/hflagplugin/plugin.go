@@ -0,0 +1,207 @@
+package hflagplugin
+
+/// 功能开关plugin，开关在配置文件中定义，修改后随配置重新加载，可按租户、用户等上下文规则或按比例开启
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+
+	"github.com/drharryhe/has/common/hconf"
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+)
+
+var (
+	plugin = &Plugin{}
+
+	defaultRolloutKey = []string{"user", "tenant"}
+)
+
+func New() *Plugin {
+	return plugin
+}
+
+type Plugin struct {
+	core.BasePlugin
+
+	conf      FlagPlugin
+	lock      sync.RWMutex
+	flags     map[string]*Flag //配置文件中的开关
+	overrides map[string]*Flag //SetFlag设置的开关
+}
+
+func (this *Plugin) Open(s core.IServer, ins core.IPlugin) *herrors.Error {
+	if err := this.BasePlugin.Open(s, ins); err != nil {
+		return err
+	}
+	this.overrides = make(map[string]*Flag)
+	if err := this.load(this.conf); err != nil {
+		return err.D("failed to open flag plugin")
+	}
+	return nil
+}
+
+func (this *Plugin) Capability() htypes.Any {
+	return this
+}
+
+func (this *Plugin) Config() core.IEntityConf {
+	return &this.conf
+}
+
+func (this *Plugin) EntityStub() *core.EntityStub {
+	return core.NewEntityStub(
+		&core.EntityStubOptions{
+			Owner:       this,
+			Ping:        nil,
+			GetLoad:     nil,
+			ResetConfig: this.resetConfig,
+		})
+}
+
+// resetConfig 配置文件变化时重新加载开关，SetFlag设置的开关不受影响
+func (this *Plugin) resetConfig(ps htypes.Map) *herrors.Error {
+	var conf FlagPlugin
+	if err := hconf.Decode(ps, &conf); err != nil {
+		return herrors.ErrCallerInvalidRequest.New(err.Error()).D("invalid config")
+	}
+	conf.EntityConfBase = this.conf.EntityConfBase
+	return this.load(conf)
+}
+
+// load 检查并替换全部开关，有错误时保留原来的开关
+func (this *Plugin) load(conf FlagPlugin) *herrors.Error {
+	if len(conf.RolloutKey) == 0 {
+		conf.RolloutKey = defaultRolloutKey
+	}
+
+	flags := make(map[string]*Flag)
+	for i := range conf.Flags {
+		f := &conf.Flags[i]
+		if err := checkFlag(f); err != nil {
+			return err
+		}
+		if flags[f.Name] != nil {
+			return herrors.ErrSysInternal.New("duplicated flag %s", f.Name).D("invalid flag")
+		}
+		flags[f.Name] = f
+	}
+
+	this.lock.Lock()
+	this.conf = conf
+	this.flags = flags
+	this.lock.Unlock()
+	return nil
+}
+
+func checkFlag(f *Flag) *herrors.Error {
+	if f.Name == "" {
+		return herrors.ErrSysInternal.New("flag name not specified").D("invalid flag")
+	}
+	for _, r := range f.Rules {
+		if r.Attribute == "" || len(r.Values) == 0 {
+			return herrors.ErrSysInternal.New("flag %s: rule attribute or values not specified", f.Name).D("invalid flag")
+		}
+	}
+	return nil
+}
+
+// IsEnabled 开关在上下文ctx中是否开启，ctx可以直接使用slot的参数，如 {"tenant": "acme", "user": "u1"}。
+// 未定义的开关返回false；按比例开启时ctx中没有RolloutKey字段也返回false
+func (this *Plugin) IsEnabled(name string, ctx htypes.Map) bool {
+	this.lock.RLock()
+	f := this.overrides[name]
+	if f == nil {
+		f = this.flags[name]
+	}
+	keys := this.conf.RolloutKey
+	this.lock.RUnlock()
+
+	if f == nil || !f.Enabled {
+		return false
+	}
+	for _, r := range f.Rules {
+		if r.match(ctx) {
+			return r.Enabled
+		}
+	}
+	if f.Percentage <= 0 || f.Percentage >= 100 {
+		return true
+	}
+
+	for _, k := range keys {
+		if v := ctxValue(ctx, k); v != "" {
+			return bucket(f.Name, v) < f.Percentage
+		}
+	}
+	return false
+}
+
+// SetFlag 运行时设置开关，如从数据库或管理接口加载的开关，优先于配置文件中的同名开关，配置重新加载后仍然有效
+func (this *Plugin) SetFlag(f Flag) *herrors.Error {
+	if err := checkFlag(&f); err != nil {
+		return err
+	}
+	this.lock.Lock()
+	this.overrides[f.Name] = &f
+	this.lock.Unlock()
+	return nil
+}
+
+// RemoveFlag 删除SetFlag设置的开关，恢复使用配置文件中的设置
+func (this *Plugin) RemoveFlag(name string) {
+	this.lock.Lock()
+	delete(this.overrides, name)
+	this.lock.Unlock()
+}
+
+// Flags 当前生效的开关，按名称排序
+func (this *Plugin) Flags() []Flag {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	var ret []Flag
+	for name, f := range this.flags {
+		if this.overrides[name] == nil {
+			ret = append(ret, *f)
+		}
+	}
+	for _, f := range this.overrides {
+		ret = append(ret, *f)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+func (this *FlagRule) match(ctx htypes.Map) bool {
+	v := ctxValue(ctx, this.Attribute)
+	if v == "" {
+		return false
+	}
+	for _, val := range this.Values {
+		if val == v {
+			return true
+		}
+	}
+	return false
+}
+
+func ctxValue(ctx htypes.Map, key string) string {
+	v, ok := ctx[key]
+	if !ok || v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// bucket 按开关名和上下文取0-99的桶号，同一上下文在不同开关中的桶号不同
+func bucket(flag string, key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(flag + ":" + key))
+	return int(h.Sum32() % 100)
+}
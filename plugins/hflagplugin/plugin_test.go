@@ -0,0 +1,94 @@
+package hflagplugin
+
+import (
+	"testing"
+
+	"github.com/drharryhe/has/common/htypes"
+)
+
+func newTestPlugin(t *testing.T, flags ...Flag) *Plugin {
+	p := &Plugin{overrides: make(map[string]*Flag)}
+	if err := p.load(FlagPlugin{Flags: flags}); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestIsEnabled(t *testing.T) {
+	p := newTestPlugin(t,
+		Flag{Name: "on", Enabled: true},
+		Flag{Name: "off", Enabled: false, Rules: []FlagRule{{Attribute: "tenant", Values: []string{"acme"}, Enabled: true}}},
+		Flag{Name: "beta", Enabled: true, Percentage: 30, Rules: []FlagRule{
+			{Attribute: "tenant", Values: []string{"acme", "globex"}, Enabled: true},
+			{Attribute: "user", Values: []string{"42"}, Enabled: false},
+		}},
+	)
+
+	cases := []struct {
+		flag string
+		ctx  htypes.Map
+		want bool
+	}{
+		{"on", nil, true},
+		{"missing", nil, false},
+		{"off", htypes.Map{"tenant": "acme"}, false},
+		{"beta", htypes.Map{"tenant": "acme", "user": 42}, true},
+		{"beta", htypes.Map{"tenant": "other", "user": 42}, false},
+		{"beta", htypes.Map{}, false},
+	}
+	for _, c := range cases {
+		if got := p.IsEnabled(c.flag, c.ctx); got != c.want {
+			t.Errorf("IsEnabled(%s, %v) = %v, want %v", c.flag, c.ctx, got, c.want)
+		}
+	}
+
+	//按比例开启，同一用户结果固定
+	n := 0
+	for i := 0; i < 1000; i++ {
+		ctx := htypes.Map{"user": i + 100}
+		got := p.IsEnabled("beta", ctx)
+		if got != p.IsEnabled("beta", ctx) {
+			t.Fatalf("user %d: result not stable", i)
+		}
+		if got {
+			n++
+		}
+	}
+	if n < 250 || n > 350 {
+		t.Errorf("%d of 1000 users enabled, want about 300", n)
+	}
+}
+
+func TestReload(t *testing.T) {
+	p := newTestPlugin(t, Flag{Name: "a", Enabled: true})
+
+	err := p.resetConfig(htypes.Map{"Flags": []interface{}{
+		map[string]interface{}{"Name": "a", "Enabled": false},
+		map[string]interface{}{"Name": "b", "Enabled": true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.IsEnabled("a", nil) || !p.IsEnabled("b", nil) {
+		t.Error("flags not reloaded")
+	}
+
+	//SetFlag优先于配置文件，重新加载后仍然有效
+	_ = p.SetFlag(Flag{Name: "a", Enabled: true})
+	_ = p.resetConfig(htypes.Map{"Flags": []interface{}{map[string]interface{}{"Name": "a"}}})
+	if !p.IsEnabled("a", nil) || p.IsEnabled("b", nil) {
+		t.Error("override lost after reload")
+	}
+	p.RemoveFlag("a")
+	if p.IsEnabled("a", nil) {
+		t.Error("override not removed")
+	}
+
+	//无效的配置不生效
+	if err = p.resetConfig(htypes.Map{"Flags": []interface{}{map[string]interface{}{"Name": ""}}}); err == nil {
+		t.Error("invalid flag accepted")
+	}
+	if len(p.Flags()) != 1 || p.Flags()[0].Name != "a" {
+		t.Errorf("Flags = %v", p.Flags())
+	}
+}
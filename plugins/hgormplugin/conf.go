@@ -5,16 +5,19 @@ import "github.com/drharryhe/has/core"
 type GormPlugin struct {
 	core.PluginConf
 
-	DBServer       string
-	DBPort         int
-	DBType         string
-	DBName         string
-	DBUser         string
-	DBPwd          string
-	DBMaxOpenConns int
-	DBMaxIdleConns int
-	DBReset        bool
-	DBInitDB       bool
-	DBDataDir      string
-	DBInitAfter    int
+	DBServer          string
+	DBPort            int
+	DBType            string
+	DBName            string
+	DBUser            string
+	DBPwd             string
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime int // seconds, 连接的最长使用时间，0表示不限制
+	DBConnMaxIdleTime int // seconds, 连接的最长空闲时间，0表示不限制
+	DBPingTimeout     int // seconds, Ping检查数据库连接的超时，缺省为 3
+	DBReset           bool
+	DBInitDB          bool
+	DBDataDir         string
+	DBInitAfter       int
 }
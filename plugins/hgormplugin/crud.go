@@ -0,0 +1,549 @@
+package hgormplugin
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hpaging"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+)
+
+const (
+	CRUDCreate = "create"
+	CRUDGet    = "get"
+	CRUDUpdate = "update"
+	CRUDDelete = "delete"
+	CRUDList   = "list"
+
+	crudSortParam = "sort"
+)
+
+var (
+	crudOperations = []string{CRUDCreate, CRUDGet, CRUDUpdate, CRUDDelete, CRUDList}
+
+	//过滤参数的后缀，如 age_gte=18，不带后缀时为等于
+	crudFilterOps = map[string]string{
+		"_ne":   "<>",
+		"_gt":   ">",
+		"_gte":  ">=",
+		"_lt":   "<",
+		"_lte":  "<=",
+		"_like": "LIKE",
+		"_in":   "IN",
+	}
+
+	//缺省不可写入的字段，由gorm维护
+	crudTimeFields = map[string]bool{"CreatedAt": true, "UpdatedAt": true, "DeletedAt": true}
+)
+
+// CRUDOptions 通用增删改查slot的设置，字段均为模型的JSON字段名
+type CRUDOptions struct {
+	Name         string   //slot名中的模型名，如 User 生成 createUser、getUser、updateUser、deleteUser、listUser，缺省为模型的类型名
+	Operations   []string //生成的操作 create、get、update、delete、list，缺省为全部
+	Fields       []string //create和update可写入的字段，缺省为除主键、VersionField和时间字段外的所有字段
+	Filters      []string //list可过滤的字段，参数 status=1 为等于，可加后缀 _ne _gt _gte _lt _lte _like _in，如 age_gte=18、id_in=1,2
+	Sorts        []string //list可排序的字段，参数 sort=-created_at,id，-表示降序，主键总是可以排序
+	DefaultSort  string   //list缺省的排序，如 -created_at，缺省按主键降序
+	MaxPageSize  int      //list每页最大数量，缺省为hpaging.DefaultMaxPageSize
+	VersionField string   //乐观锁字段，如 version，update和delete需提交读取时的版本，记录已被修改时返回ErrCallerConflict
+}
+
+// CRUD 按GORM模型生成增删改查slot。模型带有DeletedAt字段时delete为软删除，get、update和list忽略已删除的记录。
+// 服务可在slot定义文件中定义同名slot覆盖单个操作，并在实现中调用Create、Get等方法复用缺省处理
+type CRUD struct {
+	opts     CRUDOptions
+	db       *gorm.DB
+	typ      reflect.Type
+	fields   map[string]*crudField //JSON字段名 -> 字段
+	primary  *crudField
+	version  *crudField
+	writable map[string]bool
+	filters  map[string]bool
+	sorts    map[string]bool
+}
+
+type crudField struct {
+	name   string //JSON字段名
+	column string
+	index  []int
+}
+
+type crudCondition struct {
+	query string
+	args  []interface{}
+}
+
+// CRUD 使用插件的数据库连接创建增删改查slot，model为模型结构体的指针
+func (this *Plugin) CRUD(model interface{}, opts CRUDOptions) (*CRUD, *herrors.Error) {
+	return NewCRUD(this.db, model, opts)
+}
+
+// NewCRUD 服务在Open中创建并Mount，如
+//
+//	crud, err := hgormplugin.NewCRUD(db, &User{}, hgormplugin.CRUDOptions{Name: "User", Filters: []string{"status"}})
+//	err = crud.Mount(&this.Service)
+func NewCRUD(db *gorm.DB, model interface{}, opts CRUDOptions) (*CRUD, *herrors.Error) {
+	typ := reflect.TypeOf(model)
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return nil, herrors.ErrSysInternal.New("crud model should be a pointer to struct, got %T", model).D("invalid crud model")
+	}
+	typ = typ.Elem()
+	if opts.Name == "" {
+		opts.Name = typ.Name()
+	}
+	if len(opts.Operations) == 0 {
+		opts.Operations = crudOperations
+	}
+
+	this := &CRUD{
+		opts:     opts,
+		db:       db,
+		typ:      typ,
+		fields:   make(map[string]*crudField),
+		writable: make(map[string]bool),
+	}
+	var auto []string //缺省可写入的字段
+	this.parseFields(typ, nil, &auto)
+	if this.primary == nil {
+		return nil, herrors.ErrSysInternal.New("crud model %s has no primary key", typ.Name()).D("invalid crud model")
+	}
+
+	var err *herrors.Error
+	if opts.VersionField != "" {
+		if this.version = this.fields[opts.VersionField]; this.version == nil {
+			return nil, herrors.ErrSysInternal.New("crud model %s: version field %s not found", typ.Name(), opts.VersionField).D("invalid crud model")
+		}
+	}
+	if len(opts.Fields) == 0 {
+		for _, f := range auto {
+			if this.version == nil || f != this.version.name {
+				this.writable[f] = true
+			}
+		}
+	} else if this.writable, err = this.fieldSet(opts.Fields); err != nil {
+		return nil, err
+	}
+	if this.filters, err = this.fieldSet(opts.Filters); err != nil {
+		return nil, err
+	}
+	if this.sorts, err = this.fieldSet(opts.Sorts); err != nil {
+		return nil, err
+	}
+	if _, err = this.order(htypes.Map{}); err != nil {
+		return nil, herrors.ErrSysInternal.New("crud model %s: invalid default sort %s", typ.Name(), opts.DefaultSort).D("invalid crud model")
+	}
+	for _, op := range opts.Operations {
+		if !this.supported(op) {
+			return nil, herrors.ErrSysInternal.New("crud model %s: operation %s not supported", typ.Name(), op).D("invalid crud model")
+		}
+	}
+	return this, nil
+}
+
+// parseFields 按encoding/json的规则取字段名，展开嵌入的结构体(如gorm.Model)，json为 - 的字段不能读写
+func (this *CRUD) parseFields(typ reflect.Type, index []int, auto *[]string) {
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		idx := append(append([]int{}, index...), i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		tag := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if sf.Anonymous && tag == "" && sf.Type.Kind() == reflect.Struct {
+			this.parseFields(sf.Type, idx, auto)
+			continue
+		}
+		if tag == "-" {
+			continue
+		}
+
+		settings := crudTagSettings(sf.Tag)
+		if _, ok := settings["-"]; ok {
+			continue
+		}
+		f := &crudField{name: tag, column: settings["COLUMN"], index: idx}
+		if f.name == "" {
+			f.name = sf.Name
+		}
+		if f.column == "" {
+			f.column = gorm.ToColumnName(sf.Name)
+		}
+		this.fields[f.name] = f
+
+		_, primary := settings["PRIMARY_KEY"]
+		if primary || (this.primary == nil && sf.Name == "ID") {
+			this.primary = f
+		}
+		if !primary && sf.Name != "ID" && !crudTimeFields[sf.Name] {
+			*auto = append(*auto, f.name)
+		}
+	}
+}
+
+// crudTagSettings 解析gorm标签，如 `gorm:"column:user_name;primary_key"`，key转为大写
+func crudTagSettings(tag reflect.StructTag) map[string]string {
+	ret := make(map[string]string)
+	for _, item := range strings.Split(tag.Get("gorm"), ";") {
+		kv := strings.SplitN(item, ":", 2)
+		key := strings.ToUpper(strings.TrimSpace(kv[0]))
+		if key == "" {
+			continue
+		}
+		if len(kv) == 2 {
+			ret[key] = strings.TrimSpace(kv[1])
+		} else {
+			ret[key] = key
+		}
+	}
+	return ret
+}
+
+func (this *CRUD) fieldSet(names []string) (map[string]bool, *herrors.Error) {
+	ret := make(map[string]bool)
+	for _, name := range names {
+		if this.fields[name] == nil {
+			return nil, herrors.ErrSysInternal.New("crud model %s: field %s not found", this.typ.Name(), name).D("invalid crud model")
+		}
+		ret[name] = true
+	}
+	return ret, nil
+}
+
+func (this *CRUD) supported(op string) bool {
+	for _, o := range crudOperations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// SlotName 操作对应的slot名，如 createUser
+func (this *CRUD) SlotName(op string) string {
+	return op + this.opts.Name
+}
+
+// Mount 为服务注册Operations中的slot，slot定义文件中已有的同名slot保持不变。需在服务的Open中、Service.Open之后调用
+func (this *CRUD) Mount(s *core.Service) *herrors.Error {
+	handlers := map[string]core.SlotHandler{
+		CRUDCreate: this.Create,
+		CRUDGet:    this.Get,
+		CRUDUpdate: this.Update,
+		CRUDDelete: this.Delete,
+		CRUDList:   this.List,
+	}
+	for _, op := range this.opts.Operations {
+		slot := core.Slot{Name: this.SlotName(op), Desc: fmt.Sprintf("%s %s", op, this.opts.Name)}
+		if op == CRUDCreate {
+			slot.NoRetry = true
+		}
+		if _, err := s.AddSlot(slot, handlers[op]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create 以Fields中的参数创建记录，返回创建的记录
+func (this *CRUD) Create(ps htypes.Map, res *core.SlotResponse) {
+	obj := reflect.New(this.typ)
+	if err := this.decode(this.values(ps), obj.Interface()); err != nil {
+		res.Error = err
+		return
+	}
+	if e := this.db.Create(obj.Interface()).Error; e != nil {
+		res.Error = herrors.ErrSysInternal.New(e.Error())
+		return
+	}
+	res.Data = obj.Interface()
+}
+
+// Get 按主键返回记录
+func (this *CRUD) Get(ps htypes.Map, res *core.SlotResponse) {
+	id, err := this.id(ps)
+	if err != nil {
+		res.Error = err
+		return
+	}
+	res.Data, res.Error = this.find(id)
+}
+
+// Update 按主键更新参数中提交的字段，返回更新后的记录
+func (this *CRUD) Update(ps htypes.Map, res *core.SlotResponse) {
+	id, err := this.id(ps)
+	if err != nil {
+		res.Error = err
+		return
+	}
+	vals := this.values(ps)
+	if len(vals) == 0 {
+		res.Error = herrors.ErrCallerInvalidRequest.New("no field to update").D("invalid parameters")
+		return
+	}
+	obj := reflect.New(this.typ)
+	if err = this.decode(vals, obj.Interface()); err != nil {
+		res.Error = err
+		return
+	}
+	updates := make(map[string]interface{})
+	for name := range vals {
+		f := this.fields[name]
+		updates[f.column] = obj.Elem().FieldByIndex(f.index).Interface()
+	}
+
+	query, err := this.versioned(this.db.Model(reflect.New(this.typ).Interface()).Where(this.primary.column+" = ?", id), ps)
+	if err != nil {
+		res.Error = err
+		return
+	}
+	if this.version != nil {
+		updates[this.version.column] = gorm.Expr(this.version.column + " + 1")
+	}
+	r := query.Updates(updates)
+	if r.Error != nil {
+		res.Error = herrors.ErrSysInternal.New(r.Error.Error())
+		return
+	}
+	if r.RowsAffected == 0 {
+		res.Error = this.missing(id)
+		return
+	}
+	res.Data, res.Error = this.find(id)
+}
+
+// Delete 按主键删除记录，模型带有DeletedAt字段时为软删除
+func (this *CRUD) Delete(ps htypes.Map, res *core.SlotResponse) {
+	id, err := this.id(ps)
+	if err != nil {
+		res.Error = err
+		return
+	}
+	query, err := this.versioned(this.db.Where(this.primary.column+" = ?", id), ps)
+	if err != nil {
+		res.Error = err
+		return
+	}
+	r := query.Delete(reflect.New(this.typ).Interface())
+	if r.Error != nil {
+		res.Error = herrors.ErrSysInternal.New(r.Error.Error())
+		return
+	}
+	if r.RowsAffected == 0 {
+		res.Error = this.missing(id)
+	}
+}
+
+// List 按Filters过滤、按sort参数排序，分页返回hpaging.List
+func (this *CRUD) List(ps htypes.Map, res *core.SlotResponse) {
+	paging, err := hpaging.Parse(ps, this.opts.MaxPageSize)
+	if err != nil {
+		res.Error = err
+		return
+	}
+	conds, err := this.conditions(ps)
+	if err != nil {
+		res.Error = err
+		return
+	}
+	order, err := this.order(ps)
+	if err != nil {
+		res.Error = err
+		return
+	}
+
+	query := this.db.Model(reflect.New(this.typ).Interface())
+	for _, c := range conds {
+		query = query.Where(c.query, c.args...)
+	}
+	var total int64
+	if e := query.Count(&total).Error; e != nil {
+		res.Error = herrors.ErrSysInternal.New(e.Error())
+		return
+	}
+
+	items := reflect.New(reflect.SliceOf(this.typ))
+	if e := query.Order(order).Limit(paging.Limit).Offset(paging.Offset).Find(items.Interface()).Error; e != nil {
+		res.Error = herrors.ErrSysInternal.New(e.Error())
+		return
+	}
+	res.Data = hpaging.NewList(items.Elem().Interface(), total, paging)
+}
+
+func (this *CRUD) id(ps htypes.Map) (interface{}, *herrors.Error) {
+	id := ps[this.primary.name]
+	if id == nil || id == "" {
+		return nil, herrors.ErrCallerInvalidRequest.New("required parameter [%s] not found", this.primary.name).D("invalid parameters")
+	}
+	return id, nil
+}
+
+// versioned 配置了VersionField时要求参数中的版本与记录一致
+func (this *CRUD) versioned(query *gorm.DB, ps htypes.Map) (*gorm.DB, *herrors.Error) {
+	if this.version == nil {
+		return query, nil
+	}
+	v := ps[this.version.name]
+	if v == nil {
+		return nil, herrors.ErrCallerInvalidRequest.New("required parameter [%s] not found", this.version.name).D("invalid parameters")
+	}
+	return query.Where(this.version.column+" = ?", v), nil
+}
+
+func (this *CRUD) find(id interface{}) (htypes.Any, *herrors.Error) {
+	obj := reflect.New(this.typ)
+	if e := this.db.Where(this.primary.column+" = ?", id).First(obj.Interface()).Error; e != nil {
+		if e == gorm.ErrRecordNotFound {
+			return nil, herrors.ErrCallerInvalidRequest.New("%s %v not found", this.opts.Name, id).D("record not found")
+		}
+		return nil, herrors.ErrSysInternal.New(e.Error())
+	}
+	return obj.Interface(), nil
+}
+
+// missing 更新或删除的记录数为0时，区分记录不存在和版本不一致
+func (this *CRUD) missing(id interface{}) *herrors.Error {
+	var n int64
+	if e := this.db.Model(reflect.New(this.typ).Interface()).Where(this.primary.column+" = ?", id).Count(&n).Error; e != nil {
+		return herrors.ErrSysInternal.New(e.Error())
+	}
+	if n == 0 {
+		return herrors.ErrCallerInvalidRequest.New("%s %v not found", this.opts.Name, id).D("record not found")
+	}
+	return herrors.ErrCallerConflict.New("%s %v modified by others", this.opts.Name, id).D("record modified")
+}
+
+// values 参数中可写入的字段
+func (this *CRUD) values(ps htypes.Map) map[string]interface{} {
+	ret := make(map[string]interface{})
+	for name := range this.writable {
+		if v, ok := ps[name]; ok {
+			ret[name] = v
+		}
+	}
+	return ret
+}
+
+// decode 按JSON字段名写入模型，字符串与数值、布尔值之间自动转换，字符串按RFC3339转换为time.Time
+func (this *CRUD) decode(vals map[string]interface{}, out interface{}) *herrors.Error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToTimeHookFunc(time.RFC3339),
+		),
+		WeaklyTypedInput: true,
+		TagName:          "json",
+		Squash:           true,
+		Result:           out,
+	})
+	if err != nil {
+		return herrors.ErrSysInternal.New(err.Error())
+	}
+	if err = decoder.Decode(vals); err != nil {
+		return herrors.ErrCallerInvalidRequest.New(err.Error()).D("invalid parameters")
+	}
+	return nil
+}
+
+// conditions 将参数中的过滤条件转为查询条件，按参数名排序
+func (this *CRUD) conditions(ps htypes.Map) ([]crudCondition, *herrors.Error) {
+	var keys []string
+	for k := range ps {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var ret []crudCondition
+	for _, k := range keys {
+		name, op := k, "="
+		if !this.filters[k] {
+			for suffix, o := range crudFilterOps {
+				if strings.HasSuffix(k, suffix) && this.filters[strings.TrimSuffix(k, suffix)] {
+					name, op = strings.TrimSuffix(k, suffix), o
+					break
+				}
+			}
+			if op == "=" {
+				continue
+			}
+		}
+
+		column, v := this.fields[name].column, ps[k]
+		switch op {
+		case "IN":
+			vals := crudList(v)
+			if len(vals) == 0 {
+				return nil, herrors.ErrCallerInvalidRequest.New("parameter [%s] should not be empty", k).D("invalid parameters")
+			}
+			ret = append(ret, crudCondition{query: column + " IN (?)", args: []interface{}{vals}})
+		case "LIKE":
+			//以!转义，MySQL和PostgreSQL对反斜杠的处理不同
+			s := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(fmt.Sprint(v))
+			ret = append(ret, crudCondition{query: column + " LIKE ? ESCAPE '!'", args: []interface{}{"%" + s + "%"}})
+		default:
+			ret = append(ret, crudCondition{query: fmt.Sprintf("%s %s ?", column, op), args: []interface{}{v}})
+		}
+	}
+	return ret, nil
+}
+
+// crudList _in的参数可以是数组或逗号分隔的字符串
+func crudList(v interface{}) []interface{} {
+	if s, ok := v.(string); ok {
+		var ret []interface{}
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				ret = append(ret, item)
+			}
+		}
+		return ret
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return []interface{}{v}
+	}
+	ret := make([]interface{}, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		ret = append(ret, rv.Index(i).Interface())
+	}
+	return ret
+}
+
+// order 按sort参数或DefaultSort排序，最后按主键排序保证分页稳定
+func (this *CRUD) order(ps htypes.Map) (string, *herrors.Error) {
+	s, _ := ps[crudSortParam].(string)
+	custom := s != ""
+	if !custom {
+		s = this.opts.DefaultSort
+	}
+
+	var items []string
+	primary := false
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		dir := " ASC"
+		if strings.HasPrefix(item, "-") {
+			item, dir = item[1:], " DESC"
+		}
+		if item == "" {
+			continue
+		}
+		f := this.fields[item]
+		if f == nil || (custom && !this.sorts[item] && f != this.primary) {
+			return "", herrors.ErrCallerInvalidRequest.New("cannot sort by %s", item).D("invalid parameters")
+		}
+		primary = primary || f == this.primary
+		items = append(items, f.column+dir)
+	}
+	if !primary {
+		items = append(items, this.primary.column+" DESC")
+	}
+	return strings.Join(items, ", "), nil
+}
@@ -0,0 +1,101 @@
+package hgormplugin
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+)
+
+type crudItem struct {
+	gorm.Model
+	Name    string `json:"name"`
+	Age     int    `json:"age"`
+	Code    string `json:"code" gorm:"column:item_code"`
+	Version int    `json:"version"`
+	Secret  string `json:"-"`
+}
+
+func TestNewCRUD(t *testing.T) {
+	c, err := NewCRUD(nil, &crudItem{}, CRUDOptions{Name: "Item", Filters: []string{"name", "age"}, Sorts: []string{"age"}, VersionField: "version"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.primary == nil || c.primary.column != "id" || c.fields["code"].column != "item_code" || c.fields["Secret"] != nil {
+		t.Errorf("fields = %+v", c.fields)
+	}
+	if want := map[string]bool{"name": true, "age": true, "code": true}; !reflect.DeepEqual(c.writable, want) {
+		t.Errorf("writable = %v, want %v", c.writable, want)
+	}
+
+	for _, opts := range []CRUDOptions{
+		{Filters: []string{"missing"}},
+		{VersionField: "missing"},
+		{DefaultSort: "-missing"},
+		{Operations: []string{"upsert"}},
+	} {
+		if _, err := NewCRUD(nil, &crudItem{}, opts); err == nil {
+			t.Errorf("options %+v should be invalid", opts)
+		}
+	}
+	if _, err := NewCRUD(nil, crudItem{}, CRUDOptions{}); err == nil {
+		t.Error("model should be a pointer")
+	}
+}
+
+func TestCRUDQuery(t *testing.T) {
+	c, _ := NewCRUD(nil, &crudItem{}, CRUDOptions{Filters: []string{"name", "age"}, Sorts: []string{"age"}, DefaultSort: "-code"})
+
+	conds, err := c.conditions(htypes.Map{"name_like": "50%", "age_gte": "18", "age_in": "1, 2", "age": 3, "code": "x", "page": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []crudCondition{
+		{query: "age = ?", args: []interface{}{3}},
+		{query: "age >= ?", args: []interface{}{"18"}},
+		{query: "age IN (?)", args: []interface{}{[]interface{}{"1", "2"}}},
+		{query: "name LIKE ? ESCAPE '!'", args: []interface{}{"%50!%%"}},
+	}
+	if !reflect.DeepEqual(conds, want) {
+		t.Errorf("conditions = %+v, want %+v", conds, want)
+	}
+
+	for sort, want := range map[string]string{
+		"":         "item_code DESC, id DESC",
+		"-age":     "age DESC, id DESC",
+		"age, -ID": "age ASC, id DESC",
+	} {
+		if order, err := c.order(htypes.Map{"sort": sort}); err != nil || order != want {
+			t.Errorf("sort %q: order = %q, %v, want %q", sort, order, err, want)
+		}
+	}
+	if _, err := c.order(htypes.Map{"sort": "name"}); err == nil {
+		t.Error("sort by name should be rejected")
+	}
+}
+
+func TestCRUDMount(t *testing.T) {
+	c, _ := NewCRUD(nil, &crudItem{}, CRUDOptions{Name: "Item", Operations: []string{CRUDGet}})
+	var s core.Service
+	if err := c.Mount(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Slot("getItem") == nil || s.Slot("listItem") != nil {
+		t.Fatalf("slots = %v", s.SlotNames())
+	}
+
+	//主键参数在访问数据库前检查
+	_, err := s.Request("getItem", htypes.Map{})
+	if err == nil || err.Code != herrors.ECodeCallerInvalidRequest {
+		t.Errorf("err = %v, want ErrCallerInvalidRequest", err)
+	}
+
+	//已有的slot不被覆盖
+	if ok, _ := s.AddSlot(core.Slot{Name: "getItem"}, c.List); ok {
+		t.Error("existing slot replaced")
+	}
+}
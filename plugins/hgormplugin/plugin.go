@@ -3,6 +3,7 @@ package hgormplugin
 /// 关系数据库访问plugin
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -22,7 +23,8 @@ import (
 )
 
 const (
-	defaultDBInitAfter = 30 * time.Second
+	defaultDBInitAfter   = 30 * time.Second
+	defaultDBPingTimeout = 3 //seconds
 )
 
 var plugin = &Plugin{}
@@ -105,6 +107,15 @@ LOOP:
 	if d := this.conf.DBMaxIdleConns; d > 0 {
 		this.db.DB().SetMaxIdleConns(d)
 	}
+	if d := this.conf.DBConnMaxLifetime; d > 0 {
+		this.db.DB().SetConnMaxLifetime(time.Duration(d) * time.Second)
+	}
+	if d := this.conf.DBConnMaxIdleTime; d > 0 {
+		this.db.DB().SetConnMaxIdleTime(time.Duration(d) * time.Second)
+	}
+	if this.conf.DBPingTimeout <= 0 {
+		this.conf.DBPingTimeout = defaultDBPingTimeout
+	}
 
 	this.objects = make(htypes.Map)
 
@@ -130,6 +141,31 @@ func (this *Plugin) Capability() htypes.Any {
 	return this.db
 }
 
+// DB 连接池由插件统一配置，服务通过 Server().Plugin("GormPlugin") 获取插件后使用
+func (this *Plugin) DB() *gorm.DB {
+	return this.db
+}
+
+// ping 检查数据库连接，失败时服务器的就绪检查返回未就绪
+func (this *Plugin) ping(params htypes.Map) (htypes.Any, *herrors.Error) {
+	if this.db == nil {
+		return false, herrors.ErrSysUnavailable.New("database not opened")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(this.conf.DBPingTimeout)*time.Second)
+	defer cancel()
+	if err := this.db.DB().PingContext(ctx); err != nil {
+		return false, herrors.ErrSysUnavailable.New(err.Error()).D("database unavailable")
+	}
+
+	stats := this.db.DB().Stats()
+	return htypes.Map{
+		"open":   stats.OpenConnections,
+		"in_use": stats.InUse,
+		"idle":   stats.Idle,
+	}, nil
+}
+
 func (this *Plugin) createDatabase() *herrors.Error {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8", this.conf.DBUser, this.conf.DBPwd, this.conf.DBServer, this.conf.DBPort, this.conf.DBType)
 	db, err := sql.Open(this.conf.DBType, dsn)
@@ -233,7 +269,7 @@ func (this *Plugin) EntityStub() *core.EntityStub {
 	return core.NewEntityStub(
 		&core.EntityStubOptions{
 			Owner:       this,
-			Ping:        nil,
+			Ping:        this.ping,
 			GetLoad:     nil,
 			ResetConfig: nil,
 		})
@@ -0,0 +1,24 @@
+package hmailplugin
+
+import "github.com/drharryhe/has/core"
+
+type MailPlugin struct {
+	core.PluginConf
+
+	Provider      string // smtp | sendgrid | capture，capture只记录邮件不发送，用于测试和开发环境，缺省为 smtp
+	From          string // 发件人地址
+	FromName      string // 发件人名称
+	TemplateDir   string // 模板目录，模板<id>由 <id>.subject、<id>.html、<id>.txt 组成，subject和html、txt之一必须存在
+	MaxAttempts   int    // 每封邮件最多发送次数，缺省为 3，地址无效等永久错误不重试
+	RetryInterval int    // milliseconds, 第一次重试的间隔，之后每次翻倍，缺省为 1000
+	Timeout       int    // seconds, 每次发送的超时时长，缺省为 10
+
+	SMTPHost        string // 也可以使用SES等服务的SMTP接口
+	SMTPPort        int    // 缺省为 587
+	SMTPUser        string
+	SMTPPassword    string
+	SMTPImplicitTLS bool // 以TLS连接(通常为465端口)，否则在服务器支持时使用STARTTLS
+
+	APIKey string // SendGrid的API key
+	APIURL string // 缺省为 https://api.sendgrid.com/v3/mail/send
+}
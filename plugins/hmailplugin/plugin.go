@@ -0,0 +1,316 @@
+package hmailplugin
+
+/// 事务邮件plugin，按模板渲染邮件，通过SMTP或SendGrid发送，失败时按指数退避重试。capture模式只记录邮件，用于测试
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+)
+
+const (
+	ProviderSMTP     = "smtp"
+	ProviderSendGrid = "sendgrid"
+	ProviderCapture  = "capture" //只记录邮件，不发送
+
+	defaultMaxAttempts   = 3
+	defaultRetryInterval = 1000 //milliseconds
+	defaultTimeout       = 10   //seconds
+	defaultSMTPPort      = 587
+	defaultAPIURL        = "https://api.sendgrid.com/v3/mail/send"
+
+	subjectExt = ".subject"
+	htmlExt    = ".html"
+	textExt    = ".txt"
+)
+
+var plugin = &Plugin{}
+
+func New() *Plugin {
+	return plugin
+}
+
+// Message 一封邮件，HTML和Text至少一个不为空，都不为空时作为multipart/alternative发送
+type Message struct {
+	To       []string
+	Subject  string
+	HTML     string
+	Text     string
+	Template string //模板ID，直接发送的邮件为空
+}
+
+type mailTemplate struct {
+	subject *template.Template
+	html    *htmltemplate.Template
+	text    *template.Template
+}
+
+// sender 发送一封邮件，返回的错误为永久错误时(如地址无效)不重试
+type sender interface {
+	send(msg *Message) (permanent bool, err error)
+}
+
+type Plugin struct {
+	core.BasePlugin
+
+	conf      MailPlugin
+	sender    sender
+	lock      sync.RWMutex
+	templates map[string]*mailTemplate
+	sent      []*Message //capture模式下记录的邮件
+}
+
+func (this *Plugin) Open(s core.IServer, ins core.IPlugin) *herrors.Error {
+	if err := this.BasePlugin.Open(s, ins); err != nil {
+		return err
+	}
+	return this.open()
+}
+
+func (this *Plugin) open() *herrors.Error {
+	if this.conf.Provider == "" {
+		this.conf.Provider = ProviderSMTP
+	}
+	if this.conf.MaxAttempts <= 0 {
+		this.conf.MaxAttempts = defaultMaxAttempts
+	}
+	if this.conf.RetryInterval <= 0 {
+		this.conf.RetryInterval = defaultRetryInterval
+	}
+	if this.conf.Timeout <= 0 {
+		this.conf.Timeout = defaultTimeout
+	}
+	if this.conf.SMTPPort <= 0 {
+		this.conf.SMTPPort = defaultSMTPPort
+	}
+	if this.conf.APIURL == "" {
+		this.conf.APIURL = defaultAPIURL
+	}
+
+	switch this.conf.Provider {
+	case ProviderSMTP:
+		if this.conf.SMTPHost == "" {
+			return herrors.ErrSysInternal.New("SMTPHost not configured").D("failed to open mail plugin")
+		}
+		this.sender = newSMTPSender(&this.conf)
+	case ProviderSendGrid:
+		if this.conf.APIKey == "" {
+			return herrors.ErrSysInternal.New("APIKey not configured").D("failed to open mail plugin")
+		}
+		this.sender = newSendGridSender(&this.conf)
+	case ProviderCapture:
+		this.sender = nil
+	default:
+		return herrors.ErrSysInternal.New("unsupported mail provider %s", this.conf.Provider).D("failed to open mail plugin")
+	}
+	if this.conf.Provider != ProviderCapture && this.conf.From == "" {
+		return herrors.ErrSysInternal.New("From not configured").D("failed to open mail plugin")
+	}
+
+	this.templates = make(map[string]*mailTemplate)
+	if this.conf.TemplateDir != "" {
+		if err := this.loadTemplates(this.conf.TemplateDir); err != nil {
+			return err.D("failed to open mail plugin")
+		}
+	}
+	return nil
+}
+
+func (this *Plugin) Capability() htypes.Any {
+	return this
+}
+
+func (this *Plugin) Config() core.IEntityConf {
+	return &this.conf
+}
+
+func (this *Plugin) EntityStub() *core.EntityStub {
+	return core.NewEntityStub(
+		&core.EntityStubOptions{
+			Owner:       this,
+			Ping:        nil,
+			GetLoad:     nil,
+			ResetConfig: nil,
+		})
+}
+
+// Send 按模板templateID渲染data，发送给to，失败时重试，直到成功或达到MaxAttempts
+func (this *Plugin) Send(to string, templateID string, data htypes.Any) *herrors.Error {
+	msg, err := this.Render(templateID, data)
+	if err != nil {
+		return err
+	}
+	msg.To = []string{to}
+	return this.SendMessage(msg)
+}
+
+// SendMessage 发送已渲染的邮件，失败时重试，直到成功或达到MaxAttempts
+func (this *Plugin) SendMessage(msg *Message) *herrors.Error {
+	if len(msg.To) == 0 {
+		return herrors.ErrCallerInvalidRequest.New("no recipient").D("failed to send mail")
+	}
+	for _, to := range msg.To {
+		if !validAddress(to) {
+			return herrors.ErrCallerInvalidRequest.New("invalid recipient %s", to).D("failed to send mail")
+		}
+	}
+	if msg.HTML == "" && msg.Text == "" {
+		return herrors.ErrCallerInvalidRequest.New("empty mail body").D("failed to send mail")
+	}
+
+	if this.sender == nil {
+		c := *msg
+		c.To = append([]string(nil), msg.To...)
+		this.lock.Lock()
+		this.sent = append(this.sent, &c)
+		this.lock.Unlock()
+		return nil
+	}
+
+	interval := time.Duration(this.conf.RetryInterval) * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		permanent, err := this.sender.send(msg)
+		if err == nil {
+			return nil
+		}
+		if permanent || attempt >= this.conf.MaxAttempts {
+			hlogger.Error("failed to send mail %s to %s after %d attempts: %s", msg.Template, strings.Join(msg.To, ","), attempt, err.Error())
+			if permanent {
+				return herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to send mail")
+			}
+			return herrors.ErrSysUnavailable.New(err.Error()).D("failed to send mail")
+		}
+		hlogger.Warn("failed to send mail %s, retrying in %v: %s", msg.Template, interval, err.Error())
+		time.Sleep(interval)
+		interval *= 2
+	}
+}
+
+// Render 按模板templateID渲染邮件，不设置收件人
+func (this *Plugin) Render(templateID string, data htypes.Any) (*Message, *herrors.Error) {
+	this.lock.RLock()
+	t := this.templates[templateID]
+	this.lock.RUnlock()
+	if t == nil {
+		return nil, herrors.ErrCallerInvalidRequest.New("mail template %s not found", templateID).D("failed to render mail")
+	}
+
+	msg := &Message{Template: templateID}
+	var buf bytes.Buffer
+	if err := t.subject.Execute(&buf, data); err != nil {
+		return nil, herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to render mail")
+	}
+	msg.Subject = strings.TrimSpace(buf.String())
+	if t.html != nil {
+		buf.Reset()
+		if err := t.html.Execute(&buf, data); err != nil {
+			return nil, herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to render mail")
+		}
+		msg.HTML = buf.String()
+	}
+	if t.text != nil {
+		buf.Reset()
+		if err := t.text.Execute(&buf, data); err != nil {
+			return nil, herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to render mail")
+		}
+		msg.Text = buf.String()
+	}
+	return msg, nil
+}
+
+// AddTemplate 添加或替换模板，html使用html/template渲染，subject和text使用text/template。html和text至少一个不为空
+func (this *Plugin) AddTemplate(id string, subject string, html string, text string) *herrors.Error {
+	if html == "" && text == "" {
+		return herrors.ErrCallerInvalidRequest.New("mail template %s has no body", id).D("failed to add mail template")
+	}
+
+	var err error
+	t := &mailTemplate{}
+	if t.subject, err = template.New(id + subjectExt).Option("missingkey=zero").Parse(subject); err != nil {
+		return herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to add mail template")
+	}
+	if html != "" {
+		if t.html, err = htmltemplate.New(id + htmlExt).Option("missingkey=zero").Parse(html); err != nil {
+			return herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to add mail template")
+		}
+	}
+	if text != "" {
+		if t.text, err = template.New(id + textExt).Option("missingkey=zero").Parse(text); err != nil {
+			return herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to add mail template")
+		}
+	}
+
+	this.lock.Lock()
+	this.templates[id] = t
+	this.lock.Unlock()
+	return nil
+}
+
+// Sent capture模式下记录的邮件，按发送顺序
+func (this *Plugin) Sent() []*Message {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	return append([]*Message(nil), this.sent...)
+}
+
+// ResetSent 清空capture模式下记录的邮件
+func (this *Plugin) ResetSent() {
+	this.lock.Lock()
+	this.sent = nil
+	this.lock.Unlock()
+}
+
+// loadTemplates 加载目录下的模板，每个 <id>.subject 为一个模板，对应的 <id>.html 和 <id>.txt 为正文
+func (this *Plugin) loadTemplates(dir string) *herrors.Error {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+subjectExt))
+	if err != nil {
+		return herrors.ErrSysInternal.New(err.Error())
+	}
+	if len(files) == 0 {
+		hlogger.Warn("no mail template found in %s", dir)
+	}
+
+	for _, f := range files {
+		id := strings.TrimSuffix(filepath.Base(f), subjectExt)
+		subject, err := readTemplateFile(f)
+		if err != nil {
+			return err
+		}
+		html, err := readTemplateFile(filepath.Join(dir, id+htmlExt))
+		if err != nil {
+			return err
+		}
+		text, err := readTemplateFile(filepath.Join(dir, id+textExt))
+		if err != nil {
+			return err
+		}
+		if err := this.AddTemplate(id, subject, html, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTemplateFile 文件不存在时返回空字符串
+func readTemplateFile(path string) (string, *herrors.Error) {
+	bs, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", herrors.ErrSysInternal.New(err.Error())
+	}
+	return string(bs), nil
+}
@@ -0,0 +1,124 @@
+package hmailplugin
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/atomic"
+)
+
+func TestCapture(t *testing.T) {
+	dir := t.TempDir()
+	_ = ioutil.WriteFile(filepath.Join(dir, "welcome.subject"), []byte("Welcome {{.Name}}\n"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "welcome.html"), []byte("<p>Hi {{.Name}}</p>"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "welcome.txt"), []byte("Hi {{.Name}}"), 0644)
+
+	p := &Plugin{}
+	p.conf.Provider = ProviderCapture
+	p.conf.TemplateDir = dir
+	if err := p.open(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Send("a@example.com", "welcome", map[string]string{"Name": "<Bob>"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Send("a@example.com", "missing", nil); err == nil {
+		t.Fatal("expected missing template error")
+	}
+	if err := p.Send("not an address", "welcome", nil); err == nil {
+		t.Fatal("expected invalid recipient error")
+	}
+
+	sent := p.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 captured mail, got %d", len(sent))
+	}
+	m := sent[0]
+	if m.To[0] != "a@example.com" || m.Subject != "Welcome <Bob>" || m.Text != "Hi <Bob>" || m.HTML != "<p>Hi &lt;Bob&gt;</p>" || m.Template != "welcome" {
+		t.Fatalf("unexpected mail %+v", m)
+	}
+
+	p.ResetSent()
+	if len(p.Sent()) != 0 {
+		t.Fatal("expected captured mails reset")
+	}
+}
+
+func newSendGridPlugin(t *testing.T, url string) *Plugin {
+	p := &Plugin{}
+	p.conf.Provider = ProviderSendGrid
+	p.conf.APIKey = "k1"
+	p.conf.APIURL = url
+	p.conf.From = "noreply@example.com"
+	p.conf.RetryInterval = 10
+	if err := p.open(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddTemplate("code", "Your code", "", "Code: {{.}}"); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestSendGridRetry(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Content []sendGridContent `json:"content"`
+		}
+		_ = jsoniter.NewDecoder(r.Body).Decode(&body)
+		if r.Header.Get("Authorization") != "Bearer k1" || len(body.Content) != 1 || body.Content[0].Value != "Code: 1234" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if calls.Inc() < 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	p := newSendGridPlugin(t, srv.URL)
+	if err := p.Send("a@example.com", "code", 1234); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("expected 2 attempts, got %d", calls.Load())
+	}
+}
+
+func TestSendGridPermanentError(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Inc()
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"errors":[{"message":"invalid email"}]}`))
+	}))
+	defer srv.Close()
+
+	p := newSendGridPlugin(t, srv.URL)
+	err := p.Send("a@example.com", "code", 1234)
+	if err == nil || !strings.Contains(err.Cause, "invalid email") {
+		t.Fatalf("expected permanent error, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected no retry, got %d attempts", calls.Load())
+	}
+}
+
+func TestBuildMIME(t *testing.T) {
+	conf := &MailPlugin{From: "noreply@example.com", FromName: "Example"}
+	bs := string(buildMIME(conf, &Message{To: []string{"a@example.com"}, Subject: "你好", Text: "hi", HTML: "<p>hi</p>"}))
+	for _, s := range []string{"From: \"Example\" <noreply@example.com>", "Subject: =?utf-8?q?", "multipart/alternative", "text/plain; charset=utf-8", "text/html; charset=utf-8", "@example.com>"} {
+		if !strings.Contains(bs, s) {
+			t.Fatalf("mime missing %q:\n%s", s, bs)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package hmailplugin
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drharryhe/has/utils/hrandom"
+)
+
+const maxErrorBody = 4 * 1024 //bytes, 错误信息中包含的响应体上限
+
+func validAddress(addr string) bool {
+	_, err := mail.ParseAddress(addr)
+	return err == nil
+}
+
+type smtpSender struct {
+	conf *MailPlugin
+}
+
+func newSMTPSender(conf *MailPlugin) *smtpSender {
+	return &smtpSender{conf: conf}
+}
+
+// send SMTPImplicitTLS时以TLS连接，否则服务器支持时使用STARTTLS。5xx响应为永久错误
+func (this *smtpSender) send(msg *Message) (bool, error) {
+	addr := net.JoinHostPort(this.conf.SMTPHost, strconv.Itoa(this.conf.SMTPPort))
+	timeout := time.Duration(this.conf.Timeout) * time.Second
+	tlsConf := &tls.Config{ServerName: this.conf.SMTPHost}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: timeout}
+	if this.conf.SMTPImplicitTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConf)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return false, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+
+	c, err := smtp.NewClient(conn, this.conf.SMTPHost)
+	if err != nil {
+		_ = conn.Close()
+		return smtpPermanent(err), err
+	}
+	defer c.Close()
+
+	if !this.conf.SMTPImplicitTLS {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err = c.StartTLS(tlsConf); err != nil {
+				return smtpPermanent(err), err
+			}
+		}
+	}
+	if this.conf.SMTPUser != "" {
+		if err = c.Auth(smtp.PlainAuth("", this.conf.SMTPUser, this.conf.SMTPPassword, this.conf.SMTPHost)); err != nil {
+			return smtpPermanent(err), err
+		}
+	}
+	if err = c.Mail(this.conf.From); err != nil {
+		return smtpPermanent(err), err
+	}
+	for _, to := range msg.To {
+		if err = c.Rcpt(to); err != nil {
+			return smtpPermanent(err), err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return smtpPermanent(err), err
+	}
+	if _, err = w.Write(buildMIME(this.conf, msg)); err != nil {
+		return false, err
+	}
+	if err = w.Close(); err != nil {
+		return smtpPermanent(err), err
+	}
+	_ = c.Quit()
+	return false, nil
+}
+
+func smtpPermanent(err error) bool {
+	e, ok := err.(*textproto.Error)
+	return ok && e.Code >= 500
+}
+
+// buildMIME 生成邮件内容，正文以quoted-printable编码
+func buildMIME(conf *MailPlugin, msg *Message) []byte {
+	var buf bytes.Buffer
+	header := func(k, v string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", k, v)
+	}
+	from := mail.Address{Name: conf.FromName, Address: conf.From}
+	header("From", from.String())
+	header("To", strings.Join(msg.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@%s>", hrandom.UuidWithoutDash(), domain(conf.From)))
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" || msg.Text == "" {
+		ct, body := "text/plain", msg.Text
+		if msg.HTML != "" {
+			ct, body = "text/html", msg.HTML
+		}
+		header("Content-Type", ct+"; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		writeQuotedPrintable(&buf, body)
+		return buf.Bytes()
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ ct, body string }{{"text/plain", msg.Text}, {"text/html", msg.HTML}} {
+		w, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.ct + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		writeQuotedPrintable(w, part.body)
+	}
+	_ = mw.Close()
+	return buf.Bytes()
+}
+
+func writeQuotedPrintable(w io.Writer, s string) {
+	qw := quotedprintable.NewWriter(w)
+	_, _ = qw.Write([]byte(s))
+	_ = qw.Close()
+}
+
+func domain(addr string) string {
+	if i := strings.LastIndex(addr, "@"); i >= 0 {
+		return strings.TrimSuffix(addr[i+1:], ">")
+	}
+	return "localhost"
+}
+
+type sendGridSender struct {
+	conf   *MailPlugin
+	client *http.Client
+}
+
+func newSendGridSender(conf *MailPlugin) *sendGridSender {
+	return &sendGridSender{
+		conf:   conf,
+		client: &http.Client{Timeout: time.Duration(conf.Timeout) * time.Second},
+	}
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// send 调用SendGrid v3 mail send API，除429外的4xx响应为永久错误
+func (this *sendGridSender) send(msg *Message) (bool, error) {
+	var to []sendGridAddress
+	for _, addr := range msg.To {
+		to = append(to, sendGridAddress{Email: addr})
+	}
+	//SendGrid要求text/plain在text/html之前
+	var content []sendGridContent
+	if msg.Text != "" {
+		content = append(content, sendGridContent{Type: "text/plain", Value: msg.Text})
+	}
+	if msg.HTML != "" {
+		content = append(content, sendGridContent{Type: "text/html", Value: msg.HTML})
+	}
+	body, err := jsoniter.Marshal(map[string]interface{}{
+		"personalizations": []map[string]interface{}{{"to": to}},
+		"from":             sendGridAddress{Email: this.conf.From, Name: this.conf.FromName},
+		"subject":          msg.Subject,
+		"content":          content,
+	})
+	if err != nil {
+		return true, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, this.conf.APIURL, bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	req.Header.Set("Authorization", "Bearer "+this.conf.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := this.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxErrorBody))
+		return false, nil
+	}
+
+	bs, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	permanent := resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests
+	return permanent, fmt.Errorf("sendgrid responded %s: %s", resp.Status, strings.TrimSpace(string(bs)))
+}
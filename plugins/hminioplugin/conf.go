@@ -9,4 +9,7 @@ type MinioPlugin struct {
 	AccessKeyID     string
 	SecretAccessKey string
 	Tls             bool
+	Bucket          string //PutObject写入的bucket
+	PublicURL       string //对象URL的前缀，如 https://cdn.example.com/uploads，不配置则为 Endpoint/Bucket
+	PartSize        int    //MB, 大小未知的对象按该大小分片上传，每个上传占用一个分片的内存，缺省为 16
 }
@@ -1,6 +1,11 @@
 package hminioplugin
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 
@@ -9,6 +14,10 @@ import (
 	"github.com/drharryhe/has/core"
 )
 
+const (
+	defaultPartSize = 16 //MB
+)
+
 var plugin = &Plugin{}
 
 func New() *Plugin {
@@ -24,6 +33,10 @@ type Plugin struct {
 func (this *Plugin) Open(s core.IServer, ins core.IPlugin) *herrors.Error {
 	_ = this.BasePlugin.Open(s, ins)
 
+	if this.conf.PartSize <= 0 {
+		this.conf.PartSize = defaultPartSize
+	}
+
 	// Initialize minio client object.
 	if minioClient, err := minio.New(this.conf.Endpoint, &minio.Options{
 		Creds:  credentials.NewStaticV4(this.conf.AccessKeyID, this.conf.SecretAccessKey, ""),
@@ -54,3 +67,34 @@ func (this *Plugin) EntityStub() *core.EntityStub {
 			ResetConfig: nil,
 		})
 }
+
+// PutObject 按流上传对象到Bucket，size未知时为-1，按PartSize分片上传，不需要将整个对象读入内存。返回对象的URL
+func (this *Plugin) PutObject(ctx context.Context, key string, r io.Reader, size int64, contentType string) (string, *herrors.Error) {
+	if this.conf.Bucket == "" {
+		return "", herrors.ErrSysInternal.New("Bucket of minio plugin not configured")
+	}
+
+	_, err := this.minioClient.PutObject(ctx, this.conf.Bucket, key, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+		PartSize:    uint64(this.conf.PartSize) * 1024 * 1024,
+	})
+	if err != nil {
+		return "", herrors.ErrSysInternal.New(err.Error()).D("failed to put object")
+	}
+	return this.objectURL(key), nil
+}
+
+// RemoveObject 删除Bucket中的对象
+func (this *Plugin) RemoveObject(ctx context.Context, key string) *herrors.Error {
+	if err := this.minioClient.RemoveObject(ctx, this.conf.Bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return herrors.ErrSysInternal.New(err.Error()).D("failed to remove object")
+	}
+	return nil
+}
+
+func (this *Plugin) objectURL(key string) string {
+	if this.conf.PublicURL != "" {
+		return strings.TrimSuffix(this.conf.PublicURL, "/") + "/" + key
+	}
+	return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(this.minioClient.EndpointURL().String(), "/"), this.conf.Bucket, key)
+}
@@ -4,7 +4,8 @@ import "github.com/drharryhe/has/core"
 
 type RedisPlugin struct {
 	core.PluginConf
-	Backend   string
-	Password  string
-	DefaultDB int
+	Backend     string
+	Password    string
+	DefaultDB   int
+	PingTimeout int //seconds, 连接检查和就绪检查时Ping的超时，缺省为 3
 }
@@ -3,12 +3,19 @@ package hredisplugin
 ///redis 访问plugin
 
 import (
+	"context"
+	"time"
+
 	"github.com/drharryhe/has/common/herrors"
 	"github.com/drharryhe/has/common/htypes"
 	"github.com/drharryhe/has/core"
 	"github.com/go-redis/redis/v8"
 )
 
+const (
+	defaultPingTimeout = 3 //seconds
+)
+
 var plugin = &Plugin{}
 
 func New() *Plugin {
@@ -17,13 +24,17 @@ func New() *Plugin {
 
 type Plugin struct {
 	core.BasePlugin
-	redis *redis.Client
-	conf  RedisPlugin
+	redis     *redis.Client
+	reconnect *core.Reconnector
+	conf      RedisPlugin
 }
 
 func (this *Plugin) Open(s core.IServer, ins core.IPlugin) *herrors.Error {
 	_ = this.BasePlugin.Open(s, ins)
 
+	if this.conf.PingTimeout <= 0 {
+		this.conf.PingTimeout = defaultPingTimeout
+	}
 	this.redis = redis.NewClient(&redis.Options{
 		Addr:     this.conf.Backend,
 		Password: this.conf.Password,  // no password set
@@ -33,15 +44,50 @@ func (this *Plugin) Open(s core.IServer, ins core.IPlugin) *herrors.Error {
 		return herrors.ErrSysInternal.New("failed to connect redis server")
 	}
 
+	//客户端自动重连，Reconnector只跟踪连通状态，Degraded为false时redis不可用则启动失败
+	r, err := this.NewReconnector(this.check)
+	if err != nil {
+		_ = this.redis.Close()
+		return err.D("failed to open redis plugin")
+	}
+	this.reconnect = r
+
 	return nil
 }
 
 func (this *Plugin) Close() {
+	if this.reconnect != nil {
+		this.reconnect.Close()
+	}
 	if this.redis != nil {
 		_ = this.redis.Close()
 	}
 }
 
+func (this *Plugin) check() *herrors.Error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(this.conf.PingTimeout)*time.Second)
+	defer cancel()
+	if err := this.redis.Ping(ctx).Err(); err != nil {
+		return herrors.ErrSysUnavailable.New(err.Error()).D("redis unavailable")
+	}
+	return nil
+}
+
+// ping 断开期间返回重连的错误，连接中Ping失败时开始后台重连
+func (this *Plugin) ping(params htypes.Map) (htypes.Any, *herrors.Error) {
+	if this.reconnect == nil {
+		return false, herrors.ErrSysUnavailable.New("redis not opened")
+	}
+	if err := this.reconnect.Err(); err != nil {
+		return false, err
+	}
+	if err := this.check(); err != nil {
+		this.reconnect.Disconnected(err)
+		return false, err
+	}
+	return true, nil
+}
+
 func (this *Plugin) Capability() htypes.Any {
 	return this.redis
 }
@@ -54,7 +100,7 @@ func (this *Plugin) EntityStub() *core.EntityStub {
 	return core.NewEntityStub(
 		&core.EntityStubOptions{
 			Owner:       this,
-			Ping:        nil,
+			Ping:        this.ping,
 			GetLoad:     nil,
 			ResetConfig: nil,
 		})
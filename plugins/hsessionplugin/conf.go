@@ -0,0 +1,12 @@
+package hsessionplugin
+
+import "github.com/drharryhe/has/core"
+
+type SessionPlugin struct {
+	core.PluginConf
+
+	Store       string // 保存会话的插件，如 CachePlugin，需实现Store接口，不配置则保存在内存中，只在单个进程内有效
+	Prefix      string // 会话在Store中的key前缀，缺省为 session:
+	IdleTimeout int    // seconds, 会话超过该时长未访问则失效，访问时顺延，缺省为 1800
+	MaxLifetime int    // seconds, 会话自创建起的最长有效期，到期后即使一直在访问也失效，缺省为 86400
+}
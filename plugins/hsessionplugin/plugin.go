@@ -0,0 +1,250 @@
+package hsessionplugin
+
+/// 服务端会话plugin，会话可随时销毁(如退出登录、禁用账号)，弥补JWT无法撤销的不足
+
+import (
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/patrickmn/go-cache"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/utils/hrandom"
+)
+
+const (
+	defaultPrefix      = "session:"
+	defaultIdleTimeout = 1800  //seconds
+	defaultMaxLifetime = 86400 //seconds
+)
+
+var plugin = &Plugin{}
+
+func New() *Plugin {
+	return plugin
+}
+
+// Store 保存会话，方法与hcacheplugin.Plugin一致，可直接使用缓存插件
+type Store interface {
+	Get(key string) (htypes.Any, bool, *herrors.Error)
+	Set(key string, val htypes.Any, ttl time.Duration) *herrors.Error
+	Del(keys ...string) *herrors.Error
+}
+
+// Session 会话，以JSON字符串保存在Store中，不依赖存储的序列化方式
+type Session struct {
+	ID        string     `json:"id"`
+	Subject   string     `json:"subject"`
+	Data      htypes.Map `json:"data,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+}
+
+type Plugin struct {
+	core.BasePlugin
+
+	conf  SessionPlugin
+	once  sync.Once
+	store Store
+	err   *herrors.Error
+}
+
+func (this *Plugin) Open(s core.IServer, ins core.IPlugin) *herrors.Error {
+	if err := this.BasePlugin.Open(s, ins); err != nil {
+		return err
+	}
+
+	if this.conf.Prefix == "" {
+		this.conf.Prefix = defaultPrefix
+	}
+	if this.conf.IdleTimeout <= 0 {
+		this.conf.IdleTimeout = defaultIdleTimeout
+	}
+	if this.conf.MaxLifetime <= 0 {
+		this.conf.MaxLifetime = defaultMaxLifetime
+	}
+	if this.conf.Store == "" {
+		this.store = newMemoryStore()
+	}
+	return nil
+}
+
+func (this *Plugin) Capability() htypes.Any {
+	return this
+}
+
+func (this *Plugin) Config() core.IEntityConf {
+	return &this.conf
+}
+
+func (this *Plugin) EntityStub() *core.EntityStub {
+	return core.NewEntityStub(
+		&core.EntityStubOptions{
+			Owner:       this,
+			Ping:        nil,
+			GetLoad:     nil,
+			ResetConfig: nil,
+		})
+}
+
+// Create 为subject(如用户名)创建会话，data为随会话保存的数据
+func (this *Plugin) Create(subject string, data htypes.Map) (*Session, *herrors.Error) {
+	if subject == "" {
+		return nil, herrors.ErrSysInternal.New("session subject required")
+	}
+
+	now := time.Now()
+	s := &Session{
+		ID:        hrandom.UuidWithoutDash(),
+		Subject:   subject,
+		Data:      data,
+		CreatedAt: now,
+	}
+	if err := this.save(s, now); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get 读取会话，会话不存在或已过期时返回ErrCallerUnauthorizedAccess。
+// 剩余时间不足IdleTimeout的一半时顺延，避免每次访问都写入Store
+func (this *Plugin) Get(id string) (*Session, *herrors.Error) {
+	s, err := this.load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if s.ExpiresAt.Sub(now) < time.Duration(this.conf.IdleTimeout)*time.Second/2 {
+		if err := this.save(s, now); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Refresh 立即顺延会话的有效期，不超过MaxLifetime
+func (this *Plugin) Refresh(id string) (*Session, *herrors.Error) {
+	s, err := this.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := this.save(s, time.Now()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Destroy 销毁会话，之后使用该会话的请求被拒绝
+func (this *Plugin) Destroy(id string) *herrors.Error {
+	store, err := this.getStore()
+	if err != nil {
+		return err
+	}
+	return store.Del(this.conf.Prefix + id)
+}
+
+// Subject 读取会话的subject，供连接器将其写入请求参数
+func (this *Plugin) Subject(id string) (string, *herrors.Error) {
+	s, err := this.Get(id)
+	if err != nil {
+		return "", err
+	}
+	return s.Subject, nil
+}
+
+func (this *Plugin) load(id string) (*Session, *herrors.Error) {
+	store, err := this.getStore()
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, herrors.ErrCallerUnauthorizedAccess.New("session id required").D("invalid session")
+	}
+
+	val, ok, err := store.Get(this.conf.Prefix + id)
+	if err != nil {
+		return nil, err
+	}
+	str, _ := val.(string)
+	if !ok || str == "" {
+		return nil, herrors.ErrCallerUnauthorizedAccess.New("session not found or expired").D("invalid session")
+	}
+
+	var s Session
+	if e := jsoniter.UnmarshalFromString(str, &s); e != nil {
+		return nil, herrors.ErrSysInternal.New(e.Error()).D("failed to load session")
+	}
+	if !time.Now().Before(s.ExpiresAt) {
+		return nil, herrors.ErrCallerUnauthorizedAccess.New("session expired").D("invalid session")
+	}
+	return &s, nil
+}
+
+// save 有效期顺延IdleTimeout，不超过创建时间加MaxLifetime
+func (this *Plugin) save(s *Session, now time.Time) *herrors.Error {
+	store, err := this.getStore()
+	if err != nil {
+		return err
+	}
+
+	s.ExpiresAt = now.Add(time.Duration(this.conf.IdleTimeout) * time.Second)
+	if max := s.CreatedAt.Add(time.Duration(this.conf.MaxLifetime) * time.Second); s.ExpiresAt.After(max) {
+		s.ExpiresAt = max
+	}
+	ttl := s.ExpiresAt.Sub(now)
+	if ttl <= 0 {
+		return herrors.ErrCallerUnauthorizedAccess.New("session expired").D("invalid session")
+	}
+
+	str, e := jsoniter.MarshalToString(s)
+	if e != nil {
+		return herrors.ErrSysInternal.New(e.Error()).D("failed to save session")
+	}
+	return store.Set(this.conf.Prefix+s.ID, str, ttl)
+}
+
+// getStore Store插件可能在本插件之后加载，第一次使用时查找
+func (this *Plugin) getStore() (Store, *herrors.Error) {
+	this.once.Do(func() {
+		if this.store != nil {
+			return
+		}
+		store, ok := this.Server().Plugin(this.conf.Store).(Store)
+		if !ok {
+			this.err = herrors.ErrSysInternal.New("plugin %s not found or not implement Store", this.conf.Store).D("session store unavailable")
+			return
+		}
+		this.store = store
+	})
+	return this.store, this.err
+}
+
+// memoryStore 没有配置Store时使用
+type memoryStore struct {
+	cache *cache.Cache
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{cache: cache.New(time.Duration(defaultIdleTimeout)*time.Second, 10*time.Minute)}
+}
+
+func (this *memoryStore) Get(key string) (htypes.Any, bool, *herrors.Error) {
+	val, ok := this.cache.Get(key)
+	return val, ok, nil
+}
+
+func (this *memoryStore) Set(key string, val htypes.Any, ttl time.Duration) *herrors.Error {
+	this.cache.Set(key, val, ttl)
+	return nil
+}
+
+func (this *memoryStore) Del(keys ...string) *herrors.Error {
+	for _, k := range keys {
+		this.cache.Delete(k)
+	}
+	return nil
+}
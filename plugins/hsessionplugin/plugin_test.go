@@ -0,0 +1,62 @@
+package hsessionplugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+)
+
+func newTestPlugin() *Plugin {
+	p := &Plugin{store: newMemoryStore()}
+	p.conf.Prefix = defaultPrefix
+	p.conf.IdleTimeout = 60
+	p.conf.MaxLifetime = 120
+	return p
+}
+
+func near(t time.Time, want time.Time) bool {
+	d := t.Sub(want)
+	return d > -time.Second && d < time.Second
+}
+
+func TestSession(t *testing.T) {
+	p := newTestPlugin()
+
+	s, err := p.Create("alice", htypes.Map{"uid": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(s.ExpiresAt, time.Now().Add(60*time.Second)) {
+		t.Errorf("expires at %s, want idle timeout", s.ExpiresAt)
+	}
+	if sub, err := p.Subject(s.ID); err != nil || sub != "alice" {
+		t.Errorf("Subject = %s, %v", sub, err)
+	}
+
+	//剩余时间不足一半时顺延
+	_ = p.save(s, time.Now().Add(-40*time.Second))
+	got, err := p.Get(s.ID)
+	if err != nil || !near(got.ExpiresAt, time.Now().Add(60*time.Second)) {
+		t.Errorf("Get = %+v, %v, want expiration extended", got, err)
+	}
+
+	//不超过MaxLifetime
+	s.CreatedAt = time.Now().Add(-100 * time.Second)
+	_ = p.save(s, time.Now())
+	if got, _ = p.Refresh(s.ID); !near(got.ExpiresAt, time.Now().Add(20*time.Second)) {
+		t.Errorf("expires at %s, want capped by max lifetime", got.ExpiresAt)
+	}
+	s.CreatedAt = time.Now().Add(-130 * time.Second)
+	if err = p.save(s, time.Now()); err == nil {
+		t.Error("session beyond max lifetime saved")
+	}
+
+	if err = p.Destroy(s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Get(s.ID); err == nil || err.Code != herrors.ECodeCallerUnauthorizedAccess {
+		t.Errorf("Get after Destroy = %v, want unauthorized", err)
+	}
+}
@@ -0,0 +1,18 @@
+package hwebhookplugin
+
+import "github.com/drharryhe/has/core"
+
+type WebhookPlugin struct {
+	core.PluginConf
+
+	Dir              string  // 投递队列的保存目录，每个投递一个文件，重启后继续投递，缺省为 ./webhooks
+	Secret           string  // 签名密钥，配置后每个请求携带SignatureHeader
+	SignatureHeader  string  // 签名header，值为 t=时间戳,v1=HMAC-SHA256(时间戳.请求体)，缺省为 X-Webhook-Signature
+	Concurrency      int     // 同时进行的投递数，缺省为 4
+	Timeout          int     // seconds, 每次投递的超时时长，缺省为 10
+	MaxAttempts      int     // 最多投递次数，之后进入死信，可通过Retry重新投递，缺省为 8
+	RetryInterval    int     // milliseconds, 第一次重试的间隔，之后每次翻倍，缺省为 5000
+	MaxRetryInterval int     // seconds, 重试间隔的上限，缺省为 3600
+	EndpointRate     float64 // 每个endpoint(host:port)每秒最多投递的请求数，0表示不限制
+	Retention        int     // hours, 投递成功和死信记录的保留时长，缺省为 168
+}
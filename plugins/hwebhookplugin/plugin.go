@@ -0,0 +1,496 @@
+package hwebhookplugin
+
+/// webhook投递plugin，投递队列保存在本地目录，请求按HMAC签名，失败时按指数退避重试，超过最大次数后进入死信
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/juju/ratelimit"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/utils/hrandom"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead" //超过MaxAttempts仍未成功
+
+	IDHeader = "X-Webhook-Id" //投递ID，重试时不变，接收方可据此去重
+
+	defaultDir              = "./webhooks"
+	defaultSignatureHeader  = "X-Webhook-Signature"
+	defaultConcurrency      = 4
+	defaultTimeout          = 10 //seconds
+	defaultMaxAttempts      = 8
+	defaultRetryInterval    = 5000 //milliseconds
+	defaultMaxRetryInterval = 3600 //seconds
+	defaultRetention        = 168  //hours
+
+	maxDispatchWait  = time.Minute
+	purgeInterval    = time.Hour
+	maxResponseDrain = 64 * 1024 //bytes, 读取并丢弃的响应体上限，以便复用连接
+)
+
+var plugin = &Plugin{}
+
+func New() *Plugin {
+	return plugin
+}
+
+// Delivery 一次webhook投递，Body为请求体，以JSON文件保存
+type Delivery struct {
+	ID          string            `json:"id"`
+	URL         string            `json:"url"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        string            `json:"body"`
+	Status      string            `json:"status"`
+	Attempts    int               `json:"attempts"`
+	NextAttempt time.Time         `json:"next_attempt"`
+	LastStatus  int               `json:"last_status,omitempty"` //最后一次投递的HTTP状态码，请求失败时为0
+	LastError   string            `json:"last_error,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+type Plugin struct {
+	core.BasePlugin
+
+	conf       WebhookPlugin
+	store      *fileStore
+	client     *http.Client
+	lock       sync.Mutex
+	deliveries map[string]*Delivery
+	running    map[string]bool
+	limiters   map[string]*ratelimit.Bucket //endpoint -> 令牌桶
+	wake       chan struct{}
+	started    bool
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+func (this *Plugin) Open(s core.IServer, ins core.IPlugin) *herrors.Error {
+	if err := this.BasePlugin.Open(s, ins); err != nil {
+		return err
+	}
+	return this.open()
+}
+
+func (this *Plugin) open() *herrors.Error {
+	if this.conf.Dir == "" {
+		this.conf.Dir = defaultDir
+	}
+	if this.conf.SignatureHeader == "" {
+		this.conf.SignatureHeader = defaultSignatureHeader
+	}
+	if this.conf.Concurrency <= 0 {
+		this.conf.Concurrency = defaultConcurrency
+	}
+	if this.conf.Timeout <= 0 {
+		this.conf.Timeout = defaultTimeout
+	}
+	if this.conf.MaxAttempts <= 0 {
+		this.conf.MaxAttempts = defaultMaxAttempts
+	}
+	if this.conf.RetryInterval <= 0 {
+		this.conf.RetryInterval = defaultRetryInterval
+	}
+	if this.conf.MaxRetryInterval <= 0 {
+		this.conf.MaxRetryInterval = defaultMaxRetryInterval
+	}
+	if this.conf.Retention <= 0 {
+		this.conf.Retention = defaultRetention
+	}
+
+	store, err := newFileStore(this.conf.Dir)
+	if err != nil {
+		return err.D("failed to open webhook plugin")
+	}
+	ds, err := store.load()
+	if err != nil {
+		return err.D("failed to open webhook plugin")
+	}
+
+	this.store = store
+	this.client = &http.Client{Timeout: time.Duration(this.conf.Timeout) * time.Second}
+	this.deliveries = make(map[string]*Delivery)
+	for _, d := range ds {
+		this.deliveries[d.ID] = d
+	}
+	this.running = make(map[string]bool)
+	this.limiters = make(map[string]*ratelimit.Bucket)
+	this.wake = make(chan struct{}, 1)
+	this.ctx, this.cancel = context.WithCancel(context.Background())
+	return nil
+}
+
+// Start 由server启动后调用，开始投递，包括上次退出时未完成的投递
+func (this *Plugin) Start() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.started {
+		return
+	}
+	this.started = true
+	this.wg.Add(1)
+	go this.dispatch()
+}
+
+// Close 停止投递，进行中的请求被取消，不计入投递次数，下次启动后重新投递
+func (this *Plugin) Close() {
+	if this.cancel == nil {
+		return
+	}
+	this.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		this.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Duration(this.conf.Timeout) * time.Second):
+		hlogger.Warn("webhook plugin closed with deliveries still running")
+	}
+}
+
+func (this *Plugin) Capability() htypes.Any {
+	return this
+}
+
+func (this *Plugin) Config() core.IEntityConf {
+	return &this.conf
+}
+
+func (this *Plugin) EntityStub() *core.EntityStub {
+	return core.NewEntityStub(
+		&core.EntityStubOptions{
+			Owner:       this,
+			Ping:        nil,
+			GetLoad:     nil,
+			ResetConfig: nil,
+		})
+}
+
+// Enqueue 添加投递，payload为string或[]byte时作为请求体，其他类型编码为JSON。返回投递ID，用于查询状态。
+// 投递在保存到Dir后才返回，之后即使进程退出也会继续投递
+func (this *Plugin) Enqueue(endpoint string, payload htypes.Any, headers map[string]string) (string, *herrors.Error) {
+	u, e := url.Parse(endpoint)
+	if e != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", herrors.ErrCallerInvalidRequest.New("invalid webhook url %s", endpoint).D("failed to enqueue webhook")
+	}
+
+	var body string
+	switch val := payload.(type) {
+	case string:
+		body = val
+	case []byte:
+		body = string(val)
+	default:
+		s, err := jsoniter.MarshalToString(payload)
+		if err != nil {
+			return "", herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to encode webhook payload")
+		}
+		body = s
+	}
+
+	now := time.Now()
+	d := &Delivery{
+		ID:          hrandom.UuidWithoutDash(),
+		URL:         endpoint,
+		Headers:     headers,
+		Body:        body,
+		Status:      StatusPending,
+		NextAttempt: now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := this.store.save(d); err != nil {
+		return "", err
+	}
+
+	this.lock.Lock()
+	this.deliveries[d.ID] = d
+	this.lock.Unlock()
+	this.notify()
+	return d.ID, nil
+}
+
+// Status 查询投递状态，投递成功和死信的记录保留Retention小时
+func (this *Plugin) Status(id string) (*Delivery, *herrors.Error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	d := this.deliveries[id]
+	if d == nil {
+		return nil, herrors.ErrCallerInvalidRequest.New("webhook delivery %s not found", id).D("webhook delivery not found")
+	}
+	c := *d
+	return &c, nil
+}
+
+// DeadLetters 超过MaxAttempts仍未成功的投递，按创建时间排序
+func (this *Plugin) DeadLetters() []*Delivery {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	var ds []*Delivery
+	for _, d := range this.deliveries {
+		if d.Status == StatusDead {
+			c := *d
+			ds = append(ds, &c)
+		}
+	}
+	sort.Slice(ds, func(i, j int) bool {
+		return ds[i].CreatedAt.Before(ds[j].CreatedAt)
+	})
+	return ds
+}
+
+// Retry 重新投递死信，投递次数清零
+func (this *Plugin) Retry(id string) *herrors.Error {
+	this.lock.Lock()
+	d := this.deliveries[id]
+	if d == nil || d.Status != StatusDead {
+		this.lock.Unlock()
+		return herrors.ErrCallerInvalidRequest.New("webhook delivery %s not found or not dead", id).D("failed to retry webhook")
+	}
+	d.Status = StatusPending
+	d.Attempts = 0
+	d.NextAttempt = time.Now()
+	d.UpdatedAt = d.NextAttempt
+	c := *d
+	this.lock.Unlock()
+
+	if err := this.store.save(&c); err != nil {
+		return err
+	}
+	this.notify()
+	return nil
+}
+
+// Sign 计算签名header的值。接收方按相同方法计算v1并比较，同时检查时间戳t避免重放
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%d.", timestamp)
+	_, _ = mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+func (this *Plugin) notify() {
+	select {
+	case this.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch 投递到期的请求，没有到期的请求时等待到最早的下次投递时间，添加投递或投递完成时立即检查
+func (this *Plugin) dispatch() {
+	defer this.wg.Done()
+
+	this.purge()
+	sem := make(chan struct{}, this.conf.Concurrency)
+	purge := time.NewTicker(purgeInterval)
+	defer purge.Stop()
+	for {
+		timer := time.NewTimer(this.launch(sem))
+		select {
+		case <-this.ctx.Done():
+			timer.Stop()
+			return
+		case <-this.wake:
+		case <-timer.C:
+		case <-purge.C:
+			this.purge()
+		}
+		timer.Stop()
+	}
+}
+
+// launch 开始到期的投递，受Concurrency和EndpointRate限制，返回下次检查前的等待时长
+func (this *Plugin) launch(sem chan struct{}) time.Duration {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	var due []*Delivery
+	now := time.Now()
+	wait := maxDispatchWait
+	for _, d := range this.deliveries {
+		if d.Status != StatusPending || this.running[d.ID] {
+			continue
+		}
+		if w := d.NextAttempt.Sub(now); w > 0 {
+			if w < wait {
+				wait = w
+			}
+			continue
+		}
+		due = append(due, d)
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttempt.Before(due[j].NextAttempt)
+	})
+
+	for _, d := range due {
+		select {
+		case sem <- struct{}{}:
+		default:
+			//投递完成时会再次检查
+			return wait
+		}
+		if b := this.limiter(d.URL); b != nil && b.TakeAvailable(1) == 0 {
+			<-sem
+			if w := time.Duration(float64(time.Second) / this.conf.EndpointRate); w < wait {
+				wait = w
+			}
+			continue
+		}
+
+		this.running[d.ID] = true
+		this.wg.Add(1)
+		go this.deliver(*d, sem)
+	}
+	return wait
+}
+
+// limiter 按endpoint(host:port)限流，未配置EndpointRate时返回nil
+func (this *Plugin) limiter(endpoint string) *ratelimit.Bucket {
+	if this.conf.EndpointRate <= 0 {
+		return nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil
+	}
+
+	b := this.limiters[u.Host]
+	if b == nil {
+		burst := int64(this.conf.EndpointRate)
+		if burst <= 0 {
+			burst = 1
+		}
+		b = ratelimit.NewBucketWithRate(this.conf.EndpointRate, burst)
+		this.limiters[u.Host] = b
+	}
+	return b
+}
+
+func (this *Plugin) deliver(d Delivery, sem chan struct{}) {
+	defer this.wg.Done()
+	defer func() {
+		<-sem
+		this.notify()
+	}()
+
+	status, err := this.post(&d)
+	this.lock.Lock()
+	delete(this.running, d.ID)
+	cur := this.deliveries[d.ID]
+	//插件关闭导致的失败不计入投递次数
+	if cur == nil || this.ctx.Err() != nil {
+		this.lock.Unlock()
+		return
+	}
+
+	now := time.Now()
+	cur.Attempts++
+	cur.LastStatus = status
+	cur.UpdatedAt = now
+	if err == nil {
+		cur.Status = StatusDelivered
+		cur.LastError = ""
+	} else {
+		cur.LastError = err.Error()
+		if cur.Attempts >= this.conf.MaxAttempts {
+			cur.Status = StatusDead
+			hlogger.Warn("webhook %s to %s dead after %d attempts: %s", cur.ID, cur.URL, cur.Attempts, cur.LastError)
+		} else {
+			cur.NextAttempt = now.Add(this.backoff(cur.Attempts))
+		}
+	}
+	c := *cur
+	this.lock.Unlock()
+
+	if err := this.store.save(&c); err != nil {
+		hlogger.Error(err)
+	}
+}
+
+// post 返回2xx以外的状态码视为失败
+func (this *Plugin) post(d *Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(this.ctx, http.MethodPost, d.URL, strings.NewReader(d.Body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range d.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set(IDHeader, d.ID)
+	if this.conf.Secret != "" {
+		req.Header.Set(this.conf.SignatureHeader, Sign(this.conf.Secret, time.Now().Unix(), []byte(d.Body)))
+	}
+
+	res, err := this.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, maxResponseDrain))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// backoff 第n次失败后的重试间隔，RetryInterval每次翻倍，不超过MaxRetryInterval
+func (this *Plugin) backoff(attempts int) time.Duration {
+	max := time.Duration(this.conf.MaxRetryInterval) * time.Second
+	d := time.Duration(this.conf.RetryInterval) * time.Millisecond
+	for i := 1; i < attempts && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// purge 删除超过Retention的投递成功和死信记录
+func (this *Plugin) purge() {
+	before := time.Now().Add(-time.Duration(this.conf.Retention) * time.Hour)
+
+	var ids []string
+	this.lock.Lock()
+	for id, d := range this.deliveries {
+		if d.Status != StatusPending && d.UpdatedAt.Before(before) {
+			delete(this.deliveries, id)
+			ids = append(ids, id)
+		}
+	}
+	this.lock.Unlock()
+
+	for _, id := range ids {
+		this.store.remove(id)
+	}
+}
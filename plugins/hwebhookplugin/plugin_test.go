@@ -0,0 +1,121 @@
+package hwebhookplugin
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+func newTestPlugin(t *testing.T, dir string) *Plugin {
+	p := &Plugin{}
+	p.conf.Dir = dir
+	p.conf.Secret = "s1"
+	p.conf.RetryInterval = 10
+	p.conf.MaxAttempts = 3
+	p.conf.Timeout = 1
+	if err := p.open(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func waitStatus(t *testing.T, p *Plugin, id string, status string) *Delivery {
+	for i := 0; i < 100; i++ {
+		d, err := p.Status(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Status == status {
+			return d
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("delivery %s not %s", id, status)
+	return nil
+}
+
+func TestDeliver(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		sig := r.Header.Get(defaultSignatureHeader)
+		ts, _ := strconv.ParseInt(strings.TrimPrefix(strings.Split(sig, ",")[0], "t="), 10, 64)
+		if sig != Sign("s1", ts, body) || r.Header.Get(IDHeader) == "" || r.Header.Get("X-Event") != "created" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if calls.Inc() < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}))
+	defer srv.Close()
+
+	p := newTestPlugin(t, t.TempDir())
+	p.Start()
+	defer p.Close()
+
+	id, err := p.Enqueue(srv.URL, map[string]interface{}{"id": 1}, map[string]string{"X-Event": "created"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := waitStatus(t, p, id, StatusDelivered)
+	if d.Attempts != 3 || d.LastStatus != http.StatusOK || d.Body != `{"id":1}` {
+		t.Errorf("delivery = %+v", d)
+	}
+
+	if _, err := p.Enqueue("ftp://example.com", "x", nil); err == nil {
+		t.Error("invalid url should fail")
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	p := newTestPlugin(t, dir)
+	p.Start()
+	id, _ := p.Enqueue(srv.URL, "x", nil)
+	d := waitStatus(t, p, id, StatusDead)
+	if d.Attempts != 3 || d.LastStatus != http.StatusServiceUnavailable {
+		t.Errorf("delivery = %+v", d)
+	}
+	if ds := p.DeadLetters(); len(ds) != 1 || ds[0].ID != id {
+		t.Errorf("dead letters = %v", ds)
+	}
+	p.Close()
+
+	//重启后读取保存的投递
+	fail.Store(false)
+	p = newTestPlugin(t, dir)
+	p.Start()
+	defer p.Close()
+	if err := p.Retry(id); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, p, id, StatusDelivered)
+}
+
+func TestBackoff(t *testing.T) {
+	p := &Plugin{}
+	p.conf.RetryInterval = 1000
+	p.conf.MaxRetryInterval = 5
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 60: 5 * time.Second} {
+		if got := p.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
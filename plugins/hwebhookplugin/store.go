@@ -0,0 +1,79 @@
+package hwebhookplugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+)
+
+const (
+	deliveryExt = ".json"
+)
+
+// fileStore 每个投递保存为Dir下的一个JSON文件，先写临时文件再改名，进程退出时不会留下不完整的文件
+type fileStore struct {
+	dir string
+}
+
+func newFileStore(dir string) (*fileStore, *herrors.Error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, herrors.ErrSysInternal.New(err.Error()).D("failed to create webhook dir")
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+func (this *fileStore) save(d *Delivery) *herrors.Error {
+	bs, err := jsoniter.Marshal(d)
+	if err != nil {
+		return herrors.ErrSysInternal.New(err.Error()).D("failed to save webhook delivery")
+	}
+
+	name := filepath.Join(this.dir, d.ID+deliveryExt)
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, bs, 0644); err != nil {
+		return herrors.ErrSysInternal.New(err.Error()).D("failed to save webhook delivery")
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return herrors.ErrSysInternal.New(err.Error()).D("failed to save webhook delivery")
+	}
+	return nil
+}
+
+func (this *fileStore) remove(id string) {
+	if err := os.Remove(filepath.Join(this.dir, id+deliveryExt)); err != nil && !os.IsNotExist(err) {
+		hlogger.Warn("failed to remove webhook delivery %s: %s", id, err.Error())
+	}
+}
+
+// load 读取保存的投递，无法解析的文件跳过
+func (this *fileStore) load() ([]*Delivery, *herrors.Error) {
+	files, err := ioutil.ReadDir(this.dir)
+	if err != nil {
+		return nil, herrors.ErrSysInternal.New(err.Error()).D("failed to load webhook deliveries")
+	}
+
+	var ds []*Delivery
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), deliveryExt) {
+			continue
+		}
+		bs, err := ioutil.ReadFile(filepath.Join(this.dir, f.Name()))
+		if err != nil {
+			hlogger.Warn("failed to read webhook delivery %s: %s", f.Name(), err.Error())
+			continue
+		}
+		var d Delivery
+		if err := jsoniter.Unmarshal(bs, &d); err != nil || d.ID == "" {
+			hlogger.Warn("invalid webhook delivery file %s", f.Name())
+			continue
+		}
+		ds = append(ds, &d)
+	}
+	return ds, nil
+}
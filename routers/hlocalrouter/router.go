@@ -26,7 +26,7 @@ func (this *Router) Open(s core.IServer, ins core.IRouter) *herrors.Error {
 }
 
 func (this *Router) RequestService(service string, slot string, params htypes.Map) (htypes.Any, *herrors.Error) {
-	s := this.Services[service]
+	s := this.Service(service)
 
 	if s == nil || s.(core.IEntity).Config().GetDisabled() {
 		return nil, herrors.ErrCallerInvalidRequest.New("service %s not available", service)
@@ -36,7 +36,15 @@ func (this *Router) RequestService(service string, slot string, params htypes.Ma
 		return nil, herrors.ErrCallerInvalidRequest.New("slot %s not available", slot)
 	}
 
-	return s.Request(slot, params)
+	if this.Draining(service) {
+		return nil, herrors.ErrSysUnavailable.New("service %s draining", service)
+	}
+
+	if err := this.Authorize(service, s.Slot(slot), params); err != nil {
+		return nil, err
+	}
+
+	return this.Dispatch(s, s.Slot(slot), params)
 }
 
 func (this *Router) EntityStub() *core.EntityStub {
@@ -132,6 +132,13 @@ func (this *Router) Config() core.IEntityConf {
 
 func (this *Router) UnRegisterService(s core.IService) {
 	this.BaseRouter.UnRegisterService(s)
+	this.delServerAddr(s.Name(), this.conf.RpcxAddr)
+}
+
+// DrainService 同时删除服务地址，其他节点不再向本节点发送该服务的请求
+func (this *Router) DrainService(name string) {
+	this.BaseRouter.DrainService(name)
+	this.delServerAddr(name, this.conf.RpcxAddr)
 }
 
 func (this *Router) HandleServiceRequested(_ context.Context, args *core.RpcRequestArguments, resp *core.SlotResponse) error {
@@ -139,7 +146,7 @@ func (this *Router) HandleServiceRequested(_ context.Context, args *core.RpcRequ
 	slot := args.Slot
 	ps := args.Params
 
-	s := this.Services[service]
+	s := this.Service(service)
 	if s == nil {
 		return errors.New("service not found")
 	}
@@ -148,7 +155,18 @@ func (this *Router) HandleServiceRequested(_ context.Context, args *core.RpcRequ
 		return errors.New("slot not found")
 	}
 
-	ret, err := s.Request(slot, ps)
+	if this.Draining(service) {
+		resp.Error = herrors.ErrSysUnavailable.New("service %s draining", service)
+		return nil
+	}
+
+	//在提供服务的节点上授权，slot的角色设置只在该节点上可用
+	if err := this.Authorize(service, s.Slot(slot), ps); err != nil {
+		resp.Error = err
+		return nil
+	}
+
+	ret, err := this.Dispatch(s, s.Slot(slot), ps)
 	resp.Data = ret
 	resp.Error = err
 
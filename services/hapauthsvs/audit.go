@@ -0,0 +1,85 @@
+package hapauthsvs
+
+import (
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/hpaging"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/utils/hdatetime"
+)
+
+// 审计事件类型
+const (
+	AuditLoginSuccess = "login_success"
+	AuditLoginFailed  = "login_failed"
+	AuditUserLocked   = "user_locked"
+	AuditUserUnlocked = "user_unlocked"
+	AuditPwdChanged   = "pwd_changed"
+	AuditPwdReset     = "pwd_reset"
+
+	AuditPwdResetRequested = "pwd_reset_requested"
+)
+
+// audit 记录认证事件，来源地址与验证码计数相同(见loginAddress)，客户端取自InAgentField指定的参数
+func (this *Service) audit(params htypes.Map, user string, event string, detail string) {
+	if !this.conf.AuditDB && !this.conf.AuditLog {
+		return
+	}
+
+	a := SvsApAuthAudit{
+		User:      user,
+		Event:     event,
+		Detail:    detail,
+		Address:   this.loginAddress(params),
+		CreatedAt: hdatetime.Now(),
+	}
+	if this.conf.InAgentField != "" {
+		a.Agent, _ = params[this.conf.InAgentField].(string)
+	}
+
+	if this.conf.AuditLog {
+		hlogger.InfoCtx(core.RequestContext(params), "audit: user=%s event=%s address=%s detail=%s", a.User, a.Event, a.Address, a.Detail)
+	}
+	if this.conf.AuditDB {
+		if err := this.db.Save(&a).Error; err != nil {
+			hlogger.Error(herrors.ErrSysInternal.New(err.Error()).D("failed to save audit"))
+		}
+	}
+}
+
+// GetAudits 按用户、事件类型和时间范围分页查询审计记录，from和to的格式为 2006-01-02 15:04:05
+func (this *Service) GetAudits(params htypes.Map, res *core.SlotResponse) {
+	paging, err := hpaging.Parse(params, this.conf.MaxPageSize)
+	if err != nil {
+		this.Response(res, nil, err)
+		return
+	}
+
+	query := this.db.Model(&SvsApAuthAudit{})
+	if user, _ := params["user"].(string); user != "" {
+		query = query.Where("user = ?", user)
+	}
+	if event, _ := params["event"].(string); event != "" {
+		query = query.Where("event = ?", event)
+	}
+	if from, _ := params["from"].(string); from != "" {
+		query = query.Where("created_at >= ?", from)
+	}
+	if to, _ := params["to"].(string); to != "" {
+		query = query.Where("created_at <= ?", to)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		this.Response(res, nil, herrors.ErrSysInternal.New(err.Error()))
+		return
+	}
+
+	var audits []SvsApAuthAudit
+	if err := query.Order("id desc").Limit(paging.Limit).Offset(paging.Offset).Find(&audits).Error; err != nil {
+		this.Response(res, nil, herrors.ErrSysInternal.New(err.Error()))
+	} else {
+		this.Response(res, hpaging.NewList(audits, total, paging), nil)
+	}
+}
@@ -0,0 +1,211 @@
+package hapauthsvs
+
+import (
+	"strings"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hpaging"
+	"github.com/drharryhe/has/common/hparam"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+)
+
+const (
+	defaultMaxImportUsers = 1000
+	importSavepoint       = "ap_import_user"
+	likeEscape            = "!" //LIKE的转义字符，不用反斜杠，各数据库对字符串中反斜杠的处理不同
+)
+
+// ImportResult 导入一行的结果，Row为该行在users中的下标
+type ImportResult struct {
+	Row   int    `json:"row"`
+	User  string `json:"user"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// ExportedUser 导出的用户信息，不包含密码hash
+type ExportedUser struct {
+	ID          int64  `json:"id"`
+	User        string `json:"user"`
+	Email       string `json:"email"`
+	LastLogin   string `json:"last_login"`
+	Locked      bool   `json:"locked"`
+	LockedUntil string `json:"locked_until"`
+}
+
+type importUser struct {
+	User         string `param:"user,required"`
+	Password     string `param:"password"`      //明文密码，按PwdEncoding解码，检查强度后生成bcrypt hash
+	PasswordHash string `param:"password_hash"` //已生成的bcrypt hash，如从其他系统迁移，直接保存
+	Email        string `param:"email"`
+}
+
+// ImportUsers 批量导入用户，每行为 {"user":..., "password":...} 或 {"user":..., "password_hash":...}。
+// 所有行在一个事务中写入，某行失败(如用户已存在)只记录该行的错误，不影响其他行
+func (this *Service) ImportUsers(params htypes.Map, res *core.SlotResponse) {
+	rows, _ := params["users"].([]interface{})
+	if len(rows) == 0 {
+		this.Response(res, nil, herrors.ErrCallerInvalidRequest.New("parameter [users] empty").D("bad parameter"))
+		return
+	}
+	if len(rows) > this.conf.MaxImportUsers {
+		this.Response(res, nil, herrors.ErrCallerInvalidRequest.New("%d users exceeds limit %d", len(rows), this.conf.MaxImportUsers).D("too many users"))
+		return
+	}
+
+	users, results := this.prepareImport(rows)
+
+	tx := this.db.Begin()
+	if tx.Error != nil {
+		this.Response(res, nil, herrors.ErrSysInternal.New(tx.Error.Error()))
+		return
+	}
+	for i, u := range users {
+		if u == nil {
+			continue
+		}
+
+		var c int
+		if err := tx.Model(&SvsApAuthUser{}).Where("user = ?", u.User).Count(&c).Error; err != nil {
+			results[i].Error = err.Error()
+			continue
+		} else if c > 0 {
+			results[i].Error = strUserExists
+			continue
+		}
+
+		//写入失败(如并发导入时的唯一约束冲突)只回滚该行
+		tx.Exec("SAVEPOINT " + importSavepoint)
+		if err := tx.Create(u).Error; err != nil {
+			tx.Exec("ROLLBACK TO SAVEPOINT " + importSavepoint)
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].OK = true
+	}
+	if err := tx.Commit().Error; err != nil {
+		this.Response(res, nil, herrors.ErrSysInternal.New(err.Error()).D("failed to import users"))
+		return
+	}
+
+	succeeded := 0
+	for _, r := range results {
+		if r.OK {
+			succeeded++
+		}
+	}
+	this.Response(res, htypes.Map{
+		"total":     len(rows),
+		"succeeded": succeeded,
+		"failed":    len(rows) - succeeded,
+		"rows":      results,
+	}, nil)
+}
+
+// prepareImport 校验每行数据并生成密码hash，无效行对应的用户为nil，结果中记录错误。
+// hash较慢，在开启事务前完成
+func (this *Service) prepareImport(rows []interface{}) ([]*SvsApAuthUser, []ImportResult) {
+	users := make([]*SvsApAuthUser, len(rows))
+	results := make([]ImportResult, len(rows))
+	seen := make(map[string]bool)
+	for i, row := range rows {
+		results[i].Row = i
+
+		var m htypes.Map
+		switch val := row.(type) {
+		case map[string]interface{}:
+			m = val
+		case htypes.Map:
+			m = val
+		default:
+			results[i].Error = "row should be an object"
+			continue
+		}
+
+		var iu importUser
+		if err := hparam.Bind(m, &iu); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].User = iu.User
+		if seen[iu.User] {
+			results[i].Error = "user duplicated in import"
+			continue
+		}
+		seen[iu.User] = true
+
+		hash, err := this.importPwdHash(&iu)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		users[i] = &SvsApAuthUser{User: iu.User, Email: iu.Email, Password: hash}
+	}
+	return users, results
+}
+
+func (this *Service) importPwdHash(iu *importUser) (string, *herrors.Error) {
+	switch {
+	case iu.PasswordHash != "" && iu.Password != "":
+		return "", herrors.ErrCallerInvalidRequest.New("only one of password and password_hash allowed")
+	case iu.PasswordHash != "":
+		if !isBcryptHash(iu.PasswordHash) {
+			return "", herrors.ErrCallerInvalidRequest.New("password_hash should be a bcrypt hash")
+		}
+		return iu.PasswordHash, nil
+	case iu.Password != "":
+		pwd, err := this.decodePwd(iu.Password)
+		if err != nil {
+			return "", err
+		}
+		if err = this.checkPwdStrength(pwd); err != nil {
+			return "", err
+		}
+		return this.hashPwd(pwd)
+	}
+	return "", herrors.ErrCallerInvalidRequest.New("password or password_hash required")
+}
+
+// ExportUsers 按条件分页导出用户，不包含密码hash。user按包含匹配，from和to为最后登录时间范围，格式为 2006-01-02 15:04:05
+func (this *Service) ExportUsers(params htypes.Map, res *core.SlotResponse) {
+	paging, err := hpaging.Parse(params, this.conf.MaxPageSize)
+	if err != nil {
+		this.Response(res, nil, err)
+		return
+	}
+
+	query := this.db.Model(&SvsApAuthUser{})
+	if user, _ := params["user"].(string); user != "" {
+		query = query.Where("user LIKE ? ESCAPE '"+likeEscape+"'", "%"+escapeLike(user)+"%")
+	}
+	if locked, ok := params["locked"].(bool); ok {
+		query = query.Where("locked = ?", locked)
+	}
+	if from, _ := params["from"].(string); from != "" {
+		query = query.Where("last_login >= ?", from)
+	}
+	if to, _ := params["to"].(string); to != "" {
+		query = query.Where("last_login <= ?", to)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		this.Response(res, nil, herrors.ErrSysInternal.New(err.Error()))
+		return
+	}
+
+	users := []ExportedUser{}
+	if err := query.Select("id, user, email, last_login, locked, locked_until").Order("id").Limit(paging.Limit).Offset(paging.Offset).Scan(&users).Error; err != nil {
+		this.Response(res, nil, herrors.ErrSysInternal.New(err.Error()))
+	} else {
+		this.Response(res, hpaging.NewList(users, total, paging), nil)
+	}
+}
+
+var likeEscaper = strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_")
+
+// escapeLike 转义LIKE中的通配符，按字面匹配
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
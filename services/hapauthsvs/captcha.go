@@ -0,0 +1,222 @@
+package hapauthsvs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+)
+
+const (
+	CaptchaReCaptcha = "recaptcha"
+	CaptchaHCaptcha  = "hcaptcha"
+
+	defaultCaptchaField      = "captcha"
+	defaultCaptchaFailWindow = 15 //minute
+	defaultCaptchaTimeout    = 5  //seconds
+
+	maxTrackedAddresses = 100000 //超过时清理已过期的记录
+)
+
+var captchaVerifyURLs = map[string]string{
+	CaptchaReCaptcha: "https://www.google.com/recaptcha/api/siteverify",
+	CaptchaHCaptcha:  "https://hcaptcha.com/siteverify",
+}
+
+// CaptchaVerifier 校验客户端提交的验证码token，remoteIP为空表示未知
+type CaptchaVerifier interface {
+	Verify(ctx context.Context, token string, remoteIP string) (bool, error)
+}
+
+// SiteVerifier 按reCAPTCHA和hCaptcha共同的siteverify协议校验token
+type SiteVerifier struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+func (this *SiteVerifier) Verify(ctx context.Context, token string, remoteIP string) (bool, error) {
+	form := url.Values{"secret": {this.Secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, this.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := this.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("captcha verify status %s", resp.Status)
+	}
+
+	var ret struct {
+		Success bool     `json:"success"`
+		Errors  []string `json:"error-codes"`
+	}
+	if err := jsoniter.NewDecoder(resp.Body).Decode(&ret); err != nil {
+		return false, err
+	}
+	return ret.Success, nil
+}
+
+// addressFails 同一地址在时间窗口内的登录失败次数
+type addressFails struct {
+	count int
+	since time.Time
+}
+
+type failTracker struct {
+	lock   sync.Mutex
+	window time.Duration
+	fails  map[string]*addressFails
+}
+
+func newFailTracker(window time.Duration) *failTracker {
+	return &failTracker{window: window, fails: make(map[string]*addressFails)}
+}
+
+func (this *failTracker) count(addr string) int {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	f := this.fails[addr]
+	if f == nil || time.Since(f.since) > this.window {
+		return 0
+	}
+	return f.count
+}
+
+func (this *failTracker) failed(addr string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	now := time.Now()
+	f := this.fails[addr]
+	if f == nil || now.Sub(f.since) > this.window {
+		if len(this.fails) >= maxTrackedAddresses {
+			for k, v := range this.fails {
+				if now.Sub(v.since) > this.window {
+					delete(this.fails, k)
+				}
+			}
+		}
+		f = &addressFails{since: now}
+		this.fails[addr] = f
+	}
+	f.count++
+}
+
+func (this *failTracker) reset(addr string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	delete(this.fails, addr)
+}
+
+// SetCaptchaVerifier 设置验证码校验方式，替换按CaptchaProvider配置的校验
+func (this *Service) SetCaptchaVerifier(v CaptchaVerifier) {
+	this.captcha = v
+}
+
+func (this *Service) initCaptcha() *herrors.Error {
+	if this.conf.CaptchaAfterFails <= 0 {
+		return nil
+	}
+	if this.conf.CaptchaField == "" {
+		this.conf.CaptchaField = defaultCaptchaField
+	}
+	if this.conf.CaptchaFailWindow <= 0 {
+		this.conf.CaptchaFailWindow = defaultCaptchaFailWindow
+	}
+	if this.conf.CaptchaTimeout <= 0 {
+		this.conf.CaptchaTimeout = defaultCaptchaTimeout
+	}
+	this.addressFails = newFailTracker(time.Duration(this.conf.CaptchaFailWindow) * time.Minute)
+
+	if this.captcha != nil {
+		return nil
+	}
+	u := this.conf.CaptchaVerifyURL
+	if u == "" {
+		u = captchaVerifyURLs[this.conf.CaptchaProvider]
+	}
+	if u == "" || this.conf.CaptchaSecret == "" {
+		return herrors.ErrSysInternal.New("CaptchaProvider or CaptchaVerifyURL and CaptchaSecret required when CaptchaAfterFails configured").D("failed to open ap service")
+	}
+	this.captcha = &SiteVerifier{URL: u, Secret: this.conf.CaptchaSecret, Client: &http.Client{Timeout: time.Duration(this.conf.CaptchaTimeout) * time.Second}}
+	return nil
+}
+
+// loginAddress 登录请求的客户端地址，取自InAddressField指定的参数，未配置时使用连接器设置的请求地址
+func (this *Service) loginAddress(params htypes.Map) string {
+	if this.conf.InAddressField != "" {
+		if addr, _ := params[this.conf.InAddressField].(string); addr != "" {
+			return addr
+		}
+	}
+	return core.ScopedString(params, core.ScopeAddress)
+}
+
+// checkCaptcha 同一地址登录失败达到CaptchaAfterFails次后要求验证码，没有或校验失败时返回错误，
+// 错误的fields中包含验证码参数，客户端据此显示验证码
+func (this *Service) checkCaptcha(params htypes.Map, addr string) *herrors.Error {
+	if this.addressFails == nil || addr == "" {
+		return nil
+	}
+	fails := this.addressFails.count(addr)
+	if fails < this.conf.CaptchaAfterFails {
+		return nil
+	}
+
+	token, _ := params[this.conf.CaptchaField].(string)
+	if token == "" {
+		return herrors.ErrUserUnauthorizedAct.New("%d login failures from %s", fails, addr).D(strCaptchaRequired).WithFields(herrors.FieldError{
+			Field: this.conf.CaptchaField, ID: "captcha.required", Message: strCaptchaRequired,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(core.RequestContext(params), time.Duration(this.conf.CaptchaTimeout)*time.Second)
+	defer cancel()
+	ok, err := this.captcha.Verify(ctx, token, addr)
+	if err != nil {
+		return herrors.ErrSysUnavailable.New(err.Error()).D("failed to verify captcha")
+	}
+	if !ok {
+		this.addressFails.failed(addr)
+		return herrors.ErrUserUnauthorizedAct.New("captcha rejected by verifier").D(strInvalidCaptcha).WithFields(herrors.FieldError{
+			Field: this.conf.CaptchaField, ID: "captcha.invalid", Message: strInvalidCaptcha,
+		})
+	}
+	return nil
+}
+
+func (this *Service) addressFailed(addr string) {
+	if this.addressFails != nil && addr != "" {
+		this.addressFails.failed(addr)
+	}
+}
+
+func (this *Service) addressSucceeded(addr string) {
+	if this.addressFails != nil && addr != "" {
+		this.addressFails.reset(addr)
+	}
+}
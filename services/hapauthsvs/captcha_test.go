@@ -0,0 +1,81 @@
+package hapauthsvs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/drharryhe/has/common/htypes"
+)
+
+type fakeCaptcha struct {
+	token string
+}
+
+func (this *fakeCaptcha) Verify(ctx context.Context, token string, remoteIP string) (bool, error) {
+	return token == this.token, nil
+}
+
+func TestCheckCaptcha(t *testing.T) {
+	service := &Service{}
+	service.conf.CaptchaAfterFails = 2
+	service.conf.InAddressField = "Address"
+	service.SetCaptchaVerifier(&fakeCaptcha{token: "ok"})
+	if err := service.initCaptcha(); err != nil {
+		t.Fatal(err)
+	}
+
+	ps := htypes.Map{"Address": "10.0.0.1"}
+	addr := service.loginAddress(ps)
+	for i := 0; i < 2; i++ {
+		if err := service.checkCaptcha(ps, addr); err != nil {
+			t.Fatalf("failure %d: %v", i, err)
+		}
+		service.addressFailed(addr)
+	}
+
+	err := service.checkCaptcha(ps, addr)
+	if err == nil || err.Desc != strCaptchaRequired || len(err.Fields) != 1 || err.Fields[0].Field != "captcha" {
+		t.Fatalf("err = %v", err)
+	}
+	//其他地址不受影响
+	if err := service.checkCaptcha(htypes.Map{"Address": "10.0.0.2"}, "10.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+
+	ps["captcha"] = "bad"
+	if err := service.checkCaptcha(ps, addr); err == nil || err.Desc != strInvalidCaptcha {
+		t.Fatalf("err = %v", err)
+	}
+	ps["captcha"] = "ok"
+	if err := service.checkCaptcha(ps, addr); err != nil {
+		t.Fatal(err)
+	}
+
+	service.addressSucceeded(addr)
+	delete(ps, "captcha")
+	if err := service.checkCaptcha(ps, addr); err != nil {
+		t.Fatalf("after success: %v", err)
+	}
+}
+
+func TestSiteVerifier(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.Form.Get("secret") == "s" && r.Form.Get("response") == "ok" && r.Form.Get("remoteip") == "10.0.0.1" {
+			_, _ = w.Write([]byte(`{"success":true}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"success":false,"error-codes":["invalid-input-response"]}`))
+	}))
+	defer srv.Close()
+
+	v := &SiteVerifier{URL: srv.URL, Secret: "s"}
+	if ok, err := v.Verify(context.Background(), "ok", "10.0.0.1"); !ok || err != nil {
+		t.Fatalf("ok = %v, err = %v", ok, err)
+	}
+	if ok, err := v.Verify(context.Background(), "bad", "10.0.0.1"); ok || err != nil {
+		t.Fatalf("ok = %v, err = %v", ok, err)
+	}
+}
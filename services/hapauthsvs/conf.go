@@ -13,19 +13,46 @@ type ApAuthService struct {
 	SessionRevokeSlot      string
 	PwdEncoding            string
 	PwdSecret              string
+	PwdBcryptCost          int //bcrypt计算强度，缺省为10
 	PwdMinLen              int
 	PwdMaxLen              int
 	PwdUpperAndLowerLetter bool
 	PwdNumberAndLetter     bool
 	PwdSymbol              bool
 	PwdSymbols             string
+	PwdHistory             int //不允许重复使用最近几次的密码，0表示不检查
 	DefaultPwd             string
 	SuperName              string
 	SuperPwd               string
 	SuperFails             int
 	LockAfterFails         int
+	LockDuration           int //minute, 锁定后自动解锁的时长，0表示不自动解锁
 	InAddressField         string
 	InAgentField           string
 	OutAddressField        string
 	OutAgentField          string
+	JwtSecret              string //配置后登录成功时同时签发JWT
+	JwtAlgorithm           string //HS256, HS384, HS512
+	JwtExpire              int    //minute
+	JwtIssuer              string
+	SessionPlugin          string //服务端会话插件，如 SessionPlugin，配置后登录成功时创建会话，退出登录时销毁
+	MaxPageSize            int    //用户列表每页最大数量，缺省为100
+	MaxImportUsers         int    //批量导入每次最多的用户数，缺省为1000
+	AuditDB                bool   //认证审计记录写入数据库表
+	AuditLog               bool   //认证审计记录写入日志
+
+	CaptchaAfterFails int    //同一客户端地址登录失败达到该次数后要求验证码，0表示不要求
+	CaptchaFailWindow int    //minute, 统计失败次数的时间窗口，缺省为15，登录成功后清零
+	CaptchaField      string //验证码token的参数名，缺省为 captcha
+	CaptchaProvider   string //recaptcha | hcaptcha
+	CaptchaSecret     string
+	CaptchaVerifyURL  string //校验地址，缺省按CaptchaProvider
+	CaptchaTimeout    int    //seconds, 校验请求的超时，缺省为5
+
+	ResetMailPlugin   string //发送找回密码邮件的邮件插件，如 MailPlugin，未配置时不能找回密码
+	ResetMailTemplate string //找回密码邮件的模板，缺省为 pwd_reset
+	ResetURL          string //找回密码链接，其中的 {token} 替换为token，作为模板数据的URL
+	ResetTokenExpire  int    //minute, 找回密码token的有效期，缺省为30
+	ResetRateLimit    int    //同一用户或客户端地址在ResetRateWindow内最多请求找回密码的次数，缺省为3
+	ResetRateWindow   int    //minute, 缺省为60
 }
@@ -13,4 +13,9 @@ var (
 	strPwdNumberRequired              = "number required in password"
 	strPwdLetterRequired              = "letter required in password"
 	strPwdUpperAndLowerLetterRequired = "both upper and lower letter required in password"
+	strPwdRecentlyUsed                = "password used in the last %d passwords"
+	strCaptchaRequired                = "captcha required"
+	strInvalidCaptcha                 = "invalid captcha"
+	strInvalidResetToken              = "invalid or expired reset token"
+	strTooManyResetRequests           = "too many password reset requests"
 )
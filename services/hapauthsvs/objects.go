@@ -2,10 +2,41 @@ package hapauthsvs
 
 //账密登录用户表
 type SvsApAuthUser struct {
+	ID          int64  `json:"id"`
+	User        string `json:"user" gorm:"size:50;unique;index:user_idx"` //用户名，即账号
+	Email       string `json:"email" gorm:"size:100"`                     //邮箱，用于找回密码，为空时发送到用户名(如果是邮箱地址)
+	Password    string `json:"-" gorm:"size:100"`                         //用户密码，bcrypt hash
+	LastLogin   string `json:"last_login" gorm:"size:19"`                 //最后一次登录
+	Locked      bool   `json:"-"`                                         //账号是否被锁定
+	LockedUntil string `json:"-" gorm:"size:19"`                          //自动解锁时间，为空表示不自动解锁
+	Fails       int    `json:"-"`                                         //登录失败次数
+}
+
+//历史密码表，用于防止重复使用最近的密码
+type SvsApAuthPwdHistory struct {
+	ID        int64  `json:"id"`
+	UserID    int64  `json:"user_id" gorm:"index:pwd_history_user_idx"`
+	Password  string `json:"-" gorm:"size:100"`
+	CreatedAt string `json:"created_at" gorm:"size:19"`
+}
+
+//认证审计表，记录登录、锁定和密码修改等事件
+type SvsApAuthAudit struct {
+	ID        int64  `json:"id"`
+	User      string `json:"user" gorm:"size:50;index:audit_user_idx"`
+	Event     string `json:"event" gorm:"size:20"`
+	Address   string `json:"address" gorm:"size:50"` //来源IP，由connector的AddressField传入
+	Agent     string `json:"agent" gorm:"size:200"`
+	Detail    string `json:"detail" gorm:"size:200"`
+	CreatedAt string `json:"created_at" gorm:"size:19;index:audit_time_idx"`
+}
+
+//找回密码token表，只保存token的sha256，使用后或过期后失效
+type SvsApAuthResetToken struct {
 	ID        int64  `json:"id"`
-	User      string `json:"user" gorm:"size:50;unique;index:user_idx"` //用户名，即账号
-	Password  string `json:"-" gorm:"size:32"`                          //用户密码
-	LastLogin string `json:"last_login" gorm:"size:19"`                 //最后一次登录
-	Locked    bool   `json:"-"`                                         //账号是否被锁定
-	Fails     int    `json:"-"`                                         //登录失败次数
+	UserID    int64  `json:"user_id" gorm:"index:reset_token_user_idx"`
+	Token     string `json:"-" gorm:"size:64;unique"`
+	ExpiresAt string `json:"expires_at" gorm:"size:19"`
+	Used      bool   `json:"used"`
+	CreatedAt string `json:"created_at" gorm:"size:19"`
 }
@@ -0,0 +1,93 @@
+package hapauthsvs
+
+import (
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/utils/hdatetime"
+)
+
+// hashPwd 使用bcrypt生成密码hash
+func (this *Service) hashPwd(pwd string) (string, *herrors.Error) {
+	cost := this.conf.PwdBcryptCost
+	if cost <= 0 {
+		cost = bcrypt.DefaultCost
+	}
+
+	bs, err := bcrypt.GenerateFromPassword([]byte(pwd), cost)
+	if err != nil {
+		return "", herrors.ErrSysInternal.New(err.Error()).D("failed to hash password")
+	}
+	return string(bs), nil
+}
+
+// verifyPwd 校验密码，兼容bcrypt之前的旧编码格式。
+// legacy为true表示存储的是旧格式，调用方应在校验通过后用bcrypt重新生成hash
+func (this *Service) verifyPwd(hash string, pwd string) (ok bool, legacy bool) {
+	if hash == "" {
+		return false, false
+	}
+
+	if isBcryptHash(hash) {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pwd)) == nil, false
+	}
+
+	return hash == this.pwdEncodingFunc(pwd), true
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// checkPwdHistory 检查新密码是否与当前密码或最近PwdHistory次使用过的密码相同
+func (this *Service) checkPwdHistory(user *SvsApAuthUser, pwd string) *herrors.Error {
+	if this.conf.PwdHistory <= 0 {
+		return nil
+	}
+
+	if ok, _ := this.verifyPwd(user.Password, pwd); ok {
+		return herrors.ErrCallerInvalidRequest.New(strPwdRecentlyUsed, this.conf.PwdHistory).D(strTooWeakPassword)
+	}
+
+	var histories []SvsApAuthPwdHistory
+	if err := this.db.Where("user_id = ?", user.ID).Order("id desc").Limit(this.conf.PwdHistory).Find(&histories).Error; err != nil {
+		return herrors.ErrSysInternal.New(err.Error())
+	}
+	for _, h := range histories {
+		if ok, _ := this.verifyPwd(h.Password, pwd); ok {
+			return herrors.ErrCallerInvalidRequest.New(strPwdRecentlyUsed, this.conf.PwdHistory).D(strTooWeakPassword)
+		}
+	}
+	return nil
+}
+
+// addPwdHistory 在修改密码前记录当前密码，只保留最近PwdHistory条
+func (this *Service) addPwdHistory(user *SvsApAuthUser) {
+	if this.conf.PwdHistory <= 0 || user.ID == 0 || user.Password == "" {
+		return
+	}
+
+	h := SvsApAuthPwdHistory{
+		UserID:    user.ID,
+		Password:  user.Password,
+		CreatedAt: hdatetime.Now(),
+	}
+	if err := this.db.Save(&h).Error; err != nil {
+		hlogger.Error(herrors.ErrSysInternal.New(err.Error()))
+		return
+	}
+
+	var expired []int64
+	if err := this.db.Model(&SvsApAuthPwdHistory{}).Where("user_id = ?", user.ID).Order("id desc").Offset(this.conf.PwdHistory).Pluck("id", &expired).Error; err != nil {
+		hlogger.Error(herrors.ErrSysInternal.New(err.Error()))
+		return
+	}
+	if len(expired) > 0 {
+		if err := this.db.Where("id in (?)", expired).Delete(&SvsApAuthPwdHistory{}).Error; err != nil {
+			hlogger.Error(herrors.ErrSysInternal.New(err.Error()))
+		}
+	}
+}
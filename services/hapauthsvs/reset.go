@@ -0,0 +1,234 @@
+package hapauthsvs
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/drharryhe/has/common/herrors"
+	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/htypes"
+	"github.com/drharryhe/has/core"
+	"github.com/drharryhe/has/plugins/hmailplugin"
+	"github.com/drharryhe/has/utils/hdatetime"
+	"github.com/drharryhe/has/utils/hencoder"
+)
+
+const (
+	defaultResetMailTemplate = "pwd_reset"
+	defaultResetTokenExpire  = 30 //minute
+	defaultResetRateLimit    = 3
+	defaultResetRateWindow   = 60 //minute
+
+	resetTokenBytes  = 32
+	resetTokenHolder = "{token}"
+)
+
+func (this *Service) initPwdReset() *herrors.Error {
+	if this.conf.ResetMailPlugin == "" {
+		return nil
+	}
+	mails, ok := this.UsePlugin(this.conf.ResetMailPlugin).(*hmailplugin.Plugin)
+	if !ok {
+		return herrors.ErrSysInternal.New("plugin %s is not a mail plugin", this.conf.ResetMailPlugin).D("failed to open ap service")
+	}
+	this.mails = mails
+	this.applyResetDefaults()
+	return nil
+}
+
+func (this *Service) applyResetDefaults() {
+	if this.conf.ResetMailTemplate == "" {
+		this.conf.ResetMailTemplate = defaultResetMailTemplate
+	}
+	if this.conf.ResetTokenExpire <= 0 {
+		this.conf.ResetTokenExpire = defaultResetTokenExpire
+	}
+	if this.conf.ResetRateLimit <= 0 {
+		this.conf.ResetRateLimit = defaultResetRateLimit
+	}
+	if this.conf.ResetRateWindow <= 0 {
+		this.conf.ResetRateWindow = defaultResetRateWindow
+	}
+	this.resetRequests = newFailTracker(time.Duration(this.conf.ResetRateWindow) * time.Minute)
+}
+
+// RequestPwdReset 生成一次性的找回密码token，通过邮件发送给用户，之前未使用的token失效。
+// 为避免泄露用户是否存在，用户不存在、没有邮箱或邮件发送失败时同样返回成功
+func (this *Service) RequestPwdReset(params htypes.Map, res *core.SlotResponse) {
+	if this.mails == nil {
+		this.Response(res, nil, herrors.ErrCallerInvalidRequest.New("ResetMailPlugin not configured").D("password reset not supported"))
+		return
+	}
+
+	user := params["user"].(string)
+	if err := this.checkResetRate(user, this.loginAddress(params)); err != nil {
+		this.audit(params, user, AuditPwdResetRequested, err.Desc)
+		this.Response(res, nil, err)
+		return
+	}
+
+	var u SvsApAuthUser
+	if err := this.db.Where("user = ?", user).First(&u).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			this.Response(res, nil, herrors.ErrSysInternal.New(err.Error()))
+			return
+		}
+		this.audit(params, user, AuditPwdResetRequested, strUserNotExits)
+		this.Response(res, nil, nil)
+		return
+	}
+	to := resetRecipient(&u)
+	if to == "" {
+		this.audit(params, user, AuditPwdResetRequested, "no email")
+		this.Response(res, nil, nil)
+		return
+	}
+
+	//发送失败时同样返回成功，避免响应泄露用户是否存在
+	if err := this.sendResetToken(&u, to); err != nil {
+		hlogger.Error(err.D("failed to send password reset mail"))
+		this.audit(params, u.User, AuditPwdResetRequested, "failed to send mail")
+	} else {
+		this.audit(params, u.User, AuditPwdResetRequested, "")
+	}
+	this.Response(res, nil, nil)
+}
+
+// sendResetToken 生成token并发送邮件，用户之前的token和所有已过期的token被删除
+func (this *Service) sendResetToken(u *SvsApAuthUser, to string) *herrors.Error {
+	token, err := newResetToken()
+	if err != nil {
+		return err
+	}
+	now := hdatetime.Now()
+	if e := this.db.Where("user_id = ? OR expires_at < ?", u.ID, now).Delete(&SvsApAuthResetToken{}).Error; e != nil {
+		return herrors.ErrSysInternal.New(e.Error())
+	}
+	t := SvsApAuthResetToken{
+		UserID:    u.ID,
+		Token:     hashResetToken(token),
+		ExpiresAt: time.Now().Local().Add(time.Duration(this.conf.ResetTokenExpire) * time.Minute).Format("2006-01-02 15:04:05"),
+		CreatedAt: now,
+	}
+	if e := this.db.Save(&t).Error; e != nil {
+		return herrors.ErrSysInternal.New(e.Error())
+	}
+	return this.mails.Send(to, this.conf.ResetMailTemplate, this.resetMailData(u, token))
+}
+
+// CompletePwdReset 校验找回密码token并设置新密码，新密码检查强度和历史密码。token使用后失效，账号同时解锁
+func (this *Service) CompletePwdReset(params htypes.Map, res *core.SlotResponse) {
+	pwd, err := this.decodePwd(params["new_password"].(string))
+	if err != nil {
+		this.Response(res, nil, err)
+		return
+	}
+
+	var t SvsApAuthResetToken
+	var u SvsApAuthUser
+	if e := this.db.Where("token = ?", hashResetToken(params["token"].(string))).First(&t).Error; e != nil || t.Used || t.ExpiresAt <= hdatetime.Now() ||
+		this.db.Where("id = ?", t.UserID).First(&u).Error != nil {
+		this.Response(res, nil, invalidResetToken())
+		return
+	}
+
+	if err := this.checkPwdStrength(pwd); err != nil {
+		this.Response(res, nil, err.D(strTooWeakPassword))
+		return
+	}
+	if err := this.checkPwdHistory(&u, pwd); err != nil {
+		this.Response(res, nil, err)
+		return
+	}
+	hash, err := this.hashPwd(pwd)
+	if err != nil {
+		this.Response(res, nil, err)
+		return
+	}
+
+	//以条件更新标记token已使用，同一token并发的请求只有一个成功
+	r := this.db.Model(&SvsApAuthResetToken{}).Where("id = ? AND used = ?", t.ID, false).Update("used", true)
+	if r.Error != nil {
+		this.Response(res, nil, herrors.ErrSysInternal.New(r.Error.Error()))
+		return
+	}
+	if r.RowsAffected == 0 {
+		this.Response(res, nil, invalidResetToken())
+		return
+	}
+
+	this.addPwdHistory(&u)
+	u.Password = hash
+	u.Locked = false
+	u.LockedUntil = ""
+	u.Fails = 0
+	if e := this.db.Save(&u).Error; e != nil {
+		this.Response(res, nil, herrors.ErrSysInternal.New(e.Error()))
+		return
+	}
+	if e := this.db.Where("user_id = ?", u.ID).Delete(&SvsApAuthResetToken{}).Error; e != nil {
+		hlogger.Error(herrors.ErrSysInternal.New(e.Error()))
+	}
+	this.audit(params, u.User, AuditPwdReset, "reset by token")
+	this.Response(res, nil, nil)
+}
+
+// checkResetRate 同一用户或客户端地址在ResetRateWindow内最多请求ResetRateLimit次，不存在的用户同样计数
+func (this *Service) checkResetRate(user string, addr string) *herrors.Error {
+	keys := []string{"user:" + user}
+	if addr != "" {
+		keys = append(keys, "addr:"+addr)
+	}
+	for _, k := range keys {
+		if this.resetRequests.count(k) >= this.conf.ResetRateLimit {
+			return herrors.ErrCallerTooManyRequests.New("%s requested password reset %d times in %d minutes", k, this.conf.ResetRateLimit, this.conf.ResetRateWindow).D(strTooManyResetRequests)
+		}
+	}
+	for _, k := range keys {
+		this.resetRequests.failed(k)
+	}
+	return nil
+}
+
+// resetMailData 找回密码邮件的模板数据
+func (this *Service) resetMailData(u *SvsApAuthUser, token string) htypes.Map {
+	return htypes.Map{
+		"User":   u.User,
+		"Token":  token,
+		"URL":    strings.ReplaceAll(this.conf.ResetURL, resetTokenHolder, token),
+		"Expire": this.conf.ResetTokenExpire,
+	}
+}
+
+// resetRecipient 用户的邮箱，没有设置时如果用户名是邮箱地址则使用用户名
+func resetRecipient(u *SvsApAuthUser) string {
+	for _, addr := range []string{u.Email, u.User} {
+		if a, err := mail.ParseAddress(addr); err == nil && a.Address == addr {
+			return addr
+		}
+	}
+	return ""
+}
+
+func newResetToken() (string, *herrors.Error) {
+	bs := make([]byte, resetTokenBytes)
+	if _, err := rand.Read(bs); err != nil {
+		return "", herrors.ErrSysInternal.New(err.Error()).D("failed to generate reset token")
+	}
+	return base64.RawURLEncoding.EncodeToString(bs), nil
+}
+
+func hashResetToken(token string) string {
+	return hencoder.Sha256ToString([]byte(token))
+}
+
+func invalidResetToken() *herrors.Error {
+	return herrors.ErrUserInvalidAct.New(strInvalidResetToken).D(strInvalidResetToken).WithFields(herrors.FieldError{
+		Field: "token", ID: "token.invalid", Message: strInvalidResetToken,
+	})
+}
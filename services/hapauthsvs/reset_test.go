@@ -0,0 +1,64 @@
+package hapauthsvs
+
+import (
+	"testing"
+)
+
+func TestCheckResetRate(t *testing.T) {
+	service := &Service{}
+	service.conf.ResetRateLimit = 2
+	service.applyResetDefaults()
+
+	for i := 0; i < 2; i++ {
+		if err := service.checkResetRate("u1", "10.0.0.1"); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if err := service.checkResetRate("u1", "10.0.0.2"); err == nil || err.Desc != strTooManyResetRequests {
+		t.Fatalf("user limit: err = %v", err)
+	}
+	if err := service.checkResetRate("u2", "10.0.0.1"); err == nil || err.Desc != strTooManyResetRequests {
+		t.Fatalf("address limit: err = %v", err)
+	}
+	if err := service.checkResetRate("u2", "10.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResetToken(t *testing.T) {
+	t1, err := newResetToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t2, _ := newResetToken()
+	if t1 == t2 || len(t1) < 40 {
+		t.Fatalf("tokens = %q, %q", t1, t2)
+	}
+	if h := hashResetToken(t1); h == t1 || len(h) != 64 || h != hashResetToken(t1) {
+		t.Fatalf("hash = %q", h)
+	}
+
+	service := &Service{}
+	service.conf.ResetURL = "https://example.com/reset?token={token}"
+	service.applyResetDefaults()
+	data := service.resetMailData(&SvsApAuthUser{User: "u1"}, t1)
+	if data["URL"] != "https://example.com/reset?token="+t1 || data["Expire"] != defaultResetTokenExpire {
+		t.Fatalf("data = %v", data)
+	}
+}
+
+func TestResetRecipient(t *testing.T) {
+	for _, c := range []struct {
+		user SvsApAuthUser
+		to   string
+	}{
+		{SvsApAuthUser{User: "u1", Email: "u1@example.com"}, "u1@example.com"},
+		{SvsApAuthUser{User: "u2@example.com"}, "u2@example.com"},
+		{SvsApAuthUser{User: "u3"}, ""},
+		{SvsApAuthUser{User: "u4", Email: "U4 <u4@example.com>"}, ""},
+	} {
+		if to := resetRecipient(&c.user); to != c.to {
+			t.Errorf("%s: to = %q, want %q", c.user.User, to, c.to)
+		}
+	}
+}
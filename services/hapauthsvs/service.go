@@ -2,24 +2,31 @@ package hapauthsvs
 
 import (
 	"encoding/base64"
+	"fmt"
 	"reflect"
 	"regexp"
+	"time"
 
 	"github.com/jinzhu/gorm"
 
 	"github.com/drharryhe/has/common/hconf"
 	"github.com/drharryhe/has/common/herrors"
 	"github.com/drharryhe/has/common/hlogger"
+	"github.com/drharryhe/has/common/hpaging"
 	"github.com/drharryhe/has/common/htypes"
 	"github.com/drharryhe/has/core"
 	"github.com/drharryhe/has/plugins/hgormplugin"
+	"github.com/drharryhe/has/plugins/hmailplugin"
+	"github.com/drharryhe/has/plugins/hsessionplugin"
 	"github.com/drharryhe/has/utils/hconverter"
 	"github.com/drharryhe/has/utils/hdatetime"
 	"github.com/drharryhe/has/utils/hencoder"
+	"github.com/drharryhe/has/utils/hjwt"
 )
 
 const (
-	defaultRootPwd = "89c766f8cf1624a178f4c8cf599d978b"
+	defaultRootPwd   = "89c766f8cf1624a178f4c8cf599d978b"
+	defaultJwtExpire = 60 * 24 //minute
 )
 
 type PasswordEncodingFunc func(pwd string) string
@@ -34,6 +41,12 @@ type Service struct {
 	loginHook       *core.MethodCaller
 	db              *gorm.DB
 	conf            ApAuthService
+	jwt             *hjwt.Signer
+	sessions        *hsessionplugin.Plugin
+	captcha         CaptchaVerifier
+	addressFails    *failTracker //按客户端地址统计的登录失败次数
+	mails           *hmailplugin.Plugin
+	resetRequests   *failTracker //按用户和客户端地址统计的找回密码请求次数
 }
 
 func (this *Service) Open(s core.IServer, instance core.IService, args ...htypes.Any) *herrors.Error {
@@ -54,6 +67,14 @@ func (this *Service) Open(s core.IServer, instance core.IService, args ...htypes
 
 	plugin := this.UsePlugin("GormPlugin").(*hgormplugin.Plugin)
 	this.db, err = plugin.AddObjects(this.Objects())
+	if err != nil {
+		return err
+	}
+
+	//bcrypt hash比旧格式的密码长，已存在的表需要加宽password列
+	if e := this.db.Model(&SvsApAuthUser{}).ModifyColumn("password", "varchar(100)").Error; e != nil {
+		hlogger.Warn("failed to widen password column: %s", e.Error())
+	}
 
 	if this.conf.SessionService == "" {
 		return herrors.ErrSysInternal.New("SessionService not configured")
@@ -71,6 +92,37 @@ func (this *Service) Open(s core.IServer, instance core.IService, args ...htypes
 		return herrors.ErrSysInternal.New("SessionRevokeSlot not configured")
 	}
 
+	if this.conf.JwtSecret != "" {
+		if this.conf.JwtExpire <= 0 {
+			this.conf.JwtExpire = defaultJwtExpire
+		}
+		signer, e := hjwt.New(this.conf.JwtSecret, this.conf.JwtAlgorithm, time.Duration(this.conf.JwtExpire)*time.Minute, this.conf.JwtIssuer)
+		if e != nil {
+			return herrors.ErrSysInternal.New(e.Error()).D("failed to init jwt")
+		}
+		this.jwt = signer
+	}
+
+	if this.conf.MaxImportUsers <= 0 {
+		this.conf.MaxImportUsers = defaultMaxImportUsers
+	}
+
+	if this.conf.SessionPlugin != "" {
+		sessions, ok := this.UsePlugin(this.conf.SessionPlugin).(*hsessionplugin.Plugin)
+		if !ok {
+			return herrors.ErrSysInternal.New("plugin %s is not a session plugin", this.conf.SessionPlugin).D("failed to open ap service")
+		}
+		this.sessions = sessions
+	}
+
+	if err := this.initCaptcha(); err != nil {
+		return err
+	}
+
+	if err := this.initPwdReset(); err != nil {
+		return err
+	}
+
 	return err
 }
 
@@ -96,18 +148,38 @@ func (this *Service) Login(params htypes.Map, res *core.SlotResponse) {
 		return
 	}
 
+	addr := this.loginAddress(params)
+	if err := this.checkCaptcha(params, addr); err != nil {
+		this.audit(params, user, AuditLoginFailed, err.Desc)
+		this.Response(res, nil, err)
+		return
+	}
+
 	isRoot := false
 	if user == this.conf.SuperName {
-		if this.conf.SuperFails >= this.conf.SuperFails {
+		if this.superLocked() {
+			this.addressFailed(addr)
+			this.audit(params, user, AuditLoginFailed, strUserLocked)
 			this.Response(res, nil, herrors.ErrUserUnauthorizedAct.New(strUserLocked))
 			return
 		}
-		if this.conf.SuperPwd == "" || this.conf.SuperPwd != this.pwdEncodingFunc(pwd) {
+		ok, legacy := this.verifyPwd(this.conf.SuperPwd, pwd)
+		if !ok {
+			this.addressFailed(addr)
 			this.conf.SuperFails++
 			hconf.Save()
+			this.audit(params, user, AuditLoginFailed, strInvalidUserOrPassword)
 			this.Response(res, nil, herrors.ErrUserInvalidAct.New(strInvalidUserOrPassword))
 			return
 		}
+		if legacy {
+			if hash, err := this.hashPwd(pwd); err != nil {
+				hlogger.Error(err)
+			} else {
+				this.conf.SuperPwd = hash
+				hconf.Save()
+			}
+		}
 
 		isRoot = true
 	}
@@ -115,6 +187,8 @@ func (this *Service) Login(params htypes.Map, res *core.SlotResponse) {
 	if !isRoot {
 		if err := this.db.Where("user = ?", user).Find(&u).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
+				this.addressFailed(addr)
+				this.audit(params, user, AuditLoginFailed, strUserNotExits)
 				this.Response(res, nil, herrors.ErrUserInvalidAct.New(err.Error()).D(strInvalidUserOrPassword))
 			} else {
 				this.Response(res, nil, herrors.ErrSysInternal.New(err.Error()))
@@ -122,20 +196,29 @@ func (this *Service) Login(params htypes.Map, res *core.SlotResponse) {
 			return
 		}
 
-		if u.Locked {
+		if this.isLocked(&u) {
+			this.addressFailed(addr)
+			this.audit(params, user, AuditLoginFailed, strUserLocked)
 			this.Response(res, nil, herrors.ErrUserUnauthorizedAct.New(strUserLocked))
 			return
 		}
 
-		if u.Password != this.pwdEncodingFunc(pwd) {
+		ok, legacy := this.verifyPwd(u.Password, pwd)
+		if !ok {
+			this.addressFailed(addr)
+			this.audit(params, user, AuditLoginFailed, strInvalidUserOrPassword)
 			this.Response(res, nil, herrors.ErrUserInvalidAct.New(strInvalidUserOrPassword))
-			u.Fails++
-			if u.Fails >= this.conf.LockAfterFails {
-				u.Locked = true
-			}
-			this.saveUser(&u)
+			this.loginFailed(&u, params)
 			return
 		}
+		//旧格式的密码在登录成功时转为bcrypt
+		if legacy {
+			if hash, err := this.hashPwd(pwd); err != nil {
+				hlogger.Error(err)
+			} else {
+				u.Password = hash
+			}
+		}
 
 		u.LastLogin = hdatetime.Now()
 		u.Fails = 0
@@ -174,6 +257,25 @@ func (this *Service) Login(params htypes.Map, res *core.SlotResponse) {
 	} else {
 		result["token"] = string(bs.([]byte))
 	}
+
+	if this.jwt != nil {
+		token, e := this.jwt.Issue(u.User, map[string]interface{}{"uid": u.ID})
+		if e != nil {
+			this.Response(res, nil, herrors.ErrSysInternal.New(e.Error()).D("failed to issue jwt"))
+			return
+		}
+		result["jwt"] = token
+	}
+	if this.sessions != nil {
+		s, err := this.sessions.Create(u.User, htypes.Map{"uid": u.ID})
+		if err != nil {
+			this.Response(res, nil, err)
+			return
+		}
+		result["session"] = s.ID
+	}
+	this.addressSucceeded(addr)
+	this.audit(params, u.User, AuditLoginSuccess, "")
 	this.Response(res, &result, nil)
 }
 
@@ -218,7 +320,17 @@ func (this *Service) CheckLogin(params htypes.Map, res *core.SlotResponse) {
 	this.Response(res, &result, nil)
 }
 
+// Logout 销毁的会话只取自connector写入的请求作用域，不使用调用方提交的参数
 func (this *Service) Logout(params htypes.Map, res *core.SlotResponse) {
+	if this.sessions != nil {
+		if id := core.ScopedString(params, core.ScopeSession); id != "" {
+			if err := this.sessions.Destroy(id); err != nil {
+				this.Response(res, nil, err)
+				return
+			}
+		}
+	}
+
 	_, err := this.Server().RequestService(this.conf.SessionService, this.conf.SessionRevokeSlot, params)
 	if err != nil {
 		this.Response(res, nil, err)
@@ -237,14 +349,14 @@ func (this *Service) ChangeSuperPwd(params htypes.Map, res *core.SlotResponse) {
 		return
 	}
 
-	if this.conf.SuperFails >= this.conf.LockAfterFails {
+	if this.superLocked() {
 		this.conf.SuperFails++
 		hconf.Save()
 		this.Response(res, nil, herrors.ErrUserUnauthorizedAct.New(strUserLocked))
 		return
 	}
 
-	if this.conf.SuperPwd != this.pwdEncodingFunc(hencoder.Sha256Hash(pwdOld)) {
+	if ok, _ := this.verifyPwd(this.conf.SuperPwd, pwdOld); !ok {
 		this.Response(res, nil, herrors.ErrUserInvalidAct.New(strInvalidUserOrPassword))
 		return
 	}
@@ -255,8 +367,14 @@ func (this *Service) ChangeSuperPwd(params htypes.Map, res *core.SlotResponse) {
 		return
 	}
 
-	this.conf.SuperPwd = this.pwdEncodingFunc(hencoder.Sha256Hash(pwdNew))
+	hash, err := this.hashPwd(pwdNew)
+	if err != nil {
+		this.Response(res, nil, err)
+		return
+	}
+	this.conf.SuperPwd = hash
 	hconf.Save()
+	this.audit(params, this.conf.SuperName, AuditPwdChanged, "")
 }
 
 func (this *Service) ChangePwd(params htypes.Map, res *core.SlotResponse) {
@@ -278,17 +396,13 @@ func (this *Service) ChangePwd(params htypes.Map, res *core.SlotResponse) {
 		this.Response(res, nil, herrors.ErrUserInvalidAct.New(strInvalidUserOrPassword))
 		return
 	}
-	if u.Locked {
+	if this.isLocked(&u) {
 		this.Response(res, nil, herrors.ErrUserUnauthorizedAct.New(strUserLocked))
 		return
 	}
 
-	if u.Password != this.pwdEncodingFunc(pwdOld) {
-		u.Fails++
-		if u.Fails >= this.conf.LockAfterFails {
-			u.Locked = true
-		}
-		this.saveUser(&u)
+	if ok, _ := this.verifyPwd(u.Password, pwdOld); !ok {
+		this.loginFailed(&u, params)
 		this.Response(res, nil, herrors.ErrUserInvalidAct.New(strInvalidUserOrPassword).D(strInvalidUserOrPassword))
 		return
 	}
@@ -297,8 +411,19 @@ func (this *Service) ChangePwd(params htypes.Map, res *core.SlotResponse) {
 		this.Response(res, nil, err.D(strTooWeakPassword))
 		return
 	}
-	u.Password = this.pwdEncodingFunc(pwdNew)
+	if err := this.checkPwdHistory(&u, pwdNew); err != nil {
+		this.Response(res, nil, err)
+		return
+	}
+	hash, herr := this.hashPwd(pwdNew)
+	if herr != nil {
+		this.Response(res, nil, herr)
+		return
+	}
+	this.addPwdHistory(&u)
+	u.Password = hash
 	this.saveUser(&u)
+	this.audit(params, u.User, AuditPwdChanged, "")
 }
 
 func (this *Service) LockUser(params htypes.Map, res *core.SlotResponse) {
@@ -311,7 +436,9 @@ func (this *Service) LockUser(params htypes.Map, res *core.SlotResponse) {
 		return
 	}
 	u.Locked = true
+	u.LockedUntil = ""
 	this.saveUser(&u)
+	this.audit(params, u.User, AuditUserLocked, "locked by admin")
 	this.Response(res, nil, nil)
 	return
 
@@ -327,8 +454,10 @@ func (this *Service) UnLockUser(params htypes.Map, res *core.SlotResponse) {
 		return
 	}
 	u.Locked = false
+	u.LockedUntil = ""
 	u.Fails = 0
 	this.saveUser(&u)
+	this.audit(params, u.User, AuditUserUnlocked, "unlocked by admin")
 	this.Response(res, nil, nil)
 	return
 
@@ -339,7 +468,7 @@ func (this *Service) callLoginHook(params htypes.Map, extra *core.CallerResponse
 		defer func() {
 			e := recover()
 			if e != nil {
-				extra.Error = herrors.ErrSysInternal.New(e.(error).Error()).D(strLoginHookPanic)
+				extra.Error = herrors.ErrSysInternal.New(fmt.Sprint(e)).D(strLoginHookPanic)
 			}
 		}()
 	}
@@ -365,8 +494,14 @@ func (this *Service) AddUser(params htypes.Map, res *core.SlotResponse) {
 		this.Response(res, nil, err.D(strTooWeakPassword))
 		return
 	}
-	u.Password = this.pwdEncodingFunc(pwd)
+	hash, herr := this.hashPwd(pwd)
+	if herr != nil {
+		this.Response(res, nil, herr)
+		return
+	}
+	u.Password = hash
 	u.User = user
+	u.Email, _ = params["email"].(string)
 
 	if err := this.db.Save(&u).Error; err != nil {
 		this.Response(res, nil, herrors.ErrSysInternal.New(err.Error()))
@@ -386,18 +521,41 @@ func (this *Service) DelUser(params htypes.Map, res *core.SlotResponse) {
 
 func (this *Service) UpdateUser(params htypes.Map, res *core.SlotResponse) {
 	user := params["user"].(string)
-	pwd, herr := this.decodePwd(params["password"].(string))
-	if herr != nil {
-		this.Response(res, nil, herr)
-		return
-	}
 
 	vals := make(map[string]interface{})
 	if params["password"] != nil {
-		vals["password"] = this.pwdEncodingFunc(pwd)
+		pwd, herr := this.decodePwd(params["password"].(string))
+		if herr != nil {
+			this.Response(res, nil, herr)
+			return
+		}
+		var u SvsApAuthUser
+		if err := this.db.Where("user = ?", user).First(&u).Error; err != nil {
+			this.Response(res, nil, herrors.ErrCallerInvalidRequest.New(strUserNotExits))
+			return
+		}
+		if err := this.checkPwdStrength(pwd); err != nil {
+			this.Response(res, nil, err.D(strTooWeakPassword))
+			return
+		}
+		if err := this.checkPwdHistory(&u, pwd); err != nil {
+			this.Response(res, nil, err)
+			return
+		}
+		hash, herr := this.hashPwd(pwd)
+		if herr != nil {
+			this.Response(res, nil, herr)
+			return
+		}
+		this.addPwdHistory(&u)
+		vals["password"] = hash
+	}
+	if params["email"] != nil {
+		vals["email"] = params["email"]
 	}
 	if params["locked"] != nil {
 		vals["locked"] = params["locked"]
+		vals["locked_until"] = ""
 	}
 
 	if len(vals) == 0 {
@@ -408,17 +566,37 @@ func (this *Service) UpdateUser(params htypes.Map, res *core.SlotResponse) {
 	if err := this.db.Model(&SvsApAuthUser{}).Where("user = ?", user).Updates(vals).Error; err != nil {
 		this.Response(res, nil, herrors.ErrSysInternal.New(err.Error()))
 	} else {
+		if vals["password"] != nil {
+			this.audit(params, user, AuditPwdReset, "updated by admin")
+		}
 		this.Response(res, nil, nil)
 	}
 }
 
 func (this *Service) GetUsers(params htypes.Map, res *core.SlotResponse) {
-	page, count, _ := hconverter.String2NumberRange(params["paging"].(string))
+	//兼容旧的 paging=[page,count] 参数
+	if s, ok := params["paging"].(string); ok {
+		page, count, _ := hconverter.String2NumberRange(s)
+		params["page"], params["pageSize"] = page, count
+	}
+
+	paging, err := hpaging.Parse(params, this.conf.MaxPageSize)
+	if err != nil {
+		this.Response(res, nil, err)
+		return
+	}
+
+	var total int64
+	if err := this.db.Model(&SvsApAuthUser{}).Count(&total).Error; err != nil {
+		this.Response(res, nil, herrors.ErrSysInternal.New(err.Error()))
+		return
+	}
+
 	var users []SvsApAuthUser
-	if err := this.db.Limit(count).Offset((page - 1) * count).Find(&users).Error; err != nil {
+	if err := this.db.Limit(paging.Limit).Offset(paging.Offset).Find(&users).Error; err != nil {
 		this.Response(res, nil, herrors.ErrSysInternal.New(err.Error()))
 	} else {
-		this.Response(res, users, nil)
+		this.Response(res, hpaging.NewList(users, total, paging), nil)
 	}
 }
 
@@ -440,14 +618,33 @@ func (this *Service) ResetPwd(params htypes.Map, res *core.SlotResponse) {
 		this.Response(res, nil, herr)
 		return
 	}
-	u.Password = this.pwdEncodingFunc(p)
+	//指定了新密码时检查强度和历史密码，缺省密码不检查
+	if ok {
+		if err := this.checkPwdStrength(p); err != nil {
+			this.Response(res, nil, err.D(strTooWeakPassword))
+			return
+		}
+		if err := this.checkPwdHistory(&u, p); err != nil {
+			this.Response(res, nil, err)
+			return
+		}
+	}
+	hash, herr := this.hashPwd(p)
+	if herr != nil {
+		this.Response(res, nil, herr)
+		return
+	}
+	this.addPwdHistory(&u)
+	u.Password = hash
 
 	u.Locked = false
+	u.LockedUntil = ""
 	u.Fails = 0
 	err := this.db.Save(&u).Error
 	if err != nil {
 		this.Response(res, nil, herrors.ErrSysInternal.New(err.Error()))
 	} else {
+		this.audit(params, u.User, AuditPwdReset, "")
 		this.Response(res, nil, nil)
 	}
 }
@@ -455,6 +652,9 @@ func (this *Service) ResetPwd(params htypes.Map, res *core.SlotResponse) {
 func (this *Service) Objects() []interface{} {
 	return []interface{}{
 		SvsApAuthUser{},
+		SvsApAuthPwdHistory{},
+		SvsApAuthAudit{},
+		SvsApAuthResetToken{},
 	}
 }
 
@@ -499,10 +699,10 @@ func (this *Service) mountLoginHook(anchor interface{}) {
 
 func (this *Service) checkPwdStrength(pwd string) *herrors.Error {
 	if this.conf.PwdMinLen > 0 && len(pwd) < this.conf.PwdMinLen {
-		return herrors.ErrUserInvalidAct.New(strInvalidMinPwdLen, this.conf.PwdMinLen)
+		return herrors.ErrCallerInvalidRequest.New(strInvalidMinPwdLen, this.conf.PwdMinLen)
 	}
 	if this.conf.PwdMaxLen > 0 && len(pwd) > this.conf.PwdMaxLen {
-		return herrors.ErrUserInvalidAct.New(strInvalidMaxPwdLen, this.conf.PwdMaxLen)
+		return herrors.ErrCallerInvalidRequest.New(strInvalidMaxPwdLen, this.conf.PwdMaxLen)
 	}
 
 	if this.conf.PwdSymbol {
@@ -511,7 +711,7 @@ func (this *Service) checkPwdStrength(pwd string) *herrors.Error {
 			return herrors.ErrSysInternal.New(err.Error())
 		}
 		if !reg.MatchString(pwd) {
-			return herrors.ErrUserInvalidAct.New(strInvalidPwdSymbol)
+			return herrors.ErrCallerInvalidRequest.New(strInvalidPwdSymbol)
 		}
 	}
 
@@ -519,12 +719,12 @@ func (this *Service) checkPwdStrength(pwd string) *herrors.Error {
 		reg, _ := regexp.Compile("[0-9]")
 
 		if !reg.MatchString(pwd) {
-			return herrors.ErrUserInvalidAct.New(strPwdNumberRequired)
+			return herrors.ErrCallerInvalidRequest.New(strPwdNumberRequired)
 		}
 
 		reg, _ = regexp.Compile("[a-zA-Z]")
 		if !reg.MatchString(pwd) {
-			return herrors.ErrUserInvalidAct.New(strPwdLetterRequired)
+			return herrors.ErrCallerInvalidRequest.New(strPwdLetterRequired)
 		}
 	}
 
@@ -532,12 +732,12 @@ func (this *Service) checkPwdStrength(pwd string) *herrors.Error {
 		reg, _ := regexp.Compile("[a-z]")
 
 		if !reg.MatchString(pwd) {
-			return herrors.ErrUserInvalidAct.New(strPwdUpperAndLowerLetterRequired)
+			return herrors.ErrCallerInvalidRequest.New(strPwdUpperAndLowerLetterRequired)
 		}
 
 		reg, _ = regexp.Compile("[A-Z]")
 		if !reg.MatchString(pwd) {
-			return herrors.ErrUserInvalidAct.New(strPwdUpperAndLowerLetterRequired)
+			return herrors.ErrCallerInvalidRequest.New(strPwdUpperAndLowerLetterRequired)
 		}
 	}
 
@@ -561,6 +761,39 @@ func (this *Service) DB() *gorm.DB {
 	return this.db
 }
 
+// isLocked 判断账号是否被锁定，锁定时长已过的账号自动解锁
+func (this *Service) isLocked(user *SvsApAuthUser) bool {
+	if !user.Locked {
+		return false
+	}
+	if user.LockedUntil == "" || user.LockedUntil > hdatetime.Now() {
+		return true
+	}
+
+	user.Locked = false
+	user.LockedUntil = ""
+	user.Fails = 0
+	this.saveUser(user)
+	return false
+}
+
+// superLocked 超级用户登录失败达到LockAfterFails次后锁定
+func (this *Service) superLocked() bool {
+	return this.conf.SuperFails >= this.conf.LockAfterFails
+}
+
+func (this *Service) loginFailed(user *SvsApAuthUser, params htypes.Map) {
+	user.Fails++
+	if user.Fails >= this.conf.LockAfterFails {
+		user.Locked = true
+		if this.conf.LockDuration > 0 {
+			user.LockedUntil = time.Now().Local().Add(time.Duration(this.conf.LockDuration) * time.Minute).Format("2006-01-02 15:04:05")
+		}
+		this.audit(params, user.User, AuditUserLocked, fmt.Sprintf("%d login failures", user.Fails))
+	}
+	this.saveUser(user)
+}
+
 func (this *Service) saveUser(user *SvsApAuthUser) {
 	err := this.db.Save(user).Error
 	if err != nil {
@@ -12,3 +12,76 @@ func TestPasswordEncoder(t *testing.T) {
 	fmt.Println(code)
 
 }
+
+func TestPasswordHash(t *testing.T) {
+	service := &Service{}
+	service.pwdEncodingFunc = service.defaultPwdCoder
+
+	hash, err := service.hashPwd("Qaz@2020")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, legacy := service.verifyPwd(hash, "Qaz@2020"); !ok || legacy {
+		t.Fatal("bcrypt password should be verified")
+	}
+	if ok, _ := service.verifyPwd(hash, "Qaz@2021"); ok {
+		t.Fatal("wrong password should not be verified")
+	}
+	if ok, legacy := service.verifyPwd(service.defaultPwdCoder("Qaz@2020"), "Qaz@2020"); !ok || !legacy {
+		t.Fatal("legacy password should be verified and marked as legacy")
+	}
+}
+
+func TestPrepareImport(t *testing.T) {
+	service := &Service{}
+	service.conf.PwdMinLen = 6
+	service.conf.PwdBcryptCost = 4
+	hash, _ := service.hashPwd("Qaz@2020")
+
+	users, results := service.prepareImport([]interface{}{
+		map[string]interface{}{"user": "u1", "password": "Qaz@2020"},
+		map[string]interface{}{"user": "u2", "password_hash": hash},
+		map[string]interface{}{"user": "u1", "password": "Qaz@2021"},
+		map[string]interface{}{"user": "u3", "password": "123"},
+		map[string]interface{}{"user": "u4", "password_hash": "plain"},
+		map[string]interface{}{"user": "u5"},
+		map[string]interface{}{"password": "Qaz@2020"},
+		"u6",
+	})
+	for i, ok := range []bool{true, true, false, false, false, false, false, false} {
+		if (users[i] != nil) != ok || (results[i].Error == "") != ok {
+			t.Errorf("row %d: user = %v, error = %q", i, users[i], results[i].Error)
+		}
+	}
+	if ok, _ := service.verifyPwd(users[0].Password, "Qaz@2020"); !ok {
+		t.Error("imported password should be hashed")
+	}
+	if users[1].Password != hash {
+		t.Error("password_hash should be kept")
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	for s, want := range map[string]string{
+		"u1":   "u1",
+		"%":    "!%",
+		"a_b":  "a!_b",
+		"!x%_": "!!x!%!_",
+	} {
+		if got := escapeLike(s); got != want {
+			t.Errorf("escapeLike(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestSuperLocked(t *testing.T) {
+	service := &Service{}
+	service.conf.LockAfterFails = 3
+
+	for fails, locked := range map[int]bool{0: false, 2: false, 3: true, 4: true} {
+		service.conf.SuperFails = fails
+		if got := service.superLocked(); got != locked {
+			t.Errorf("fails %d: locked = %v, want %v", fails, got, locked)
+		}
+	}
+}
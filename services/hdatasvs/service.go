@@ -1492,7 +1492,7 @@ func (this *Service) callBeforeCreateHook(name string, ps htypes.Map, reply *cor
 		defer func() {
 			e := recover()
 			if e != nil {
-				reply.Error = herrors.ErrSysInternal.New(e.(error).Error())
+				reply.Error = herrors.ErrSysInternal.New(fmt.Sprint(e))
 			}
 		}()
 	}
@@ -1511,7 +1511,7 @@ func (this *Service) callBeforeUpdateHook(name string, id int64, ps htypes.Map,
 		defer func() {
 			e := recover()
 			if e != nil {
-				reply.Error = herrors.ErrSysInternal.New(e.(error).Error())
+				reply.Error = herrors.ErrSysInternal.New(fmt.Sprint(e))
 			}
 		}()
 	}
@@ -1530,7 +1530,7 @@ func (this *Service) callBeforeQueryHook(name string, ps htypes.Map, reply *core
 		defer func() {
 			e := recover()
 			if e != nil {
-				reply.Error = herrors.ErrSysInternal.New(e.(error).Error())
+				reply.Error = herrors.ErrSysInternal.New(fmt.Sprint(e))
 			}
 		}()
 	}
@@ -1549,7 +1549,7 @@ func (this *Service) callBeforeDelHook(name string, ids []int64, reply *core.Cal
 		defer func() {
 			e := recover()
 			if e != nil {
-				reply.Error = herrors.ErrSysInternal.New(e.(error).Error())
+				reply.Error = herrors.ErrSysInternal.New(fmt.Sprint(e))
 			}
 		}()
 	}
@@ -1568,7 +1568,7 @@ func (this *Service) callAfterCreateHook(name string, ps htypes.Map, reply *core
 		defer func() {
 			e := recover()
 			if e != nil {
-				reply.Error = herrors.ErrSysInternal.New(e.(error).Error())
+				reply.Error = herrors.ErrSysInternal.New(fmt.Sprint(e))
 			}
 		}()
 	}
@@ -1587,7 +1587,7 @@ func (this *Service) callAfterUpdateHook(name string, id int64, ps htypes.Map, r
 		defer func() {
 			e := recover()
 			if e != nil {
-				reply.Error = herrors.ErrSysInternal.New(e.(error).Error())
+				reply.Error = herrors.ErrSysInternal.New(fmt.Sprint(e))
 			}
 		}()
 	}
@@ -1606,7 +1606,7 @@ func (this *Service) callAfterQueryHook(name string, ps htypes.Map, records []in
 		defer func() {
 			e := recover()
 			if e != nil {
-				reply.Error = herrors.ErrSysInternal.New(e.(error).Error())
+				reply.Error = herrors.ErrSysInternal.New(fmt.Sprint(e))
 			}
 		}()
 	}
@@ -1625,7 +1625,7 @@ func (this *Service) callAfterDelHook(name string, ids []int64, reply *core.Call
 		defer func() {
 			e := recover()
 			if e != nil {
-				reply.Error = herrors.ErrSysInternal.New(e.(error).Error())
+				reply.Error = herrors.ErrSysInternal.New(fmt.Sprint(e))
 			}
 		}()
 	}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"os"
 	"path"
 	"reflect"
@@ -176,7 +178,11 @@ func (this *Service) Upload(ps htypes.Map, res *core.SlotResponse) {
 	for _, f := range files {
 		fd := f.(htypes.Map)
 		name := fd["name"].(string)
-		data := fd["data"].([]byte)
+		data, e := this.fileData(fd)
+		if e != nil {
+			this.Response(res, nil, e)
+			return
+		}
 
 		var file SvsFile
 		var fp string
@@ -220,6 +226,31 @@ func (this *Service) Upload(ps htypes.Map, res *core.SlotResponse) {
 	res.Data = results
 }
 
+// fileData 获取上传文件内容，兼容connector以文件句柄（如*multipart.FileHeader）传入的文件
+func (this *Service) fileData(fd htypes.Map) ([]byte, *herrors.Error) {
+	if data, ok := fd["data"].([]byte); ok {
+		return data, nil
+	}
+
+	fh, ok := fd["file"].(interface {
+		Open() (multipart.File, error)
+	})
+	if !ok {
+		return nil, herrors.ErrCallerInvalidRequest.New("file data not found").D("bad parameter")
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to open file")
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, herrors.ErrCallerInvalidRequest.New(err.Error()).D("failed to read file data")
+	}
+	return data, nil
+}
+
 func (this *Service) mountHook(anchor interface{}) {
 	typ := reflect.TypeOf(anchor)
 	val := reflect.ValueOf(anchor)
@@ -267,7 +298,7 @@ func (this *Service) callHook(fd htypes.Map, res *core.CallerResponse) {
 		defer func() {
 			e := recover()
 			if e != nil {
-				res.Error = herrors.ErrSysInternal.New(e.(error).Error())
+				res.Error = herrors.ErrSysInternal.New(fmt.Sprint(e))
 			}
 		}()
 
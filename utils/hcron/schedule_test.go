@@ -0,0 +1,41 @@
+package hcron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	base := time.Date(2022, 5, 10, 8, 30, 15, 0, time.Local) //周二
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2022, 5, 10, 8, 31, 0, 0, time.Local)},
+		{"*/15 * * * *", time.Date(2022, 5, 10, 8, 45, 0, 0, time.Local)},
+		{"0 3 * * *", time.Date(2022, 5, 11, 3, 0, 0, 0, time.Local)},
+		{"0 9-17/4 * * 1-5", time.Date(2022, 5, 10, 9, 0, 0, 0, time.Local)},
+		{"0 0 * * 0", time.Date(2022, 5, 15, 0, 0, 0, 0, time.Local)},
+		{"0 0 * * 7", time.Date(2022, 5, 15, 0, 0, 0, 0, time.Local)},
+		{"0 0 1,15 * 3", time.Date(2022, 5, 11, 0, 0, 0, 0, time.Local)},
+		{"0 0 31 2 *", time.Time{}},
+		{"@monthly", time.Date(2022, 6, 1, 0, 0, 0, 0, time.Local)},
+		{"@every 90s", time.Date(2022, 5, 10, 8, 31, 45, 0, time.Local)},
+	}
+
+	for _, c := range cases {
+		s, err := Parse(c.spec)
+		if err != nil {
+			t.Fatalf("Parse(%s): %s", c.spec, err)
+		}
+		if next := s.Next(base); !next.Equal(c.next) {
+			t.Errorf("%s: Next = %s, want %s", c.spec, next, c.next)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "@every 10ms", "@every x"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) should fail", spec)
+		}
+	}
+}
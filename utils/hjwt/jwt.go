@@ -0,0 +1,87 @@
+package hjwt
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	ClaimSubject     = "sub"
+	ClaimExpire      = "exp"
+	ClaimIssuedAt    = "iat"
+	ClaimIssuer      = "iss"
+	DefaultAlgorithm = "HS256"
+)
+
+// Signer 签发和校验JWT，只支持HMAC系列算法（HS256/HS384/HS512）
+type Signer struct {
+	method jwt.SigningMethod
+	secret []byte
+	expire time.Duration
+	issuer string
+}
+
+func New(secret string, algorithm string, expire time.Duration, issuer string) (*Signer, error) {
+	if secret == "" {
+		return nil, errors.New("jwt secret not configured")
+	}
+	if algorithm == "" {
+		algorithm = DefaultAlgorithm
+	}
+
+	method, ok := jwt.GetSigningMethod(algorithm).(*jwt.SigningMethodHMAC)
+	if !ok {
+		return nil, fmt.Errorf("jwt algorithm %s not supported", algorithm)
+	}
+
+	return &Signer{
+		method: method,
+		secret: []byte(secret),
+		expire: expire,
+		issuer: issuer,
+	}, nil
+}
+
+// Issue 签发token，claims中的字段会原样写入token
+func (this *Signer) Issue(subject string, claims map[string]interface{}) (string, error) {
+	mc := jwt.MapClaims{}
+	for k, v := range claims {
+		mc[k] = v
+	}
+
+	now := time.Now()
+	mc[ClaimSubject] = subject
+	mc[ClaimIssuedAt] = now.Unix()
+	if this.expire > 0 {
+		mc[ClaimExpire] = now.Add(this.expire).Unix()
+	}
+	if this.issuer != "" {
+		mc[ClaimIssuer] = this.issuer
+	}
+
+	return jwt.NewWithClaims(this.method, mc).SignedString(this.secret)
+}
+
+// Verify 校验token的签名和有效期，返回token中的claims
+func (this *Signer) Verify(token string) (map[string]interface{}, error) {
+	mc := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, mc, func(t *jwt.Token) (interface{}, error) {
+		//必须是配置的算法，防止算法替换攻击
+		if t.Method.Alg() != this.method.Alg() {
+			return nil, fmt.Errorf("unexpected jwt algorithm %s", t.Method.Alg())
+		}
+		return this.secret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if this.issuer != "" && !mc.VerifyIssuer(this.issuer, true) {
+		return nil, errors.New("invalid jwt issuer")
+	}
+
+	return mc, nil
+}
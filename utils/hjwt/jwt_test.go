@@ -0,0 +1,37 @@
+package hjwt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	s, err := New("secret", "HS256", time.Minute, "has")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := s.Issue("root", map[string]interface{}{"role": "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := s.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims[ClaimSubject] != "root" || claims["role"] != "admin" {
+		t.Fatalf("unexpected claims %v", claims)
+	}
+
+	other, _ := New("other", "HS256", time.Minute, "has")
+	if _, err = other.Verify(token); err == nil {
+		t.Fatal("token signed by other secret should be invalid")
+	}
+
+	noExpire, _ := New("secret", "HS256", 0, "has")
+	token, _ = noExpire.Issue("root", map[string]interface{}{ClaimExpire: time.Now().Add(-time.Minute).Unix()})
+	if _, err = s.Verify(token); err == nil {
+		t.Fatal("expired token should be invalid")
+	}
+}
@@ -0,0 +1,89 @@
+package hsignurl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	ParamExpires   = "expires"   //过期时间，unix秒
+	ParamSignature = "signature" //签名，base64url编码的HMAC-SHA256
+)
+
+// Signer 生成和校验带有效期的签名URL，签名覆盖路径、查询参数和过期时间，任何一项被修改都会校验失败
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func New(secret string, ttl time.Duration) (*Signer, error) {
+	if secret == "" {
+		return nil, errors.New("signed url secret not configured")
+	}
+	if ttl <= 0 {
+		return nil, errors.New("ttl of signed url should be positive")
+	}
+
+	return &Signer{
+		secret: []byte(secret),
+		ttl:    ttl,
+	}, nil
+}
+
+// Sign 返回带过期时间和签名参数的URL，如 /signed/v1/download?expires=...&id=1&signature=...，ttl为0时使用缺省有效期
+func (this *Signer) Sign(path string, params url.Values, ttl time.Duration) string {
+	if ttl <= 0 {
+		ttl = this.ttl
+	}
+
+	vals := make(url.Values, len(params)+2)
+	for k, v := range params {
+		vals[k] = v
+	}
+	delete(vals, ParamSignature)
+	vals.Set(ParamExpires, strconv.FormatInt(time.Now().Add(ttl).Unix(), 10))
+	vals.Set(ParamSignature, this.signature(path, vals))
+	return path + "?" + vals.Encode()
+}
+
+// Verify 校验签名和有效期，query为请求的全部查询参数
+func (this *Signer) Verify(path string, query url.Values) error {
+	sig := query.Get(ParamSignature)
+	if sig == "" {
+		return errors.New("signature not found")
+	}
+
+	expires, err := strconv.ParseInt(query.Get(ParamExpires), 10, 64)
+	if err != nil {
+		return errors.New("invalid expires")
+	}
+
+	vals := make(url.Values, len(query))
+	for k, v := range query {
+		vals[k] = v
+	}
+	delete(vals, ParamSignature)
+	if !hmac.Equal([]byte(sig), []byte(this.signature(path, vals))) {
+		return errors.New("invalid signature")
+	}
+
+	//先校验签名，避免通过修改过期时间得到不同的错误信息
+	if time.Now().Unix() > expires {
+		return errors.New("signed url expired")
+	}
+	return nil
+}
+
+// signature 对路径和按参数名排序的查询参数签名
+func (this *Signer) signature(path string, vals url.Values) string {
+	mac := hmac.New(sha256.New, this.secret)
+	mac.Write([]byte(path))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(vals.Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
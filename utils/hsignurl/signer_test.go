@@ -0,0 +1,49 @@
+package hsignurl
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	s, err := New("secret", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link := s.Sign("/signed/v1/download", url.Values{"id": {"1"}}, 0)
+	u, _ := url.Parse(link)
+	if err = s.Verify(u.Path, u.Query()); err != nil {
+		t.Fatalf("verify %s: %v", link, err)
+	}
+
+	tampered := u.Query()
+	tampered.Set("id", "2")
+	if err = s.Verify(u.Path, tampered); err == nil {
+		t.Error("url with modified params should be invalid")
+	}
+	if err = s.Verify("/signed/v1/other", u.Query()); err == nil {
+		t.Error("url with modified path should be invalid")
+	}
+
+	other, _ := New("other", time.Minute)
+	if err = other.Verify(u.Path, u.Query()); err == nil {
+		t.Error("url signed by other secret should be invalid")
+	}
+
+	expired := url.Values{ParamExpires: {"1"}}
+	expired.Set(ParamSignature, s.signature("/signed/v1/download", expired))
+	if err = s.Verify("/signed/v1/download", expired); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("expired url: err = %v", err)
+	}
+
+	link = s.Sign("/signed/v1/download", nil, time.Second)
+	u, _ = url.Parse(link)
+	q := u.Query()
+	q.Set(ParamExpires, "1")
+	if err = s.Verify(u.Path, q); err == nil {
+		t.Error("url with modified expires should be invalid")
+	}
+}